@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/projectdiscovery/goflags"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/gologger/levels"
+	"github.com/projectdiscovery/interactsh/internal/bench"
+	"github.com/projectdiscovery/interactsh/pkg/options"
+)
+
+func main() {
+	var (
+		benchOptions = &bench.Options{}
+		duration     int
+		pollInterval int
+		grace        int
+		verbose      bool
+		version      bool
+	)
+
+	flagSet := goflags.NewFlagSet()
+	flagSet.SetDescription(`Interactsh bench - simulate clients and interactions to size interactsh server instances.`)
+
+	flagSet.CreateGroup("input", "Input",
+		flagSet.StringVarP(&benchOptions.ServerURL, "server", "s", "", "interactsh server to benchmark"),
+		flagSet.StringVarP(&benchOptions.Token, "token", "t", "", "authentication token to connect protected interactsh server"),
+		flagSet.StringVarP(&benchOptions.Mode, "mode", "m", bench.ModeDNS, fmt.Sprintf("way the interactions are generated (%s)", strings.Join(bench.Modes, ","))),
+		flagSet.StringVar(&benchOptions.Target, "target", "", "host receiving the dns and http traffic (default host of the server)"),
+		flagSet.IntVar(&benchOptions.DNSPort, "dns-port", 53, "port receiving the dns queries"),
+		flagSet.IntVar(&benchOptions.HTTPPort, "http-port", 80, "port receiving the http requests"),
+	)
+
+	flagSet.CreateGroup("load", "Load",
+		flagSet.IntVarP(&benchOptions.Clients, "clients", "n", 100, "number of clients to register"),
+		flagSet.IntVarP(&benchOptions.Rate, "rate", "r", 100, "number of interactions sent per second"),
+		flagSet.IntVarP(&benchOptions.Concurrency, "concurrency", "c", 0, "maximum number of requests in flight (default rate)"),
+		flagSet.IntVarP(&duration, "duration", "d", 60, "time in seconds during which the interactions are sent"),
+		flagSet.IntVarP(&pollInterval, "poll-interval", "pi", 5, "poll interval in seconds of the clients"),
+		flagSet.IntVarP(&grace, "grace", "g", 0, "time in seconds waited for the pending interactions (default twice the poll interval)"),
+	)
+
+	flagSet.CreateGroup("debug", "Debug",
+		flagSet.BoolVar(&verbose, "v", false, "display the errors of the generated requests"),
+		flagSet.BoolVar(&version, "version", false, "show version of the project"),
+	)
+
+	if err := flagSet.Parse(); err != nil {
+		gologger.Fatal().Msgf("Could not parse options: %s\n", err)
+	}
+
+	options.ShowBanner()
+
+	if version {
+		gologger.Info().Msgf("Current Version: %s\n", options.Version)
+		os.Exit(0)
+	}
+	if verbose {
+		gologger.DefaultLogger.SetMaxLevel(levels.LevelVerbose)
+	}
+	benchOptions.Duration = time.Duration(duration) * time.Second
+	benchOptions.PollInterval = time.Duration(pollInterval) * time.Second
+	benchOptions.Grace = time.Duration(grace) * time.Second
+
+	benchmark, err := bench.New(benchOptions)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not start benchmark: %s\n", err)
+	}
+	defer benchmark.Close()
+	gologger.Info().Msgf("Sending %d %s interactions/s for %d clients during %s\n", benchOptions.Rate, benchOptions.Mode, benchOptions.Clients, benchOptions.Duration)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+	go func() {
+		<-c
+		gologger.Info().Msgf("Stopping, waiting for the pending interactions\n")
+		cancel()
+	}()
+
+	report := benchmark.Run(ctx)
+	fmt.Print(report.String())
+}
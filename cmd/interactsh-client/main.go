@@ -2,12 +2,15 @@ package main
 
 import (
 	"bytes"
+	"crypto/ed25519"
 	jsonpkg "encoding/json"
 	"fmt"
 	"os"
 	"os/signal"
 	"path/filepath"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/projectdiscovery/fileutil"
@@ -15,7 +18,9 @@ import (
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/levels"
+	"github.com/projectdiscovery/interactsh/internal/keychain"
 	"github.com/projectdiscovery/interactsh/internal/runner"
+	"github.com/projectdiscovery/interactsh/internal/tui"
 	"github.com/projectdiscovery/interactsh/pkg/client"
 	"github.com/projectdiscovery/interactsh/pkg/options"
 	"github.com/projectdiscovery/interactsh/pkg/server"
@@ -25,6 +30,7 @@ import (
 var (
 	healthcheck           bool
 	defaultConfigLocation = filepath.Join(folderutil.HomeDirOrDefault("."), ".config/interactsh-client/config.yaml")
+	defaultSigningKey     = filepath.Join(folderutil.HomeDirOrDefault("."), ".config/interactsh-client/signing-key.pem")
 )
 
 func main() {
@@ -48,7 +54,13 @@ func main() {
 		flagSet.BoolVarP(&cliOptions.DisableHTTPFallback, "no-http-fallback", "nf", false, "disable http fallback registration"),
 		flagSet.IntVarP(&cliOptions.CorrelationIdLength, "correlation-id-length", "cidl", settings.CorrelationIdLengthDefault, "length of the correlation id preamble"),
 		flagSet.IntVarP(&cliOptions.CorrelationIdNonceLength, "correlation-id-nonce-length", "cidn", settings.CorrelationIdNonceLengthDefault, "length of the correlation id nonce"),
+		flagSet.StringVarP(&cliOptions.NonceEncoding, "nonce-encoding", "ne", settings.NonceEncodingDefault, "encoding of the correlation id nonce (zbase32,hex,base36,custom:<alphabet>)"),
 		flagSet.StringVarP(&cliOptions.SessionFile, "session-file", "sf", "", "store/read from session file"),
+		flagSet.BoolVarP(&cliOptions.SessionKeychain, "session-keychain", "skc", false, "store the secrets of the session file in the os keychain"),
+		flagSet.BoolVarP(&cliOptions.IPv6Only, "ipv6-only", "v6", false, "generate payloads resolved only to the ipv6 address of the server"),
+		flagSet.StringSliceVarP(&cliOptions.Headers, "header", "H", nil, "custom header sent to the server in header:value format (e.g. for a reverse proxy)", goflags.StringSliceOptions),
+		flagSet.StringVarP(&cliOptions.UserAgent, "user-agent", "ua", "", "custom user agent of the requests to the server"),
+		flagSet.StringVar(&cliOptions.Seed, "seed", "", "secret seed to derive the same payloads on distributed workers"),
 	)
 
 	flagSet.CreateGroup("filter", "Filter",
@@ -57,12 +69,23 @@ func main() {
 		flagSet.BoolVar(&cliOptions.DNSOnly, "dns-only", false, "display only dns interaction in CLI output"),
 		flagSet.BoolVar(&cliOptions.HTTPOnly, "http-only", false, "display only http interaction in CLI output"),
 		flagSet.BoolVar(&cliOptions.SmtpOnly, "smtp-only", false, "display only smtp interactions in CLI output"),
+		flagSet.IntVarP(&cliOptions.DedupWindow, "dedup-window", "dw", 0, "collapse identical interactions received within the window in seconds"),
+		flagSet.StringVarP(&cliOptions.ExfilEncoding, "exfil-encoding", "ee", "", "reassemble data exfiltrated through dns queries (hex, base32, base64)"),
 	)
 
 	flagSet.CreateGroup("output", "Output",
 		flagSet.StringVar(&cliOptions.Output, "o", "", "output file to write interaction data"),
 		flagSet.BoolVar(&cliOptions.JSON, "json", false, "write output in JSONL(ines) format"),
 		flagSet.BoolVar(&cliOptions.Verbose, "v", false, "display verbose interaction"),
+		flagSet.BoolVar(&cliOptions.TUI, "tui", false, "display interactions in an interactive terminal ui"),
+	)
+
+	flagSet.CreateGroup("archive", "Archive",
+		flagSet.StringVar(&cliOptions.Export, "export", "", "export the received interactions to an encrypted archive on exit"),
+		flagSet.StringVar(&cliOptions.Import, "import", "", "verify and decrypt an archive, writing its interactions in JSONL format"),
+		flagSet.StringVarP(&cliOptions.ArchivePassword, "archive-password", "ap", "", "password of the archive (default $INTERACTSH_ARCHIVE_PASSWORD)"),
+		flagSet.StringVarP(&cliOptions.SigningKey, "signing-key", "sk", defaultSigningKey, "ed25519 key signing the exported archives, generated if missing"),
+		flagSet.StringVarP(&cliOptions.ArchiveSigner, "archive-signer", "as", "", "signing key of the imported archive, displayed by the exporting client (required)"),
 	)
 
 	flagSet.CreateGroup("debug", "Debug",
@@ -101,12 +124,45 @@ func main() {
 		defer outputFile.Close()
 	}
 
+	if cliOptions.ArchivePassword == "" {
+		cliOptions.ArchivePassword = os.Getenv("INTERACTSH_ARCHIVE_PASSWORD")
+	}
+	if cliOptions.Import != "" {
+		importArchive(cliOptions, outputFile)
+		return
+	}
+	var signingKey ed25519.PrivateKey
+	if cliOptions.Export != "" {
+		if cliOptions.ArchivePassword == "" {
+			gologger.Fatal().Msgf("An archive password is required to export the interactions\n")
+		}
+		if signingKey, err = client.LoadSigningKey(cliOptions.SigningKey); err != nil {
+			gologger.Fatal().Msgf("Could not load signing key: %s\n", err)
+		}
+		publicKey := signingKey.Public().(ed25519.PublicKey)
+		gologger.Info().Msgf("Archive signing key: %s (fingerprint %s)\n", client.EncodeSignerKey(publicKey), client.KeyFingerprint(publicKey))
+	}
+
 	var sessionInfo *options.SessionInfo
 	if fileutil.FileExists(cliOptions.SessionFile) {
 		// attempt to load session info - silently ignore on failure
 		_ = fileutil.Unmarshal(fileutil.YAML, []byte(cliOptions.SessionFile), &sessionInfo)
 	}
+	// keychainID is the correlation id of the secrets stored in the keychain by the loaded session
+	var keychainID string
+	if sessionInfo != nil && sessionInfo.Keychain {
+		if err := keychain.LoadSession(sessionInfo); err != nil {
+			gologger.Warning().Msgf("Could not load session secrets from keychain, creating a new session: %s\n", err)
+			sessionInfo = nil
+		} else {
+			keychainID = sessionInfo.CorrelationID
+		}
+	}
 
+	headers, err := client.ParseHeaders(cliOptions.Headers)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not parse headers: %s\n", err)
+	}
 	client, err := client.New(&client.Options{
 		ServerURL:                cliOptions.ServerURL,
 		Token:                    cliOptions.Token,
@@ -114,14 +170,27 @@ func main() {
 		CorrelationIdLength:      cliOptions.CorrelationIdLength,
 		CorrelationIdNonceLength: cliOptions.CorrelationIdNonceLength,
 		SessionInfo:              sessionInfo,
+		DedupWindow:              time.Duration(cliOptions.DedupWindow) * time.Second,
+		ExfilEncoding:            cliOptions.ExfilEncoding,
+		IPv6Only:                 cliOptions.IPv6Only,
+		Headers:                  headers,
+		UserAgent:                cliOptions.UserAgent,
+		Seed:                     cliOptions.Seed,
+		NonceEncoding:            cliOptions.NonceEncoding,
 	})
 	if err != nil {
 		gologger.Fatal().Msgf("Could not create client: %s\n", err)
 	}
 
 	gologger.Info().Msgf("Listing %d payload for OOB Testing\n", cliOptions.NumberOfPayloads)
+	var payloads []string
 	for i := 0; i < cliOptions.NumberOfPayloads; i++ {
-		gologger.Info().Msgf("%s\n", client.URL())
+		payload := client.URL()
+		if cliOptions.Seed != "" {
+			payload = client.URLFromSeed(uint64(i))
+		}
+		payloads = append(payloads, payload)
+		gologger.Info().Msgf("%s\n", payload)
 	}
 
 	// show all interactions
@@ -140,13 +209,44 @@ func main() {
 		}
 	}
 
+	c := make(chan os.Signal, 1)
+	signal.Notify(c, os.Interrupt)
+
+	var viewer *tui.Viewer
+	if cliOptions.TUI {
+		viewer = tui.New(os.Stdout, os.Stdin, payloads)
+		go func() {
+			viewer.Run()
+			c <- os.Interrupt
+		}()
+	}
+
+	// exported are the interactions received, kept for the archive
+	var exported []*server.Interaction
+	var exportedMutex sync.Mutex
+
 	client.StartPolling(time.Duration(cliOptions.PollInterval)*time.Second, func(interaction *server.Interaction) {
+		if cliOptions.Export != "" {
+			exportedMutex.Lock()
+			exported = append(exported, interaction)
+			exportedMutex.Unlock()
+		}
 		if matcher != nil && !matcher.match(interaction.FullId) {
 			return
 		}
 		if filter != nil && filter.match(interaction.FullId) {
 			return
 		}
+		if viewer != nil {
+			viewer.Add(interaction)
+			if outputFile != nil {
+				if b, err := jsonpkg.Marshal(interaction); err == nil {
+					_, _ = outputFile.Write(b)
+					_, _ = outputFile.Write([]byte("\n"))
+				}
+			}
+			return
+		}
 		if !cliOptions.JSON {
 			builder := &bytes.Buffer{}
 
@@ -154,14 +254,22 @@ func main() {
 			case "dns":
 				if noFilter || cliOptions.DNSOnly {
 					builder.WriteString(fmt.Sprintf("[%s] Received DNS interaction (%s) from %s at %s", interaction.FullId, interaction.QType, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(occurrences(interaction))
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n-----------\nDNS Request\n-----------\n\n%s\n\n------------\nDNS Response\n------------\n\n%s\n\n", interaction.RawRequest, interaction.RawResponse))
 					}
 					writeOutput(outputFile, builder)
 				}
+			case "dns-exfil":
+				if noFilter || cliOptions.DNSOnly {
+					builder.WriteString(fmt.Sprintf("[%s] Received DNS exfiltrated data (%d bytes) from %s at %s", interaction.FullId, len(interaction.ExfilData), interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(fmt.Sprintf("\n-----------\nExfiltrated Data\n-----------\n\n%q\n\n", interaction.ExfilData))
+					writeOutput(outputFile, builder)
+				}
 			case "http":
 				if noFilter || cliOptions.HTTPOnly {
 					builder.WriteString(fmt.Sprintf("[%s] Received HTTP interaction from %s at %s", interaction.FullId, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(occurrences(interaction))
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nHTTP Request\n------------\n\n%s\n\n-------------\nHTTP Response\n-------------\n\n%s\n\n", interaction.RawRequest, interaction.RawResponse))
 					}
@@ -170,14 +278,25 @@ func main() {
 			case "smtp":
 				if noFilter || cliOptions.SmtpOnly {
 					builder.WriteString(fmt.Sprintf("[%s] Received SMTP interaction from %s at %s", interaction.FullId, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(occurrences(interaction))
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nSMTP Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
 					}
 					writeOutput(outputFile, builder)
 				}
+			case "tls":
+				if interaction.TLS != nil && (noFilter || cliOptions.HTTPOnly || cliOptions.SmtpOnly) {
+					builder.WriteString(fmt.Sprintf("[%s] Received TLS handshake without request (SNI %s) from %s at %s", interaction.FullId, interaction.TLS.SNI, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(occurrences(interaction))
+					if cliOptions.Verbose {
+						builder.WriteString(fmt.Sprintf("\n------------\nTLS Handshake\n------------\n\nJA3: %s\nJA3 Hash: %s\nALPN: %s\n\n", interaction.TLS.JA3, interaction.TLS.JA3Hash, strings.Join(interaction.TLS.ALPN, ", ")))
+					}
+					writeOutput(outputFile, builder)
+				}
 			case "ftp":
 				if noFilter {
 					builder.WriteString(fmt.Sprintf("Received FTP interaction from %s at %s", interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(occurrences(interaction))
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nFTP Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
 					}
@@ -186,14 +305,19 @@ func main() {
 			case "responder", "smb":
 				if noFilter {
 					builder.WriteString(fmt.Sprintf("Received Responder/Smb interaction at %s", interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(occurrences(interaction))
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nResponder/SMB Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
 					}
 					writeOutput(outputFile, builder)
 				}
+			case "overflow":
+				builder.WriteString(fmt.Sprintf("[%s] %d interactions dropped by the server quota or memory budget at %s", interaction.FullId, interaction.Dropped, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+				writeOutput(outputFile, builder)
 			case "ldap":
 				if noFilter {
 					builder.WriteString(fmt.Sprintf("[%s] Received LDAP interaction from %s at %s", interaction.FullId, interaction.RemoteAddress, interaction.Timestamp.Format("2006-01-02 15:04:05")))
+					builder.WriteString(occurrences(interaction))
 					if cliOptions.Verbose {
 						builder.WriteString(fmt.Sprintf("\n------------\nLDAP Interaction\n------------\n\n%s\n\n", interaction.RawRequest))
 					}
@@ -215,13 +339,25 @@ func main() {
 		}
 	})
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
 	for range c {
 		if cliOptions.SessionFile != "" {
-			_ = client.SaveSessionTo(cliOptions.SessionFile)
+			if err := saveSession(client, cliOptions, keychainID); err != nil {
+				gologger.Error().Msgf("Could not save session: %s\n", err)
+			}
 		}
 		client.StopPolling()
+		if stats := client.Stats(); stats.Interacted > 0 {
+			gologger.Info().Msgf("Time to first interaction of %d/%d payloads: min %s, p50 %s, p90 %s, p99 %s, max %s\n", stats.Interacted, stats.Payloads, stats.Min, stats.P50, stats.P90, stats.P99, stats.Max)
+		}
+		if cliOptions.Export != "" {
+			exportedMutex.Lock()
+			if err := exportArchive(client, cliOptions, exported, signingKey); err != nil {
+				gologger.Error().Msgf("Could not export interactions: %s\n", err)
+			} else {
+				gologger.Info().Msgf("Exported %d interactions to %s\n", len(exported), cliOptions.Export)
+			}
+			exportedMutex.Unlock()
+		}
 		// whether the session is saved/loaded it shouldn't be destroyed {
 		if cliOptions.SessionFile == "" {
 			client.Close()
@@ -230,6 +366,78 @@ func main() {
 	}
 }
 
+// saveSession saves the session file, with its secrets stored in the keychain if requested.
+// The secrets previously stored in the keychain for keychainID are removed once obsolete.
+func saveSession(c *client.Client, cliOptions *options.CLIClientOptions, keychainID string) error {
+	sessionInfo := c.SessionInfo()
+	if cliOptions.SessionKeychain {
+		if err := keychain.StoreSession(sessionInfo); err != nil {
+			return err
+		}
+	}
+	if err := client.WriteSessionInfo(cliOptions.SessionFile, sessionInfo); err != nil {
+		return err
+	}
+	if keychainID != "" && (!sessionInfo.Keychain || keychainID != sessionInfo.CorrelationID) {
+		_ = keychain.DeleteSession(keychainID)
+	}
+	return nil
+}
+
+// exportArchive writes the interactions to the encrypted archive file
+func exportArchive(c *client.Client, cliOptions *options.CLIClientOptions, interactions []*server.Interaction, signingKey ed25519.PrivateKey) error {
+	file, err := os.OpenFile(cliOptions.Export, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return c.ExportArchive(file, interactions, cliOptions.ArchivePassword, signingKey)
+}
+
+// importArchive verifies and decrypts an archive, writing its interactions in JSONL format
+func importArchive(cliOptions *options.CLIClientOptions, outputFile *os.File) {
+	if cliOptions.ArchiveSigner == "" {
+		gologger.Fatal().Msgf("The signing key of the archive is required to import it\n")
+	}
+	signer, err := client.ParseSignerKey(cliOptions.ArchiveSigner)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not parse archive signer: %s\n", err)
+	}
+	file, err := os.Open(cliOptions.Import)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not open archive: %s\n", err)
+	}
+	defer file.Close()
+
+	archive, err := client.ReadArchive(file, cliOptions.ArchivePassword, signer)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not read archive: %s\n", err)
+	}
+	gologger.Info().Msgf("Archive of %s created at %s, signed by %s\n", archive.CorrelationID, archive.Created.Format("2006-01-02 15:04:05"), client.KeyFingerprint(signer))
+
+	for _, interaction := range archive.Interactions {
+		b, err := jsonpkg.Marshal(interaction)
+		if err != nil {
+			gologger.Error().Msgf("Could not marshal json output: %s\n", err)
+			continue
+		}
+		os.Stdout.Write(b)
+		os.Stdout.Write([]byte("\n"))
+		if outputFile != nil {
+			_, _ = outputFile.Write(b)
+			_, _ = outputFile.Write([]byte("\n"))
+		}
+	}
+}
+
+// occurrences returns the number of collapsed duplicates of the interaction, if any
+func occurrences(interaction *server.Interaction) string {
+	if interaction.Count > 1 {
+		return fmt.Sprintf(" (%d times)", interaction.Count)
+	}
+	return ""
+}
+
 func writeOutput(outputFile *os.File, builder *bytes.Buffer) {
 	if outputFile != nil {
 		_, _ = outputFile.Write(builder.Bytes())
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/tls"
 	"encoding/hex"
@@ -11,16 +12,22 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	_ "net/http/pprof"
 
+	units "github.com/docker/go-units"
 	"github.com/projectdiscovery/folderutil"
 	"github.com/projectdiscovery/goflags"
 	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/gologger/levels"
 	"github.com/projectdiscovery/interactsh/internal/runner"
+	"github.com/projectdiscovery/interactsh/internal/service"
 	"github.com/projectdiscovery/interactsh/pkg/options"
 	"github.com/projectdiscovery/interactsh/pkg/server"
 	"github.com/projectdiscovery/interactsh/pkg/server/acme"
@@ -28,6 +35,7 @@ import (
 	"github.com/projectdiscovery/interactsh/pkg/storage"
 	"github.com/projectdiscovery/iputil"
 	"github.com/projectdiscovery/stringsutil"
+	"go.uber.org/multierr"
 )
 
 var (
@@ -44,15 +52,18 @@ func main() {
 	flagSet.CreateGroup("input", "Input",
 		flagSet.StringSliceVarP(&cliOptions.Domains, "domain", "d", []string{}, "single/multiple configured domain to use for server", goflags.CommaSeparatedStringSliceOptions),
 		flagSet.StringVar(&cliOptions.IPAddress, "ip", "", "public ip address to use for interactsh server"),
+		flagSet.StringVar(&cliOptions.IPv6Address, "ipv6", "", "public ipv6 address to use for interactsh server"),
 		flagSet.StringVarP(&cliOptions.ListenIP, "listen-ip", "lip", "0.0.0.0", "public ip address to listen on"),
 		flagSet.IntVarP(&cliOptions.Eviction, "eviction", "e", 30, "number of days to persist interaction data in memory"),
 		flagSet.BoolVarP(&cliOptions.Auth, "auth", "a", false, "enable authentication to server using random generated token"),
 		flagSet.StringVarP(&cliOptions.Token, "token", "t", "", "enable authentication to server using given token"),
 		flagSet.StringVar(&cliOptions.OriginURL, "acao-url", "*", "origin url to send in acao header to use web-client)"), // cli flag set to deprecate
 		flagSet.BoolVarP(&cliOptions.SkipAcme, "skip-acme", "sa", false, "skip acme registration (certificate checks/handshake + TLS protocols will be disabled)"),
+		flagSet.StringVarP(&cliOptions.AcmeChallenge, "acme-challenge", "ach", acme.ChallengeDNS01, "acme challenge for the certificates (dns-01, tls-alpn-01)"),
 		flagSet.BoolVarP(&cliOptions.ScanEverywhere, "scan-everywhere", "se", false, "scan canary token everywhere"),
 		flagSet.IntVarP(&cliOptions.CorrelationIdLength, "correlation-id-length", "cidl", settings.CorrelationIdLengthDefault, "length of the correlation id preamble"),
 		flagSet.IntVarP(&cliOptions.CorrelationIdNonceLength, "correlation-id-nonce-length", "cidn", settings.CorrelationIdNonceLengthDefault, "length of the correlation id nonce"),
+		flagSet.StringSliceVarP(&cliOptions.NonceEncodings, "nonce-encodings", "nes", nil, "nonce encodings accepted from the clients (zbase32,hex,base36,custom), all if empty", goflags.CommaSeparatedStringSliceOptions),
 		flagSet.StringVar(&cliOptions.CertificatePath, "cert", "", "custom certificate path"),
 		flagSet.StringVar(&cliOptions.PrivateKeyPath, "privkey", "", "custom private key path"),
 		flagSet.StringVarP(&cliOptions.OriginIPHeader, "origin-ip-header", "oih", "", "HTTP header containing origin ip (interactsh behind a reverse proxy)"),
@@ -60,12 +71,30 @@ func main() {
 
 	flagSet.CreateGroup("config", "config",
 		flagSet.StringVar(&cliOptions.Config, "config", defaultConfigLocation, "flag configuration file"),
+		flagSet.StringVarP(&cliOptions.ServerConfig, "server-config", "sc", "", "structured server configuration file (yaml/toml)"),
 		flagSet.BoolVarP(&cliOptions.DynamicResp, "dynamic-resp", "dr", false, "enable setting up arbitrary response data"),
 		flagSet.StringVarP(&cliOptions.CustomRecords, "custom-records", "cr", "", "custom dns records YAML file for DNS server"),
+		flagSet.StringVarP(&cliOptions.DNSZone, "dns-zone", "dz", "", "dns zone YAML file (SOA, name servers, static records) for DNS server"),
+		flagSet.StringVarP(&cliOptions.InteractionRules, "interaction-rules", "ir", "", "interaction rules YAML file to drop, tag or mirror interactions"),
+		flagSet.StringVarP(&cliOptions.NoiseFilter, "noise-filter", "nf", "", "action on the interactions of unregistered correlation ids and known scanners (tag,drop)"),
+		flagSet.StringVarP(&cliOptions.NoiseScanners, "noise-scanners", "nsc", "", "scanners YAML file (ranges, user agents) recognized by the noise filter"),
 		flagSet.StringVarP(&cliOptions.HTTPIndex, "http-index", "hi", "", "custom index file for http server"),
 		flagSet.StringVarP(&cliOptions.HTTPDirectory, "http-directory", "hd", "", "directory with files to serve with http server"),
 		flagSet.BoolVarP(&cliOptions.DiskStorage, "disk", "ds", false, "disk based storage"),
 		flagSet.StringVarP(&cliOptions.DiskStoragePath, "disk-path", "dsp", "", "disk storage path"),
+		flagSet.IntVarP(&cliOptions.ReplayWindow, "replay-window", "rw", 0, "number of minutes to keep delivered interactions for replay"),
+		flagSet.BoolVar(&cliOptions.Search, "search", false, "keep the interactions history on disk for the search api on /admin/search"),
+		flagSet.IntVarP(&cliOptions.RetentionAge, "retention-age", "ra", 0, "number of days to keep the interactions history (0 = unlimited)"),
+		flagSet.StringVarP(&cliOptions.RetentionSize, "retention-size", "rs", "", "max size of the interactions history (e.g. 10GB)"),
+		flagSet.StringVar(&cliOptions.Archive, "archive", "", "directory or s3://bucket/prefix url to archive the purged interactions"),
+		flagSet.StringVar(&cliOptions.ArchiveEndpoint, "archive-endpoint", "", "endpoint of the S3-compatible storage for s3 archives (default AWS)"),
+		flagSet.IntVarP(&cliOptions.QuotaInteractions, "quota-interactions", "qi", 0, "max pending interactions per correlation id (0 = unlimited)"),
+		flagSet.StringVarP(&cliOptions.QuotaBytes, "quota-bytes", "qb", "", "max size of pending interactions per correlation id (e.g. 10MB)"),
+		flagSet.StringVarP(&cliOptions.QuotaOverflow, "quota-overflow", "qo", storage.OverflowDropOldest, "policy when a quota or the memory budget is exceeded (drop-oldest, drop-new)"),
+		flagSet.StringVarP(&cliOptions.MemoryBudget, "memory-budget", "mbu", "1GB", "max size of pending interactions in memory for all correlation ids (0 = unlimited)"),
+		flagSet.IntVarP(&cliOptions.ShutdownTimeout, "shutdown-timeout", "st", 10, "seconds to wait for in-flight interactions on shutdown"),
+		flagSet.StringVarP(&cliOptions.APIKeysFile, "api-keys-file", "akf", "", "file to persist the scoped admin api keys"),
+		flagSet.StringVar(&cliOptions.Tenants, "tenants", "", "tenants YAML file with the tokens and quotas of the teams sharing the server"),
 	)
 
 	flagSet.CreateGroup("services", "Services",
@@ -75,6 +104,9 @@ func main() {
 		flagSet.IntVar(&cliOptions.SmtpPort, "smtp-port", 25, "port to use for smtp service"),
 		flagSet.IntVar(&cliOptions.SmtpsPort, "smtps-port", 587, "port to use for smtps service"),
 		flagSet.IntVar(&cliOptions.SmtpAutoTLSPort, "smtp-autotls-port", 465, "port to use for smtps autotls service"),
+		flagSet.StringVar(&cliOptions.SMTPBanner, "smtp-banner", "interactsh", "text of the smtp greeting after the hostname"),
+		flagSet.StringVar(&cliOptions.SMTPRelay, "smtp-relay", server.SMTPRelayAccept, "response to smtp recipients of external domains (accept,reject)"),
+		flagSet.StringVar(&cliOptions.SMTPMaxSize, "smtp-max-size", "10MB", "max size of the smtp messages"),
 		flagSet.IntVar(&cliOptions.LdapPort, "ldap-port", 389, "port to use for ldap service"),
 		flagSet.BoolVar(&cliOptions.LdapWithFullLogger, "ldap", false, "enable ldap server with full logging (authenticated)"),
 		flagSet.BoolVarP(&cliOptions.RootTLD, "wildcard", "wc", false, "enable wildcard interaction for interactsh domain (authenticated)"),
@@ -84,6 +116,17 @@ func main() {
 		flagSet.IntVar(&cliOptions.SmbPort, "smb-port", 445, "port to use for smb service"),
 		flagSet.IntVar(&cliOptions.FtpPort, "ftp-port", 21, "port to use for ftp service"),
 		flagSet.StringVar(&cliOptions.FTPDirectory, "ftp-dir", "", "ftp directory - temporary if not specified"),
+		flagSet.StringSliceVarP(&cliOptions.Protocols, "protocol", "proto", nil, "custom protocol handlers compiled in the server to start", goflags.CommaSeparatedStringSliceOptions),
+		flagSet.StringSliceVarP(&cliOptions.DisabledServices, "disable-service", "dsv", nil, "services not to start (dns,http,https,smtp,smtps,smtp-autotls,ldap)", goflags.CommaSeparatedStringSliceOptions),
+		flagSet.BoolVarP(&cliOptions.Unprivileged, "unprivileged", "up", false, "listen on unprivileged ports (dns 5353, http 8080, https 8443, smtp 2525, ...) forwarded from the standard ones"),
+		flagSet.StringSliceVarP(&cliOptions.PublicPorts, "public-port", "pp", nil, "public port of a service behind port forwarding (service:port, e.g. http:80)", goflags.CommaSeparatedStringSliceOptions),
+	)
+	flagSet.CreateGroup("output", "Output",
+		flagSet.StringVar(&cliOptions.SyslogAddress, "syslog", "", "syslog server address to forward interactions to (host:port)"),
+		flagSet.StringVarP(&cliOptions.SyslogNetwork, "syslog-network", "sn", "udp", "syslog transport (udp,tcp,tls)"),
+		flagSet.StringVarP(&cliOptions.SyslogFormat, "syslog-format", "sfmt", "cef", "syslog message format (cef,leef)"),
+		flagSet.BoolVar(&cliOptions.Dashboard, "dashboard", false, "enable web dashboard on /dashboard (authenticated)"),
+		flagSet.IntVarP(&cliOptions.DashboardHistory, "dashboard-history", "dh", 10000, "number of interactions to keep for the web dashboard"),
 	)
 	flagSet.CreateGroup("debug", "Debug",
 		flagSet.BoolVar(&cliOptions.Version, "version", false, "show version of the project"),
@@ -91,11 +134,36 @@ func main() {
 		flagSet.BoolVarP(&cliOptions.EnablePprof, "enable-pprof", "ep", false, "enable pprof debugging server"),
 		flagSet.BoolVarP(&healthcheck, "hc", "health-check", false, "run diagnostic check up"),
 		flagSet.BoolVar(&cliOptions.EnableMetrics, "metrics", false, "enable metrics endpoint"),
+		flagSet.StringVarP(&cliOptions.LogFile, "log-file", "lf", "", "file to append the logs to, e.g. when running as a service"),
 	)
 
+	// service is a subcommand managing the server as a service of the os
+	if len(os.Args) > 1 && os.Args[1] == "service" {
+		if err := runServiceCommand(os.Args[2:]); err != nil {
+			gologger.Fatal().Msgf("%s\n", err)
+		}
+		os.Exit(0)
+	}
+
+	// api-key is a subcommand creating an admin api key in the api keys file
+	if len(os.Args) > 1 && os.Args[1] == "api-key" {
+		if err := runAPIKeyCommand(os.Args[2:]); err != nil {
+			gologger.Fatal().Msgf("%s\n", err)
+		}
+		os.Exit(0)
+	}
+
+	// validate is a subcommand checking the setup instead of starting the server
+	validate := len(os.Args) > 1 && os.Args[1] == "validate"
+	if validate {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
+	}
+
 	if err := flagSet.Parse(); err != nil {
 		gologger.Fatal().Msgf("Could not parse options: %s\n", err)
 	}
+	// the service manager expects the server to report its status quickly
+	service.Run("interactsh-server")
 	options.ShowBanner()
 
 	if healthcheck {
@@ -113,34 +181,117 @@ func main() {
 			gologger.Fatal().Msgf("Could not read config: %s\n", err)
 		}
 	}
+	if cliOptions.ServerConfig != "" {
+		serverConfig, err := options.LoadServerConfig(cliOptions.ServerConfig)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not read server config: %s\n", err)
+		}
+		// the flags given on the command line take precedence over the file
+		serverConfig.Apply(cliOptions, options.FlagsSet(os.Args[1:]))
+	}
+
+	if cliOptions.LogFile != "" {
+		if err := service.RedirectLogs(cliOptions.LogFile); err != nil {
+			gologger.Fatal().Msgf("Could not redirect logs: %s\n", err)
+		}
+	}
 
 	if len(cliOptions.Domains) == 0 {
 		gologger.Fatal().Msgf("No domains specified\n")
 	}
 
-	if cliOptions.IPAddress == "" && cliOptions.ListenIP == "0.0.0.0" {
+	// correlation ids are generated by clients from xids, which are 20 chars long
+	if cliOptions.CorrelationIdLength < 1 || cliOptions.CorrelationIdLength > settings.CorrelationIdLengthDefault || cliOptions.CorrelationIdNonceLength < 1 {
+		gologger.Fatal().Msgf("correlation id length must be between 1 and %d and nonce length must be positive\n", settings.CorrelationIdLengthDefault)
+	}
+
+	if cliOptions.AcmeChallenge != acme.ChallengeDNS01 && cliOptions.AcmeChallenge != acme.ChallengeTLSALPN01 {
+		gologger.Fatal().Msgf("acme challenge must be %s or %s\n", acme.ChallengeDNS01, acme.ChallengeTLSALPN01)
+	}
+
+	for _, encoding := range cliOptions.NonceEncodings {
+		if !stringsutil.EqualFoldAny(encoding, settings.NonceEncodingNames()...) {
+			gologger.Fatal().Msgf("Unknown nonce encoding %s (%s)\n", encoding, strings.Join(settings.NonceEncodingNames(), ","))
+		}
+	}
+
+	cliOptions.SMTPRelay = strings.ToLower(cliOptions.SMTPRelay)
+	if err := server.ValidateSMTPRelay(cliOptions.SMTPRelay); err != nil {
+		gologger.Fatal().Msgf("%s\n", err)
+	}
+	smtpMaxSize, err := units.RAMInBytes(cliOptions.SMTPMaxSize)
+	if err != nil || smtpMaxSize <= 0 {
+		gologger.Fatal().Msgf("Could not parse smtp max size: %s\n", cliOptions.SMTPMaxSize)
+	}
+
+	if err := server.ValidateServices(cliOptions.DisabledServices); err != nil {
+		gologger.Fatal().Msgf("Could not disable services: %s\n", err)
+	}
+	for i, name := range cliOptions.DisabledServices {
+		cliOptions.DisabledServices[i] = strings.ToLower(name)
+	}
+	if stringsutil.EqualFoldAny("http", cliOptions.DisabledServices...) && stringsutil.EqualFoldAny("https", cliOptions.DisabledServices...) {
+		gologger.Warning().Msgf("The client api is not served with http and https disabled\n")
+	}
+	if stringsutil.EqualFoldAny("dns", cliOptions.DisabledServices...) && !cliOptions.SkipAcme && cliOptions.CertificatePath == "" && cliOptions.AcmeChallenge == acme.ChallengeDNS01 {
+		gologger.Warning().Msgf("The acme %s challenge can't be answered with dns disabled\n", acme.ChallengeDNS01)
+	}
+
+	publicPorts, err := cliOptions.ParsePublicPorts()
+	if err != nil {
+		gologger.Fatal().Msgf("Could not parse public ports: %s\n", err)
+	}
+	if cliOptions.Unprivileged {
+		for name, port := range cliOptions.UseUnprivilegedPorts() {
+			if _, ok := publicPorts[name]; !ok {
+				publicPorts[name] = port
+			}
+		}
+	}
+
+	if validate {
+		validateSetup(cliOptions, publicPorts)
+	}
+
+	// an ipv6 address given with the ip flag is the ipv6 address of the server
+	if ip := net.ParseIP(cliOptions.IPAddress); ip != nil && ip.To4() == nil && cliOptions.IPv6Address == "" {
+		cliOptions.IPv6Address, cliOptions.IPAddress = cliOptions.IPAddress, ""
+	}
+	if cliOptions.IPv6Address != "" {
+		if ip := net.ParseIP(cliOptions.IPv6Address); ip == nil || ip.To4() != nil {
+			gologger.Fatal().Msgf("Invalid ipv6 address %s\n", cliOptions.IPv6Address)
+		}
+		// the listeners bind all the ipv4 and ipv6 addresses
+		if cliOptions.ListenIP == "0.0.0.0" {
+			cliOptions.ListenIP = "::"
+		}
+	}
+
+	if cliOptions.IPAddress == "" && (cliOptions.ListenIP == "0.0.0.0" || cliOptions.ListenIP == "::") {
 		publicIP, _ := getPublicIP()
 		gologger.Info().Msgf("Public IP: %s\n", publicIP)
-		outboundIP, _ := iputil.GetSourceIP("scanme.sh")
-		gologger.Info().Msgf("Outbound IP: %s\n", outboundIP)
-		// it's essential to be able to bind to cliOptions.DnsPort on any of the two ips
-		bindableIP, err := iputil.GetBindableAddress(cliOptions.DnsPort, publicIP, outboundIP.String())
-		if bindableIP == "" && err != nil {
-			var addressesBuilder strings.Builder
-			networkInterfaces, _ := net.Interfaces()
-			for _, networkInterface := range networkInterfaces {
-				addresses, _ := networkInterface.Addrs()
-				var addressesStr []string
-				for _, address := range addresses {
-					addressesStr = append(addressesStr, address.String())
-				}
-				if len(addressesStr) > 0 {
-					addressesBuilder.WriteString(fmt.Sprintf("%s: %s\n", networkInterface.Name, strings.Join(addressesStr, ",")))
+		if cliOptions.ListenIP == "0.0.0.0" {
+			outboundIP, _ := iputil.GetSourceIP("scanme.sh")
+			gologger.Info().Msgf("Outbound IP: %s\n", outboundIP)
+			// it's essential to be able to bind to cliOptions.DnsPort on any of the two ips
+			bindableIP, err := iputil.GetBindableAddress(cliOptions.DnsPort, publicIP, outboundIP.String())
+			if bindableIP == "" && err != nil {
+				var addressesBuilder strings.Builder
+				networkInterfaces, _ := net.Interfaces()
+				for _, networkInterface := range networkInterfaces {
+					addresses, _ := networkInterface.Addrs()
+					var addressesStr []string
+					for _, address := range addresses {
+						addressesStr = append(addressesStr, address.String())
+					}
+					if len(addressesStr) > 0 {
+						addressesBuilder.WriteString(fmt.Sprintf("%s: %s\n", networkInterface.Name, strings.Join(addressesStr, ",")))
+					}
 				}
+				gologger.Fatal().Msgf("%s\nNo bindable address could be found for port %d\nPlease ensure to have proper privileges and/or choose the correct ip:\n%s\n", err, cliOptions.DnsPort, addressesBuilder.String())
 			}
-			gologger.Fatal().Msgf("%s\nNo bindable address could be found for port %d\nPlease ensure to have proper privileges and/or choose the correct ip:\n%s\n", err, cliOptions.DnsPort, addressesBuilder.String())
+			cliOptions.ListenIP = bindableIP
 		}
-		cliOptions.ListenIP = bindableIP
 		cliOptions.IPAddress = publicIP
 	}
 
@@ -150,6 +301,18 @@ func main() {
 	}
 
 	serverOptions := cliOptions.AsServerOptions()
+	serverOptions.PublicPorts = publicPorts
+	serverOptions.DisabledServices = cliOptions.DisabledServices
+	serverOptions.NonceEncodings = cliOptions.NonceEncodings
+	serverOptions.SMTPProfile = &server.SMTPProfile{Banner: cliOptions.SMTPBanner, Relay: cliOptions.SMTPRelay, MaxSize: int(smtpMaxSize)}
+	if serverOptions.APIKeys, err = server.NewAPIKeys(cliOptions.APIKeysFile); err != nil {
+		gologger.Fatal().Msgf("Could not load api keys: %s\n", err)
+	}
+	if cliOptions.Tenants != "" {
+		if serverOptions.Tenants, err = server.LoadTenants(cliOptions.Tenants); err != nil {
+			gologger.Fatal().Msgf("Could not load tenants: %s\n", err)
+		}
+	}
 	if cliOptions.Debug {
 		gologger.DefaultLogger.SetMaxLevel(levels.LevelDebug)
 	}
@@ -160,7 +323,7 @@ func main() {
 	}
 
 	// Requires auth if token is specified or enables it automatically for responder and smb options
-	if serverOptions.Token != "" || cliOptions.Responder || cliOptions.Smb || cliOptions.Ftp || cliOptions.LdapWithFullLogger {
+	if serverOptions.Token != "" || cliOptions.Responder || cliOptions.Smb || cliOptions.Ftp || cliOptions.LdapWithFullLogger || cliOptions.Dashboard || cliOptions.Tenants != "" {
 		serverOptions.Auth = true
 	}
 
@@ -187,6 +350,26 @@ func main() {
 	var store storage.Storage
 	storeOptions := storage.DefaultOptions
 	storeOptions.EvictionTTL = evictionTTL
+	storeOptions.ReplayWindow = time.Duration(cliOptions.ReplayWindow) * time.Minute
+	if cliOptions.QuotaOverflow != storage.OverflowDropOldest && cliOptions.QuotaOverflow != storage.OverflowDropNew {
+		gologger.Fatal().Msgf("quota overflow policy must be %s or %s\n", storage.OverflowDropOldest, storage.OverflowDropNew)
+	}
+	storeOptions.MaxInteractions = cliOptions.QuotaInteractions
+	storeOptions.OverflowPolicy = cliOptions.QuotaOverflow
+	if cliOptions.QuotaBytes != "" {
+		maxBytes, err := units.RAMInBytes(cliOptions.QuotaBytes)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not parse quota bytes: %s\n", err)
+		}
+		storeOptions.MaxBytes = int(maxBytes)
+	}
+	if cliOptions.MemoryBudget != "" && cliOptions.MemoryBudget != "0" {
+		memoryBudget, err := units.RAMInBytes(cliOptions.MemoryBudget)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not parse memory budget: %s\n", err)
+		}
+		storeOptions.MaxMemory = memoryBudget
+	}
 	if cliOptions.DiskStorage {
 		if cliOptions.DiskStoragePath == "" {
 			gologger.Fatal().Msgf("disk storage path must be specified\n")
@@ -194,7 +377,6 @@ func main() {
 		storeOptions.DbPath = cliOptions.DiskStoragePath
 	}
 
-	var err error
 	store, err = storage.New(&storeOptions)
 	if err != nil {
 		gologger.Fatal().Msgf("couldn't create storage: %s\n", err)
@@ -207,6 +389,58 @@ func main() {
 	}
 
 	serverOptions.Stats = &server.Metrics{}
+	serverOptions.Health = server.NewHealth(options.Version)
+	serverOptions.Health.SetDisabled(serverOptions.DisabledServices)
+	serverOptions.Listeners = server.InheritListeners()
+	if count := serverOptions.Listeners.Len(); count > 0 {
+		gologger.Info().Msgf("Using %d sockets from socket activation\n", count)
+	}
+
+	if !cliOptions.Search && (cliOptions.RetentionAge > 0 || cliOptions.RetentionSize != "" || cliOptions.Archive != "") {
+		gologger.Warning().Msgf("Retention options are ignored without search\n")
+	}
+	if cliOptions.Search {
+		if !cliOptions.DiskStorage {
+			gologger.Fatal().Msgf("search requires disk storage\n")
+		}
+		history, err := storage.OpenHistory(filepath.Join(cliOptions.DiskStoragePath, "history"))
+		if err != nil {
+			gologger.Fatal().Msgf("Could not open interactions history: %s\n", err)
+		}
+		serverOptions.History = history
+
+		retention := &storage.RetentionOptions{MaxAge: time.Duration(cliOptions.RetentionAge) * 24 * time.Hour}
+		if cliOptions.RetentionSize != "" {
+			maxSize, err := units.RAMInBytes(cliOptions.RetentionSize)
+			if err != nil {
+				gologger.Fatal().Msgf("Could not parse retention size: %s\n", err)
+			}
+			retention.MaxSize = maxSize
+		}
+		if cliOptions.Archive != "" {
+			if retention.Archiver, err = storage.NewArchiver(cliOptions.Archive, cliOptions.ArchiveEndpoint); err != nil {
+				gologger.Fatal().Msgf("Could not create archiver: %s\n", err)
+			}
+		}
+		if retention.Enabled() {
+			go runRetention(history, retention)
+		}
+	}
+
+	if cliOptions.Dashboard {
+		if cliOptions.DashboardHistory < 0 {
+			gologger.Fatal().Msgf("dashboard history must not be negative (%d)\n", cliOptions.DashboardHistory)
+		}
+		serverOptions.Dashboard = server.NewDashboard(cliOptions.DashboardHistory, serverOptions.CorrelationIdLength)
+	}
+
+	if cliOptions.SyslogAddress != "" {
+		syslogExporter, err := server.NewSyslogExporter(cliOptions.SyslogNetwork, cliOptions.SyslogAddress, cliOptions.SyslogFormat, options.Version)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not create syslog exporter: %s\n", err)
+		}
+		serverOptions.SyslogExporter = syslogExporter
+	}
 
 	// If root-tld is enabled create a singleton unencrypted record in the store
 	if serverOptions.RootTLD {
@@ -218,25 +452,77 @@ func main() {
 	acmeStore := acme.NewProvider()
 	serverOptions.ACMEStore = acmeStore
 
-	dnsTcpServer := server.NewDNSServer("tcp", serverOptions)
-	dnsUdpServer := server.NewDNSServer("udp", serverOptions)
-	dnsTcpAlive := make(chan bool, 1)
-	dnsUdpAlive := make(chan bool, 1)
-	go dnsTcpServer.ListenAndServe(dnsTcpAlive)
-	go dnsUdpServer.ListenAndServe(dnsUdpAlive)
+	serverOptions.Middlewares = server.NewMiddlewareChain()
+	if err := serverOptions.Middlewares.ReloadRules(cliOptions.InteractionRules); err != nil {
+		gologger.Fatal().Msgf("Could not read interaction rules: %s\n", err)
+	}
+	if cliOptions.NoiseFilter != "" {
+		noiseFilter, err := server.NewNoiseFilter(serverOptions, cliOptions.NoiseFilter)
+		if err != nil {
+			gologger.Fatal().Msgf("Could not create noise filter: %s\n", err)
+		}
+		if err := noiseFilter.ReloadScanners(cliOptions.NoiseScanners); err != nil {
+			gologger.Fatal().Msgf("Could not read noise scanners: %s\n", err)
+		}
+		serverOptions.NoiseFilter = noiseFilter
+		serverOptions.Middlewares.Use(noiseFilter.Filter)
+	}
+
+	if cliOptions.DNSZone != "" {
+		if _, err := server.LoadDNSZoneConfig(cliOptions.DNSZone); err != nil {
+			gologger.Fatal().Msgf("Could not read dns zone: %s\n", err)
+		}
+	}
+	serverOptions.Protocols = append([]string{}, server.DefaultProtocols...)
+	if cliOptions.Ftp {
+		serverOptions.Protocols = append(serverOptions.Protocols, server.ProtocolFTP)
+	}
+	if cliOptions.Responder {
+		serverOptions.Protocols = append(serverOptions.Protocols, server.ProtocolResponder)
+	}
+	if cliOptions.Smb {
+		serverOptions.Protocols = append(serverOptions.Protocols, server.ProtocolSMB)
+	}
+	serverOptions.Protocols = append(serverOptions.Protocols, cliOptions.Protocols...)
+	handlers, err := server.NewProtocolHandlers(serverOptions)
+	if err != nil {
+		gologger.Fatal().Msgf("Could not create protocol handlers: %s\n", err)
+	}
+
+	// the dns server answers the acme challenges, so it is started before the
+	// certificates are requested and the other handlers are started with them
+	serviceStatus := make(chan server.ServiceStatus, len(handlers)*2)
+	for _, handler := range handlers {
+		if handler.Name() != server.ProtocolDNS {
+			continue
+		}
+		if err := handler.Start(nil, serviceStatus); err != nil {
+			gologger.Fatal().Msgf("Could not start %s protocol: %s\n", handler.Name(), err)
+		}
+	}
 
 	var tlsConfig *tls.Config
+	// certReloader serves the custom certificate, which is loaded again on reload
+	var certReloader *acme.CertificateReloader
 	switch {
 	case cliOptions.CertificatePath != "" && cliOptions.PrivateKeyPath != "":
 		var domain string
 		if len(cliOptions.Domains) > 0 {
 			domain = cliOptions.Domains[0]
 		}
-		acmeManagerTLS, acmeErr := acme.BuildTlsConfigWithCertAndKeyPaths(cliOptions.CertificatePath, cliOptions.PrivateKeyPath, domain)
+		reloader, acmeErr := acme.NewCertificateReloader(cliOptions.CertificatePath, cliOptions.PrivateKeyPath)
 		if acmeErr != nil {
 			gologger.Error().Msgf("https will be disabled: %s", acmeErr)
 		} else {
-			tlsConfig = acmeManagerTLS
+			certReloader = reloader
+			tlsConfig = acme.BuildTlsConfigWithCertificateReloader(domain, certReloader)
+		}
+	case !cliOptions.SkipAcme && len(cliOptions.Domains) > 0 && cliOptions.AcmeChallenge == acme.ChallengeTLSALPN01:
+		var acmeErr error
+		tlsConfig, acmeErr = acme.HandleTLSALPNCertificates(cliOptions.Domains, serverOptions.Hostmasters[0], cliOptions.ListenIP, cliOptions.HttpsPort, cliOptions.Debug)
+		if acmeErr != nil {
+			gologger.Error().Msgf("An error occurred while applying for a certificate, error: %v", acmeErr)
+			gologger.Error().Msgf("Could not generate certs for auto TLS, https will be disabled")
 		}
 	case !cliOptions.SkipAcme && len(cliOptions.Domains) > 0:
 		var certs []tls.Certificate
@@ -261,117 +547,95 @@ func main() {
 	// manually cleans up stale OCSP from storage
 	acme.CleanupStorage()
 
-	httpServer, err := server.NewHTTPServer(serverOptions)
-	if err != nil {
-		gologger.Fatal().Msgf("Could not create HTTP server: %s", err)
-	}
-	httpAlive := make(chan bool)
-	httpsAlive := make(chan bool)
-	go httpServer.ListenAndServe(tlsConfig, httpAlive, httpsAlive)
-
-	smtpServer, err := server.NewSMTPServer(serverOptions)
-	if err != nil {
-		gologger.Fatal().Msgf("Could not create SMTP server: %s", err)
-	}
-	smtpAlive := make(chan bool)
-	smtpsAlive := make(chan bool)
-	go smtpServer.ListenAndServe(tlsConfig, smtpAlive, smtpsAlive)
-
-	ldapAlive := make(chan bool)
-	ldapServer, err := server.NewLDAPServer(serverOptions, cliOptions.LdapWithFullLogger)
-	if err != nil {
-		gologger.Fatal().Msgf("Could not create LDAP server: %s", err)
-	}
-	go ldapServer.ListenAndServe(tlsConfig, ldapAlive)
-	defer ldapServer.Close()
-
-	ftpAlive := make(chan bool)
-	if cliOptions.Ftp {
-		ftpServer, err := server.NewFTPServer(serverOptions)
-		if err != nil {
-			gologger.Fatal().Msgf("Could not create FTP server: %s", err)
+	// reload applies the changes of the configuration that don't require restarting
+	// the listeners or dropping the sessions, it is triggered by SIGHUP or the admin api
+	var reloadMutex sync.Mutex
+	serverOptions.Reload = func() error {
+		reloadMutex.Lock()
+		defer reloadMutex.Unlock()
+
+		reloadOptions := *cliOptions
+		if cliOptions.ServerConfig != "" {
+			serverConfig, err := options.LoadServerConfig(cliOptions.ServerConfig)
+			if err != nil {
+				return err
+			}
+			serverConfig.Apply(&reloadOptions, options.FlagsSet(os.Args[1:]))
 		}
-		go ftpServer.ListenAndServe(tlsConfig, ftpAlive) //nolint
-	}
-
-	responderAlive := make(chan bool)
-	if cliOptions.Responder {
-		responderServer, err := server.NewResponderServer(serverOptions)
-		if err != nil {
-			gologger.Fatal().Msgf("Could not create SMB server: %s", err)
+		var errs error
+		if err := serverOptions.APIKeys.Reload(); err != nil {
+			errs = multierr.Append(errs, err)
+		}
+		if serverOptions.Tenants != nil && reloadOptions.Tenants != "" {
+			if err := serverOptions.Tenants.Reload(reloadOptions.Tenants); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("could not reload tenants: %s", err))
+			}
 		}
-		go responderServer.ListenAndServe(responderAlive) //nolint
-		defer responderServer.Close()
+		for _, handler := range handlers {
+			dnsReloader, ok := handler.(server.DNSReloader)
+			if !ok {
+				continue
+			}
+			if err := dnsReloader.ReloadCustomRecords(reloadOptions.CustomRecords); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("could not reload custom dns records: %s", err))
+			}
+			if err := dnsReloader.ReloadZone(reloadOptions.DNSZone); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("could not reload dns zone: %s", err))
+			}
+		}
+		if err := serverOptions.Middlewares.ReloadRules(reloadOptions.InteractionRules); err != nil {
+			errs = multierr.Append(errs, fmt.Errorf("could not reload interaction rules: %s", err))
+		}
+		if serverOptions.NoiseFilter != nil {
+			if err := serverOptions.NoiseFilter.ReloadScanners(reloadOptions.NoiseScanners); err != nil {
+				errs = multierr.Append(errs, fmt.Errorf("could not reload noise scanners: %s", err))
+			}
+		}
+		if certReloader != nil {
+			if err := certReloader.Reload(reloadOptions.CertificatePath, reloadOptions.PrivateKeyPath); err != nil {
+				errs = multierr.Append(errs, err)
+			}
+		}
+		if errs == nil {
+			gologger.Info().Msgf("Configuration reloaded\n")
+		}
+		return errs
 	}
+	go func() {
+		hup := make(chan os.Signal, 1)
+		signal.Notify(hup, syscall.SIGHUP)
+		for range hup {
+			if err := serverOptions.Reload(); err != nil {
+				gologger.Warning().Msgf("Could not reload configuration: %s\n", err)
+			}
+		}
+	}()
 
-	smbAlive := make(chan bool)
-	if cliOptions.Smb {
-		smbServer, err := server.NewSMBServer(serverOptions)
-		if err != nil {
-			gologger.Fatal().Msgf("Could not create SMB server: %s", err)
+	for _, handler := range handlers {
+		if handler.Name() == server.ProtocolDNS {
+			continue
+		}
+		if err := handler.Start(tlsConfig, serviceStatus); err != nil {
+			gologger.Fatal().Msgf("Could not start %s protocol: %s\n", handler.Name(), err)
 		}
-		go smbServer.ListenAndServe(smbAlive) //nolint
-		defer smbServer.Close()
 	}
 
+	// shuttingDown is set on shutdown, when the services are expected to stop
+	var shuttingDown int32
+
 	gologger.Info().Msgf("Listening with the following services:\n")
 	go func() {
-		for {
-			service := ""
-			network := ""
-			port := 0
-			status := true
-			fatal := false
-			select {
-			case status = <-dnsUdpAlive:
-				service = "DNS"
-				network = "UDP"
-				port = serverOptions.DnsPort
-				fatal = true
-			case status = <-dnsTcpAlive:
-				service = "DNS"
-				network = "TCP"
-				port = serverOptions.DnsPort
-			case status = <-httpAlive:
-				service = "HTTP"
-				network = "TCP"
-				port = serverOptions.HttpPort
-				fatal = true
-			case status = <-httpsAlive:
-				service = "HTTPS"
-				network = "TCP"
-				port = serverOptions.HttpsPort
-			case status = <-smtpAlive:
-				service = "SMTP"
-				network = "TCP"
-				port = serverOptions.SmtpPort
-			case status = <-smtpsAlive:
-				service = "SMTPS"
-				network = "TCP"
-				port = serverOptions.SmtpsPort
-			case status = <-ftpAlive:
-				service = "FTP"
-				network = "TCP"
-				port = serverOptions.FtpPort
-			case status = <-responderAlive:
-				service = "Responder"
-				network = "TCP"
-				port = 445
-			case status = <-smbAlive:
-				service = "SMB"
-				network = "TCP"
-				port = serverOptions.SmbPort
-			case status = <-ldapAlive:
-				service = "LDAP"
-				network = "TCP"
-				port = serverOptions.LdapPort
+		for status := range serviceStatus {
+			serverOptions.Health.SetStatus(status.Service, status.Network, status.Port, status.Up)
+			if atomic.LoadInt32(&shuttingDown) == 1 {
+				continue
 			}
-			if status {
-				gologger.Silent().Msgf("[%s] Listening on %s %s:%d", service, network, serverOptions.ListenIP, port)
-			} else if fatal {
-				gologger.Fatal().Msgf("The %s %s service has unexpectedly stopped", network, service)
+			if status.Up {
+				gologger.Silent().Msgf("[%s] Listening on %s %s", status.Service, status.Network, net.JoinHostPort(serverOptions.ListenIP, strconv.Itoa(status.Port)))
+			} else if status.Fatal {
+				gologger.Fatal().Msgf("The %s %s service has unexpectedly stopped", status.Network, status.Service)
 			} else {
-				gologger.Warning().Msgf("The %s %s service has unexpectedly stopped", network, service)
+				gologger.Warning().Msgf("The %s %s service has unexpectedly stopped", status.Network, status.Service)
 			}
 		}
 	}()
@@ -388,17 +652,94 @@ func main() {
 		}()
 	}
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	for range c {
-		if err := store.Close(); err != nil {
-			gologger.Warning().Msgf("Couldn't close the storage: %s\n", err)
+	<-service.StopRequested()
+	atomic.StoreInt32(&shuttingDown, 1)
+
+	// stop accepting connections and wait for the in-flight interactions
+	gologger.Info().Msgf("Shutting down, waiting up to %d seconds for in-flight interactions\n", cliOptions.ShutdownTimeout)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cliOptions.ShutdownTimeout)*time.Second)
+	for _, handler := range handlers {
+		if err := handler.Stop(ctx); err != nil {
+			gologger.Warning().Msgf("Couldn't shutdown the %s server: %s\n", handler.Name(), err)
 		}
-		if pprofServer != nil {
-			pprofServer.Close()
+	}
+	cancel()
+
+	if serverOptions.SyslogExporter != nil {
+		_ = serverOptions.SyslogExporter.Close()
+	}
+	// the interactions queued on disk are kept on a graceful shutdown
+	if err := store.Shutdown(); err != nil {
+		gologger.Warning().Msgf("Couldn't close the storage: %s\n", err)
+	}
+	if serverOptions.History != nil {
+		if err := serverOptions.History.Close(); err != nil {
+			gologger.Warning().Msgf("Couldn't close the interactions history: %s\n", err)
+		}
+	}
+	if pprofServer != nil {
+		pprofServer.Close()
+	}
+	service.Stopped()
+	os.Exit(0)
+}
+
+// retentionInterval is the interval between the purges of the interactions history
+const retentionInterval = 10 * time.Minute
+
+// runRetention periodically purges the interactions history exceeding the retention limits
+func runRetention(history *storage.History, retention *storage.RetentionOptions) {
+	ticker := time.NewTicker(retentionInterval)
+	defer ticker.Stop()
+	for {
+		purged, err := history.Purge(retention)
+		if purged > 0 {
+			gologger.Info().Msgf("Purged %d interactions from the history\n", purged)
+		}
+		if err != nil {
+			gologger.Warning().Msgf("Could not purge history: %s\n", err)
+		}
+		<-ticker.C
+	}
+}
+
+// validateSetup checks the dns delegation and the ports of the server, and exits
+// with a non-zero status if any of the checks failed.
+func validateSetup(cliOptions *options.CLIServerOptions, publicPorts map[string]int) {
+	ipAddress := cliOptions.IPAddress
+	if ipAddress == "" {
+		publicIP, err := getPublicIP()
+		if publicIP == "" {
+			gologger.Fatal().Msgf("Could not get public ip, specify it with -ip: %s\n", err)
 		}
+		ipAddress = publicIP
+	}
+	gologger.Info().Msgf("Validating setup of %s with public ip %s\n", strings.Join(cliOptions.Domains, ","), ipAddress)
+
+	listenPorts := map[string]int{
+		"dns":   cliOptions.DnsPort,
+		"http":  cliOptions.HttpPort,
+		"https": cliOptions.HttpsPort,
+		"smtp":  cliOptions.SmtpPort,
+	}
+	for _, name := range cliOptions.DisabledServices {
+		delete(listenPorts, name)
+	}
+	checks := runner.ValidateSetup(&runner.ValidateOptions{
+		Domains:     cliOptions.Domains,
+		IPAddress:   ipAddress,
+		ListenIP:    cliOptions.ListenIP,
+		ListenPorts: listenPorts,
+		PublicPorts: publicPorts,
+	})
+	output, valid := runner.FormatValidation(checks)
+	gologger.Print().Msgf("%s", output)
+	if !valid {
+		gologger.Error().Msgf("Setup validation failed, fix the issues above and run validate again\n")
 		os.Exit(1)
 	}
+	gologger.Info().Msgf("Setup validation succeeded\n")
+	os.Exit(0)
 }
 
 func getPublicIP() (string, error) {
@@ -421,3 +762,51 @@ func getPublicIP() (string, error) {
 
 	return externalIP, errors.New("couldn't find an interface configured with external ip")
 }
+
+// runServiceCommand performs an action of the service subcommand, the remaining
+// arguments being the flags of the server run by the service.
+// runAPIKeyCommand creates an admin api key in a keys file, so that the first
+// key with the keys scope can be created on servers without authentication.
+// The arguments are the path of the file, the comma separated scopes and an
+// optional name.
+func runAPIKeyCommand(args []string) error {
+	if len(args) < 2 {
+		return fmt.Errorf("usage: interactsh-server api-key <api-keys-file> <scopes> [name], scopes among %s", strings.Join(server.APIScopes, ","))
+	}
+	apiKeys, err := server.NewAPIKeys(args[0])
+	if err != nil {
+		return err
+	}
+	var name string
+	if len(args) > 2 {
+		name = args[2]
+	}
+	key, plaintext, err := apiKeys.Create(name, strings.Split(args[1], ","))
+	if err != nil {
+		return err
+	}
+	gologger.Info().Msgf("Created api key %s with scopes %s in %s\n", key.ID, strings.Join(key.Scopes, ","), args[0])
+	fmt.Println(plaintext)
+	return nil
+}
+
+func runServiceCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("no service action specified, must be one of %s", strings.Join(service.Actions, ", "))
+	}
+	config, err := service.DefaultConfig(args[1:])
+	if err != nil {
+		return err
+	}
+	output, err := service.Control(args[0], config)
+	if err != nil {
+		return err
+	}
+	switch args[0] {
+	case service.ActionStatus, service.ActionUnit:
+		fmt.Println(strings.TrimSpace(output))
+	default:
+		gologger.Info().Msgf("Service %s: %s done\n", config.Name, args[0])
+	}
+	return nil
+}
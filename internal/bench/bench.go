@@ -0,0 +1,314 @@
+// Package bench simulates registered clients and interactions against an
+// interactsh server, measuring the delivery latency and the drop rate of the
+// interactions to size the server instances.
+package bench
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/client"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/remeh/sizedwaitgroup"
+	"go.uber.org/ratelimit"
+)
+
+// Modes generating the interactions
+const (
+	// ModeDNS sends dns queries for the client URLs
+	ModeDNS = "dns"
+	// ModeHTTP sends http requests with the client URLs as host
+	ModeHTTP = "http"
+	// ModeInject records the interactions directly in an in-process server
+	ModeInject = "inject"
+)
+
+// Modes are the supported modes
+var Modes = []string{ModeDNS, ModeHTTP, ModeInject}
+
+// Options contains the configuration of a benchmark
+type Options struct {
+	// ServerURL is the URL of the benchmarked server, ignored in inject mode
+	ServerURL string
+	// Token is the authentication token of the server
+	Token string
+	// Mode is the way the interactions are generated
+	Mode string
+	// Target is the host receiving the generated traffic, the host of the
+	// server URL by default.
+	Target string
+	// DNSPort is the port receiving the dns queries
+	DNSPort int
+	// HTTPPort is the port receiving the http requests
+	HTTPPort int
+	// Clients is the number of registered clients
+	Clients int
+	// Rate is the number of interactions sent per second, across all the clients
+	Rate int
+	// Concurrency is the maximum number of requests in flight
+	Concurrency int
+	// Duration is the time during which the interactions are sent
+	Duration time.Duration
+	// PollInterval is the poll interval of the clients
+	PollInterval time.Duration
+	// Grace is the time waited for the pending interactions once sending is over
+	Grace time.Duration
+}
+
+// validate checks the options and sets the defaults
+func (options *Options) validate() error {
+	switch options.Mode {
+	case ModeDNS, ModeHTTP, ModeInject:
+	default:
+		return errors.Errorf("unknown mode %s, must be one of %s", options.Mode, strings.Join(Modes, ", "))
+	}
+	if options.Mode != ModeInject && options.ServerURL == "" {
+		return errors.New("no server url specified")
+	}
+	if options.Clients <= 0 || options.Rate <= 0 || options.Duration <= 0 || options.PollInterval <= 0 {
+		return errors.New("clients, rate, duration and poll interval must be positive")
+	}
+	if options.DNSPort == 0 {
+		options.DNSPort = 53
+	}
+	if options.HTTPPort == 0 {
+		options.HTTPPort = 80
+	}
+	if options.Concurrency <= 0 {
+		options.Concurrency = options.Rate
+	}
+	if options.Grace <= 0 {
+		options.Grace = 2 * options.PollInterval
+	}
+	return nil
+}
+
+// Benchmark sends interactions for registered clients and tracks their delivery
+type Benchmark struct {
+	options    *Options
+	clients    []*client.Client
+	tracker    *tracker
+	inject     *injectServer
+	dnsClient  *dns.Client
+	httpClient *http.Client
+}
+
+// New registers the clients of a benchmark, starting an in-process server in
+// inject mode.
+func New(options *Options) (*Benchmark, error) {
+	if err := options.validate(); err != nil {
+		return nil, err
+	}
+	b := &Benchmark{
+		options:    options,
+		tracker:    newTracker(),
+		dnsClient:  &dns.Client{Timeout: 5 * time.Second},
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+	serverURL := options.ServerURL
+	if options.Mode == ModeInject {
+		inject, err := startInjectServer()
+		if err != nil {
+			return nil, err
+		}
+		b.inject = inject
+		serverURL = inject.url
+	} else if options.Target == "" {
+		if !strings.HasPrefix(serverURL, "http://") && !strings.HasPrefix(serverURL, "https://") {
+			serverURL = "https://" + serverURL
+		}
+		parsed, err := url.Parse(serverURL)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not parse server url")
+		}
+		options.Target = parsed.Hostname()
+	}
+
+	var mutex sync.Mutex
+	var registerErr error
+	swg := sizedwaitgroup.New(50)
+	for i := 0; i < options.Clients; i++ {
+		swg.Add()
+		go func() {
+			defer swg.Done()
+			c, err := client.New(&client.Options{ServerURL: serverURL, Token: options.Token})
+			mutex.Lock()
+			defer mutex.Unlock()
+			if err != nil {
+				registerErr = err
+				return
+			}
+			b.clients = append(b.clients, c)
+		}()
+	}
+	swg.Wait()
+	if len(b.clients) == 0 {
+		b.Close()
+		return nil, errors.Wrap(registerErr, "could not register clients")
+	}
+	if registerErr != nil {
+		gologger.Warning().Msgf("Could not register %d clients: %s\n", options.Clients-len(b.clients), registerErr)
+	}
+	return b, nil
+}
+
+// Run sends the interactions until the duration has elapsed or ctx is done,
+// then waits for the pending ones and reports the results.
+func (b *Benchmark) Run(ctx context.Context) *Report {
+	for _, c := range b.clients {
+		c.StartPolling(b.options.PollInterval, b.tracker.received)
+	}
+	start := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, b.options.Duration)
+	defer cancel()
+	limiter := ratelimit.New(b.options.Rate)
+	swg := sizedwaitgroup.New(b.options.Concurrency)
+	for i := 0; ctx.Err() == nil; i++ {
+		limiter.Take()
+		if ctx.Err() != nil {
+			break
+		}
+		URL := b.clients[i%len(b.clients)].URL()
+		uniqueID := strings.ToLower(URL[:strings.Index(URL, ".")])
+		b.tracker.sent(uniqueID)
+		swg.Add()
+		go func() {
+			defer swg.Done()
+			if err := b.send(URL); err != nil {
+				gologger.Verbose().Msgf("Could not send %s interaction: %s\n", b.options.Mode, err)
+				b.tracker.failed(uniqueID)
+			}
+		}()
+	}
+	swg.Wait()
+	sending := time.Since(start)
+
+	// the interactions are delivered at the next polls
+	deadline := time.Now().Add(b.options.Grace)
+	for b.tracker.pendingCount() > 0 && time.Now().Before(deadline) {
+		time.Sleep(100 * time.Millisecond)
+	}
+	for _, c := range b.clients {
+		c.StopPolling()
+	}
+	return b.tracker.report(len(b.clients), sending)
+}
+
+// Close deregisters the clients and stops the in-process server
+func (b *Benchmark) Close() {
+	for _, c := range b.clients {
+		_ = c.Close()
+	}
+	if b.inject != nil {
+		b.inject.Close()
+	}
+}
+
+// send generates an interaction for URL
+func (b *Benchmark) send(URL string) error {
+	switch b.options.Mode {
+	case ModeDNS:
+		msg := &dns.Msg{}
+		msg.SetQuestion(dns.Fqdn(URL), dns.TypeA)
+		_, _, err := b.dnsClient.Exchange(msg, net.JoinHostPort(b.options.Target, strconv.Itoa(b.options.DNSPort)))
+		return err
+	case ModeHTTP:
+		req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://%s/", net.JoinHostPort(b.options.Target, strconv.Itoa(b.options.HTTPPort))), nil)
+		if err != nil {
+			return err
+		}
+		req.Host = URL
+		resp, err := b.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_, _ = io.Copy(ioutil.Discard, resp.Body)
+		return resp.Body.Close()
+	default:
+		b.inject.record(URL)
+		return nil
+	}
+}
+
+// tracker matches the received interactions with the sent ones
+type tracker struct {
+	sync.Mutex
+	pending    map[string]time.Time
+	delivery   []time.Duration
+	capture    []time.Duration
+	sentCount  int
+	errors     int
+	duplicates int
+}
+
+func newTracker() *tracker {
+	return &tracker{pending: make(map[string]time.Time)}
+}
+
+// sent records an interaction sent for a unique id
+func (t *tracker) sent(uniqueID string) {
+	t.Lock()
+	defer t.Unlock()
+	t.pending[uniqueID] = time.Now()
+	t.sentCount++
+}
+
+// failed records an interaction which could not be sent
+func (t *tracker) failed(uniqueID string) {
+	t.Lock()
+	defer t.Unlock()
+	delete(t.pending, uniqueID)
+	t.errors++
+}
+
+// received records the latencies of a polled interaction
+func (t *tracker) received(interaction *types.Interaction) {
+	now := time.Now()
+	t.Lock()
+	defer t.Unlock()
+	sentAt, ok := t.pending[interaction.UniqueID]
+	if !ok {
+		// a dns query can be retried or resolved by several resolvers
+		t.duplicates++
+		return
+	}
+	delete(t.pending, interaction.UniqueID)
+	t.delivery = append(t.delivery, now.Sub(sentAt))
+	t.capture = append(t.capture, interaction.Timestamp.Sub(sentAt))
+}
+
+func (t *tracker) pendingCount() int {
+	t.Lock()
+	defer t.Unlock()
+	return len(t.pending)
+}
+
+// report returns the results of the tracked interactions
+func (t *tracker) report(clients int, elapsed time.Duration) *Report {
+	t.Lock()
+	defer t.Unlock()
+	return &Report{
+		Clients:    clients,
+		Elapsed:    elapsed,
+		Sent:       t.sentCount,
+		Errors:     t.errors,
+		Received:   len(t.delivery),
+		Dropped:    len(t.pending),
+		Duplicates: t.duplicates,
+		Delivery:   newLatency(t.delivery),
+		Capture:    newLatency(t.capture),
+	}
+}
@@ -0,0 +1,54 @@
+package bench
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracker(t *testing.T) {
+	tracker := newTracker()
+	tracker.sent("a")
+	tracker.sent("b")
+	tracker.sent("c")
+	tracker.failed("c")
+
+	tracker.received(&types.Interaction{UniqueID: "a", Timestamp: time.Now()})
+	tracker.received(&types.Interaction{UniqueID: "a", Timestamp: time.Now()})
+	require.Equal(t, 1, tracker.pendingCount(), "could not track pending interactions")
+
+	report := tracker.report(1, time.Second)
+	require.Equal(t, 3, report.Sent, "could not count sent interactions")
+	require.Equal(t, 1, report.Errors, "could not count send errors")
+	require.Equal(t, 1, report.Received, "could not count received interactions")
+	require.Equal(t, 1, report.Dropped, "could not count dropped interactions")
+	require.Equal(t, 1, report.Duplicates, "could not count duplicate interactions")
+	require.Equal(t, 0.5, report.DropRate(), "could not compute drop rate")
+}
+
+func TestNewLatency(t *testing.T) {
+	var durations []time.Duration
+	for i := 100; i >= 1; i-- {
+		durations = append(durations, time.Duration(i)*time.Millisecond)
+	}
+	latency := newLatency(durations)
+	require.Equal(t, time.Millisecond, latency.Min, "could not get min latency")
+	require.Equal(t, 100*time.Millisecond, latency.Max, "could not get max latency")
+	require.Equal(t, 50*time.Millisecond, latency.P50, "could not get median latency")
+	require.Equal(t, 99*time.Millisecond, latency.P99, "could not get p99 latency")
+	require.Equal(t, 50500*time.Microsecond, latency.Mean, "could not get mean latency")
+
+	require.Equal(t, Latency{}, newLatency(nil), "could not get empty latency")
+}
+
+func TestValidateOptions(t *testing.T) {
+	options := &Options{Mode: "smtp", Clients: 1, Rate: 1, Duration: time.Second, PollInterval: time.Second}
+	require.NotNil(t, options.validate(), "could validate unknown mode")
+
+	options = &Options{Mode: ModeInject, Clients: 1, Rate: 10, Duration: time.Second, PollInterval: time.Second}
+	require.Nil(t, options.validate(), "could not validate options")
+	require.Equal(t, 10, options.Concurrency, "could not set default concurrency")
+	require.Equal(t, 2*time.Second, options.Grace, "could not set default grace")
+}
@@ -0,0 +1,82 @@
+package bench
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/projectdiscovery/interactsh/pkg/settings"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+)
+
+// injectServer is an in-process server on the loopback interface, recording the
+// interactions without generating network traffic to measure the storage and
+// polling paths.
+type injectServer struct {
+	options *server.Options
+	http    *server.HTTPServer
+	url     string
+}
+
+// startInjectServer starts the http server of an in-process server
+func startInjectServer() (*injectServer, error) {
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	store, err := storage.New(&storage.Options{EvictionTTL: time.Hour, MaxSize: storage.DefaultOptions.MaxSize})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create storage")
+	}
+	options := &server.Options{
+		Domains:                  []string{"localhost"},
+		ListenIP:                 "127.0.0.1",
+		HttpPort:                 port,
+		CorrelationIdLength:      settings.CorrelationIdLengthDefault,
+		CorrelationIdNonceLength: settings.CorrelationIdNonceLengthDefault,
+		Storage:                  store,
+		Stats:                    &server.Metrics{},
+	}
+	httpServer, err := server.NewHTTPServer(options)
+	if err != nil {
+		_ = store.Close()
+		return nil, errors.Wrap(err, "could not create http server")
+	}
+	alive := make(chan bool, 2)
+	go httpServer.ListenAndServe(nil, alive, alive)
+	if !<-alive {
+		_ = store.Close()
+		return nil, errors.New("could not start http server")
+	}
+	return &injectServer{options: options, http: httpServer, url: fmt.Sprintf("http://127.0.0.1:%d", port)}, nil
+}
+
+// record records a http interaction for URL
+func (s *injectServer) record(URL string) {
+	s.options.RecordInteraction(&server.Interaction{
+		Protocol:      "http",
+		RawRequest:    fmt.Sprintf("GET / HTTP/1.1\r\nHost: %s\r\n\r\n", URL),
+		RemoteAddress: "127.0.0.1",
+	}, URL)
+}
+
+// Close stops the server
+func (s *injectServer) Close() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	_ = s.http.Shutdown(ctx)
+	_ = s.options.Storage.Close()
+}
+
+// freePort returns a free tcp port of the loopback interface
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, errors.Wrap(err, "could not find free port")
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
@@ -0,0 +1,102 @@
+package bench
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Report contains the results of a benchmark
+type Report struct {
+	// Clients is the number of registered clients
+	Clients int
+	// Elapsed is the time spent sending the interactions
+	Elapsed time.Duration
+	// Sent is the number of interactions sent
+	Sent int
+	// Errors is the number of interactions which could not be sent
+	Errors int
+	// Received is the number of interactions polled by the clients
+	Received int
+	// Dropped is the number of interactions sent but never polled
+	Dropped int
+	// Duplicates is the number of interactions polled more than once
+	Duplicates int
+	// Delivery is the latency between sending and polling the interactions
+	Delivery Latency
+	// Capture is the latency between sending and capturing the interactions,
+	// which relies on the clock of the server.
+	Capture Latency
+}
+
+// DropRate returns the ratio of the interactions sent without error that
+// were never polled.
+func (r *Report) DropRate() float64 {
+	delivered := r.Sent - r.Errors
+	if delivered <= 0 {
+		return 0
+	}
+	return float64(r.Dropped) / float64(delivered)
+}
+
+// String returns the report in a human readable form
+func (r *Report) String() string {
+	builder := &strings.Builder{}
+	fmt.Fprintf(builder, "Clients:      %d\n", r.Clients)
+	fmt.Fprintf(builder, "Duration:     %s\n", r.Elapsed.Round(time.Millisecond))
+	rate := 0.0
+	if r.Elapsed > 0 {
+		rate = float64(r.Sent) / r.Elapsed.Seconds()
+	}
+	fmt.Fprintf(builder, "Sent:         %d (%.1f/s)\n", r.Sent, rate)
+	fmt.Fprintf(builder, "Send errors:  %d\n", r.Errors)
+	fmt.Fprintf(builder, "Received:     %d\n", r.Received)
+	fmt.Fprintf(builder, "Dropped:      %d (%.2f%%)\n", r.Dropped, r.DropRate()*100)
+	fmt.Fprintf(builder, "Duplicates:   %d\n", r.Duplicates)
+	fmt.Fprintf(builder, "Delivery:     %s\n", r.Delivery)
+	fmt.Fprintf(builder, "Capture:      %s\n", r.Capture)
+	return builder.String()
+}
+
+// Latency contains the distribution of latencies
+type Latency struct {
+	Min  time.Duration
+	Mean time.Duration
+	P50  time.Duration
+	P90  time.Duration
+	P99  time.Duration
+	Max  time.Duration
+}
+
+// newLatency returns the distribution of durations
+func newLatency(durations []time.Duration) Latency {
+	if len(durations) == 0 {
+		return Latency{}
+	}
+	sorted := make([]time.Duration, len(durations))
+	copy(sorted, durations)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var total time.Duration
+	for _, duration := range sorted {
+		total += duration
+	}
+	percentile := func(p int) time.Duration {
+		return sorted[(len(sorted)-1)*p/100]
+	}
+	return Latency{
+		Min:  sorted[0],
+		Mean: total / time.Duration(len(sorted)),
+		P50:  percentile(50),
+		P90:  percentile(90),
+		P99:  percentile(99),
+		Max:  sorted[len(sorted)-1],
+	}
+}
+
+// String returns the latencies rounded to the millisecond
+func (l Latency) String() string {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	return fmt.Sprintf("min=%s mean=%s p50=%s p90=%s p99=%s max=%s", round(l.Min), round(l.Mean), round(l.P50), round(l.P90), round(l.P99), round(l.Max))
+}
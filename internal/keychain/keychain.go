@@ -0,0 +1,105 @@
+// Package keychain stores the secrets of the client sessions in the keychain
+// of the operating system, the macOS Keychain, the Windows Credential Manager
+// or the Secret Service of libsecret on linux and BSD, instead of the session
+// files.
+package keychain
+
+import (
+	"encoding/base64"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// Service is the name of the keychain service of the stored sessions
+const Service = "interactsh"
+
+// ErrNotFound is returned when no secret is stored for the account
+var ErrNotFound = errors.New("secret not found in keychain")
+
+// provider stores the secrets in the keychain of the operating system
+type provider interface {
+	set(service, account, secret string) error
+	get(service, account string) (string, error)
+	remove(service, account string) error
+}
+
+// keyring is the keychain of the operating system, replaced in tests
+var keyring provider = newProvider()
+
+// Set stores the secret of account in the keychain, replacing the existing one
+func Set(service, account, secret string) error {
+	return keyring.set(service, account, secret)
+}
+
+// Get returns the secret of account stored in the keychain, or ErrNotFound
+func Get(service, account string) (string, error) {
+	return keyring.get(service, account)
+}
+
+// Delete removes the secret of account from the keychain
+func Delete(service, account string) error {
+	return keyring.remove(service, account)
+}
+
+// sessionSecrets are the secrets of a session stored in the keychain
+type sessionSecrets struct {
+	Token         string `json:"server-token,omitempty"`
+	SecretKey     string `json:"secret-key"`
+	DerivationKey string `json:"derivation-key,omitempty"`
+	PrivateKey    string `json:"private-key"`
+}
+
+// StoreSession stores the secret key, the derivation key, the private key and
+// the server token of the session in the keychain under its correlation id, and
+// removes them from the session so that it can be saved without its secrets.
+func StoreSession(sessionInfo *types.SessionInfo) error {
+	if sessionInfo.CorrelationID == "" {
+		return errors.New("session has no correlation id")
+	}
+	data, err := jsoniter.Marshal(&sessionSecrets{
+		Token:         sessionInfo.Token,
+		SecretKey:     sessionInfo.SecretKey,
+		DerivationKey: sessionInfo.DerivationKey,
+		PrivateKey:    base64.StdEncoding.EncodeToString([]byte(sessionInfo.PrivateKey)),
+	})
+	if err != nil {
+		return errors.Wrap(err, "could not marshal session secrets")
+	}
+	if err := Set(Service, sessionInfo.CorrelationID, string(data)); err != nil {
+		return errors.Wrap(err, "could not store session secrets")
+	}
+	sessionInfo.Token = ""
+	sessionInfo.SecretKey = ""
+	sessionInfo.DerivationKey = ""
+	sessionInfo.PrivateKey = ""
+	sessionInfo.Keychain = true
+	return nil
+}
+
+// LoadSession restores the secrets of a session saved with StoreSession
+func LoadSession(sessionInfo *types.SessionInfo) error {
+	data, err := Get(Service, sessionInfo.CorrelationID)
+	if err != nil {
+		return errors.Wrap(err, "could not get session secrets")
+	}
+	var secrets sessionSecrets
+	if err := jsoniter.Unmarshal([]byte(data), &secrets); err != nil {
+		return errors.Wrap(err, "could not unmarshal session secrets")
+	}
+	privateKey, err := base64.StdEncoding.DecodeString(secrets.PrivateKey)
+	if err != nil {
+		return errors.Wrap(err, "could not decode private key")
+	}
+	sessionInfo.Token = secrets.Token
+	sessionInfo.SecretKey = secrets.SecretKey
+	sessionInfo.DerivationKey = secrets.DerivationKey
+	sessionInfo.PrivateKey = string(privateKey)
+	return nil
+}
+
+// DeleteSession removes the secrets of the session of correlationID from the keychain
+func DeleteSession(correlationID string) error {
+	return Delete(Service, correlationID)
+}
@@ -0,0 +1,55 @@
+package keychain
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// securityItemNotFound is the exit code of security when the item doesn't exist
+const securityItemNotFound = 44
+
+// securityProvider stores the secrets in the login keychain with the security tool
+type securityProvider struct{}
+
+func newProvider() provider {
+	return securityProvider{}
+}
+
+func (securityProvider) set(service, account, secret string) error {
+	// the interactive mode reads the command from stdin, keeping the secret
+	// out of the arguments visible to the other processes
+	cmd := exec.Command("security", "-i")
+	cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -X %s\n", service, account, hex.EncodeToString([]byte(secret))))
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "could not run security: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (securityProvider) get(service, account string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("security", "find-generic-password", "-s", service, "-a", account, "-w")
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == securityItemNotFound {
+			return "", ErrNotFound
+		}
+		return "", errors.Wrap(err, "could not run security")
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+func (securityProvider) remove(service, account string) error {
+	if err := exec.Command("security", "delete-generic-password", "-s", service, "-a", account).Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == securityItemNotFound {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "could not run security")
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+//go:build !darwin && !linux && !freebsd && !openbsd && !netbsd && !windows
+
+package keychain
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// errUnsupported is returned on the platforms without keychain support
+var errUnsupported = errors.Errorf("keychain is not supported on %s", runtime.GOOS)
+
+type unsupportedProvider struct{}
+
+func newProvider() provider {
+	return unsupportedProvider{}
+}
+
+func (unsupportedProvider) set(service, account, secret string) error {
+	return errUnsupported
+}
+
+func (unsupportedProvider) get(service, account string) (string, error) {
+	return "", errUnsupported
+}
+
+func (unsupportedProvider) remove(service, account string) error {
+	return errUnsupported
+}
@@ -0,0 +1,56 @@
+package keychain
+
+import (
+	"testing"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+// memoryProvider stores the secrets in memory for the tests
+type memoryProvider map[string]string
+
+func (m memoryProvider) set(service, account, secret string) error {
+	m[service+":"+account] = secret
+	return nil
+}
+
+func (m memoryProvider) get(service, account string) (string, error) {
+	secret, ok := m[service+":"+account]
+	if !ok {
+		return "", ErrNotFound
+	}
+	return secret, nil
+}
+
+func (m memoryProvider) remove(service, account string) error {
+	delete(m, service+":"+account)
+	return nil
+}
+
+func TestStoreSession(t *testing.T) {
+	defaultKeyring := keyring
+	defer func() { keyring = defaultKeyring }()
+	keyring = memoryProvider{}
+
+	sessionInfo := &types.SessionInfo{
+		ServerURL:     "https://oast.pro",
+		Token:         "token",
+		PrivateKey:    "\x30\x82\x04\xa4private",
+		CorrelationID: "c59e3crp82ke7bcnedq0",
+		SecretKey:     "secret",
+		DerivationKey: "derivation",
+	}
+	require.Nil(t, StoreSession(sessionInfo), "could not store session")
+	require.True(t, sessionInfo.Keychain, "could not mark keychain session")
+	require.Empty(t, sessionInfo.Token+sessionInfo.PrivateKey+sessionInfo.SecretKey+sessionInfo.DerivationKey, "could not remove secrets from session")
+
+	require.Nil(t, LoadSession(sessionInfo), "could not load session")
+	require.Equal(t, "token", sessionInfo.Token, "could not load token")
+	require.Equal(t, "\x30\x82\x04\xa4private", sessionInfo.PrivateKey, "could not load private key")
+	require.Equal(t, "secret", sessionInfo.SecretKey, "could not load secret key")
+	require.Equal(t, "derivation", sessionInfo.DerivationKey, "could not load derivation key")
+
+	require.Nil(t, DeleteSession(sessionInfo.CorrelationID), "could not delete session")
+	require.ErrorIs(t, LoadSession(sessionInfo), ErrNotFound, "could load deleted session")
+}
@@ -0,0 +1,50 @@
+//go:build linux || freebsd || openbsd || netbsd
+
+package keychain
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// secretToolProvider stores the secrets with the Secret Service of libsecret
+// (gnome-keyring, kwallet) through the secret-tool command
+type secretToolProvider struct{}
+
+func newProvider() provider {
+	return secretToolProvider{}
+}
+
+func (secretToolProvider) set(service, account, secret string) error {
+	// the secret is read from stdin, out of the arguments visible to the other processes
+	cmd := exec.Command("secret-tool", "store", "--label", service+" session "+account, "service", service, "account", account)
+	cmd.Stdin = strings.NewReader(secret)
+	if output, err := cmd.CombinedOutput(); err != nil {
+		return errors.Wrapf(err, "could not run secret-tool: %s", strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+func (secretToolProvider) get(service, account string) (string, error) {
+	var stdout bytes.Buffer
+	cmd := exec.Command("secret-tool", "lookup", "service", service, "account", account)
+	cmd.Stdout = &stdout
+	if err := cmd.Run(); err != nil {
+		// lookup fails without output when no secret matches the attributes
+		if _, ok := err.(*exec.ExitError); ok && stdout.Len() == 0 {
+			return "", ErrNotFound
+		}
+		return "", errors.Wrap(err, "could not run secret-tool")
+	}
+	return strings.TrimSuffix(stdout.String(), "\n"), nil
+}
+
+func (secretToolProvider) remove(service, account string) error {
+	if err := exec.Command("secret-tool", "clear", "service", service, "account", account).Run(); err != nil {
+		return errors.Wrap(err, "could not run secret-tool")
+	}
+	return nil
+}
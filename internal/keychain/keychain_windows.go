@@ -0,0 +1,117 @@
+package keychain
+
+import (
+	"syscall"
+	"unsafe"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// credTypeGeneric is the type of the generic credentials
+	credTypeGeneric = 1
+	// credPersistLocalMachine persists the credentials across the logon sessions of the user
+	credPersistLocalMachine = 2
+	// credMaxBlobSize is the maximum size of the secret of a credential
+	credMaxBlobSize = 5 * 512
+	// errorNotFound is the error of the credential functions when the credential doesn't exist
+	errorNotFound = syscall.Errno(1168)
+)
+
+var (
+	advapi32       = syscall.NewLazyDLL("advapi32.dll")
+	procCredWriteW = advapi32.NewProc("CredWriteW")
+	procCredReadW  = advapi32.NewProc("CredReadW")
+	procCredDelete = advapi32.NewProc("CredDeleteW")
+	procCredFree   = advapi32.NewProc("CredFree")
+)
+
+// credential is the CREDENTIALW structure of the Credential Manager
+type credential struct {
+	Flags              uint32
+	Type               uint32
+	TargetName         *uint16
+	Comment            *uint16
+	LastWritten        syscall.Filetime
+	CredentialBlobSize uint32
+	CredentialBlob     *byte
+	Persist            uint32
+	AttributeCount     uint32
+	Attributes         uintptr
+	TargetAlias        *uint16
+	UserName           *uint16
+}
+
+// credentialProvider stores the secrets as generic credentials of the Windows Credential Manager
+type credentialProvider struct{}
+
+func newProvider() provider {
+	return credentialProvider{}
+}
+
+// targetName returns the name of the credential of account
+func targetName(service, account string) (*uint16, error) {
+	return syscall.UTF16PtrFromString(service + ":" + account)
+}
+
+func (credentialProvider) set(service, account, secret string) error {
+	if len(secret) == 0 || len(secret) > credMaxBlobSize {
+		return errors.Errorf("secret size must be between 1 and %d bytes", credMaxBlobSize)
+	}
+	target, err := targetName(service, account)
+	if err != nil {
+		return err
+	}
+	userName, err := syscall.UTF16PtrFromString(account)
+	if err != nil {
+		return err
+	}
+	blob := []byte(secret)
+	cred := credential{
+		Type:               credTypeGeneric,
+		TargetName:         target,
+		CredentialBlobSize: uint32(len(blob)),
+		CredentialBlob:     &blob[0],
+		Persist:            credPersistLocalMachine,
+		UserName:           userName,
+	}
+	if ret, _, err := procCredWriteW.Call(uintptr(unsafe.Pointer(&cred)), 0); ret == 0 {
+		return errors.Wrap(err, "could not write credential")
+	}
+	return nil
+}
+
+func (credentialProvider) get(service, account string) (string, error) {
+	target, err := targetName(service, account)
+	if err != nil {
+		return "", err
+	}
+	var cred *credential
+	if ret, _, err := procCredReadW.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0, uintptr(unsafe.Pointer(&cred))); ret == 0 {
+		if err == errorNotFound {
+			return "", ErrNotFound
+		}
+		return "", errors.Wrap(err, "could not read credential")
+	}
+	defer procCredFree.Call(uintptr(unsafe.Pointer(cred)))
+
+	if cred.CredentialBlobSize == 0 {
+		return "", nil
+	}
+	blob := (*[credMaxBlobSize]byte)(unsafe.Pointer(cred.CredentialBlob))[:cred.CredentialBlobSize:cred.CredentialBlobSize]
+	return string(blob), nil
+}
+
+func (credentialProvider) remove(service, account string) error {
+	target, err := targetName(service, account)
+	if err != nil {
+		return err
+	}
+	if ret, _, err := procCredDelete.Call(uintptr(unsafe.Pointer(target)), credTypeGeneric, 0); ret == 0 {
+		if err == errorNotFound {
+			return ErrNotFound
+		}
+		return errors.Wrap(err, "could not delete credential")
+	}
+	return nil
+}
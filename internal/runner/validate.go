@@ -0,0 +1,216 @@
+package runner
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ValidateOptions contains the setup of a self-hosted server to validate
+type ValidateOptions struct {
+	// Domains are the domains delegated to the server
+	Domains []string
+	// IPAddress is the public ip address of the server
+	IPAddress string
+	// ListenIP is the ip address the server listens on
+	ListenIP string
+	// ListenPorts are the ports the services listen on, by service name,
+	// the services without port not being checked
+	ListenPorts map[string]int
+	// PublicPorts are the ports the services are reachable on from outside, by service name
+	PublicPorts map[string]int
+}
+
+// ValidationCheck is the result of a setup check
+type ValidationCheck struct {
+	Name    string
+	OK      bool
+	Message string
+}
+
+// validator performs the setup checks with replaceable network primitives
+type validator struct {
+	lookupNS   func(name string) ([]*net.NS, error)
+	lookupHost func(host string) ([]string, error)
+	listen     func(network, address string) (func() error, error)
+	dial       func(network, address string) error
+}
+
+var defaultValidator = &validator{
+	lookupNS:   net.LookupNS,
+	lookupHost: net.LookupHost,
+	listen: func(network, address string) (func() error, error) {
+		if network == "udp" {
+			conn, err := net.ListenPacket(network, address)
+			if err != nil {
+				return nil, err
+			}
+			return conn.Close, nil
+		}
+		listener, err := net.Listen(network, address)
+		if err != nil {
+			return nil, err
+		}
+		go acceptAndClose(listener)
+		return listener.Close, nil
+	},
+	dial: func(network, address string) error {
+		conn, err := net.DialTimeout(network, address, 5*time.Second)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	},
+}
+
+// acceptAndClose closes the connections accepted by a probe listener
+func acceptAndClose(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		_ = conn.Close()
+	}
+}
+
+// validatedServices are the services whose ports are checked, with their network
+var validatedServices = []struct {
+	service string
+	network string
+}{
+	{"dns", "udp"},
+	{"dns", "tcp"},
+	{"http", "tcp"},
+	{"https", "tcp"},
+	{"smtp", "tcp"},
+}
+
+// ValidateSetup checks the dns delegation of the domains to the server and
+// the reachability of the dns, http, https and smtp ports.
+func ValidateSetup(options *ValidateOptions) []ValidationCheck {
+	return defaultValidator.validate(options)
+}
+
+func (v *validator) validate(options *ValidateOptions) []ValidationCheck {
+	var checks []ValidationCheck
+	for _, domain := range options.Domains {
+		checks = append(checks, v.checkDelegation(strings.TrimSuffix(domain, "."), options.IPAddress)...)
+	}
+	for _, service := range validatedServices {
+		if _, ok := options.ListenPorts[service.service]; !ok {
+			continue
+		}
+		checks = append(checks, v.checkPort(service.service, service.network, options))
+	}
+	return checks
+}
+
+// checkDelegation checks that the domain is delegated to name servers whose
+// glue records point to the server ip address.
+func (v *validator) checkDelegation(domain, ipAddress string) []ValidationCheck {
+	name := fmt.Sprintf("NS delegation of %s", domain)
+	nameServers, err := v.lookupNS(domain)
+	if err != nil || len(nameServers) == 0 {
+		message := fmt.Sprintf("no NS records found for %s", domain)
+		if err != nil {
+			message = fmt.Sprintf("could not resolve NS records of %s (%s)", domain, err)
+		}
+		return []ValidationCheck{{
+			Name:    name,
+			Message: fmt.Sprintf("%s: at the registrar of %s, set the name servers to ns1.%s and ns2.%s and register glue records pointing them to %s", message, domain, domain, domain, ipAddress),
+		}}
+	}
+
+	hosts := make([]string, 0, len(nameServers))
+	for _, nameServer := range nameServers {
+		hosts = append(hosts, strings.TrimSuffix(nameServer.Host, "."))
+	}
+	sort.Strings(hosts)
+	checks := []ValidationCheck{{Name: name, OK: true, Message: fmt.Sprintf("delegated to %s", strings.Join(hosts, ", "))}}
+
+	for _, host := range hosts {
+		glueName := fmt.Sprintf("Glue A record of %s", host)
+		addresses, err := v.lookupHost(host)
+		switch {
+		case err != nil:
+			checks = append(checks, ValidationCheck{
+				Name:    glueName,
+				Message: fmt.Sprintf("could not resolve %s (%s): register a glue record for %s pointing to %s at the registrar of %s", host, err, host, ipAddress, domain),
+			})
+		case !containsAddress(addresses, ipAddress):
+			checks = append(checks, ValidationCheck{
+				Name:    glueName,
+				Message: fmt.Sprintf("%s resolves to %s instead of %s: update the glue record of %s at the registrar of %s", host, strings.Join(addresses, ", "), ipAddress, host, domain),
+			})
+		default:
+			checks = append(checks, ValidationCheck{Name: glueName, OK: true, Message: fmt.Sprintf("%s resolves to %s", host, ipAddress)})
+		}
+	}
+	return checks
+}
+
+// checkPort checks that the port of a service can be bound, and that the
+// server can reach it through its public ip address.
+func (v *validator) checkPort(service, network string, options *ValidateOptions) ValidationCheck {
+	listenPort := options.ListenPorts[service]
+	publicPort := listenPort
+	if port, ok := options.PublicPorts[service]; ok {
+		publicPort = port
+	}
+	check := ValidationCheck{Name: fmt.Sprintf("%s port %d/%s", strings.ToUpper(service), publicPort, network)}
+
+	closer, err := v.listen(network, net.JoinHostPort(options.ListenIP, strconv.Itoa(listenPort)))
+	if err != nil {
+		check.Message = fmt.Sprintf("could not listen on %s:%d (%s): stop the service using the port, run with the required privileges or use -unprivileged with port forwarding", options.ListenIP, listenPort, err)
+		return check
+	}
+	defer func() {
+		_ = closer()
+	}()
+
+	// a connectionless probe can't tell whether the datagrams are received
+	if network == "udp" {
+		check.OK = true
+		check.Message = fmt.Sprintf("listening on %s:%d, make sure the firewall allows incoming udp traffic on port %d", options.ListenIP, listenPort, publicPort)
+		return check
+	}
+	publicAddress := net.JoinHostPort(options.IPAddress, strconv.Itoa(publicPort))
+	if err := v.dial(network, publicAddress); err != nil {
+		check.Message = fmt.Sprintf("could not connect to %s (%s): allow incoming traffic on port %d in the firewall or security group, and forward it to port %d if behind NAT", publicAddress, err, publicPort, listenPort)
+		return check
+	}
+	check.OK = true
+	check.Message = fmt.Sprintf("reachable on %s", publicAddress)
+	return check
+}
+
+// containsAddress returns true if the address is one of the addresses
+func containsAddress(addresses []string, address string) bool {
+	ip := net.ParseIP(address)
+	for _, candidate := range addresses {
+		if candidateIP := net.ParseIP(candidate); candidateIP != nil && candidateIP.Equal(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// FormatValidation formats the results of the setup checks, returning
+// false if any of them failed.
+func FormatValidation(checks []ValidationCheck) (string, bool) {
+	var builder strings.Builder
+	valid := true
+	for _, check := range checks {
+		result := "Ok"
+		if !check.OK {
+			result = "Ko"
+			valid = false
+		}
+		builder.WriteString(fmt.Sprintf("%s => %s (%s)\n", check.Name, result, check.Message))
+	}
+	return builder.String(), valid
+}
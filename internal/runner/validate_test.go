@@ -0,0 +1,69 @@
+package runner
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateSetup(t *testing.T) {
+	var dialed []string
+	v := &validator{
+		lookupNS: func(name string) ([]*net.NS, error) {
+			if name == "oast.example" {
+				return []*net.NS{{Host: "ns2.oast.example."}, {Host: "ns1.oast.example."}}, nil
+			}
+			return nil, errors.New("no such host")
+		},
+		lookupHost: func(host string) ([]string, error) {
+			if host == "ns1.oast.example" {
+				return []string{"203.0.113.10"}, nil
+			}
+			return []string{"203.0.113.20"}, nil
+		},
+		listen: func(network, address string) (func() error, error) {
+			if strings.HasSuffix(address, ":2525") {
+				return nil, errors.New("address already in use")
+			}
+			return func() error { return nil }, nil
+		},
+		dial: func(network, address string) error {
+			dialed = append(dialed, address)
+			if strings.HasSuffix(address, ":443") {
+				return errors.New("connection refused")
+			}
+			return nil
+		},
+	}
+
+	checks := v.validate(&ValidateOptions{
+		Domains:     []string{"oast.example", "missing.example"},
+		IPAddress:   "203.0.113.10",
+		ListenIP:    "0.0.0.0",
+		ListenPorts: map[string]int{"dns": 53, "http": 8080, "https": 443, "smtp": 2525},
+		PublicPorts: map[string]int{"http": 80, "smtp": 25},
+	})
+	results := make(map[string]ValidationCheck)
+	for _, check := range checks {
+		results[check.Name] = check
+	}
+
+	require.True(t, results["NS delegation of oast.example"].OK, "could not validate delegation")
+	require.True(t, results["Glue A record of ns1.oast.example"].OK, "could not validate glue record")
+	require.False(t, results["Glue A record of ns2.oast.example"].OK, "could not detect wrong glue record")
+	require.False(t, results["NS delegation of missing.example"].OK, "could not detect missing delegation")
+	require.Contains(t, results["NS delegation of missing.example"].Message, "ns1.missing.example", "could not suggest name servers")
+
+	require.True(t, results["DNS port 53/udp"].OK, "could not validate dns udp port")
+	require.True(t, results["HTTP port 80/tcp"].OK, "could not validate forwarded http port")
+	require.False(t, results["HTTPS port 443/tcp"].OK, "could not detect unreachable port")
+	require.False(t, results["SMTP port 25/tcp"].OK, "could not detect unbindable port")
+	require.Contains(t, dialed, "203.0.113.10:80", "could not dial the public port")
+
+	output, valid := FormatValidation(checks)
+	require.False(t, valid, "could not report failed checks")
+	require.Contains(t, output, "HTTPS port 443/tcp => Ko", "could not format failed check")
+}
@@ -0,0 +1,138 @@
+// Package service runs the server as a service managed by the operating system,
+// with systemd on linux, launchd on macOS and the service control manager on
+// Windows.
+package service
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/pkg/errors"
+)
+
+// Actions of the service command
+const (
+	ActionInstall   = "install"
+	ActionUninstall = "uninstall"
+	ActionStart     = "start"
+	ActionStop      = "stop"
+	ActionStatus    = "status"
+	ActionUnit      = "unit"
+)
+
+// Actions are the supported actions of the service command
+var Actions = []string{ActionInstall, ActionUninstall, ActionStart, ActionStop, ActionStatus, ActionUnit}
+
+// Config is the configuration of the service
+type Config struct {
+	// Name is the name of the service
+	Name string
+	// Description is the description of the service
+	Description string
+	// Executable is the path of the server executable
+	Executable string
+	// Args are the arguments of the server
+	Args []string
+	// LogFile receives the output of the server, the default of the service
+	// manager being used if empty.
+	LogFile string
+}
+
+// DefaultConfig returns the configuration of the service running the current
+// executable with args.
+func DefaultConfig(args []string) (*Config, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return nil, errors.Wrap(err, "could not get executable path")
+	}
+	return &Config{
+		Name:        "interactsh-server",
+		Description: "Interactsh server",
+		Executable:  executable,
+		Args:        args,
+		LogFile:     defaultLogFile(),
+	}, nil
+}
+
+// Control performs an action of the service command, returning its output
+func Control(action string, config *Config) (string, error) {
+	switch action {
+	case ActionInstall:
+		return "", install(config)
+	case ActionUninstall:
+		return "", uninstall(config)
+	case ActionStart:
+		return "", start(config)
+	case ActionStop:
+		return "", stop(config)
+	case ActionStatus:
+		return status(config)
+	case ActionUnit:
+		return unit(config)
+	default:
+		return "", errors.Errorf("unknown action %s, must be one of %s", action, strings.Join(Actions, ", "))
+	}
+}
+
+var (
+	stopOnce    sync.Once
+	stopChan    = make(chan struct{})
+	doneOnce    sync.Once
+	doneChan    = make(chan struct{})
+	managerDone = make(chan struct{})
+)
+
+// Run prepares the server to run in the foreground or under the service manager,
+// the stop requests being reported by StopRequested.
+func Run(name string) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-signals
+		requestStop()
+	}()
+	if !runManaged(name) {
+		close(managerDone)
+	}
+}
+
+// StopRequested returns a channel closed when the server is requested to stop,
+// by SIGINT, SIGTERM or the service manager.
+func StopRequested() <-chan struct{} {
+	return stopChan
+}
+
+// Stopped reports to the service manager that the server has stopped
+func Stopped() {
+	doneOnce.Do(func() { close(doneChan) })
+	<-managerDone
+}
+
+// requestStop closes the stop channel
+func requestStop() {
+	stopOnce.Do(func() { close(stopChan) })
+}
+
+// RedirectLogs appends the output of the server, including the logs, to path
+func RedirectLogs(path string) error {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return errors.Wrap(err, "could not open log file")
+	}
+	os.Stdout = file
+	os.Stderr = file
+	return nil
+}
+
+// runCommand runs a command of the service manager, returning its output
+func runCommand(name string, args ...string) (string, error) {
+	output, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		return string(output), errors.Wrapf(err, "could not run %s %s: %s", name, strings.Join(args, " "), strings.TrimSpace(string(output)))
+	}
+	return string(output), nil
+}
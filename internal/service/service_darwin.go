@@ -0,0 +1,76 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// launchdPlistPath returns the path of the launchd property list of the service
+func launchdPlistPath(config *Config) string {
+	return filepath.Join("/Library/LaunchDaemons", LaunchdLabel(config)+".plist")
+}
+
+func defaultLogFile() string {
+	return "/usr/local/var/log/interactsh-server.log"
+}
+
+func install(config *Config) error {
+	path := launchdPlistPath(config)
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("service %s already exists", config.Name)
+	}
+	if config.LogFile != "" {
+		if err := os.MkdirAll(filepath.Dir(config.LogFile), 0755); err != nil {
+			return errors.Wrap(err, "could not create log folder")
+		}
+	}
+	if err := os.WriteFile(path, []byte(LaunchdPlist(config)), 0644); err != nil {
+		return errors.Wrap(err, "could not write launchd plist")
+	}
+	_, err := runCommand("launchctl", "load", "-w", path)
+	return err
+}
+
+func uninstall(config *Config) error {
+	path := launchdPlistPath(config)
+	if _, err := runCommand("launchctl", "unload", "-w", path); err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil {
+		return errors.Wrap(err, "could not remove launchd plist")
+	}
+	return nil
+}
+
+func start(config *Config) error {
+	_, err := runCommand("launchctl", "start", LaunchdLabel(config))
+	return err
+}
+
+func stop(config *Config) error {
+	_, err := runCommand("launchctl", "stop", LaunchdLabel(config))
+	return err
+}
+
+func status(config *Config) (string, error) {
+	output, err := runCommand("launchctl", "list", LaunchdLabel(config))
+	if err != nil {
+		return "not installed", nil
+	}
+	if strings.Contains(output, `"PID" =`) {
+		return "running", nil
+	}
+	return "stopped", nil
+}
+
+func unit(config *Config) (string, error) {
+	return LaunchdPlist(config), nil
+}
+
+func runManaged(name string) bool {
+	// launchd stops the jobs with SIGTERM
+	return false
+}
@@ -0,0 +1,73 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// systemdUnitPath returns the path of the systemd unit of the service
+func systemdUnitPath(config *Config) string {
+	return filepath.Join("/etc/systemd/system", config.Name+".service")
+}
+
+func defaultLogFile() string {
+	// the output is kept by journald
+	return ""
+}
+
+func install(config *Config) error {
+	path := systemdUnitPath(config)
+	if _, err := os.Stat(path); err == nil {
+		return errors.Errorf("service %s already exists", config.Name)
+	}
+	if err := os.WriteFile(path, []byte(SystemdUnit(config)), 0644); err != nil {
+		return errors.Wrap(err, "could not write systemd unit")
+	}
+	if _, err := runCommand("systemctl", "daemon-reload"); err != nil {
+		return err
+	}
+	_, err := runCommand("systemctl", "enable", config.Name)
+	return err
+}
+
+func uninstall(config *Config) error {
+	if _, err := runCommand("systemctl", "disable", "--now", config.Name); err != nil {
+		return err
+	}
+	if err := os.Remove(systemdUnitPath(config)); err != nil {
+		return errors.Wrap(err, "could not remove systemd unit")
+	}
+	_, err := runCommand("systemctl", "daemon-reload")
+	return err
+}
+
+func start(config *Config) error {
+	_, err := runCommand("systemctl", "start", config.Name)
+	return err
+}
+
+func stop(config *Config) error {
+	_, err := runCommand("systemctl", "stop", config.Name)
+	return err
+}
+
+func status(config *Config) (string, error) {
+	// is-active exits with a non-zero status for the inactive services
+	output, err := runCommand("systemctl", "is-active", config.Name)
+	if output = strings.TrimSpace(output); output != "" {
+		return output, nil
+	}
+	return "", err
+}
+
+func unit(config *Config) (string, error) {
+	return SystemdUnit(config), nil
+}
+
+func runManaged(name string) bool {
+	// systemd stops the services with SIGTERM
+	return false
+}
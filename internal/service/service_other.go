@@ -0,0 +1,44 @@
+//go:build !linux && !darwin && !windows
+
+package service
+
+import (
+	"runtime"
+
+	"github.com/pkg/errors"
+)
+
+// errUnsupported is returned by the actions on the platforms without service manager support
+var errUnsupported = errors.Errorf("service management is not supported on %s", runtime.GOOS)
+
+func defaultLogFile() string {
+	return ""
+}
+
+func install(config *Config) error {
+	return errUnsupported
+}
+
+func uninstall(config *Config) error {
+	return errUnsupported
+}
+
+func start(config *Config) error {
+	return errUnsupported
+}
+
+func stop(config *Config) error {
+	return errUnsupported
+}
+
+func status(config *Config) (string, error) {
+	return "", errUnsupported
+}
+
+func unit(config *Config) (string, error) {
+	return "", errUnsupported
+}
+
+func runManaged(name string) bool {
+	return false
+}
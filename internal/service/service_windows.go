@@ -0,0 +1,197 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/pkg/errors"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+// stopTimeout is the time waited for the service to stop
+const stopTimeout = 30 * time.Second
+
+func defaultLogFile() string {
+	return filepath.Join(os.Getenv("ProgramData"), "interactsh", "interactsh-server.log")
+}
+
+// openService connects to the service manager and opens the service
+func openService(name string) (*mgr.Mgr, *mgr.Service, error) {
+	m, err := mgr.Connect()
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "could not connect to the service manager")
+	}
+	s, err := m.OpenService(name)
+	if err != nil {
+		_ = m.Disconnect()
+		return nil, nil, errors.Wrapf(err, "could not open service %s", name)
+	}
+	return m, s, nil
+}
+
+func install(config *Config) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return errors.Wrap(err, "could not connect to the service manager")
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(config.Name); err == nil {
+		s.Close()
+		return errors.Errorf("service %s already exists", config.Name)
+	}
+	// the service manager doesn't redirect the output of the services
+	args := config.Args
+	if config.LogFile != "" {
+		if err := os.MkdirAll(filepath.Dir(config.LogFile), 0755); err != nil {
+			return errors.Wrap(err, "could not create log folder")
+		}
+		args = append([]string{"-log-file", config.LogFile}, args...)
+	}
+	s, err := m.CreateService(config.Name, config.Executable, mgr.Config{
+		DisplayName: config.Description,
+		Description: config.Description,
+		StartType:   mgr.StartAutomatic,
+	}, args...)
+	if err != nil {
+		return errors.Wrap(err, "could not create service")
+	}
+	defer s.Close()
+
+	// the service is restarted if it fails
+	if err := s.SetRecoveryActions([]mgr.RecoveryAction{{Type: mgr.ServiceRestart, Delay: 5 * time.Second}}, 60); err != nil {
+		return errors.Wrap(err, "could not set recovery actions")
+	}
+	return nil
+}
+
+func uninstall(config *Config) error {
+	m, s, err := openService(config.Name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	if state, err := s.Query(); err == nil && state.State != svc.Stopped {
+		if err := stopService(s); err != nil {
+			return err
+		}
+	}
+	if err := s.Delete(); err != nil {
+		return errors.Wrap(err, "could not delete service")
+	}
+	return nil
+}
+
+func start(config *Config) error {
+	m, s, err := openService(config.Name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	if err := s.Start(); err != nil {
+		return errors.Wrap(err, "could not start service")
+	}
+	return nil
+}
+
+func stop(config *Config) error {
+	m, s, err := openService(config.Name)
+	if err != nil {
+		return err
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	return stopService(s)
+}
+
+// stopService stops a service, waiting until it has stopped
+func stopService(s *mgr.Service) error {
+	state, err := s.Control(svc.Stop)
+	if err != nil {
+		return errors.Wrap(err, "could not stop service")
+	}
+	deadline := time.Now().Add(stopTimeout)
+	for state.State != svc.Stopped {
+		if time.Now().After(deadline) {
+			return errors.New("timeout waiting for the service to stop")
+		}
+		time.Sleep(300 * time.Millisecond)
+		if state, err = s.Query(); err != nil {
+			return errors.Wrap(err, "could not query service")
+		}
+	}
+	return nil
+}
+
+func status(config *Config) (string, error) {
+	m, s, err := openService(config.Name)
+	if err != nil {
+		return "not installed", nil
+	}
+	defer m.Disconnect()
+	defer s.Close()
+
+	state, err := s.Query()
+	if err != nil {
+		return "", errors.Wrap(err, "could not query service")
+	}
+	switch state.State {
+	case svc.Running:
+		return "running", nil
+	case svc.StartPending:
+		return "starting", nil
+	case svc.StopPending:
+		return "stopping", nil
+	case svc.Stopped:
+		return "stopped", nil
+	default:
+		return "paused", nil
+	}
+}
+
+func unit(config *Config) (string, error) {
+	return "", errors.New("the windows services have no unit file, use install")
+}
+
+// runManaged runs the dispatcher of the service manager if the server has been
+// started as a windows service, returning false otherwise.
+func runManaged(name string) bool {
+	if isService, err := svc.IsWindowsService(); err != nil || !isService {
+		return false
+	}
+	go func() {
+		defer close(managerDone)
+		_ = svc.Run(name, &handler{})
+	}()
+	return true
+}
+
+// handler handles the requests of the service manager
+type handler struct{}
+
+// Execute reports the status of the server to the service manager and stops it on request
+func (h *handler) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	const accepted = svc.AcceptStop | svc.AcceptShutdown
+	changes <- svc.Status{State: svc.Running, Accepts: accepted}
+	for {
+		select {
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending}
+				requestStop()
+			}
+		case <-doneChan:
+			return false, 0
+		}
+	}
+}
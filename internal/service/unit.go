@@ -0,0 +1,80 @@
+package service
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"strings"
+)
+
+// SystemdUnit returns the systemd unit of the service
+func SystemdUnit(config *Config) string {
+	var b strings.Builder
+	b.WriteString("[Unit]\n")
+	fmt.Fprintf(&b, "Description=%s\n", config.Description)
+	b.WriteString("After=network-online.target\n")
+	b.WriteString("Wants=network-online.target\n\n")
+	b.WriteString("[Service]\n")
+	b.WriteString("Type=simple\n")
+	fmt.Fprintf(&b, "ExecStart=%s\n", systemdCommandLine(append([]string{config.Executable}, config.Args...)))
+	b.WriteString("ExecReload=/bin/kill -HUP $MAINPID\n")
+	b.WriteString("Restart=on-failure\n")
+	b.WriteString("RestartSec=5\n")
+	b.WriteString("AmbientCapabilities=CAP_NET_BIND_SERVICE\n")
+	if config.LogFile != "" {
+		fmt.Fprintf(&b, "StandardOutput=append:%s\n", config.LogFile)
+		fmt.Fprintf(&b, "StandardError=append:%s\n", config.LogFile)
+	}
+	b.WriteString("\n[Install]\n")
+	b.WriteString("WantedBy=multi-user.target\n")
+	return b.String()
+}
+
+// systemdCommandLine quotes the arguments of a command line of a systemd unit
+func systemdCommandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg != "" && !strings.ContainsAny(arg, " \t\"'\\$%;") {
+			quoted[i] = arg
+			continue
+		}
+		arg = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", "$$", "%", "%%").Replace(arg)
+		quoted[i] = `"` + arg + `"`
+	}
+	return strings.Join(quoted, " ")
+}
+
+// LaunchdLabel returns the label of the launchd job of the service
+func LaunchdLabel(config *Config) string {
+	return "io.projectdiscovery." + config.Name
+}
+
+// LaunchdPlist returns the launchd property list of the service
+func LaunchdPlist(config *Config) string {
+	var b strings.Builder
+	b.WriteString(xml.Header)
+	b.WriteString(`<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">` + "\n")
+	b.WriteString("<plist version=\"1.0\">\n<dict>\n")
+	fmt.Fprintf(&b, "\t<key>Label</key>\n\t<string>%s</string>\n", xmlEscape(LaunchdLabel(config)))
+	b.WriteString("\t<key>ProgramArguments</key>\n\t<array>\n")
+	for _, arg := range append([]string{config.Executable}, config.Args...) {
+		fmt.Fprintf(&b, "\t\t<string>%s</string>\n", xmlEscape(arg))
+	}
+	b.WriteString("\t</array>\n")
+	b.WriteString("\t<key>RunAtLoad</key>\n\t<true/>\n")
+	// the server is restarted unless it has been stopped
+	b.WriteString("\t<key>KeepAlive</key>\n\t<dict>\n\t\t<key>SuccessfulExit</key>\n\t\t<false/>\n\t</dict>\n")
+	if config.LogFile != "" {
+		fmt.Fprintf(&b, "\t<key>StandardOutPath</key>\n\t<string>%s</string>\n", xmlEscape(config.LogFile))
+		fmt.Fprintf(&b, "\t<key>StandardErrorPath</key>\n\t<string>%s</string>\n", xmlEscape(config.LogFile))
+	}
+	b.WriteString("</dict>\n</plist>\n")
+	return b.String()
+}
+
+// xmlEscape escapes the special characters of a xml text
+func xmlEscape(value string) string {
+	var b bytes.Buffer
+	_ = xml.EscapeText(&b, []byte(value))
+	return b.String()
+}
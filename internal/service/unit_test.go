@@ -0,0 +1,42 @@
+package service
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSystemdUnit(t *testing.T) {
+	config := &Config{
+		Name:        "interactsh-server",
+		Description: "Interactsh server",
+		Executable:  "/usr/local/bin/interactsh-server",
+		Args:        []string{"-d", "oast.example", "-http-index", "/srv/my index.html", "-token", "a$b"},
+		LogFile:     "/var/log/interactsh-server.log",
+	}
+	unit := SystemdUnit(config)
+	require.Contains(t, unit, `ExecStart=/usr/local/bin/interactsh-server -d oast.example -http-index "/srv/my index.html" -token "a$$b"`+"\n", "could not quote arguments")
+	require.Contains(t, unit, "StandardOutput=append:/var/log/interactsh-server.log\n", "could not redirect output")
+
+	config.LogFile = ""
+	require.NotContains(t, SystemdUnit(config), "StandardOutput", "could redirect output without log file")
+}
+
+func TestLaunchdPlist(t *testing.T) {
+	config := &Config{
+		Name:       "interactsh-server",
+		Executable: "/usr/local/bin/interactsh-server",
+		Args:       []string{"-d", "oast.example", "-http-index", "<index>&.html"},
+		LogFile:    "/usr/local/var/log/interactsh-server.log",
+	}
+	plist := LaunchdPlist(config)
+	require.Contains(t, plist, "<string>io.projectdiscovery.interactsh-server</string>", "could not set label")
+	require.Contains(t, plist, "<string>&lt;index&gt;&amp;.html</string>", "could not escape arguments")
+	require.Equal(t, 2, strings.Count(plist, config.LogFile), "could not redirect output")
+}
+
+func TestControlUnknownAction(t *testing.T) {
+	_, err := Control("restart", &Config{Name: "interactsh-server"})
+	require.NotNil(t, err, "could run unknown action")
+}
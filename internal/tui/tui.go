@@ -0,0 +1,267 @@
+// Package tui implements a minimal terminal interface to browse interactions
+// received by the client. It relies only on ANSI escape sequences and line
+// based commands so that it works on any terminal without raw mode.
+package tui
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+const (
+	clearScreen   = "\033[H\033[2J"
+	reverseVideo  = "\033[7m"
+	resetGraphics = "\033[0m"
+	bold          = "\033[1m"
+
+	defaultTableRows  = 15
+	defaultDetailRows = 20
+)
+
+const help = "[n]ext [p]rev [<num>] select [/text] filter [c]lear [f]ollow [q]uit"
+
+// Viewer is a live updating table of interactions with a detail pane.
+type Viewer struct {
+	sync.Mutex
+	out          io.Writer
+	in           io.Reader
+	payloads     []string
+	interactions []*server.Interaction
+	filtered     []*server.Interaction
+	filter       string
+	selected     int
+	follow       bool
+	tableRows    int
+	detailRows   int
+	status       string
+}
+
+// New creates a new viewer writing to out and reading commands from in.
+// The payloads are displayed on top of the screen as a reminder.
+func New(out io.Writer, in io.Reader, payloads []string) *Viewer {
+	viewer := &Viewer{
+		out:        out,
+		in:         in,
+		payloads:   payloads,
+		follow:     true,
+		tableRows:  defaultTableRows,
+		detailRows: defaultDetailRows,
+	}
+	// LINES is exported by most shells, use it to size the panes
+	if lines, err := strconv.Atoi(os.Getenv("LINES")); err == nil && lines > 12+len(payloads) {
+		available := lines - 6 - len(payloads)
+		viewer.tableRows = available / 2
+		viewer.detailRows = available - viewer.tableRows
+	}
+	return viewer
+}
+
+// Add appends an interaction and refreshes the screen.
+func (v *Viewer) Add(interaction *server.Interaction) {
+	v.Lock()
+	defer v.Unlock()
+
+	v.interactions = append(v.interactions, interaction)
+	if v.matches(interaction) {
+		v.filtered = append(v.filtered, interaction)
+		if v.follow {
+			v.selected = len(v.filtered) - 1
+		}
+	}
+	v.render()
+}
+
+// Run processes user commands until the user quits or the input is closed.
+func (v *Viewer) Run() {
+	v.Lock()
+	v.render()
+	v.Unlock()
+
+	scanner := bufio.NewScanner(v.in)
+	for scanner.Scan() {
+		if quit := v.command(strings.TrimSpace(scanner.Text())); quit {
+			return
+		}
+	}
+}
+
+// command executes a single user command and returns true on quit
+func (v *Viewer) command(cmd string) bool {
+	v.Lock()
+	defer v.Unlock()
+
+	v.status = ""
+	switch {
+	case cmd == "q":
+		return true
+	case cmd == "" || cmd == "n":
+		v.move(1)
+	case cmd == "p":
+		v.move(-1)
+	case cmd == "f":
+		v.follow = !v.follow
+		if v.follow {
+			v.selected = len(v.filtered) - 1
+		}
+	case cmd == "c":
+		v.setFilter("")
+	case strings.HasPrefix(cmd, "/"):
+		v.setFilter(strings.TrimPrefix(cmd, "/"))
+	default:
+		index, err := strconv.Atoi(cmd)
+		if err != nil || index < 1 || index > len(v.filtered) {
+			v.status = fmt.Sprintf("unknown command: %s", cmd)
+			break
+		}
+		v.follow = false
+		v.selected = index - 1
+	}
+	v.render()
+	return false
+}
+
+func (v *Viewer) move(delta int) {
+	v.follow = false
+	v.selected += delta
+	if v.selected >= len(v.filtered) {
+		v.selected = len(v.filtered) - 1
+	}
+	if v.selected < 0 {
+		v.selected = 0
+	}
+}
+
+func (v *Viewer) setFilter(filter string) {
+	v.filter = strings.ToLower(filter)
+	v.filtered = nil
+	for _, interaction := range v.interactions {
+		if v.matches(interaction) {
+			v.filtered = append(v.filtered, interaction)
+		}
+	}
+	v.selected = len(v.filtered) - 1
+}
+
+// matches returns true if the interaction contains the filter text
+func (v *Viewer) matches(interaction *server.Interaction) bool {
+	if v.filter == "" {
+		return true
+	}
+	for _, field := range []string{interaction.Protocol, interaction.FullId, interaction.RemoteAddress, interaction.QType, interaction.RawRequest} {
+		if strings.Contains(strings.ToLower(field), v.filter) {
+			return true
+		}
+	}
+	return false
+}
+
+func (v *Viewer) render() {
+	var builder strings.Builder
+	builder.WriteString(clearScreen)
+	for _, payload := range v.payloads {
+		builder.WriteString(fmt.Sprintf("Payload: %s\n", payload))
+	}
+	builder.WriteString(bold)
+	builder.WriteString(fmt.Sprintf("Interactions: %d", len(v.interactions)))
+	if v.filter != "" {
+		builder.WriteString(fmt.Sprintf(" (filter: %q, %d matching)", v.filter, len(v.filtered)))
+	}
+	if v.follow {
+		builder.WriteString(" [following]")
+	}
+	builder.WriteString(resetGraphics)
+	builder.WriteString("\n")
+	builder.WriteString(fmt.Sprintf("%-5s %-19s %-9s %-5s %-40s %s\n", "#", "TIME", "PROTOCOL", "TYPE", "ID", "REMOTE ADDRESS"))
+
+	// keep the selected row visible in the table window
+	start := 0
+	if v.selected >= v.tableRows {
+		start = v.selected - v.tableRows + 1
+	}
+	end := start + v.tableRows
+	if end > len(v.filtered) {
+		end = len(v.filtered)
+	}
+	for i := start; i < end; i++ {
+		interaction := v.filtered[i]
+		row := fmt.Sprintf("%-5d %-19s %-9s %-5s %-40s %s", i+1, interaction.Timestamp.Format("2006-01-02 15:04:05"), sanitize(interaction.Protocol), sanitize(interaction.QType), truncate(sanitize(interaction.FullId), 40), sanitize(interaction.RemoteAddress))
+		if i == v.selected {
+			row = reverseVideo + row + resetGraphics
+		}
+		builder.WriteString(row)
+		builder.WriteString("\n")
+	}
+	for i := end - start; i < v.tableRows; i++ {
+		builder.WriteString("\n")
+	}
+
+	builder.WriteString(strings.Repeat("-", 80))
+	builder.WriteString("\n")
+	if v.selected >= 0 && v.selected < len(v.filtered) {
+		builder.WriteString(detail(v.filtered[v.selected], v.detailRows))
+	}
+	builder.WriteString(strings.Repeat("-", 80))
+	builder.WriteString("\n")
+	if v.status != "" {
+		builder.WriteString(sanitize(v.status))
+		builder.WriteString("\n")
+	}
+	builder.WriteString(help)
+	builder.WriteString("\n> ")
+	_, _ = io.WriteString(v.out, builder.String())
+}
+
+// detail returns the detail pane content for an interaction
+func detail(interaction *server.Interaction, rows int) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%s interaction from %s at %s\n", sanitize(strings.ToUpper(interaction.Protocol)), sanitize(interaction.RemoteAddress), interaction.Timestamp.Format("2006-01-02 15:04:05")))
+	if interaction.SMTPFrom != "" {
+		builder.WriteString(fmt.Sprintf("From: %s\n", sanitize(interaction.SMTPFrom)))
+	}
+	lines := strings.Split(strings.ReplaceAll(interaction.RawRequest, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		if i >= rows-1 {
+			builder.WriteString(fmt.Sprintf("... %d more lines\n", len(lines)-i))
+			break
+		}
+		builder.WriteString(sanitize(line))
+		builder.WriteString("\n")
+	}
+	return builder.String()
+}
+
+// sanitize escapes the control characters of the values sent by the remote
+// hosts, which could otherwise inject escape sequences (ANSI, OSC) in the terminal
+func sanitize(value string) string {
+	if strings.IndexFunc(value, isControl) == -1 {
+		return value
+	}
+	var builder strings.Builder
+	for _, r := range value {
+		if isControl(r) {
+			builder.WriteString(fmt.Sprintf("\\x%02x", r))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func isControl(r rune) bool {
+	return r != '\t' && unicode.IsControl(r)
+}
+
+func truncate(value string, size int) string {
+	if len(value) > size {
+		return value[:size-3] + "..."
+	}
+	return value
+}
@@ -0,0 +1,200 @@
+package tui
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+)
+
+func newTestViewer() (*Viewer, *bytes.Buffer) {
+	out := &bytes.Buffer{}
+	viewer := New(out, strings.NewReader(""), []string{"c8rf4e8xm4c8rf4e8xm4abcdefghijklm.oast.example"})
+	for _, interaction := range []*server.Interaction{
+		{Protocol: "dns", QType: "A", FullId: "first", RemoteAddress: "192.0.2.1", RawRequest: "first query"},
+		{Protocol: "http", FullId: "second", RemoteAddress: "192.0.2.2", RawRequest: "GET / HTTP/1.1\r\nHost: second\r\n"},
+		{Protocol: "smtp", FullId: "third", RemoteAddress: "198.51.100.3", SMTPFrom: "user@example.com", RawRequest: "HELO example.com"},
+	} {
+		viewer.Add(interaction)
+	}
+	return viewer, out
+}
+
+func TestCommand(t *testing.T) {
+	tests := []struct {
+		name     string
+		commands []string
+		quit     bool
+		selected int
+		follow   bool
+		filtered int
+		status   string
+	}{
+		{name: "quit", commands: []string{"q"}, quit: true, selected: 2, follow: true, filtered: 3},
+		{name: "previous", commands: []string{"p"}, selected: 1, filtered: 3},
+		{name: "next", commands: []string{"p", "p", "n"}, selected: 1, filtered: 3},
+		{name: "empty", commands: []string{"p", ""}, selected: 2, filtered: 3},
+		{name: "select", commands: []string{"1"}, selected: 0, filtered: 3},
+		{name: "select out of range", commands: []string{"4"}, selected: 2, follow: true, filtered: 3, status: "unknown command: 4"},
+		{name: "unknown", commands: []string{"x"}, selected: 2, follow: true, filtered: 3, status: "unknown command: x"},
+		{name: "follow", commands: []string{"1", "f"}, selected: 2, follow: true, filtered: 3},
+		{name: "unfollow", commands: []string{"f"}, selected: 2, filtered: 3},
+		{name: "filter", commands: []string{"/HTTP"}, selected: 0, follow: true, filtered: 1},
+		{name: "clear", commands: []string{"/http", "c"}, selected: 2, follow: true, filtered: 3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			viewer, _ := newTestViewer()
+			var quit bool
+			for _, cmd := range test.commands {
+				quit = viewer.command(cmd)
+			}
+			require.Equal(t, test.quit, quit, "could not get quit")
+			require.Equal(t, test.selected, viewer.selected, "could not get selected interaction")
+			require.Equal(t, test.follow, viewer.follow, "could not get follow mode")
+			require.Len(t, viewer.filtered, test.filtered, "could not get filtered interactions")
+			require.Equal(t, test.status, viewer.status, "could not get status")
+		})
+	}
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		filter   string
+		expected []string
+		added    bool
+	}{
+		{filter: "", expected: []string{"first", "second", "third"}, added: true},
+		{filter: "DNS", expected: []string{"first"}, added: true},
+		{filter: "third", expected: []string{"third"}, added: true},
+		{filter: "192.0.2", expected: []string{"first", "second"}},
+		{filter: "a", expected: []string{"first", "third"}, added: true},
+		{filter: "host: second", expected: []string{"second"}},
+		{filter: "missing", expected: nil},
+	}
+	for _, test := range tests {
+		t.Run(test.filter, func(t *testing.T) {
+			viewer, _ := newTestViewer()
+			viewer.setFilter(test.filter)
+			var ids []string
+			for _, interaction := range viewer.filtered {
+				ids = append(ids, interaction.FullId)
+			}
+			require.Equal(t, test.expected, ids, "could not filter interactions")
+			require.Equal(t, len(test.expected)-1, viewer.selected, "could not select last interaction")
+
+			viewer.Add(&server.Interaction{Protocol: "dns", QType: "A", FullId: "third-dns", RawRequest: "second"})
+			added := len(viewer.filtered) > len(test.expected)
+			require.Equal(t, test.added, added, "could not filter added interaction")
+		})
+	}
+}
+
+func TestMove(t *testing.T) {
+	tests := []struct {
+		name     string
+		selected int
+		delta    int
+		expected int
+	}{
+		{name: "next", selected: 0, delta: 1, expected: 1},
+		{name: "previous", selected: 2, delta: -1, expected: 1},
+		{name: "after last", selected: 2, delta: 1, expected: 2},
+		{name: "before first", selected: 0, delta: -1, expected: 0},
+		{name: "far", selected: 1, delta: 10, expected: 2},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			viewer, _ := newTestViewer()
+			viewer.selected = test.selected
+			viewer.move(test.delta)
+			require.Equal(t, test.expected, viewer.selected, "could not move selection")
+			require.False(t, viewer.follow, "could follow after move")
+		})
+	}
+
+	viewer := New(&bytes.Buffer{}, strings.NewReader(""), nil)
+	viewer.move(1)
+	require.Equal(t, 0, viewer.selected, "could not move in empty viewer")
+}
+
+func TestDetail(t *testing.T) {
+	timestamp := time.Date(2022, 9, 1, 10, 20, 30, 0, time.UTC)
+	tests := []struct {
+		name        string
+		interaction *server.Interaction
+		rows        int
+		expected    string
+	}{
+		{
+			name:        "dns",
+			interaction: &server.Interaction{Protocol: "dns", RemoteAddress: "192.0.2.1", Timestamp: timestamp, RawRequest: "query"},
+			rows:        5,
+			expected:    "DNS interaction from 192.0.2.1 at 2022-09-01 10:20:30\nquery\n",
+		},
+		{
+			name:        "smtp",
+			interaction: &server.Interaction{Protocol: "smtp", RemoteAddress: "192.0.2.1", Timestamp: timestamp, SMTPFrom: "user@example.com", RawRequest: "HELO"},
+			rows:        5,
+			expected:    "SMTP interaction from 192.0.2.1 at 2022-09-01 10:20:30\nFrom: user@example.com\nHELO\n",
+		},
+		{
+			name:        "crlf",
+			interaction: &server.Interaction{Protocol: "http", RemoteAddress: "192.0.2.1", Timestamp: timestamp, RawRequest: "GET / HTTP/1.1\r\nHost: example.com"},
+			rows:        5,
+			expected:    "HTTP interaction from 192.0.2.1 at 2022-09-01 10:20:30\nGET / HTTP/1.1\nHost: example.com\n",
+		},
+		{
+			name:        "truncated",
+			interaction: &server.Interaction{Protocol: "http", RemoteAddress: "192.0.2.1", Timestamp: timestamp, RawRequest: "1\n2\n3\n4\n5"},
+			rows:        3,
+			expected:    "HTTP interaction from 192.0.2.1 at 2022-09-01 10:20:30\n1\n2\n... 3 more lines\n",
+		},
+		{
+			name:        "escape sequences",
+			interaction: &server.Interaction{Protocol: "smtp", RemoteAddress: "192.0.2.1", Timestamp: timestamp, SMTPFrom: "\x1b]0;title\x07user@example.com", RawRequest: "\x1b[2J\x1b[31mred\tline\r\u009b1m"},
+			rows:        5,
+			expected:    "SMTP interaction from 192.0.2.1 at 2022-09-01 10:20:30\nFrom: \\x1b]0;title\\x07user@example.com\n\\x1b[2J\\x1b[31mred\tline\\x0d\\x9b1m\n",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			require.Equal(t, test.expected, detail(test.interaction, test.rows), "could not get detail")
+		})
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		value    string
+		size     int
+		expected string
+	}{
+		{value: "", size: 10, expected: ""},
+		{value: "short", size: 10, expected: "short"},
+		{value: "exactly10!", size: 10, expected: "exactly10!"},
+		{value: "longer than ten", size: 10, expected: "longer ..."},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			require.Equal(t, test.expected, truncate(test.value, test.size), "could not truncate value")
+		})
+	}
+}
+
+func TestRenderEscapesControlCharacters(t *testing.T) {
+	viewer, out := newTestViewer()
+	viewer.Add(&server.Interaction{Protocol: "dns", QType: "A", FullId: "\x1b]0;title\x07id", RemoteAddress: "192.0.2.1", RawRequest: "\x1b[2Jquery"})
+	rendered := out.String()
+	rendered = rendered[strings.LastIndex(rendered, clearScreen)+len(clearScreen):]
+
+	require.Contains(t, rendered, `\x1b]0;title\x07id`, "could not escape id")
+	require.Contains(t, rendered, `\x1b[2Jquery`, "could not escape request")
+	for _, sequence := range []string{"\x1b]", "\x07", "\x1b[2J"} {
+		require.NotContains(t, rendered, sequence, "could write control characters")
+	}
+}
@@ -0,0 +1,219 @@
+package client
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"golang.org/x/crypto/pbkdf2"
+)
+
+const (
+	// archiveVersion is the version of the archive format
+	archiveVersion = 1
+	// archiveIterations is the number of PBKDF2 iterations deriving the archive key,
+	// the archives with another number are rejected before deriving their key
+	archiveIterations = 200000
+	archiveSaltSize   = 16
+)
+
+// Archive is the interaction history of a correlation-id, exported to move
+// the evidence between machines.
+type Archive struct {
+	CorrelationID string               `json:"correlation-id"`
+	Created       time.Time            `json:"created"`
+	Interactions  []*types.Interaction `json:"interactions"`
+}
+
+// archiveEnvelope is the encrypted and signed form of an archive. The archive is
+// encrypted with AES-GCM with a key derived from a password, and the salt, nonce
+// and ciphertext are signed with an ed25519 key.
+type archiveEnvelope struct {
+	Version    int    `json:"version"`
+	Iterations int    `json:"iterations"`
+	Salt       []byte `json:"salt"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+	PublicKey  []byte `json:"public-key"`
+	Signature  []byte `json:"signature"`
+}
+
+// signedData returns the data covered by the signature of the envelope
+func (e *archiveEnvelope) signedData() []byte {
+	data := make([]byte, 8, 8+len(e.Salt)+len(e.Nonce)+len(e.Ciphertext))
+	binary.BigEndian.PutUint32(data[:4], uint32(e.Version))
+	binary.BigEndian.PutUint32(data[4:], uint32(e.Iterations))
+	data = append(data, e.Salt...)
+	data = append(data, e.Nonce...)
+	return append(data, e.Ciphertext...)
+}
+
+// ExportArchive writes the interactions of the client as an archive encrypted
+// with password and signed with signingKey.
+func (c *Client) ExportArchive(w io.Writer, interactions []*types.Interaction, password string, signingKey ed25519.PrivateKey) error {
+	archive := &Archive{CorrelationID: c.correlationID, Created: time.Now().UTC(), Interactions: interactions}
+	return WriteArchive(w, archive, password, signingKey)
+}
+
+// WriteArchive writes an archive encrypted with password and signed with signingKey
+func WriteArchive(w io.Writer, archive *Archive, password string, signingKey ed25519.PrivateKey) error {
+	if password == "" {
+		return errors.New("no archive password specified")
+	}
+	if len(signingKey) != ed25519.PrivateKeySize {
+		return errors.New("invalid signing key")
+	}
+	plaintext, err := jsoniter.Marshal(archive)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal archive")
+	}
+
+	envelope := &archiveEnvelope{Version: archiveVersion, Iterations: archiveIterations, Salt: make([]byte, archiveSaltSize)}
+	if _, err := rand.Read(envelope.Salt); err != nil {
+		return errors.Wrap(err, "could not generate salt")
+	}
+	aead, err := archiveCipher(password, envelope.Salt, envelope.Iterations)
+	if err != nil {
+		return err
+	}
+	envelope.Nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(envelope.Nonce); err != nil {
+		return errors.Wrap(err, "could not generate nonce")
+	}
+	envelope.Ciphertext = aead.Seal(nil, envelope.Nonce, plaintext, nil)
+	envelope.PublicKey = signingKey.Public().(ed25519.PublicKey)
+	envelope.Signature = ed25519.Sign(signingKey, envelope.signedData())
+
+	if err := jsoniter.NewEncoder(w).Encode(envelope); err != nil {
+		return errors.Wrap(err, "could not write archive")
+	}
+	return nil
+}
+
+// ReadArchive verifies that an archive was signed by signer and decrypts it with
+// password. The signer is checked before the key is derived from the password,
+// since the public key embedded in the archive proves nothing on its own.
+func ReadArchive(r io.Reader, password string, signer ed25519.PublicKey) (*Archive, error) {
+	if len(signer) != ed25519.PublicKeySize {
+		return nil, errors.New("invalid archive signer key")
+	}
+	envelope := &archiveEnvelope{}
+	if err := jsoniter.NewDecoder(r).Decode(envelope); err != nil {
+		return nil, errors.Wrap(err, "could not decode archive")
+	}
+	if envelope.Version != archiveVersion {
+		return nil, errors.Errorf("unsupported archive version %d", envelope.Version)
+	}
+	if envelope.Iterations != archiveIterations {
+		return nil, errors.Errorf("unsupported archive iterations %d", envelope.Iterations)
+	}
+	if subtle.ConstantTimeCompare(envelope.PublicKey, signer) != 1 {
+		return nil, errors.Errorf("archive signed by unexpected key %s", KeyFingerprint(envelope.PublicKey))
+	}
+	if !ed25519.Verify(signer, envelope.signedData(), envelope.Signature) {
+		return nil, errors.New("invalid archive signature")
+	}
+	aead, err := archiveCipher(password, envelope.Salt, envelope.Iterations)
+	if err != nil {
+		return nil, err
+	}
+	if len(envelope.Nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid archive nonce")
+	}
+	plaintext, err := aead.Open(nil, envelope.Nonce, envelope.Ciphertext, nil)
+	if err != nil {
+		return nil, errors.New("could not decrypt archive, invalid password")
+	}
+	archive := &Archive{}
+	if err := jsoniter.Unmarshal(plaintext, archive); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal archive")
+	}
+	return archive, nil
+}
+
+// archiveCipher returns the AES-GCM cipher with the key derived from password
+func archiveCipher(password string, salt []byte, iterations int) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(pbkdf2.Key([]byte(password), salt, iterations, 32, sha256.New))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not create cipher")
+	}
+	return cipher.NewGCM(block)
+}
+
+// KeyFingerprint returns the hex encoded SHA-256 fingerprint of a signing public key
+func KeyFingerprint(publicKey ed25519.PublicKey) string {
+	hash := sha256.Sum256(publicKey)
+	return hex.EncodeToString(hash[:])
+}
+
+// EncodeSignerKey returns the base64 encoded signing public key, to be shared
+// with the recipients of the archives
+func EncodeSignerKey(publicKey ed25519.PublicKey) string {
+	return base64.StdEncoding.EncodeToString(publicKey)
+}
+
+// ParseSignerKey parses a signing public key encoded with EncodeSignerKey
+func ParseSignerKey(value string) (ed25519.PublicKey, error) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decode signer key")
+	}
+	if len(decoded) != ed25519.PublicKeySize {
+		return nil, errors.New("signer key is not an ed25519 public key")
+	}
+	return ed25519.PublicKey(decoded), nil
+}
+
+// LoadSigningKey reads the ed25519 signing key in PEM format from path, generating
+// it if the file doesn't exist.
+func LoadSigningKey(path string) (ed25519.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not generate signing key")
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			return nil, errors.Wrap(err, "could not marshal signing key")
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, errors.Wrap(err, "could not create signing key folder")
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600); err != nil {
+			return nil, errors.Wrap(err, "could not write signing key")
+		}
+		return key, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read signing key")
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("could not decode signing key")
+	}
+	parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not parse signing key")
+	}
+	key, ok := parsed.(ed25519.PrivateKey)
+	if !ok {
+		return nil, errors.New("signing key is not an ed25519 key")
+	}
+	return key, nil
+}
@@ -0,0 +1,61 @@
+package client
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"path/filepath"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArchive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "signing-key.pem")
+	signingKey, err := LoadSigningKey(path)
+	require.Nil(t, err, "could not generate signing key")
+	loaded, err := LoadSigningKey(path)
+	require.Nil(t, err, "could not load signing key")
+	require.Equal(t, signingKey, loaded, "could not persist signing key")
+
+	c := &Client{correlationID: "c6rj61aciaeutn2ae680"}
+	interactions := []*server.Interaction{{Protocol: "dns", UniqueID: "c6rj61aciaeutn2ae680abcdefghijklm", Sequence: 1}}
+	buffer := &bytes.Buffer{}
+	require.Nil(t, c.ExportArchive(buffer, interactions, "password", signingKey), "could not export archive")
+
+	signer, err := ParseSignerKey(EncodeSignerKey(signingKey.Public().(ed25519.PublicKey)))
+	require.Nil(t, err, "could not parse signer key")
+	archive, err := ReadArchive(bytes.NewReader(buffer.Bytes()), "password", signer)
+	require.Nil(t, err, "could not read archive")
+	require.Equal(t, "c6rj61aciaeutn2ae680", archive.CorrelationID, "could not get correlation id")
+	require.Len(t, archive.Interactions, 1, "could not get interactions")
+	require.Equal(t, "dns", archive.Interactions[0].Protocol, "could not get interaction")
+
+	_, err = ReadArchive(bytes.NewReader(buffer.Bytes()), "wrong", signer)
+	require.NotNil(t, err, "could not reject wrong password")
+
+	otherSigner, _, err := ed25519.GenerateKey(nil)
+	require.Nil(t, err, "could not generate key")
+	_, err = ReadArchive(bytes.NewReader(buffer.Bytes()), "password", otherSigner)
+	require.NotNil(t, err, "could not reject unexpected signer")
+	_, err = ReadArchive(bytes.NewReader(buffer.Bytes()), "password", nil)
+	require.NotNil(t, err, "could read archive without signer")
+
+	envelope := &archiveEnvelope{}
+	require.Nil(t, jsoniter.Unmarshal(buffer.Bytes(), envelope), "could not decode envelope")
+	envelope.Ciphertext[0] ^= 0xff
+	tampered, err := jsoniter.Marshal(envelope)
+	require.Nil(t, err, "could not encode envelope")
+	_, err = ReadArchive(bytes.NewReader(tampered), "password", signer)
+	require.NotNil(t, err, "could not reject tampered archive")
+
+	// an archive forcing an expensive key derivation is rejected before deriving its key
+	envelope.Ciphertext[0] ^= 0xff
+	envelope.Iterations = 1 << 31
+	envelope.Signature = ed25519.Sign(signingKey, envelope.signedData())
+	expensive, err := jsoniter.Marshal(envelope)
+	require.Nil(t, err, "could not encode envelope")
+	_, err = ReadArchive(bytes.NewReader(expensive), "password", signer)
+	require.NotNil(t, err, "could not reject unsupported iterations")
+}
@@ -2,6 +2,7 @@ package client
 
 import (
 	"bytes"
+	"compress/gzip"
 	"context"
 	"crypto/aes"
 	"crypto/cipher"
@@ -15,23 +16,25 @@ import (
 	"io"
 	"io/ioutil"
 	mathrand "math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
 	"github.com/projectdiscovery/gologger"
-	"github.com/projectdiscovery/interactsh/pkg/options"
-	"github.com/projectdiscovery/interactsh/pkg/server"
 	"github.com/projectdiscovery/interactsh/pkg/settings"
+	"github.com/projectdiscovery/interactsh/pkg/types"
 	"github.com/projectdiscovery/retryablehttp-go"
 	"github.com/projectdiscovery/stringsutil"
 	"github.com/rs/xid"
-	"gopkg.in/corvus-ch/zbase32.v1"
 	"gopkg.in/yaml.v3"
 )
 
@@ -43,8 +46,10 @@ var authError = errors.New("couldn't authenticate to the server")
 
 // Client is a client for communicating with interactsh server instance.
 type Client struct {
-	correlationID            string
-	secretKey                string
+	correlationID string
+	secretKey     string
+	// derivationKey authenticates the nonces of URLForKey, unlike the secret key it is never rotated
+	derivationKey            string
 	serverURL                *url.URL
 	httpClient               *retryablehttp.Client
 	privKey                  *rsa.PrivateKey
@@ -53,6 +58,39 @@ type Client struct {
 	token                    string
 	correlationIdLength      int
 	CorrelationIdNonceLength int
+	dedup                    *deduplicator
+	exfil                    *exfilReassembler
+	dnsAnswers               *types.DNSAnswers
+	callback                 InteractionCallback
+	// publicPorts are the public ports of the services of the server
+	publicPorts map[string]int
+	// disabledServices are the services disabled on the server
+	disabledServices []string
+	// subdomain is the dedicated subdomain of the tenant of the token, if any
+	subdomain string
+	// ipv6Only generates URLs resolved only to the ipv6 address of the server
+	ipv6Only bool
+	// headers are the extra headers of the requests to the server
+	headers http.Header
+	// seed derives the payloads of URLFromSeed if the options have a seed
+	seed *Seed
+	// nonceEncoding is the encoding of the nonces negotiated with the server
+	nonceEncoding string
+	// nonceAlphabet is the alphabet of the nonce encoding
+	nonceAlphabet string
+	// keysMutex protects the keys from a rotation while they are in use
+	keysMutex sync.RWMutex
+	// aesKey is the last AES key of the session received from the server
+	aesKey      []byte
+	aesKeyMutex sync.Mutex
+	// metadata is the session metadata returned by the last poll
+	metadata      *types.SessionMetadata
+	metadataMutex sync.Mutex
+	// sequence is the sequence number of the last interaction received by polling
+	sequence      uint64
+	sequenceMutex sync.Mutex
+	// latency tracks the time between the generation of the payloads and their first interaction
+	latency *latencyTracker
 }
 
 // Options contains configuration options for interactsh client
@@ -69,8 +107,34 @@ type Options struct {
 	CorrelationIdNonceLength int
 	// HTTPClient use a custom http client
 	HTTPClient *retryablehttp.Client
+	// Transport is the http transport of the requests to the server, for example to
+	// add instrumentation or authentication. It is ignored if HTTPClient is set.
+	Transport http.RoundTripper
 	// SessionInfo to resume an existing session
-	SessionInfo *options.SessionInfo
+	SessionInfo *types.SessionInfo
+	// DedupWindow collapses identical interactions received within the window (disabled if zero)
+	DedupWindow time.Duration
+	// ExfilEncoding enables the reassembly of data exfiltrated through dns queries
+	// encoded with hex, base32 or base64 (disabled if empty)
+	ExfilEncoding string
+	// ExfilTimeout is the time after which incomplete exfiltrated data is dropped
+	ExfilTimeout time.Duration
+	// DNSAnswers are the custom dns answers returned by the server for the client URLs
+	DNSAnswers *types.DNSAnswers
+	// IPv6Only generates URLs resolved only to the ipv6 address of the server
+	IPv6Only bool
+	// Headers are extra headers sent on the requests to the server, for example
+	// for an authenticating reverse proxy. The token takes precedence over an
+	// Authorization header.
+	Headers http.Header
+	// UserAgent is the User-Agent of the requests to the server if not empty
+	UserAgent string
+	// Seed derives the correlation id, the secret key and the payloads of
+	// URLFromSeed, so that distributed workers share the same payloads
+	Seed string
+	// NonceEncoding is the encoding of the nonces of the URLs, zbase32 (default),
+	// hex, base36 or custom:<alphabet>, for the targets rejecting some characters
+	NonceEncoding string
 }
 
 // DefaultOptions is the default options for the interact client
@@ -96,15 +160,35 @@ func New(options *Options) (*Client, error) {
 	} else {
 		opts := retryablehttp.DefaultOptionsSingle
 		opts.Timeout = 10 * time.Second
+		transport := options.Transport
+		if transport == nil {
+			transport = defaultTransport()
+		}
+		if transport != nil {
+			opts.HttpClient = &http.Client{Transport: transport, Timeout: opts.Timeout}
+		}
 		httpclient = retryablehttp.NewClient(opts)
 	}
 
-	var correlationID, secretKey, token string
+	var correlationID, secretKey, derivationKey, token string
+	var sequence uint64
+	var seed *Seed
 
 	if options.SessionInfo != nil {
 		correlationID = options.SessionInfo.CorrelationID
 		secretKey = options.SessionInfo.SecretKey
+		derivationKey = options.SessionInfo.DerivationKey
+		if derivationKey == "" {
+			// the sessions saved without derivation key authenticated their payloads with the secret key
+			derivationKey = secretKey
+		}
 		token = options.SessionInfo.Token
+		sequence = options.SessionInfo.Sequence
+		// the lengths negotiated when the session was created take precedence
+		options.CorrelationIdLength = len(correlationID)
+		if options.SessionInfo.CorrelationIdNonceLength > 0 {
+			options.CorrelationIdNonceLength = options.SessionInfo.CorrelationIdNonceLength
+		}
 	} else {
 		// Generate a random ksuid which will be used as server secret.
 		correlationID = xid.New().String()
@@ -112,17 +196,58 @@ func New(options *Options) (*Client, error) {
 			correlationID = correlationID[:options.CorrelationIdLength]
 		}
 		secretKey = uuid.New().String()
+		derivationKey = uuid.New().String()
 		token = options.Token
 	}
+	nonceAlphabet, err := settings.NonceAlphabet(options.NonceEncoding)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid nonce encoding")
+	}
+	if options.Seed != "" {
+		if seed, err = NewSeed(options.Seed, options.CorrelationIdLength, options.CorrelationIdNonceLength); err != nil {
+			return nil, errors.Wrap(err, "could not derive seed")
+		}
+		// a restored session keeps its own correlation id
+		if options.SessionInfo == nil {
+			correlationID, secretKey, derivationKey = seed.CorrelationID(), seed.SecretKey(), seed.DerivationKey()
+		}
+	}
 
 	client := &Client{
 		secretKey:                secretKey,
+		derivationKey:            derivationKey,
 		correlationID:            correlationID,
 		httpClient:               httpclient,
 		token:                    token,
 		disableHTTPFallback:      options.DisableHTTPFallback,
 		correlationIdLength:      options.CorrelationIdLength,
 		CorrelationIdNonceLength: options.CorrelationIdNonceLength,
+		sequence:                 sequence,
+		dnsAnswers:               options.DNSAnswers,
+		ipv6Only:                 options.IPv6Only,
+		headers:                  make(http.Header),
+		seed:                     seed,
+		nonceEncoding:            strings.ToLower(options.NonceEncoding),
+		nonceAlphabet:            nonceAlphabet,
+		latency:                  newLatencyTracker(),
+	}
+	for name, values := range options.Headers {
+		for _, value := range values {
+			client.headers.Add(name, value)
+		}
+	}
+	if options.UserAgent != "" {
+		client.headers.Set("User-Agent", options.UserAgent)
+	}
+	if options.DedupWindow > 0 {
+		client.dedup = newDeduplicator(options.DedupWindow)
+	}
+	if options.ExfilEncoding != "" {
+		exfil, err := newExfilReassembler(options.ExfilEncoding, options.ExfilTimeout)
+		if err != nil {
+			return nil, err
+		}
+		client.exfil = exfil
 	}
 	if options.SessionInfo != nil {
 		privKey, err := x509.ParsePKCS1PrivateKey([]byte(options.SessionInfo.PrivateKey))
@@ -132,13 +257,17 @@ func New(options *Options) (*Client, error) {
 		if serverURL, err := url.Parse(options.SessionInfo.ServerURL); err == nil {
 			client.serverURL = serverURL
 		}
+		client.publicPorts = options.SessionInfo.PublicPorts
+		client.disabledServices = options.SessionInfo.DisabledServices
+		if err := client.resumeSession(); err != nil {
+			return nil, errors.Wrap(err, "could not resume session")
+		}
 	} else {
-		payload, err := client.initializeRSAKeys()
-		if err != nil {
+		if err := client.initializeRSAKeys(); err != nil {
 			return nil, errors.Wrap(err, "could not initialize rsa keys")
 		}
 
-		if err := client.parseServerURLs(options.ServerURL, payload); err != nil {
+		if err := client.parseServerURLs(options.ServerURL); err != nil {
 			return nil, errors.Wrap(err, "could not register to servers")
 		}
 	}
@@ -146,31 +275,43 @@ func New(options *Options) (*Client, error) {
 	return client, nil
 }
 
-// initializeRSAKeys does the one-time initialization for RSA crypto mechanism
-// and returns the data payload for the client.
-func (c *Client) initializeRSAKeys() ([]byte, error) {
+// initializeRSAKeys does the one-time initialization for RSA crypto mechanism.
+func (c *Client) initializeRSAKeys() error {
 	// Generate a 2048-bit private-key
 	priv, err := rsa.GenerateKey(rand.Reader, 2048)
 	if err != nil {
-		return nil, errors.Wrap(err, "could not generate rsa private key")
+		return errors.Wrap(err, "could not generate rsa private key")
 	}
 	c.privKey = priv
-	pub := priv.Public()
+	return nil
+}
 
-	pubkeyBytes, err := x509.MarshalPKIXPublicKey(pub)
+// encodePublicKey returns the base64 encoded pem of the public key of priv.
+func encodePublicKey(priv *rsa.PrivateKey) (string, error) {
+	pubkeyBytes, err := x509.MarshalPKIXPublicKey(priv.Public())
 	if err != nil {
-		return nil, errors.Wrap(err, "could not marshal public key")
+		return "", errors.Wrap(err, "could not marshal public key")
 	}
 	pubkeyPem := pem.EncodeToMemory(&pem.Block{
 		Type:  "RSA PUBLIC KEY",
 		Bytes: pubkeyBytes,
 	})
+	return base64.StdEncoding.EncodeToString(pubkeyPem), nil
+}
 
-	encoded := base64.StdEncoding.EncodeToString(pubkeyPem)
-	register := server.RegisterRequest{
-		PublicKey:     encoded,
-		SecretKey:     c.secretKey,
-		CorrelationID: c.correlationID,
+// registrationPayload returns the data payload for the registration of the client.
+func (c *Client) registrationPayload() ([]byte, error) {
+	encoded, err := encodePublicKey(c.privKey)
+	if err != nil {
+		return nil, err
+	}
+	register := types.RegisterRequest{
+		PublicKey:                encoded,
+		SecretKey:                c.secretKey,
+		CorrelationID:            c.correlationID,
+		CorrelationIdNonceLength: c.CorrelationIdNonceLength,
+		DNSAnswers:               c.dnsAnswers,
+		NonceEncoding:            c.nonceEncoding,
 	}
 	data, err := jsoniter.Marshal(register)
 	if err != nil {
@@ -187,7 +328,7 @@ func (c *Client) initializeRSAKeys() ([]byte, error) {
 //
 // If the first picked random domain doesn't work, the list of domains is iterated
 // after being shuffled.
-func (c *Client) parseServerURLs(serverURL string, payload []byte) error {
+func (c *Client) parseServerURLs(serverURL string) error {
 	if serverURL == "" {
 		return errors.New("invalid server url provided")
 	}
@@ -205,7 +346,7 @@ func (c *Client) parseServerURLs(serverURL string, payload []byte) error {
 			return errors.Wrap(err, "could not parse server URL")
 		}
 	makeReq:
-		if err := c.performRegistration(parsed.String(), payload); err != nil {
+		if err := c.performRegistration(parsed.String()); err != nil {
 			if !c.disableHTTPFallback && parsed.Scheme == "https" {
 				parsed.Scheme = "http"
 				gologger.Verbose().Msgf("Could not register to %s: %s, retrying with http\n", parsed.String(), err)
@@ -241,13 +382,26 @@ func removeIndex(s []string, index int) []string {
 }
 
 // InteractionCallback is a callback function for a reported interaction
-type InteractionCallback func(*server.Interaction)
+type InteractionCallback func(*types.Interaction)
 
 // StartPolling starts polling the server each duration and returns any events
 // that may have been captured by the collaborator server.
 func (c *Client) StartPolling(duration time.Duration, callback InteractionCallback) {
 	ticker := time.NewTicker(duration)
 	c.quitChan = make(chan struct{})
+	c.callback = callback
+	if c.dedup != nil {
+		callback = c.dedup.add
+	}
+	if c.exfil != nil {
+		next := callback
+		callback = func(interaction *types.Interaction) {
+			next(interaction)
+			if reassembled := c.exfil.add(interaction); reassembled != nil {
+				next(reassembled)
+			}
+		}
+	}
 	go func() {
 		for {
 			select {
@@ -256,6 +410,9 @@ func (c *Client) StartPolling(duration time.Duration, callback InteractionCallba
 				if err != nil && err.Error() == authError.Error() {
 					gologger.Fatal().Msgf("Could not authenticate to the server")
 				}
+				if c.dedup != nil {
+					c.dedup.flush(c.callback, false)
+				}
 			case <-c.quitChan:
 				ticker.Stop()
 				return
@@ -266,20 +423,25 @@ func (c *Client) StartPolling(duration time.Duration, callback InteractionCallba
 
 // getInteractions returns the interactions from the server.
 func (c *Client) getInteractions(callback InteractionCallback) error {
+	c.keysMutex.RLock()
+	defer c.keysMutex.RUnlock()
+
 	builder := &strings.Builder{}
 	builder.WriteString(c.serverURL.String())
 	builder.WriteString("/poll?id=")
 	builder.WriteString(c.correlationID)
 	builder.WriteString("&secret=")
 	builder.WriteString(c.secretKey)
+	builder.WriteString("&since=")
+	builder.WriteString(strconv.FormatUint(c.Sequence(), 10))
 	req, err := retryablehttp.NewRequest("GET", builder.String(), nil)
 	if err != nil {
 		return err
 	}
 
-	if c.token != "" {
-		req.Header.Add("Authorization", c.token)
-	}
+	c.setHeaders(req)
+	// setting the header disables the transparent decompression of the transport
+	req.Header.Set("Accept-Encoding", "gzip")
 
 	resp, err := c.httpClient.Do(req)
 	defer func() {
@@ -291,64 +453,190 @@ func (c *Client) getInteractions(callback InteractionCallback) error {
 	if err != nil {
 		return err
 	}
+	body := io.Reader(resp.Body)
+	if resp.Header.Get("Content-Encoding") == "gzip" {
+		gzipReader, err := gzip.NewReader(resp.Body)
+		if err != nil {
+			return errors.Wrap(err, "could not decompress interactions")
+		}
+		defer gzipReader.Close()
+		body = gzipReader
+	}
 	if resp.StatusCode != 200 {
 		if resp.StatusCode == http.StatusUnauthorized {
 			return authError
 		}
-		data, _ := ioutil.ReadAll(resp.Body)
+		data, _ := ioutil.ReadAll(body)
 		return fmt.Errorf("could not poll interactions: %s", string(data))
 	}
-	response := &server.PollResponse{}
-	if err := jsoniter.NewDecoder(resp.Body).Decode(response); err != nil {
+	response := &types.PollResponse{}
+	if err := jsoniter.NewDecoder(body).Decode(response); err != nil {
 		gologger.Error().Msgf("Could not decode interactions: %v\n", err)
 		return err
 	}
 
-	for _, data := range response.Data {
-		plaintext, err := c.decryptMessage(response.AESKey, data)
-		if err != nil {
-			gologger.Error().Msgf("Could not decrypt interaction: %v\n", err)
-			continue
-		}
-		interaction := &server.Interaction{}
-		if err := jsoniter.Unmarshal(plaintext, interaction); err != nil {
-			gologger.Error().Msgf("Could not unmarshal interaction data interaction: %v\n", err)
-			continue
-		}
-		callback(interaction)
+	if response.Metadata != nil {
+		c.metadataMutex.Lock()
+		c.metadata = response.Metadata
+		c.metadataMutex.Unlock()
 	}
+	interactions := c.decryptInteractions(response)
 
 	for _, plaintext := range response.Extra {
-		interaction := &server.Interaction{}
+		interaction := &types.Interaction{}
 		if err := jsoniter.UnmarshalFromString(plaintext, interaction); err != nil {
 			gologger.Error().Msgf("Could not unmarshal interaction data interaction: %v\n", err)
 			continue
 		}
-		callback(interaction)
+		interactions = append(interactions, interaction)
 	}
 
 	// handle root-tld data if any
 	for _, data := range response.TLDData {
-		interaction := &server.Interaction{}
+		interaction := &types.Interaction{}
 		if err := jsoniter.UnmarshalFromString(data, interaction); err != nil {
 			gologger.Error().Msgf("Could not unmarshal interaction data interaction: %v\n", err)
 			continue
 		}
+		interactions = append(interactions, interaction)
+	}
+
+	sortInteractions(interactions)
+	for _, interaction := range interactions {
+		c.latency.observe(interaction)
 		callback(interaction)
 	}
 
+	// acknowledge the interactions received in the next poll
+	c.sequenceMutex.Lock()
+	if response.Sequence > c.sequence {
+		c.sequence = response.Sequence
+	}
+	c.sequenceMutex.Unlock()
+	return nil
+}
+
+// decryptInteractions decrypts the interactions of a response
+func (c *Client) decryptInteractions(response *types.PollResponse) []*types.Interaction {
+	var interactions []*types.Interaction
+	if len(response.Data) == 0 {
+		return interactions
+	}
+	keyPlaintext, err := c.decryptKey(response.AESKey)
+	if err != nil {
+		gologger.Error().Msgf("Could not decrypt interaction key: %v\n", err)
+		return interactions
+	}
+	c.setAESKey(keyPlaintext)
+	for _, data := range response.Data {
+		plaintext, err := decryptData(keyPlaintext, data)
+		if err != nil {
+			gologger.Error().Msgf("Could not decrypt interaction: %v\n", err)
+			continue
+		}
+		interaction := &types.Interaction{}
+		if err := jsoniter.Unmarshal(plaintext, interaction); err != nil {
+			gologger.Error().Msgf("Could not unmarshal interaction data interaction: %v\n", err)
+			continue
+		}
+		interactions = append(interactions, interaction)
+	}
+	return interactions
+}
+
+// sortInteractions sorts the interactions in the order they were received by the
+// server, by sequence number or by timestamp for servers not assigning them.
+func sortInteractions(interactions []*types.Interaction) {
+	sort.SliceStable(interactions, func(i, j int) bool {
+		if interactions[i].Sequence != 0 && interactions[j].Sequence != 0 {
+			return interactions[i].Sequence < interactions[j].Sequence
+		}
+		return interactions[i].Timestamp.Before(interactions[j].Timestamp)
+	})
+}
+
+// Sequence returns the sequence number of the last interaction received by polling
+func (c *Client) Sequence() uint64 {
+	c.sequenceMutex.Lock()
+	defer c.sequenceMutex.Unlock()
+	return c.sequence
+}
+
+// Replay fetches again the interactions already delivered with a sequence number
+// between from and to (inclusive), as long as they are within the replay window
+// of the server. It can be used to recover events after a crash following a poll.
+func (c *Client) Replay(from, to uint64, callback InteractionCallback) error {
+	c.keysMutex.RLock()
+	defer c.keysMutex.RUnlock()
+
+	builder := &strings.Builder{}
+	builder.WriteString(c.serverURL.String())
+	builder.WriteString("/replay?id=")
+	builder.WriteString(c.correlationID)
+	builder.WriteString("&secret=")
+	builder.WriteString(c.secretKey)
+	builder.WriteString("&from=")
+	builder.WriteString(strconv.FormatUint(from, 10))
+	builder.WriteString("&to=")
+	builder.WriteString(strconv.FormatUint(to, 10))
+	req, err := retryablehttp.NewRequest("GET", builder.String(), nil)
+	if err != nil {
+		return errors.Wrap(err, "could not create new request")
+	}
+
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return errors.Wrap(err, "could not make replay request")
+	}
+	if resp.StatusCode != 200 {
+		if resp.StatusCode == http.StatusUnauthorized {
+			return authError
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("could not replay interactions: %s", string(data))
+	}
+	response := &types.PollResponse{}
+	if err := jsoniter.NewDecoder(resp.Body).Decode(response); err != nil {
+		return errors.Wrap(err, "could not decode interactions")
+	}
+	interactions := c.decryptInteractions(response)
+	sortInteractions(interactions)
+	for _, interaction := range interactions {
+		callback(interaction)
+	}
 	return nil
 }
 
+// Metadata returns the session metadata returned by the last poll, with the
+// interactions counted by protocol and the dropped and truncated ones, or nil
+// if the server didn't return it.
+func (c *Client) Metadata() *types.SessionMetadata {
+	c.metadataMutex.Lock()
+	defer c.metadataMutex.Unlock()
+	return c.metadata
+}
+
 // StopPolling stops the polling to the interactsh server.
 func (c *Client) StopPolling() {
 	close(c.quitChan)
+	// deliver the interactions still held for deduplication
+	if c.dedup != nil {
+		c.dedup.flush(c.callback, true)
+	}
 }
 
 // Close closes the collaborator client and deregisters from the
 // collaborator server if not explicitly asked by the user.
 func (c *Client) Close() error {
-	register := server.DeregisterRequest{
+	register := types.DeregisterRequest{
 		CorrelationID: c.correlationID,
 		SecretKey:     c.secretKey,
 	}
@@ -363,9 +651,7 @@ func (c *Client) Close() error {
 	}
 	req.ContentLength = int64(len(data))
 
-	if c.token != "" {
-		req.Header.Add("Authorization", c.token)
-	}
+	c.setHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	defer func() {
@@ -384,22 +670,296 @@ func (c *Client) Close() error {
 	return nil
 }
 
+// RotateKeys replaces the rsa key pair registered to the server, and the secret key
+// if rotateSecret is true, keeping the correlation ID. The URLs generated before
+// the rotation stay valid, including the ones of URLForKey which are authenticated
+// with a derivation key that is never rotated.
+//
+// Sessions saved with SaveSessionTo must be saved again after the rotation.
+func (c *Client) RotateKeys(rotateSecret bool) error {
+	c.keysMutex.Lock()
+	defer c.keysMutex.Unlock()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return errors.Wrap(err, "could not generate rsa private key")
+	}
+	encoded, err := encodePublicKey(priv)
+	if err != nil {
+		return err
+	}
+	rotate := types.RotateRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		PublicKey:     encoded,
+	}
+	if rotateSecret {
+		rotate.NewSecretKey = uuid.New().String()
+	}
+	data, err := jsoniter.Marshal(rotate)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal rotate request")
+	}
+	URL := c.serverURL.String() + "/rotate"
+	req, err := retryablehttp.NewRequest("POST", URL, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "could not create new request")
+	}
+	req.ContentLength = int64(len(data))
+
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return errors.Wrap(err, "could not make rotate request")
+	}
+	if resp.StatusCode != 200 {
+		if resp.StatusCode == http.StatusUnauthorized {
+			return authError
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("could not rotate keys: %s", string(data))
+	}
+
+	c.privKey = priv
+	c.setAESKey(nil)
+	if rotateSecret {
+		c.secretKey = rotate.NewSecretKey
+	}
+	return nil
+}
+
+// SetDNSAnswers sets the dns answers returned by the server for the client URLs
+// instead of the default ones, the default ones are restored if answers is nil.
+func (c *Client) SetDNSAnswers(answers *types.DNSAnswers) error {
+	c.keysMutex.RLock()
+	request := types.DNSAnswersRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		Answers:       answers,
+	}
+	c.keysMutex.RUnlock()
+
+	data, err := jsoniter.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal dns answers request")
+	}
+	if err := c.post("/dns-answers", data); err != nil {
+		return errors.Wrap(err, "could not set dns answers")
+	}
+	c.dnsAnswers = answers
+	return nil
+}
+
+// SetHTTPResponse sets the http response served by the server for the client URLs
+// instead of the default one. Headers replace the default ones with the same name.
+func (c *Client) SetHTTPResponse(status int, headers map[string][]string, body []byte) error {
+	return c.setHTTPResponse(&types.HTTPResponseDefinition{StatusCode: status, Headers: headers, Body: body})
+}
+
+// ResetHTTPResponse restores the default http response for the client URLs.
+func (c *Client) ResetHTTPResponse() error {
+	return c.setHTTPResponse(nil)
+}
+
+func (c *Client) setHTTPResponse(response *types.HTTPResponseDefinition) error {
+	c.keysMutex.RLock()
+	request := types.HTTPResponseRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		Response:      response,
+	}
+	c.keysMutex.RUnlock()
+
+	data, err := jsoniter.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal http response request")
+	}
+	if err := c.post("/http-response", data); err != nil {
+		return errors.Wrap(err, "could not set http response")
+	}
+	return nil
+}
+
+// SetResponseDelay sets the delay of the http and dns responses for the client
+// URLs, responses are not delayed if zero. A delay-<seconds> label in the
+// subdomain takes precedence for single payloads.
+func (c *Client) SetResponseDelay(delay time.Duration) error {
+	c.keysMutex.RLock()
+	request := types.ResponseDelayRequest{
+		CorrelationID: c.correlationID,
+		SecretKey:     c.secretKey,
+		Delay:         int(delay / time.Second),
+	}
+	c.keysMutex.RUnlock()
+
+	data, err := jsoniter.Marshal(request)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal response delay request")
+	}
+	if err := c.post("/response-delay", data); err != nil {
+		return errors.Wrap(err, "could not set response delay")
+	}
+	return nil
+}
+
+// post sends an authenticated json request to the server, returning an error
+// with the response of the server if it is not successful.
+func (c *Client) post(path string, data []byte) error {
+	req, err := retryablehttp.NewRequest("POST", c.serverURL.String()+path, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "could not create new request")
+	}
+	req.ContentLength = int64(len(data))
+
+	c.setHeaders(req)
+
+	resp, err := c.httpClient.Do(req)
+	defer func() {
+		if resp != nil && resp.Body != nil {
+			_ = resp.Body.Close()
+			_, _ = io.Copy(ioutil.Discard, resp.Body)
+		}
+	}()
+	if err != nil {
+		return errors.Wrap(err, "could not make request")
+	}
+	if resp.StatusCode != 200 {
+		if resp.StatusCode == http.StatusUnauthorized {
+			return authError
+		}
+		data, _ := ioutil.ReadAll(resp.Body)
+		return errors.New(string(data))
+	}
+	return nil
+}
+
 // performRegistration registers the current client with the master server using the
 // provided RSA Public Key as well as Correlation Key.
-func (c *Client) performRegistration(serverURL string, payload []byte) error {
+//
+// If the server uses different correlation id or nonce lengths, they are
+// adopted and the registration is performed again with a new correlation id.
+func (c *Client) performRegistration(serverURL string) error {
+	response, err := c.register(serverURL)
+	if err != nil {
+		return err
+	}
+	if response.Message == "registration successful" {
+		c.publicPorts = response.PublicPorts
+		c.disabledServices = response.DisabledServices
+		c.subdomain = response.Subdomain
+		return nil
+	}
+	if response.CorrelationIdLength <= 0 || response.CorrelationIdNonceLength <= 0 || (response.CorrelationIdLength == c.correlationIdLength && response.CorrelationIdNonceLength == c.CorrelationIdNonceLength) {
+		return fmt.Errorf("could not register to server: %s", response.Error)
+	}
+	if err := c.setCorrelationIdLengths(response.CorrelationIdLength, response.CorrelationIdNonceLength); err != nil {
+		return errors.Wrap(err, "could not negotiate correlation id lengths")
+	}
+	gologger.Verbose().Msgf("Using correlation id length %d and nonce length %d from %s\n", c.correlationIdLength, c.CorrelationIdNonceLength, serverURL)
+
+	if response, err = c.register(serverURL); err != nil {
+		return err
+	}
+	if response.Message != "registration successful" {
+		return fmt.Errorf("could not register to server: %s", response.Error)
+	}
+	c.publicPorts = response.PublicPorts
+	c.disabledServices = response.DisabledServices
+	c.subdomain = response.Subdomain
+	return nil
+}
+
+// resumeSession registers again the correlation id of a restored session, so that
+// the server keeps the pending interactions of the session if it is still known,
+// or starts accepting again the interactions of the payloads already planted.
+func (c *Client) resumeSession() error {
+	if c.privKey == nil || c.serverURL == nil {
+		return errors.New("invalid session info")
+	}
+	response, err := c.register(c.serverURL.String())
+	if err != nil {
+		return err
+	}
+	if response.Message != "registration successful" {
+		return fmt.Errorf("could not register to server: %s", response.Error)
+	}
+	c.publicPorts = response.PublicPorts
+	c.disabledServices = response.DisabledServices
+	c.subdomain = response.Subdomain
+	return nil
+}
+
+// setCorrelationIdLengths changes the lengths of the correlation id and
+// of the nonce, generating a new correlation id.
+func (c *Client) setCorrelationIdLengths(correlationIdLength, nonceLength int) error {
+	if c.seed != nil {
+		seed, err := NewSeed(string(c.seed.key), correlationIdLength, nonceLength)
+		if err != nil {
+			return err
+		}
+		c.seed, c.correlationID, c.secretKey, c.derivationKey = seed, seed.CorrelationID(), seed.SecretKey(), seed.DerivationKey()
+		c.correlationIdLength = correlationIdLength
+		c.CorrelationIdNonceLength = nonceLength
+		return nil
+	}
+	correlationID := xid.New().String()
+	if correlationIdLength > len(correlationID) {
+		return fmt.Errorf("correlation id length %d is greater than %d", correlationIdLength, len(correlationID))
+	}
+	c.correlationID = correlationID[:correlationIdLength]
+	c.correlationIdLength = correlationIdLength
+	c.CorrelationIdNonceLength = nonceLength
+	return nil
+}
+
+// ParseHeaders parses headers in the "Name: value" format
+func ParseHeaders(headers []string) (http.Header, error) {
+	parsed := make(http.Header)
+	for _, header := range headers {
+		index := strings.Index(header, ":")
+		if index <= 0 {
+			return nil, fmt.Errorf("invalid header %q, expected name:value", header)
+		}
+		parsed.Add(strings.TrimSpace(header[:index]), strings.TrimSpace(header[index+1:]))
+	}
+	return parsed, nil
+}
+
+// setHeaders adds the extra headers and the token to a request to the server
+func (c *Client) setHeaders(req *retryablehttp.Request) {
+	for name, values := range c.headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", c.token)
+	}
+}
+
+// register sends a registration request and returns the response of the server.
+func (c *Client) register(serverURL string) (*types.RegisterResponse, error) {
+	payload, err := c.registrationPayload()
+	if err != nil {
+		return nil, err
+	}
+
 	// By default we attempt registration once before switching to the next server
 	ctx := context.WithValue(context.Background(), retryablehttp.RETRY_MAX, 0)
 
 	URL := serverURL + "/register"
 	req, err := retryablehttp.NewRequestWithContext(ctx, "POST", URL, bytes.NewReader(payload))
 	if err != nil {
-		return errors.Wrap(err, "could not create new request")
+		return nil, errors.Wrap(err, "could not create new request")
 	}
 	req.ContentLength = int64(len(payload))
 
-	if c.token != "" {
-		req.Header.Add("Authorization", c.token)
-	}
+	c.setHeaders(req)
 
 	resp, err := c.httpClient.Do(req)
 	defer func() {
@@ -409,61 +969,111 @@ func (c *Client) performRegistration(serverURL string, payload []byte) error {
 		}
 	}()
 	if err != nil {
-		return errors.Wrap(err, "could not make register request")
+		return nil, errors.Wrap(err, "could not make register request")
 	}
 	if resp.StatusCode == 401 {
-		return errors.New("invalid token provided for interactsh server")
+		return nil, errors.New("invalid token provided for interactsh server")
 	}
-	if resp.StatusCode != 200 {
-		data, _ := ioutil.ReadAll(resp.Body)
-		return fmt.Errorf("could not register to server: %s", string(data))
+	data, _ := ioutil.ReadAll(resp.Body)
+	response := &types.RegisterResponse{}
+	if jsonErr := jsoniter.Unmarshal(data, response); jsonErr != nil {
+		if resp.StatusCode != 200 {
+			return nil, fmt.Errorf("could not register to server: %s", string(data))
+		}
+		return nil, errors.Wrap(jsonErr, "could not register to server")
 	}
-	response := make(map[string]interface{})
-	if jsonErr := jsoniter.NewDecoder(resp.Body).Decode(&response); jsonErr != nil {
-		return errors.Wrap(jsonErr, "could not register to server")
+	if resp.StatusCode != 200 && response.Error == "" {
+		return nil, fmt.Errorf("could not register to server: %s", string(data))
 	}
-	message, ok := response["message"]
-	if !ok {
-		return errors.New("could not get register response")
+	if resp.StatusCode == 200 && response.Message == "" {
+		return nil, errors.New("could not get register response")
 	}
-	if message.(string) != "registration successful" {
-		return fmt.Errorf("could not get register response: %s", message.(string))
+	if resp.StatusCode == 200 && response.Message != "registration successful" {
+		return nil, fmt.Errorf("could not get register response: %s", response.Message)
 	}
-	return nil
+	return response, nil
 }
 
 // URL returns a new URL that can be used for external interaction requests.
 func (c *Client) URL() string {
 	data := make([]byte, c.CorrelationIdNonceLength)
 	_, _ = rand.Read(data)
-	randomData := zbase32.StdEncoding.EncodeToString(data)
-	if len(randomData) > c.CorrelationIdNonceLength {
-		randomData = randomData[:c.CorrelationIdNonceLength]
-	}
+	randomData := c.encodeNonce(data, c.CorrelationIdNonceLength)
 
+	host := c.host()
 	builder := &strings.Builder{}
-	builder.Grow(len(c.correlationID) + len(randomData) + len(c.serverURL.Host) + 1)
+	builder.Grow(len(c.correlationID) + len(randomData) + len(host) + 1)
 	builder.WriteString(c.correlationID)
 	builder.WriteString(randomData)
 	builder.WriteString(".")
-	builder.WriteString(c.serverURL.Host)
+	builder.WriteString(host)
 	URL := builder.String()
+	c.latency.track(c.correlationID + randomData)
 	return URL
 }
 
-// decryptMessage decrypts an AES-256-RSA-OAEP encrypted message to string
-func (c *Client) decryptMessage(key string, secureMessage string) ([]byte, error) {
-	decodedKey, err := base64.StdEncoding.DecodeString(key)
-	if err != nil {
-		return nil, err
+// host returns the host of the server used in the URLs
+func (c *Client) host() string {
+	host := c.serverURL.Host
+	if c.ipv6Only {
+		host = settings.IPv6OnlyLabel + "." + host
 	}
+	if c.subdomain != "" {
+		host = c.subdomain + "." + host
+	}
+	return host
+}
+
+// ServiceEnabled returns false if the service (dns, http, https, smtp, ldap, ...)
+// is disabled on the server, so that its payloads would not be captured.
+func (c *Client) ServiceEnabled(service string) bool {
+	for _, disabled := range c.disabledServices {
+		if strings.EqualFold(disabled, service) {
+			return false
+		}
+	}
+	return true
+}
 
-	// Decrypt the key plaintext first
-	keyPlaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, c.privKey, decodedKey, nil)
+// HTTPURL returns a new http URL that can be used for external interaction
+// requests, with the public http port of the server if it is not the default one.
+// It returns an empty string if http is disabled on the server.
+func (c *Client) HTTPURL() string {
+	if !c.ServiceEnabled("http") {
+		return ""
+	}
+	return "http://" + hostWithPort(c.URL(), c.publicPorts["http"], 80)
+}
+
+// HTTPSURL returns a new https URL that can be used for external interaction
+// requests, with the public https port of the server if it is not the default one.
+// It returns an empty string if https is disabled on the server.
+func (c *Client) HTTPSURL() string {
+	if !c.ServiceEnabled("https") {
+		return ""
+	}
+	return "https://" + hostWithPort(c.URL(), c.publicPorts["https"], 443)
+}
+
+// hostWithPort appends port to host unless it is unknown or the default port
+func hostWithPort(host string, port, defaultPort int) string {
+	if port <= 0 || port == defaultPort {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// decryptKey decrypts the RSA-OAEP encrypted AES key of the interactions
+func (c *Client) decryptKey(key string) ([]byte, error) {
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
 	if err != nil {
 		return nil, err
 	}
+	return rsa.DecryptOAEP(sha256.New(), rand.Reader, c.privKey, decodedKey, nil)
+}
 
+// decryptData decrypts an AES-256-CFB encrypted message with the key plaintext
+func decryptData(keyPlaintext []byte, secureMessage string) ([]byte, error) {
 	cipherText, err := base64.StdEncoding.DecodeString(secureMessage)
 	if err != nil {
 		return nil, err
@@ -489,15 +1099,33 @@ func (c *Client) decryptMessage(key string, secureMessage string) ([]byte, error
 	return decoded, nil
 }
 
+// SaveSessionTo saves the session of the client to filename, to resume it later
 func (c *Client) SaveSessionTo(filename string) error {
+	return WriteSessionInfo(filename, c.SessionInfo())
+}
+
+// SessionInfo returns the session of the client, with its secrets, to resume it later
+func (c *Client) SessionInfo() *types.SessionInfo {
+	c.keysMutex.RLock()
+	defer c.keysMutex.RUnlock()
+
 	privateKeyData := x509.MarshalPKCS1PrivateKey(c.privKey)
-	sessionInfo := &options.SessionInfo{
-		ServerURL:     c.serverURL.String(),
-		Token:         c.token,
-		PrivateKey:    string(privateKeyData),
-		CorrelationID: c.correlationID,
-		SecretKey:     c.secretKey,
+	return &types.SessionInfo{
+		ServerURL:                c.serverURL.String(),
+		Token:                    c.token,
+		PrivateKey:               string(privateKeyData),
+		CorrelationID:            c.correlationID,
+		SecretKey:                c.secretKey,
+		DerivationKey:            c.derivationKey,
+		CorrelationIdNonceLength: c.CorrelationIdNonceLength,
+		Sequence:                 c.Sequence(),
+		PublicPorts:              c.publicPorts,
+		DisabledServices:         c.disabledServices,
 	}
+}
+
+// WriteSessionInfo writes the session to filename in yaml format
+func WriteSessionInfo(filename string, sessionInfo *types.SessionInfo) error {
 	data, err := yaml.Marshal(sessionInfo)
 	if err != nil {
 		return err
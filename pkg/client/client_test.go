@@ -0,0 +1,115 @@
+package client
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortInteractions(t *testing.T) {
+	now := time.Now()
+	interactions := []*server.Interaction{
+		{Protocol: "http", Sequence: 3, Timestamp: now},
+		{Protocol: "dns", Sequence: 1, Timestamp: now.Add(time.Second)},
+		{Protocol: "smtp", Sequence: 2, Timestamp: now},
+	}
+	sortInteractions(interactions)
+	require.Equal(t, "dns", interactions[0].Protocol, "could not sort by sequence")
+	require.Equal(t, "smtp", interactions[1].Protocol, "could not sort by sequence")
+	require.Equal(t, "http", interactions[2].Protocol, "could not sort by sequence")
+
+	legacy := []*server.Interaction{
+		{Protocol: "http", Timestamp: now.Add(time.Millisecond)},
+		{Protocol: "dns", Timestamp: now},
+	}
+	sortInteractions(legacy)
+	require.Equal(t, "dns", legacy[0].Protocol, "could not sort by timestamp without sequence")
+}
+
+func TestDecryptPollResponse(t *testing.T) {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err, "could not generate rsa key")
+	c := &Client{privKey: priv}
+
+	_, err = c.DecryptInteraction([]byte("data"))
+	require.NotNil(t, err, "could not reject unknown aes key")
+
+	aesKey := []byte("0123456789abcdef0123456789abcdef")
+	encryptedKey, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, &priv.PublicKey, aesKey, nil)
+	require.Nil(t, err, "could not encrypt aes key")
+	ciphertext, err := storage.AESEncrypt(aesKey, []byte(`{"protocol":"dns","sequence":2}`))
+	require.Nil(t, err, "could not encrypt interaction")
+	other, err := storage.AESEncrypt(aesKey, []byte(`{"protocol":"http","sequence":1}`))
+	require.Nil(t, err, "could not encrypt interaction")
+
+	data, err := jsoniter.Marshal(&server.PollResponse{Data: []string{ciphertext, other}, AESKey: base64.StdEncoding.EncodeToString(encryptedKey)})
+	require.Nil(t, err, "could not marshal poll response")
+	interactions, err := c.DecryptPollResponse(data)
+	require.Nil(t, err, "could not decrypt poll response")
+	require.Len(t, interactions, 2, "could not get interactions")
+	require.Equal(t, "http", interactions[0].Protocol, "could not sort interactions")
+
+	interaction, err := c.DecryptInteraction([]byte(ciphertext))
+	require.Nil(t, err, "could not decrypt interaction with known aes key")
+	require.Equal(t, "dns", interaction.Protocol, "could not decrypt interaction")
+
+	block, _ := pem.Decode(c.ExportPrivateKey())
+	require.NotNil(t, block, "could not export private key")
+	exported, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+	require.Nil(t, err, "could not parse exported private key")
+	require.True(t, priv.Equal(exported), "could not export the session private key")
+}
+
+// roundTripperFunc is a test double of the http transport
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestClientTransport(t *testing.T) {
+	var paths []string
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		paths = append(paths, req.URL.Path)
+		body := `{"message":"registration successful"}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header), Request: req}, nil
+	})
+
+	c, err := New(&Options{ServerURL: "oast.test", Transport: transport})
+	require.Nil(t, err, "could not create client with custom transport")
+	require.Equal(t, []string{"/register"}, paths, "could not use custom transport")
+	require.Equal(t, "https://oast.test", c.serverURL.String(), "could not register through custom transport")
+}
+
+func TestClientHeaders(t *testing.T) {
+	var header http.Header
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		header = req.Header.Clone()
+		body := `{"message":"registration successful"}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header), Request: req}, nil
+	})
+
+	headers, err := ParseHeaders([]string{"x-proxy-auth: secret, with comma", "Authorization: proxy"})
+	require.Nil(t, err, "could not parse headers")
+	_, err = New(&Options{ServerURL: "oast.test", Transport: transport, Token: "token", Headers: headers, UserAgent: "scanner/1.0"})
+	require.Nil(t, err, "could not create client with custom headers")
+	require.Equal(t, "secret, with comma", header.Get("X-Proxy-Auth"), "could not send custom header")
+	require.Equal(t, "scanner/1.0", header.Get("User-Agent"), "could not send custom user agent")
+	require.Equal(t, []string{"token"}, header.Values("Authorization"), "could not give precedence to the token")
+
+	_, err = ParseHeaders([]string{"invalid"})
+	require.NotNil(t, err, "could parse invalid header")
+}
@@ -0,0 +1,87 @@
+package client
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"strings"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// DecryptInteraction decrypts an interaction fetched out-of-band, like from a
+// mirrored poll response or a webhook push, without polling. The ciphertext is
+// base64 encoded as in the data of the poll responses, and it is decrypted with
+// the AES key of the session, learned from the polls or set with SetAESKey.
+func (c *Client) DecryptInteraction(ciphertext []byte) (*types.Interaction, error) {
+	c.aesKeyMutex.Lock()
+	aesKey := c.aesKey
+	c.aesKeyMutex.Unlock()
+	if aesKey == nil {
+		return nil, errors.New("aes key of the session is unknown")
+	}
+	plaintext, err := decryptData(aesKey, strings.TrimSpace(string(ciphertext)))
+	if err != nil {
+		return nil, errors.Wrap(err, "could not decrypt interaction")
+	}
+	interaction := &types.Interaction{}
+	if err := jsoniter.Unmarshal(plaintext, interaction); err != nil {
+		return nil, errors.Wrap(err, "could not unmarshal interaction")
+	}
+	return interaction, nil
+}
+
+// SetAESKey sets the AES key of the session from its RSA encrypted form, as
+// returned in the aes_key field of the poll responses.
+func (c *Client) SetAESKey(encryptedKey string) error {
+	c.keysMutex.RLock()
+	defer c.keysMutex.RUnlock()
+
+	keyPlaintext, err := c.decryptKey(encryptedKey)
+	if err != nil {
+		return errors.Wrap(err, "could not decrypt aes key")
+	}
+	c.setAESKey(keyPlaintext)
+	return nil
+}
+
+func (c *Client) setAESKey(key []byte) {
+	c.aesKeyMutex.Lock()
+	c.aesKey = key
+	c.aesKeyMutex.Unlock()
+}
+
+// DecryptPollResponse decrypts the interactions of a poll response in json
+// format fetched out-of-band, sorted in the order they were received.
+func (c *Client) DecryptPollResponse(data []byte) ([]*types.Interaction, error) {
+	response := &types.PollResponse{}
+	if err := jsoniter.Unmarshal(data, response); err != nil {
+		return nil, errors.Wrap(err, "could not decode poll response")
+	}
+	if len(response.Data) == 0 {
+		return nil, nil
+	}
+	if err := c.SetAESKey(response.AESKey); err != nil {
+		return nil, err
+	}
+	var interactions []*types.Interaction
+	for _, item := range response.Data {
+		interaction, err := c.DecryptInteraction([]byte(item))
+		if err != nil {
+			return nil, err
+		}
+		interactions = append(interactions, interaction)
+	}
+	sortInteractions(interactions)
+	return interactions, nil
+}
+
+// ExportPrivateKey returns the RSA private key of the session in PEM format, to
+// decrypt the interactions with external tools. It must be kept secret.
+func (c *Client) ExportPrivateKey() []byte {
+	c.keysMutex.RLock()
+	defer c.keysMutex.RUnlock()
+
+	return pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(c.privKey)})
+}
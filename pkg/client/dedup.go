@@ -0,0 +1,68 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// deduplicator collapses identical interactions received within a window
+// into a single one, reporting the number of occurrences in its Count.
+//
+// Interactions are identical when they have the same unique id, protocol
+// and remote address. They are held until the window since the first
+// occurrence expires, then delivered in the order they were first seen.
+type deduplicator struct {
+	sync.Mutex
+	window  time.Duration
+	pending map[string]*pendingInteraction
+	order   []string
+}
+
+type pendingInteraction struct {
+	interaction *types.Interaction
+	firstSeen   time.Time
+}
+
+func newDeduplicator(window time.Duration) *deduplicator {
+	return &deduplicator{window: window, pending: make(map[string]*pendingInteraction)}
+}
+
+// add records an interaction, collapsing it into a pending identical one if any
+func (d *deduplicator) add(interaction *types.Interaction) {
+	key := interaction.UniqueID + "|" + interaction.Protocol + "|" + interaction.RemoteAddress
+
+	d.Lock()
+	defer d.Unlock()
+
+	if pending, ok := d.pending[key]; ok {
+		pending.interaction.Count++
+		return
+	}
+	interaction.Count = 1
+	d.pending[key] = &pendingInteraction{interaction: interaction, firstSeen: time.Now()}
+	d.order = append(d.order, key)
+}
+
+// flush delivers to callback the interactions whose window expired, or all of them if force is set
+func (d *deduplicator) flush(callback InteractionCallback, force bool) {
+	d.Lock()
+	var expired []*types.Interaction
+	now := time.Now()
+	for len(d.order) > 0 {
+		key := d.order[0]
+		pending := d.pending[key]
+		if !force && now.Sub(pending.firstSeen) < d.window {
+			break
+		}
+		expired = append(expired, pending.interaction)
+		delete(d.pending, key)
+		d.order = d.order[1:]
+	}
+	d.Unlock()
+
+	for _, interaction := range expired {
+		callback(interaction)
+	}
+}
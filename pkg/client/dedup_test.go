@@ -0,0 +1,30 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeduplicator(t *testing.T) {
+	dedup := newDeduplicator(time.Hour)
+	for i := 0; i < 5; i++ {
+		dedup.add(&server.Interaction{UniqueID: "c8rf4e8xm4", Protocol: "dns", RemoteAddress: "127.0.0.1"})
+	}
+	dedup.add(&server.Interaction{UniqueID: "c8rf4e8xm4", Protocol: "http", RemoteAddress: "127.0.0.1"})
+
+	var delivered []*server.Interaction
+	callback := func(interaction *server.Interaction) {
+		delivered = append(delivered, interaction)
+	}
+	dedup.flush(callback, false)
+	require.Empty(t, delivered, "could not hold interactions within window")
+
+	dedup.flush(callback, true)
+	require.Len(t, delivered, 2, "could not collapse identical interactions")
+	require.Equal(t, 5, delivered[0].Count, "could not count identical interactions")
+	require.Equal(t, "http", delivered[1].Protocol, "could not keep interactions order")
+	require.Equal(t, 1, delivered[1].Count, "could not count single interaction")
+}
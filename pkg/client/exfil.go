@@ -0,0 +1,146 @@
+package client
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+const (
+	// maxExfilChunks is the maximum number of chunks of an exfiltrated payload
+	maxExfilChunks = 4096
+	// defaultExfilTimeout is the time after which incomplete payloads are dropped
+	defaultExfilTimeout = 5 * time.Minute
+)
+
+// exfilReassembler reassembles data exfiltrated through dns queries.
+//
+// Each query carries a chunk of the data in the labels preceding a header
+// label with the chunk index and the total number of chunks:
+//
+//	<data>[.<data>...].<index>-<total>.<unique-id>.<domain>
+//
+// Chunks are collected by unique id in any order, and once all of them have
+// been received the decoded data is delivered in a dns-exfil interaction.
+type exfilReassembler struct {
+	sync.Mutex
+	encoding string
+	timeout  time.Duration
+	pending  map[string]*exfilTransfer
+}
+
+type exfilTransfer struct {
+	chunks    map[int]string
+	total     int
+	firstSeen time.Time
+}
+
+func newExfilReassembler(encoding string, timeout time.Duration) (*exfilReassembler, error) {
+	switch encoding {
+	case "hex", "base32", "base64":
+	default:
+		return nil, fmt.Errorf("unsupported exfiltration encoding %s", encoding)
+	}
+	if timeout <= 0 {
+		timeout = defaultExfilTimeout
+	}
+	return &exfilReassembler{encoding: encoding, timeout: timeout, pending: make(map[string]*exfilTransfer)}, nil
+}
+
+// add records the chunk carried by a dns interaction, returning the reassembled
+// interaction if it was the last missing one.
+func (e *exfilReassembler) add(interaction *types.Interaction) *types.Interaction {
+	if interaction.Protocol != "dns" {
+		return nil
+	}
+	index, total, data, ok := parseExfilChunk(interaction.FullId)
+	if !ok {
+		return nil
+	}
+
+	e.Lock()
+	defer e.Unlock()
+
+	now := time.Now()
+	for uniqueID, transfer := range e.pending {
+		if now.Sub(transfer.firstSeen) > e.timeout {
+			delete(e.pending, uniqueID)
+		}
+	}
+
+	uniqueID := strings.ToLower(interaction.UniqueID)
+	transfer, ok := e.pending[uniqueID]
+	if !ok || transfer.total != total {
+		transfer = &exfilTransfer{chunks: make(map[int]string), total: total, firstSeen: now}
+		e.pending[uniqueID] = transfer
+	}
+	transfer.chunks[index] = data
+	if len(transfer.chunks) < transfer.total {
+		return nil
+	}
+	delete(e.pending, uniqueID)
+
+	indexes := make([]int, 0, len(transfer.chunks))
+	for index := range transfer.chunks {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	builder := &strings.Builder{}
+	for _, index := range indexes {
+		builder.WriteString(transfer.chunks[index])
+	}
+	decoded, err := decodeExfilData(e.encoding, builder.String())
+	if err != nil {
+		return nil
+	}
+	return &types.Interaction{
+		Protocol:      "dns-exfil",
+		UniqueID:      interaction.UniqueID,
+		FullId:        interaction.UniqueID,
+		RemoteAddress: interaction.RemoteAddress,
+		Timestamp:     interaction.Timestamp,
+		ExfilData:     decoded,
+	}
+}
+
+// parseExfilChunk returns the index, the total number of chunks and the
+// encoded data of a chunk from the full id of a dns interaction.
+func parseExfilChunk(fullID string) (int, int, string, bool) {
+	labels := strings.Split(fullID, ".")
+	if len(labels) < 3 {
+		return 0, 0, "", false
+	}
+	header := strings.SplitN(labels[len(labels)-2], "-", 2)
+	if len(header) != 2 {
+		return 0, 0, "", false
+	}
+	index, err := strconv.Atoi(header[0])
+	if err != nil {
+		return 0, 0, "", false
+	}
+	total, err := strconv.Atoi(header[1])
+	if err != nil || index < 0 || index >= total || total > maxExfilChunks {
+		return 0, 0, "", false
+	}
+	return index, total, strings.Join(labels[:len(labels)-2], ""), true
+}
+
+// decodeExfilData decodes the reassembled data. Base64 uses the url alphabet
+// without padding, as the standard one contains characters not valid in labels.
+func decodeExfilData(encoding, data string) ([]byte, error) {
+	switch encoding {
+	case "hex":
+		return hex.DecodeString(strings.ToLower(data))
+	case "base32":
+		return keyEncoding.DecodeString(strings.ToUpper(strings.TrimRight(data, "=")))
+	default:
+		return base64.RawURLEncoding.DecodeString(strings.TrimRight(data, "="))
+	}
+}
@@ -0,0 +1,42 @@
+package client
+
+import (
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExfilReassembler(t *testing.T) {
+	exfil, err := newExfilReassembler("hex", time.Minute)
+	require.Nil(t, err, "could not create reassembler")
+
+	uniqueID := "c6rj61aciaeutn2ae680cfjqdpeyyyyyy"
+	chunks := []string{"20776f.726c64.1-2." + uniqueID, "68656c6c6f.0-2." + uniqueID}
+
+	reassembled := exfil.add(&server.Interaction{Protocol: "dns", UniqueID: uniqueID, FullId: chunks[0]})
+	require.Nil(t, reassembled, "could not wait for missing chunks")
+	reassembled = exfil.add(&server.Interaction{Protocol: "http", UniqueID: uniqueID, FullId: chunks[1]})
+	require.Nil(t, reassembled, "could not ignore non dns interactions")
+	reassembled = exfil.add(&server.Interaction{Protocol: "dns", UniqueID: uniqueID, FullId: chunks[1]})
+	require.NotNil(t, reassembled, "could not reassemble chunks")
+	require.Equal(t, "dns-exfil", reassembled.Protocol, "could not get correct protocol")
+	require.Equal(t, []byte("hello world"), reassembled.ExfilData, "could not get correct data")
+
+	_, err = newExfilReassembler("rot13", time.Minute)
+	require.NotNil(t, err, "could not reject unsupported encoding")
+}
+
+func TestParseExfilChunk(t *testing.T) {
+	index, total, data, ok := parseExfilChunk("abc.def.2-3.uniqueid")
+	require.True(t, ok, "could not parse chunk")
+	require.Equal(t, 2, index, "could not get correct index")
+	require.Equal(t, 3, total, "could not get correct total")
+	require.Equal(t, "abcdef", data, "could not get correct data")
+
+	_, _, _, ok = parseExfilChunk("abc.3-3.uniqueid")
+	require.False(t, ok, "could parse chunk with invalid index")
+	_, _, _, ok = parseExfilChunk("abc.uniqueid")
+	require.False(t, ok, "could parse interaction without header")
+}
@@ -0,0 +1,170 @@
+package client
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+const (
+	// maxTrackedPayloads is the maximum number of payloads whose generation time
+	// is tracked, the oldest ones being forgotten first
+	maxTrackedPayloads = 100000
+	// maxLatencySamples is the maximum number of latencies kept for the percentiles,
+	// the oldest ones being dropped first
+	maxLatencySamples = 10000
+)
+
+// Stats contains the latencies between the generation of the payloads of a
+// client and their first interaction, a key signal for asynchronous or stored
+// payload vulnerabilities.
+type Stats struct {
+	// Payloads is the number of payloads tracked
+	Payloads int
+	// Interacted is the number of tracked payloads which received an interaction
+	Interacted int
+	// Min is the lowest time to first interaction
+	Min time.Duration
+	// Max is the highest time to first interaction
+	Max time.Duration
+	// P50 is the median time to first interaction
+	P50 time.Duration
+	// P90 is the 90th percentile of the time to first interaction
+	P90 time.Duration
+	// P99 is the 99th percentile of the time to first interaction
+	P99 time.Duration
+}
+
+// latencyTracker records the generation time of the payloads of a client and
+// the time to their first interaction.
+type latencyTracker struct {
+	sync.Mutex
+	generated map[string]time.Time
+	first     map[string]time.Duration
+	order     []string
+	samples   []time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{generated: make(map[string]time.Time), first: make(map[string]time.Duration)}
+}
+
+// track records the generation time of the payload of uniqueID, the first
+// generation being kept for the payloads derived deterministically.
+func (l *latencyTracker) track(uniqueID string) {
+	if l == nil {
+		return
+	}
+	uniqueID = strings.ToLower(uniqueID)
+
+	l.Lock()
+	defer l.Unlock()
+
+	if _, ok := l.generated[uniqueID]; ok {
+		return
+	}
+	if len(l.order) >= maxTrackedPayloads {
+		oldest := l.order[0]
+		l.order = l.order[1:]
+		delete(l.generated, oldest)
+		delete(l.first, oldest)
+	}
+	l.generated[uniqueID] = time.Now()
+	l.order = append(l.order, uniqueID)
+}
+
+// observe records the time to first interaction of the payload of the interaction
+func (l *latencyTracker) observe(interaction *types.Interaction) {
+	if l == nil {
+		return
+	}
+	uniqueID := strings.ToLower(interaction.UniqueID)
+
+	l.Lock()
+	defer l.Unlock()
+
+	generated, ok := l.generated[uniqueID]
+	if !ok {
+		return
+	}
+	if _, ok := l.first[uniqueID]; ok {
+		return
+	}
+	// the interactions are timestamped by the server when they are received
+	received := interaction.Timestamp
+	if received.IsZero() {
+		received = time.Now()
+	}
+	latency := received.Sub(generated)
+	if latency < 0 {
+		// clock skew between the client and the server
+		latency = 0
+	}
+	l.first[uniqueID] = latency
+	if len(l.samples) >= maxLatencySamples {
+		l.samples = l.samples[1:]
+	}
+	l.samples = append(l.samples, latency)
+}
+
+// timeToFirstInteraction returns the time to first interaction of the payload of uniqueID
+func (l *latencyTracker) timeToFirstInteraction(uniqueID string) (time.Duration, bool) {
+	if l == nil {
+		return 0, false
+	}
+	l.Lock()
+	defer l.Unlock()
+
+	latency, ok := l.first[strings.ToLower(uniqueID)]
+	return latency, ok
+}
+
+// stats returns the aggregated latencies of the tracked payloads
+func (l *latencyTracker) stats() *Stats {
+	stats := &Stats{}
+	if l == nil {
+		return stats
+	}
+	l.Lock()
+	stats.Payloads = len(l.generated)
+	stats.Interacted = len(l.first)
+	samples := make([]time.Duration, len(l.samples))
+	copy(samples, l.samples)
+	l.Unlock()
+
+	if len(samples) == 0 {
+		return stats
+	}
+	sort.Slice(samples, func(i, j int) bool { return samples[i] < samples[j] })
+	stats.Min = samples[0]
+	stats.Max = samples[len(samples)-1]
+	stats.P50 = percentile(samples, 50)
+	stats.P90 = percentile(samples, 90)
+	stats.P99 = percentile(samples, 99)
+	return stats
+}
+
+// percentile returns the nearest-rank percentile of the sorted samples
+func percentile(samples []time.Duration, p int) time.Duration {
+	rank := (p*len(samples) + 99) / 100
+	if rank < 1 {
+		rank = 1
+	}
+	return samples[rank-1]
+}
+
+// TimeToFirstInteraction returns the time between the generation of the payload
+// of uniqueID and its first interaction. The boolean is false if the payload
+// wasn't generated by the client or didn't receive any interaction yet.
+func (c *Client) TimeToFirstInteraction(uniqueID string) (time.Duration, bool) {
+	return c.latency.timeToFirstInteraction(uniqueID)
+}
+
+// Stats returns the percentiles of the time to first interaction of the
+// payloads generated by the client, over the last 10000 interacted payloads.
+func (c *Client) Stats() *Stats {
+	return c.latency.stats()
+}
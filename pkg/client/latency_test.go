@@ -0,0 +1,42 @@
+package client
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLatencyTracking(t *testing.T) {
+	c := &Client{correlationID: "c59e3crp82ke7bcnedq0", CorrelationIdNonceLength: 13, serverURL: &url.URL{Scheme: "https", Host: "oast.example"}, latency: newLatencyTracker()}
+
+	URL := c.URL()
+	uniqueID := strings.Split(URL, ".")[0]
+	_, ok := c.TimeToFirstInteraction(uniqueID)
+	require.False(t, ok, "could get latency without interaction")
+
+	generated := c.latency.generated[uniqueID]
+	c.latency.observe(&types.Interaction{UniqueID: strings.ToUpper(uniqueID), Timestamp: generated.Add(100 * time.Second)})
+	c.latency.observe(&types.Interaction{UniqueID: uniqueID, Timestamp: generated.Add(time.Hour)})
+	c.latency.observe(&types.Interaction{UniqueID: "unknown", Timestamp: generated.Add(time.Minute)})
+	latency, ok := c.TimeToFirstInteraction(uniqueID)
+	require.True(t, ok, "could not get latency")
+	require.Equal(t, 100*time.Second, latency, "could not keep first interaction latency")
+
+	for i := 1; i <= 99; i++ {
+		id := c.correlationID + strings.Repeat("x", 10) + string(rune('a'+i%26)) + string(rune('a'+i/26))
+		c.latency.track(id)
+		c.latency.observe(&types.Interaction{UniqueID: id, Timestamp: c.latency.generated[id].Add(time.Duration(i) * time.Second)})
+	}
+	stats := c.Stats()
+	require.Equal(t, 100, stats.Payloads, "could not count payloads")
+	require.Equal(t, 100, stats.Interacted, "could not count interacted payloads")
+	require.Equal(t, time.Second, stats.Min, "could not get min latency")
+	require.Equal(t, 100*time.Second, stats.Max, "could not get max latency")
+	require.Equal(t, 50*time.Second, stats.P50, "could not get median latency")
+	require.Equal(t, 90*time.Second, stats.P90, "could not get p90 latency")
+	require.Equal(t, 99*time.Second, stats.P99, "could not get p99 latency")
+}
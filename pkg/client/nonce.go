@@ -0,0 +1,36 @@
+package client
+
+import (
+	"math/big"
+
+	"github.com/projectdiscovery/interactsh/pkg/settings"
+	"gopkg.in/corvus-ch/zbase32.v1"
+)
+
+// encodeNonce encodes data as a nonce of length characters of the nonce
+// alphabet of the client. The default alphabet keeps the zbase32 encoding, so
+// that the nonces derived from keys don't change.
+func (c *Client) encodeNonce(data []byte, length int) string {
+	if c.nonceAlphabet == "" || c.nonceAlphabet == settings.NonceEncodings[settings.NonceEncodingDefault] {
+		nonce := zbase32.StdEncoding.EncodeToString(data)
+		if len(nonce) > length {
+			nonce = nonce[:length]
+		}
+		return nonce
+	}
+	return encodeAlphabet(data, c.nonceAlphabet, length)
+}
+
+// encodeAlphabet encodes data as a number in the base of the alphabet, returning
+// its length least significant digits.
+func encodeAlphabet(data []byte, alphabet string, length int) string {
+	value := new(big.Int).SetBytes(data)
+	base := big.NewInt(int64(len(alphabet)))
+	digit := new(big.Int)
+	nonce := make([]byte, length)
+	for i := range nonce {
+		value.DivMod(value, base, digit)
+		nonce[i] = alphabet[digit.Int64()]
+	}
+	return string(nonce)
+}
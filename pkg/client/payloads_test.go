@@ -0,0 +1,103 @@
+package client
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPayloads(t *testing.T) {
+	client := &Client{
+		correlationID:            "c6rj61aciaeutn2ae680",
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+		CorrelationIdNonceLength: 13,
+	}
+
+	payloads := client.Payloads("JNDI", "unknown")
+	require.Len(t, payloads, len(payloadTemplates["jndi"]), "could not render only known types")
+
+	hosts := make(map[string]struct{})
+	for _, payload := range payloads {
+		require.Equal(t, "jndi", payload.Type, "could not get correct type")
+		require.True(t, strings.HasSuffix(payload.Host, ".oast.pro"), "could not get interaction host")
+		require.Contains(t, payload.Value, payload.Host, "could not fill payload with host")
+		require.NotContains(t, payload.Value, hostPlaceholder, "could not replace placeholder")
+		hosts[payload.Host] = struct{}{}
+	}
+	require.Len(t, hosts, len(payloads), "could not generate a fresh host for each payload")
+
+	all := RenderPayloads(func() string { return "example.oast.pro" })
+	count := 0
+	for _, templates := range payloadTemplates {
+		count += len(templates)
+	}
+	require.Len(t, all, count, "could not render all types")
+}
+
+func TestPayloadsPublicPorts(t *testing.T) {
+	client := &Client{
+		correlationID:            "c6rj61aciaeutn2ae680",
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+		CorrelationIdNonceLength: 13,
+		publicPorts:              map[string]int{"http": 8080, "https": 443},
+	}
+
+	for _, payload := range client.Payloads("ssrf") {
+		require.NotContains(t, payload.Value, "{{", "could not replace placeholders")
+		if strings.HasPrefix(payload.Value, "http://") {
+			require.Equal(t, "http://"+payload.Host+":8080/", payload.Value, "could not use public http port")
+		}
+		if strings.HasPrefix(payload.Value, "https://") {
+			require.Equal(t, "https://"+payload.Host+"/", payload.Value, "could not omit default https port")
+		}
+	}
+	require.True(t, strings.HasSuffix(client.HTTPURL(), ".oast.pro:8080"), "could not get http url with public port")
+	require.False(t, strings.HasSuffix(client.HTTPSURL(), ":443"), "could not get https url without default port")
+}
+
+func TestPayloadsDisabledServices(t *testing.T) {
+	client := &Client{
+		correlationID:            "c6rj61aciaeutn2ae680",
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+		CorrelationIdNonceLength: 13,
+		disabledServices:         []string{"http", "ldap"},
+	}
+
+	payloads := client.Payloads()
+	require.NotEmpty(t, payloads, "could not render payloads of enabled services")
+	for _, payload := range payloads {
+		require.False(t, strings.HasPrefix(payload.Value, "http://"), "could render payload of disabled http")
+		require.NotContains(t, payload.Value, "ldap://", "could render payload of disabled ldap")
+	}
+	require.Empty(t, client.Payloads("xxe"), "could render payloads of disabled http")
+	require.Empty(t, client.HTTPURL(), "could get url of disabled http")
+	require.NotEmpty(t, client.HTTPSURL(), "could not get url of enabled https")
+}
+
+func TestURLIPv6Only(t *testing.T) {
+	client := &Client{
+		correlationID:            "c6rj61aciaeutn2ae680",
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+		CorrelationIdNonceLength: 13,
+		ipv6Only:                 true,
+	}
+	URL := client.URL()
+	require.True(t, strings.HasPrefix(URL, "c6rj61aciaeutn2ae680"), "could not keep unique id as first label")
+	require.True(t, strings.HasSuffix(URL, ".v6.oast.pro"), "could not get ipv6 only host")
+	require.True(t, strings.HasSuffix(client.URLForKey("template:target"), ".v6.oast.pro"), "could not get ipv6 only host for key")
+}
+
+func TestURLTenantSubdomain(t *testing.T) {
+	client := &Client{
+		correlationID:            "c6rj61aciaeutn2ae680",
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+		CorrelationIdNonceLength: 13,
+		ipv6Only:                 true,
+		subdomain:                "red",
+	}
+	URL := client.URL()
+	require.True(t, strings.HasPrefix(URL, "c6rj61aciaeutn2ae680"), "could not keep unique id as first label")
+	require.True(t, strings.HasSuffix(URL, ".red.v6.oast.pro"), "could not get tenant subdomain host")
+}
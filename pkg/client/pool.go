@@ -0,0 +1,221 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/remeh/sizedwaitgroup"
+	"go.uber.org/multierr"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// poolRegisterConcurrency is the maximum number of sessions of a pool registered concurrently
+const poolRegisterConcurrency = 10
+
+// PoolCallback is a callback function for an interaction reported to a session of a pool
+type PoolCallback func(client *Client, interaction *types.Interaction)
+
+// ClientPool manages many independent sessions of the same server, so that
+// large scans can partition their payloads across correlation ids. The URLs
+// are generated round-robin across the sessions, and the interactions of all
+// of them are delivered to a single callback.
+type ClientPool struct {
+	options *Options
+	next    uint64
+
+	mutex    sync.RWMutex
+	clients  []*Client
+	index    int
+	polling  bool
+	duration time.Duration
+	callback PoolCallback
+	// callbackMutex serializes the callbacks of the polling sessions
+	callbackMutex sync.Mutex
+}
+
+// NewClientPool creates a pool of size sessions registered with options. A seed
+// in the options derives a distinct seed for each session, so that distributed
+// workers sharing it create the same pool.
+func NewClientPool(size int, options *Options) (*ClientPool, error) {
+	if options.SessionInfo != nil {
+		return nil, errors.New("sessions can't be restored in a pool, add the restored clients instead")
+	}
+	pool := &ClientPool{options: options}
+
+	swg := sizedwaitgroup.New(poolRegisterConcurrency)
+	var errs error
+	var errsMutex sync.Mutex
+	for i := 0; i < size; i++ {
+		swg.Add()
+		go func() {
+			defer swg.Done()
+			if _, err := pool.Register(); err != nil {
+				errsMutex.Lock()
+				errs = multierr.Append(errs, err)
+				errsMutex.Unlock()
+			}
+		}()
+	}
+	swg.Wait()
+	if errs != nil {
+		_ = pool.Close()
+		return nil, errors.Wrap(errs, "could not register pool sessions")
+	}
+	return pool, nil
+}
+
+// Register registers a new session with the options of the pool and adds it to the pool
+func (p *ClientPool) Register() (*Client, error) {
+	p.mutex.Lock()
+	options := *p.options
+	if options.Seed != "" {
+		options.Seed = fmt.Sprintf("%s/%d", p.options.Seed, p.index)
+	}
+	p.index++
+	p.mutex.Unlock()
+
+	client, err := New(&options)
+	if err != nil {
+		return nil, err
+	}
+	p.Add(client)
+	return client, nil
+}
+
+// Add adds a client to the pool, for example a restored session. The client
+// starts polling if the pool is polling.
+func (p *ClientPool) Add(client *Client) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.clients = append(p.clients, client)
+	if p.polling {
+		p.startPolling(client)
+	}
+}
+
+// Clients returns the clients of the pool
+func (p *ClientPool) Clients() []*Client {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	clients := make([]*Client, len(p.clients))
+	copy(clients, p.clients)
+	return clients
+}
+
+// Len returns the number of clients of the pool
+func (p *ClientPool) Len() int {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+	return len(p.clients)
+}
+
+// Next returns the next client of the pool, round-robin, or nil if the pool is empty
+func (p *ClientPool) Next() *Client {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	if len(p.clients) == 0 {
+		return nil
+	}
+	next := atomic.AddUint64(&p.next, 1) - 1
+	return p.clients[next%uint64(len(p.clients))]
+}
+
+// URL returns a new URL of the next client of the pool, round-robin
+func (p *ClientPool) URL() string {
+	if client := p.Next(); client != nil {
+		return client.URL()
+	}
+	return ""
+}
+
+// ClientForInteraction returns the client of the pool whose session received
+// the interaction, or nil if there is none.
+func (p *ClientPool) ClientForInteraction(interaction *types.Interaction) *Client {
+	p.mutex.RLock()
+	defer p.mutex.RUnlock()
+
+	for _, client := range p.clients {
+		if strings.HasPrefix(strings.ToLower(interaction.UniqueID), client.correlationID) {
+			return client
+		}
+	}
+	return nil
+}
+
+// StartPolling starts polling the server for all the clients of the pool each
+// duration. The callback receives the interactions of all the sessions, one
+// at a time, with the client of the session.
+func (p *ClientPool) StartPolling(duration time.Duration, callback PoolCallback) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.polling = true
+	p.duration = duration
+	p.callback = callback
+	for _, client := range p.clients {
+		p.startPolling(client)
+	}
+}
+
+// startPolling starts polling for a client, the pool lock being held
+func (p *ClientPool) startPolling(client *Client) {
+	client.StartPolling(p.duration, func(interaction *types.Interaction) {
+		p.callbackMutex.Lock()
+		defer p.callbackMutex.Unlock()
+		p.callback(client, interaction)
+	})
+}
+
+// StopPolling stops the polling of all the clients of the pool
+func (p *ClientPool) StopPolling() {
+	p.mutex.Lock()
+	polling := p.polling
+	p.polling = false
+	clients := p.clients
+	p.mutex.Unlock()
+
+	if !polling {
+		return
+	}
+	// the interactions held for deduplication are delivered without the lock,
+	// the callback being free to use the pool
+	for _, client := range clients {
+		client.StopPolling()
+	}
+}
+
+// Close stops the polling and deregisters all the sessions of the pool,
+// returning the errors of the sessions which could not be deregistered.
+func (p *ClientPool) Close() error {
+	p.StopPolling()
+
+	p.mutex.Lock()
+	clients := p.clients
+	p.clients = nil
+	p.mutex.Unlock()
+
+	swg := sizedwaitgroup.New(poolRegisterConcurrency)
+	var errs error
+	var errsMutex sync.Mutex
+	for _, client := range clients {
+		swg.Add()
+		go func(client *Client) {
+			defer swg.Done()
+			if err := client.Close(); err != nil {
+				errsMutex.Lock()
+				errs = multierr.Append(errs, err)
+				errsMutex.Unlock()
+			}
+		}(client)
+	}
+	swg.Wait()
+	return errs
+}
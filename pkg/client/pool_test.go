@@ -0,0 +1,75 @@
+package client
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientPool(t *testing.T) {
+	var mutex sync.Mutex
+	requests := make(map[string]int)
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		mutex.Lock()
+		requests[req.URL.Path]++
+		mutex.Unlock()
+
+		body := `{"message":"registration successful"}`
+		if req.URL.Path == "/poll" {
+			body = fmt.Sprintf(`{"extra":["{\"protocol\":\"dns\",\"unique-id\":\"%sabcdefghijklm\"}"]}`, req.URL.Query().Get("id"))
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header), Request: req}, nil
+	})
+
+	_, err := NewClientPool(2, &Options{ServerURL: "oast.test", Transport: transport, SessionInfo: &types.SessionInfo{}})
+	require.NotNil(t, err, "could create pool restoring a session")
+
+	pool, err := NewClientPool(3, &Options{ServerURL: "oast.test", Transport: transport})
+	require.Nil(t, err, "could not create pool")
+	require.Equal(t, 3, pool.Len(), "could not register sessions")
+	require.Equal(t, 3, requests["/register"], "could not register sessions")
+
+	seen := make(map[*Client]bool)
+	for i := 0; i < 3; i++ {
+		client := pool.Next()
+		require.False(t, seen[client], "could not generate urls round-robin")
+		seen[client] = true
+	}
+	require.True(t, strings.HasPrefix(pool.URL(), pool.Clients()[0].correlationID), "could not restart round-robin")
+
+	_, err = pool.Register()
+	require.Nil(t, err, "could not register session")
+	require.Equal(t, 4, pool.Len(), "could not add session")
+
+	// the clients reporting the interactions and the clients owning them
+	received := make(chan [2]*Client, 100)
+	pool.StartPolling(10*time.Millisecond, func(client *Client, interaction *types.Interaction) {
+		select {
+		case received <- [2]*Client{client, pool.ClientForInteraction(interaction)}:
+		default:
+		}
+	})
+	polled := make(map[*Client]bool)
+	for len(polled) < 4 {
+		select {
+		case clients := <-received:
+			require.Equal(t, clients[0], clients[1], "could not get client of interaction")
+			polled[clients[0]] = true
+		case <-time.After(5 * time.Second):
+			require.Fail(t, "could not poll all the sessions")
+		}
+	}
+
+	require.Nil(t, pool.Close(), "could not close pool")
+	require.Equal(t, 0, pool.Len(), "could not remove sessions")
+	mutex.Lock()
+	require.Equal(t, 4, requests["/deregister"], "could not deregister sessions")
+	mutex.Unlock()
+}
@@ -0,0 +1,133 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"gopkg.in/corvus-ch/zbase32.v1"
+)
+
+const (
+	// seedIndexLength is the number of characters of the payload nonces encoding the index
+	seedIndexLength = 6
+	// MaxSeedIndex is the highest index of the payloads derived from a seed
+	MaxSeedIndex = 1<<(5*seedIndexLength) - 1
+	// minSeedNonceLength is the minimum nonce length, keeping 4 characters of HMAC
+	minSeedNonceLength = seedIndexLength + 4
+	// maxSeedNonceLength is the maximum nonce length, with the whole HMAC
+	maxSeedNonceLength = seedIndexLength + 52
+)
+
+// seedEncoding encodes the correlation ids derived from a seed with the
+// lowercase alphabet of the xid correlation ids.
+var seedEncoding = base32.HexEncoding.WithPadding(base32.NoPadding)
+
+// Seed derives a correlation id and payload hostnames from a secret seed, so
+// that distributed workers sharing the seed generate the same payloads without
+// registering, and any of them can correlate the resulting interactions.
+//
+// The nonce of a payload encodes its index followed by an HMAC of the index
+// with the seed, so the index is recovered from the interactions and payloads
+// can't be forged without the seed.
+type Seed struct {
+	key           []byte
+	correlationID string
+	nonceLength   int
+}
+
+// NewSeed returns the derivation of seed for the given correlation id and nonce lengths
+func NewSeed(seed string, correlationIdLength, nonceLength int) (*Seed, error) {
+	if seed == "" {
+		return nil, fmt.Errorf("empty seed")
+	}
+	if nonceLength < minSeedNonceLength || nonceLength > maxSeedNonceLength {
+		return nil, fmt.Errorf("correlation id nonce length %d is not between %d and %d", nonceLength, minSeedNonceLength, maxSeedNonceLength)
+	}
+	s := &Seed{key: []byte(seed), nonceLength: nonceLength}
+	correlationID := strings.ToLower(seedEncoding.EncodeToString(s.mac("correlation-id")))
+	if correlationIdLength > len(correlationID) {
+		return nil, fmt.Errorf("correlation id length %d is greater than %d", correlationIdLength, len(correlationID))
+	}
+	s.correlationID = correlationID[:correlationIdLength]
+	return s, nil
+}
+
+// CorrelationID returns the correlation id derived from the seed
+func (s *Seed) CorrelationID() string {
+	return s.correlationID
+}
+
+// SecretKey returns the secret key of the session derived from the seed
+func (s *Seed) SecretKey() string {
+	return hex.EncodeToString(s.mac("secret-key"))
+}
+
+// DerivationKey returns the key authenticating the payloads of URLForKey derived from the seed
+func (s *Seed) DerivationKey() string {
+	return hex.EncodeToString(s.mac("derivation-key"))
+}
+
+// URL returns the payload hostname of index under domain, or an empty
+// string if index is greater than MaxSeedIndex.
+func (s *Seed) URL(index uint64, domain string) string {
+	if index > MaxSeedIndex {
+		return ""
+	}
+	return s.correlationID + s.nonce(index) + "." + domain
+}
+
+// Index returns the index of the payload which triggered the interaction.
+// The boolean is false if the interaction wasn't triggered by a payload of the seed.
+func (s *Seed) Index(interaction *types.Interaction) (uint64, bool) {
+	uniqueID := strings.ToLower(interaction.UniqueID)
+	if len(uniqueID) != len(s.correlationID)+s.nonceLength || !strings.HasPrefix(uniqueID, s.correlationID) {
+		return 0, false
+	}
+	nonce := uniqueID[len(s.correlationID):]
+	index, err := strconv.ParseUint(nonce[:seedIndexLength], 32, 64)
+	if err != nil || index > MaxSeedIndex {
+		return 0, false
+	}
+	if !hmac.Equal([]byte(nonce), []byte(s.nonce(index))) {
+		return 0, false
+	}
+	return index, true
+}
+
+func (s *Seed) nonce(index uint64) string {
+	encodedIndex := strconv.FormatUint(index, 32)
+	encodedIndex = strings.Repeat("0", seedIndexLength-len(encodedIndex)) + encodedIndex
+	tag := zbase32.StdEncoding.EncodeToString(s.mac("payload:" + encodedIndex))
+	return encodedIndex + tag[:s.nonceLength-seedIndexLength]
+}
+
+func (s *Seed) mac(data string) []byte {
+	mac := hmac.New(sha256.New, s.key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// URLFromSeed returns the payload hostname of index derived from the seed of
+// the client options, or an empty string if the client has no seed.
+func (c *Client) URLFromSeed(index uint64) string {
+	if c.seed == nil || index > MaxSeedIndex {
+		return ""
+	}
+	c.latency.track(c.seed.correlationID + c.seed.nonce(index))
+	return c.seed.URL(index, c.host())
+}
+
+// IndexFromInteraction returns the index of the payload derived from the seed
+// of the client options which triggered the interaction.
+func (c *Client) IndexFromInteraction(interaction *types.Interaction) (uint64, bool) {
+	if c.seed == nil {
+		return 0, false
+	}
+	return c.seed.Index(interaction)
+}
@@ -0,0 +1,60 @@
+package client
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeedURL(t *testing.T) {
+	seed, err := NewSeed("shared-secret", 20, 13)
+	require.Nil(t, err, "could not create seed")
+	other, err := NewSeed("shared-secret", 20, 13)
+	require.Nil(t, err, "could not create seed")
+
+	require.Len(t, seed.CorrelationID(), 20, "could not get correlation id length")
+	require.Equal(t, seed.CorrelationID(), other.CorrelationID(), "could not derive the same correlation id")
+	require.Equal(t, seed.SecretKey(), other.SecretKey(), "could not derive the same secret key")
+
+	for _, index := range []uint64{0, 1, 42, MaxSeedIndex} {
+		URL := seed.URL(index, "oast.pro")
+		require.Equal(t, URL, other.URL(index, "oast.pro"), "could not derive the same url")
+
+		uniqueID := strings.TrimSuffix(URL, ".oast.pro")
+		require.Len(t, uniqueID, 33, "could not get unique id length")
+		got, ok := other.Index(&server.Interaction{UniqueID: strings.ToUpper(uniqueID)})
+		require.True(t, ok, "could not get index from interaction")
+		require.Equal(t, index, got, "could not get correct index")
+	}
+	require.Empty(t, seed.URL(MaxSeedIndex+1, "oast.pro"), "could get url beyond the maximum index")
+
+	uniqueID := strings.TrimSuffix(seed.URL(42, "oast.pro"), ".oast.pro")
+	forged := uniqueID[:20] + "000043" + uniqueID[26:]
+	_, ok := seed.Index(&server.Interaction{UniqueID: forged})
+	require.False(t, ok, "could verify forged payload")
+
+	different, err := NewSeed("other-secret", 20, 13)
+	require.Nil(t, err, "could not create seed")
+	require.NotEqual(t, seed.CorrelationID(), different.CorrelationID(), "could derive the same correlation id from another seed")
+
+	_, err = NewSeed("shared-secret", 20, 8)
+	require.NotNil(t, err, "could create seed with short nonce")
+}
+
+func TestClientURLFromSeed(t *testing.T) {
+	seed, err := NewSeed("shared-secret", 20, 13)
+	require.Nil(t, err, "could not create seed")
+	client := &Client{
+		correlationID:            seed.CorrelationID(),
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+		CorrelationIdNonceLength: 13,
+		seed:                     seed,
+	}
+	require.Equal(t, seed.URL(7, "oast.pro"), client.URLFromSeed(7), "could not get seeded url")
+
+	client.seed = nil
+	require.Empty(t, client.URLFromSeed(7), "could get seeded url without seed")
+}
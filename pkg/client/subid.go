@@ -0,0 +1,84 @@
+package client
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// maxLabelLength is the maximum length of a DNS label
+const maxLabelLength = 63
+
+// keyEncoding is used to store keys in DNS labels, which are case insensitive
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// ScannerKey returns a key identifying a template and target pair which
+// can be used with URLForKey to derive payload hostnames.
+func ScannerKey(templateID, target string) string {
+	targetHash := sha256.Sum256([]byte(target))
+	return templateID + ":" + hex.EncodeToString(targetHash[:8])
+}
+
+// URLForKey returns a payload URL deterministically derived from key.
+//
+// The key is encoded in the labels preceding the correlation id while the
+// nonce is an HMAC of the key with the derivation key of the session, so the key can be
+// recovered and verified from incoming interactions with KeyFromInteraction
+// without keeping track of the generated URLs.
+func (c *Client) URLForKey(key string) string {
+	encodedKey := strings.ToLower(keyEncoding.EncodeToString([]byte(key)))
+
+	builder := &strings.Builder{}
+	for len(encodedKey) > maxLabelLength {
+		builder.WriteString(encodedKey[:maxLabelLength])
+		builder.WriteString(".")
+		encodedKey = encodedKey[maxLabelLength:]
+	}
+	if encodedKey != "" {
+		builder.WriteString(encodedKey)
+		builder.WriteString(".")
+	}
+	nonce := c.keyNonce(key)
+	builder.WriteString(c.correlationID)
+	builder.WriteString(nonce)
+	builder.WriteString(".")
+	builder.WriteString(c.host())
+	c.latency.track(c.correlationID + nonce)
+	return builder.String()
+}
+
+// KeyFromInteraction returns the key used to generate the payload which
+// triggered the interaction. The boolean is false if the interaction wasn't
+// generated by URLForKey with the current client.
+func (c *Client) KeyFromInteraction(interaction *types.Interaction) (string, bool) {
+	labels := strings.Split(strings.ToLower(interaction.FullId), ".")
+	if len(labels) < 2 {
+		return "", false
+	}
+	uniqueID := labels[len(labels)-1]
+	if !strings.HasPrefix(uniqueID, c.correlationID) {
+		return "", false
+	}
+	decoded, err := keyEncoding.DecodeString(strings.ToUpper(strings.Join(labels[:len(labels)-1], "")))
+	if err != nil {
+		return "", false
+	}
+	key := string(decoded)
+	if !hmac.Equal([]byte(uniqueID[len(c.correlationID):]), []byte(c.keyNonce(key))) {
+		return "", false
+	}
+	return key, true
+}
+
+// keyNonce returns the nonce derived from the key and the derivation key.
+// The derivation key isn't sent to the server and survives the rotations of
+// the secret key, so the payloads stay verifiable for the lifetime of the session.
+func (c *Client) keyNonce(key string) string {
+	mac := hmac.New(sha256.New, []byte(c.derivationKey))
+	_, _ = mac.Write([]byte(key))
+	return c.encodeNonce(mac.Sum(nil), c.CorrelationIdNonceLength)
+}
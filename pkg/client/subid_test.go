@@ -0,0 +1,63 @@
+package client
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/projectdiscovery/interactsh/pkg/server"
+	"github.com/stretchr/testify/require"
+)
+
+func TestURLForKey(t *testing.T) {
+	client := &Client{
+		correlationID:            "c6rj61aciaeutn2ae680",
+		secretKey:                "a3f4c1e2-0000-4000-8000-1234567890ab",
+		derivationKey:            "5d0b8e6a-0000-4000-8000-1234567890ab",
+		serverURL:                &url.URL{Scheme: "https", Host: "oast.pro"},
+		CorrelationIdNonceLength: 13,
+	}
+	key := ScannerKey("CVE-2021-44228", "https://example.com/very/long/path/to/make/sure/the/key/spans/more/than/a/single/label")
+
+	URL := client.URLForKey(key)
+	require.Equal(t, URL, client.URLForKey(key), "could not derive the same url")
+	for _, label := range strings.Split(URL, ".") {
+		require.LessOrEqual(t, len(label), maxLabelLength, "could not split key in valid labels")
+	}
+
+	// the server reports the full id as the labels up to the unique id
+	fullID := strings.ToUpper(strings.TrimSuffix(URL, ".oast.pro"))
+	got, ok := client.KeyFromInteraction(&server.Interaction{FullId: fullID})
+	require.True(t, ok, "could not get key from interaction")
+	require.Equal(t, key, got, "could not get correct key")
+
+	tampered := "a" + fullID[1:]
+	_, ok = client.KeyFromInteraction(&server.Interaction{FullId: tampered})
+	require.False(t, ok, "could verify tampered key")
+}
+
+func TestURLForKeyAfterRotation(t *testing.T) {
+	transport := roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+		body := `{"message":"registration successful"}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body)), Header: make(http.Header), Request: req}, nil
+	})
+	client, err := New(&Options{ServerURL: "oast.test", Transport: transport})
+	require.Nil(t, err, "could not create client")
+
+	key := ScannerKey("CVE-2021-44228", "https://example.com")
+	fullID := strings.TrimSuffix(client.URLForKey(key), ".oast.test")
+	secretKey := client.secretKey
+	require.Nil(t, client.RotateKeys(true), "could not rotate keys")
+	require.NotEqual(t, secretKey, client.secretKey, "could not rotate secret key")
+
+	got, ok := client.KeyFromInteraction(&server.Interaction{FullId: fullID})
+	require.True(t, ok, "could not get key from interaction after rotation")
+	require.Equal(t, key, got, "could not get correct key after rotation")
+
+	restored, err := New(&Options{ServerURL: "oast.test", Transport: transport, SessionInfo: client.SessionInfo()})
+	require.Nil(t, err, "could not restore session")
+	_, ok = restored.KeyFromInteraction(&server.Interaction{FullId: fullID})
+	require.True(t, ok, "could not get key from interaction with restored session")
+}
@@ -0,0 +1,12 @@
+//go:build js && wasm
+
+package client
+
+import "net/http"
+
+// defaultTransport returns the transport of the requests to the server if none is
+// set. Sockets are not available in browsers, and the net/http transports without
+// custom dialers send the requests with the fetch api instead.
+func defaultTransport() http.RoundTripper {
+	return &http.Transport{}
+}
@@ -0,0 +1,11 @@
+//go:build !(js && wasm)
+
+package client
+
+import "net/http"
+
+// defaultTransport returns the transport of the requests to the server if none is
+// set, nil keeping the one of the retryable http client.
+func defaultTransport() http.RoundTripper {
+	return nil
+}
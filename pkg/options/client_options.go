@@ -21,4 +21,18 @@ type CLIClientOptions struct {
 	CorrelationIdLength      int
 	CorrelationIdNonceLength int
 	SessionFile              string
+	SessionKeychain          bool
+	TUI                      bool
+	DedupWindow              int
+	ExfilEncoding            string
+	IPv6Only                 bool
+	Headers                  goflags.StringSlice
+	UserAgent                string
+	Seed                     string
+	NonceEncoding            string
+	Export                   string
+	Import                   string
+	ArchivePassword          string
+	SigningKey               string
+	ArchiveSigner            string
 }
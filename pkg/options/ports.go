@@ -0,0 +1,68 @@
+package options
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// servicePort is the listening port of a service with its standard and unprivileged values
+type servicePort struct {
+	service      string
+	port         *int
+	standard     int
+	unprivileged int
+}
+
+func (cliServerOptions *CLIServerOptions) servicePorts() []servicePort {
+	return []servicePort{
+		{"dns", &cliServerOptions.DnsPort, 53, 5353},
+		{"http", &cliServerOptions.HttpPort, 80, 8080},
+		{"https", &cliServerOptions.HttpsPort, 443, 8443},
+		{"smtp", &cliServerOptions.SmtpPort, 25, 2525},
+		{"smtps", &cliServerOptions.SmtpsPort, 587, 2587},
+		{"smtp-autotls", &cliServerOptions.SmtpAutoTLSPort, 465, 2465},
+		{"ldap", &cliServerOptions.LdapPort, 389, 1389},
+		{"ftp", &cliServerOptions.FtpPort, 21, 2121},
+		{"smb", &cliServerOptions.SmbPort, 445, 4445},
+	}
+}
+
+// UseUnprivilegedPorts moves the services listening on their standard privileged
+// port to an unprivileged one, returning the standard ports as public ports of the
+// moved services, which have to be forwarded to them by NAT or firewall rules.
+func (cliServerOptions *CLIServerOptions) UseUnprivilegedPorts() map[string]int {
+	publicPorts := make(map[string]int)
+	for _, servicePort := range cliServerOptions.servicePorts() {
+		if *servicePort.port == servicePort.standard {
+			*servicePort.port = servicePort.unprivileged
+			publicPorts[servicePort.service] = servicePort.standard
+		}
+	}
+	return publicPorts
+}
+
+// ParsePublicPorts parses the service:port public port mappings
+func (cliServerOptions *CLIServerOptions) ParsePublicPorts() (map[string]int, error) {
+	services := make(map[string]struct{})
+	for _, servicePort := range cliServerOptions.servicePorts() {
+		services[servicePort.service] = struct{}{}
+	}
+	publicPorts := make(map[string]int)
+	for _, mapping := range cliServerOptions.PublicPorts {
+		parts := strings.SplitN(mapping, ":", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid public port %s, expected service:port", mapping)
+		}
+		service := strings.ToLower(strings.TrimSpace(parts[0]))
+		if _, ok := services[service]; !ok {
+			return nil, fmt.Errorf("unknown service %s in public port %s", service, mapping)
+		}
+		port, err := strconv.Atoi(strings.TrimSpace(parts[1]))
+		if err != nil || port < 1 || port > 65535 {
+			return nil, fmt.Errorf("invalid port in public port %s", mapping)
+		}
+		publicPorts[service] = port
+	}
+	return publicPorts, nil
+}
@@ -0,0 +1,289 @@
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/goflags"
+	"gopkg.in/yaml.v3"
+)
+
+// ServerConfig is the structured configuration file of the server, in yaml or
+// toml format. Unset values keep the defaults of the flags, and the flags given
+// on the command line override the values of the file.
+type ServerConfig struct {
+	Domains                  []string `yaml:"domains"`
+	IPAddress                string   `yaml:"ip"`
+	IPv6Address              string   `yaml:"ipv6"`
+	ListenIP                 string   `yaml:"listen-ip"`
+	Eviction                 *int     `yaml:"eviction"`
+	CorrelationIdLength      *int     `yaml:"correlation-id-length"`
+	CorrelationIdNonceLength *int     `yaml:"correlation-id-nonce-length"`
+	NonceEncodings           []string `yaml:"nonce-encodings"`
+	ScanEverywhere           *bool    `yaml:"scan-everywhere"`
+	ShutdownTimeout          *int     `yaml:"shutdown-timeout"`
+	Debug                    *bool    `yaml:"debug"`
+	InteractionRules         string   `yaml:"interaction-rules"`
+	LogFile                  string   `yaml:"log-file"`
+
+	Listeners struct {
+		DNS          *int     `yaml:"dns"`
+		HTTP         *int     `yaml:"http"`
+		HTTPS        *int     `yaml:"https"`
+		SMTP         *int     `yaml:"smtp"`
+		SMTPS        *int     `yaml:"smtps"`
+		SMTPAutoTLS  *int     `yaml:"smtp-autotls"`
+		LDAP         *int     `yaml:"ldap"`
+		FTP          *int     `yaml:"ftp"`
+		SMB          *int     `yaml:"smb"`
+		Unprivileged *bool    `yaml:"unprivileged"`
+		PublicPorts  []string `yaml:"public-ports"`
+	} `yaml:"listeners"`
+
+	Services struct {
+		LDAP         *bool    `yaml:"ldap"`
+		FTP          *bool    `yaml:"ftp"`
+		FTPDirectory string   `yaml:"ftp-dir"`
+		SMB          *bool    `yaml:"smb"`
+		Responder    *bool    `yaml:"responder"`
+		Wildcard     *bool    `yaml:"wildcard"`
+		Protocols    []string `yaml:"protocols"`
+		Disable      []string `yaml:"disable"`
+	} `yaml:"services"`
+
+	TLS struct {
+		Certificate string `yaml:"cert"`
+		PrivateKey  string `yaml:"privkey"`
+		SkipACME    *bool  `yaml:"skip-acme"`
+		Challenge   string `yaml:"acme-challenge"`
+	} `yaml:"tls"`
+
+	HTTP struct {
+		Index          string `yaml:"index"`
+		Directory      string `yaml:"directory"`
+		DynamicResp    *bool  `yaml:"dynamic-resp"`
+		OriginIPHeader string `yaml:"origin-ip-header"`
+	} `yaml:"http"`
+
+	// SMTP controls the responses of the smtp listeners
+	SMTP struct {
+		Banner  string `yaml:"banner"`
+		Relay   string `yaml:"relay"`
+		MaxSize string `yaml:"max-size"`
+	} `yaml:"smtp"`
+
+	DNS struct {
+		CustomRecords string `yaml:"custom-records"`
+		Zone          string `yaml:"zone"`
+	} `yaml:"dns"`
+
+	// Noise tags or drops the background noise of the internet scanners
+	Noise struct {
+		Filter   string `yaml:"filter"`
+		Scanners string `yaml:"scanners"`
+	} `yaml:"noise"`
+
+	Storage struct {
+		Disk         *bool  `yaml:"disk"`
+		Path         string `yaml:"path"`
+		ReplayWindow *int   `yaml:"replay-window"`
+		Search       *bool  `yaml:"search"`
+		// Retention limits the interactions history kept for the search api
+		RetentionAge    *int   `yaml:"retention-age"`
+		RetentionSize   string `yaml:"retention-size"`
+		Archive         string `yaml:"archive"`
+		ArchiveEndpoint string `yaml:"archive-endpoint"`
+	} `yaml:"storage"`
+
+	// Quotas limit the pending interactions of each correlation id
+	Quotas struct {
+		Interactions *int   `yaml:"interactions"`
+		Bytes        string `yaml:"bytes"`
+		Overflow     string `yaml:"overflow"`
+		// MemoryBudget is the size of the pending interactions in memory for all correlation ids
+		MemoryBudget string `yaml:"memory-budget"`
+	} `yaml:"quotas"`
+
+	// ACL contains the access control of the clients and of the admin api
+	ACL struct {
+		Auth        *bool  `yaml:"auth"`
+		Token       string `yaml:"token"`
+		OriginURL   string `yaml:"acao-url"`
+		APIKeysFile string `yaml:"api-keys-file"`
+		Tenants     string `yaml:"tenants"`
+	} `yaml:"acl"`
+
+	Exporters struct {
+		Syslog struct {
+			Address string `yaml:"address"`
+			Network string `yaml:"network"`
+			Format  string `yaml:"format"`
+		} `yaml:"syslog"`
+		Dashboard        *bool `yaml:"dashboard"`
+		DashboardHistory *int  `yaml:"dashboard-history"`
+		Metrics          *bool `yaml:"metrics"`
+	} `yaml:"exporters"`
+}
+
+// LoadServerConfig reads a server configuration file, in toml format if its
+// extension is .toml and in yaml format otherwise.
+func LoadServerConfig(path string) (*ServerConfig, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read config file")
+	}
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		var values map[string]interface{}
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return nil, errors.Wrap(err, "could not parse toml config")
+		}
+		// the toml values are decoded through yaml to share the field tags
+		if data, err = yaml.Marshal(values); err != nil {
+			return nil, errors.Wrap(err, "could not convert toml config")
+		}
+	}
+	config := &ServerConfig{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return nil, errors.Wrap(err, "could not decode config")
+	}
+	return config, nil
+}
+
+// Apply sets the options from the config, except the ones whose flags are
+// reported as set on the command line by isSet.
+func (config *ServerConfig) Apply(cliServerOptions *CLIServerOptions, isSet func(flags ...string) bool) {
+	setString := func(dst *string, value string, flags ...string) {
+		if value != "" && !isSet(flags...) {
+			*dst = value
+		}
+	}
+	setInt := func(dst *int, value *int, flags ...string) {
+		if value != nil && !isSet(flags...) {
+			*dst = *value
+		}
+	}
+	setBool := func(dst *bool, value *bool, flags ...string) {
+		if value != nil && !isSet(flags...) {
+			*dst = *value
+		}
+	}
+	setSlice := func(dst *goflags.StringSlice, value []string, flags ...string) {
+		if len(value) > 0 && !isSet(flags...) {
+			*dst = goflags.StringSlice(value)
+		}
+	}
+
+	setSlice(&cliServerOptions.Domains, config.Domains, "domain", "d")
+	setString(&cliServerOptions.IPAddress, config.IPAddress, "ip")
+	setString(&cliServerOptions.IPv6Address, config.IPv6Address, "ipv6")
+	setString(&cliServerOptions.ListenIP, config.ListenIP, "listen-ip", "lip")
+	setInt(&cliServerOptions.Eviction, config.Eviction, "eviction", "e")
+	setInt(&cliServerOptions.CorrelationIdLength, config.CorrelationIdLength, "correlation-id-length", "cidl")
+	setInt(&cliServerOptions.CorrelationIdNonceLength, config.CorrelationIdNonceLength, "correlation-id-nonce-length", "cidn")
+	setSlice(&cliServerOptions.NonceEncodings, config.NonceEncodings, "nonce-encodings", "nes")
+	setBool(&cliServerOptions.ScanEverywhere, config.ScanEverywhere, "scan-everywhere", "se")
+	setInt(&cliServerOptions.ShutdownTimeout, config.ShutdownTimeout, "shutdown-timeout", "st")
+	setBool(&cliServerOptions.Debug, config.Debug, "debug")
+	setString(&cliServerOptions.InteractionRules, config.InteractionRules, "interaction-rules", "ir")
+	setString(&cliServerOptions.LogFile, config.LogFile, "log-file", "lf")
+
+	listeners := &config.Listeners
+	setInt(&cliServerOptions.DnsPort, listeners.DNS, "dns-port")
+	setInt(&cliServerOptions.HttpPort, listeners.HTTP, "http-port")
+	setInt(&cliServerOptions.HttpsPort, listeners.HTTPS, "https-port")
+	setInt(&cliServerOptions.SmtpPort, listeners.SMTP, "smtp-port")
+	setInt(&cliServerOptions.SmtpsPort, listeners.SMTPS, "smtps-port")
+	setInt(&cliServerOptions.SmtpAutoTLSPort, listeners.SMTPAutoTLS, "smtp-autotls-port")
+	setInt(&cliServerOptions.LdapPort, listeners.LDAP, "ldap-port")
+	setInt(&cliServerOptions.FtpPort, listeners.FTP, "ftp-port")
+	setInt(&cliServerOptions.SmbPort, listeners.SMB, "smb-port")
+	setBool(&cliServerOptions.Unprivileged, listeners.Unprivileged, "unprivileged", "up")
+	setSlice(&cliServerOptions.PublicPorts, listeners.PublicPorts, "public-port", "pp")
+
+	services := &config.Services
+	setBool(&cliServerOptions.LdapWithFullLogger, services.LDAP, "ldap")
+	setBool(&cliServerOptions.Ftp, services.FTP, "ftp")
+	setString(&cliServerOptions.FTPDirectory, services.FTPDirectory, "ftp-dir")
+	setBool(&cliServerOptions.Smb, services.SMB, "smb")
+	setBool(&cliServerOptions.Responder, services.Responder, "responder")
+	setBool(&cliServerOptions.RootTLD, services.Wildcard, "wildcard", "wc")
+	setSlice(&cliServerOptions.Protocols, services.Protocols, "protocol", "proto")
+	setSlice(&cliServerOptions.DisabledServices, services.Disable, "disable-service", "dsv")
+
+	setString(&cliServerOptions.CertificatePath, config.TLS.Certificate, "cert")
+	setString(&cliServerOptions.PrivateKeyPath, config.TLS.PrivateKey, "privkey")
+	setBool(&cliServerOptions.SkipAcme, config.TLS.SkipACME, "skip-acme", "sa")
+	setString(&cliServerOptions.AcmeChallenge, config.TLS.Challenge, "acme-challenge", "ach")
+
+	setString(&cliServerOptions.HTTPIndex, config.HTTP.Index, "http-index", "hi")
+	setString(&cliServerOptions.HTTPDirectory, config.HTTP.Directory, "http-directory", "hd")
+	setBool(&cliServerOptions.DynamicResp, config.HTTP.DynamicResp, "dynamic-resp", "dr")
+	setString(&cliServerOptions.OriginIPHeader, config.HTTP.OriginIPHeader, "origin-ip-header", "oih")
+
+	setString(&cliServerOptions.SMTPBanner, config.SMTP.Banner, "smtp-banner")
+	setString(&cliServerOptions.SMTPRelay, config.SMTP.Relay, "smtp-relay")
+	setString(&cliServerOptions.SMTPMaxSize, config.SMTP.MaxSize, "smtp-max-size")
+
+	setString(&cliServerOptions.CustomRecords, config.DNS.CustomRecords, "custom-records", "cr")
+	setString(&cliServerOptions.DNSZone, config.DNS.Zone, "dns-zone", "dz")
+	setString(&cliServerOptions.NoiseFilter, config.Noise.Filter, "noise-filter", "nf")
+	setString(&cliServerOptions.NoiseScanners, config.Noise.Scanners, "noise-scanners", "nsc")
+
+	setBool(&cliServerOptions.DiskStorage, config.Storage.Disk, "disk", "ds")
+	setString(&cliServerOptions.DiskStoragePath, config.Storage.Path, "disk-path", "dsp")
+	setInt(&cliServerOptions.ReplayWindow, config.Storage.ReplayWindow, "replay-window", "rw")
+	setBool(&cliServerOptions.Search, config.Storage.Search, "search")
+	setInt(&cliServerOptions.RetentionAge, config.Storage.RetentionAge, "retention-age", "ra")
+	setString(&cliServerOptions.RetentionSize, config.Storage.RetentionSize, "retention-size", "rs")
+	setString(&cliServerOptions.Archive, config.Storage.Archive, "archive")
+	setString(&cliServerOptions.ArchiveEndpoint, config.Storage.ArchiveEndpoint, "archive-endpoint")
+	setInt(&cliServerOptions.QuotaInteractions, config.Quotas.Interactions, "quota-interactions", "qi")
+	setString(&cliServerOptions.QuotaBytes, config.Quotas.Bytes, "quota-bytes", "qb")
+	setString(&cliServerOptions.QuotaOverflow, config.Quotas.Overflow, "quota-overflow", "qo")
+	setString(&cliServerOptions.MemoryBudget, config.Quotas.MemoryBudget, "memory-budget", "mbu")
+
+	setBool(&cliServerOptions.Auth, config.ACL.Auth, "auth", "a")
+	setString(&cliServerOptions.Token, config.ACL.Token, "token", "t")
+	setString(&cliServerOptions.OriginURL, config.ACL.OriginURL, "acao-url")
+	setString(&cliServerOptions.APIKeysFile, config.ACL.APIKeysFile, "api-keys-file", "akf")
+	setString(&cliServerOptions.Tenants, config.ACL.Tenants, "tenants")
+
+	exporters := &config.Exporters
+	setString(&cliServerOptions.SyslogAddress, exporters.Syslog.Address, "syslog")
+	setString(&cliServerOptions.SyslogNetwork, exporters.Syslog.Network, "syslog-network", "sn")
+	setString(&cliServerOptions.SyslogFormat, exporters.Syslog.Format, "syslog-format", "sfmt")
+	setBool(&cliServerOptions.Dashboard, exporters.Dashboard, "dashboard")
+	setInt(&cliServerOptions.DashboardHistory, exporters.DashboardHistory, "dashboard-history", "dh")
+	setBool(&cliServerOptions.EnableMetrics, exporters.Metrics, "metrics")
+}
+
+// FlagsSet returns a function reporting if any of the flags is set in args
+func FlagsSet(args []string) func(flags ...string) bool {
+	set := make(map[string]struct{})
+	for _, arg := range args {
+		if arg == "--" {
+			break
+		}
+		if !strings.HasPrefix(arg, "-") {
+			continue
+		}
+		name := strings.TrimLeft(arg, "-")
+		if index := strings.Index(name, "="); index >= 0 {
+			name = name[:index]
+		}
+		set[name] = struct{}{}
+	}
+	return func(flags ...string) bool {
+		for _, flag := range flags {
+			if _, ok := set[flag]; ok {
+				return true
+			}
+		}
+		return false
+	}
+}
@@ -0,0 +1,86 @@
+package options
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadServerConfig(t *testing.T) {
+	yamlConfig := `
+domains: [oast.example, oast.test]
+listeners:
+  dns: 5353
+  public-ports: ["dns:53"]
+tls:
+  cert: /etc/interactsh/cert.pem
+acl:
+  auth: true
+exporters:
+  syslog:
+    address: 127.0.0.1:514
+`
+	tomlConfig := `
+domains = [
+  "oast.example", # primary
+  "oast.test",
+]
+
+[listeners]
+dns = 5353
+public-ports = ["dns:53"]
+
+[tls]
+cert = "/etc/interactsh/cert.pem"
+
+[acl]
+auth = true
+
+[exporters.syslog]
+address = '127.0.0.1:514'
+`
+	dir := t.TempDir()
+	for name, data := range map[string]string{"config.yaml": yamlConfig, "config.toml": tomlConfig} {
+		path := filepath.Join(dir, name)
+		require.Nil(t, os.WriteFile(path, []byte(data), 0600), "could not write config")
+
+		config, err := LoadServerConfig(path)
+		require.Nil(t, err, "could not load %s", name)
+
+		cliOptions := &CLIServerOptions{DnsPort: 53, HttpPort: 80, CertificatePath: "/tmp/flag.pem"}
+		config.Apply(cliOptions, FlagsSet([]string{"-cert", "/tmp/flag.pem", "-http-port=8000"}))
+		require.Equal(t, []string{"oast.example", "oast.test"}, []string(cliOptions.Domains), "could not apply domains from %s", name)
+		require.Equal(t, 5353, cliOptions.DnsPort, "could not apply dns port from %s", name)
+		require.Equal(t, 80, cliOptions.HttpPort, "could not keep default http port with %s", name)
+		require.Equal(t, []string{"dns:53"}, []string(cliOptions.PublicPorts), "could not apply public ports from %s", name)
+		require.Equal(t, "/tmp/flag.pem", cliOptions.CertificatePath, "could not give precedence to flag over %s", name)
+		require.True(t, cliOptions.Auth, "could not apply auth from %s", name)
+		require.Equal(t, "127.0.0.1:514", cliOptions.SyslogAddress, "could not apply syslog address from %s", name)
+	}
+
+	// strings with brackets, multi-line strings and inline tables
+	path := filepath.Join(dir, "full.toml")
+	require.Nil(t, os.WriteFile(path, []byte(`
+domains = [
+  "oast.example", # [primary]
+]
+listen-ip = "::1"
+tls = { cert = "/etc/interactsh/[oast]/cert.pem" }
+
+[http]
+index = """
+/var/www/index.html"""
+`), 0600), "could not write config")
+	config, err := LoadServerConfig(path)
+	require.Nil(t, err, "could not load toml config")
+	require.Equal(t, []string{"oast.example"}, config.Domains, "could not get domains")
+	require.Equal(t, "/etc/interactsh/[oast]/cert.pem", config.TLS.Certificate, "could not get string with brackets")
+	require.Equal(t, "/var/www/index.html", config.HTTP.Index, "could not get multi-line string")
+
+	path = filepath.Join(dir, "invalid.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("listeners:\n  gopher: 70\n"), 0600), "could not write config")
+	_, err = LoadServerConfig(path)
+	require.NotNil(t, err, "could not reject unknown field")
+}
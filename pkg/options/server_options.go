@@ -7,11 +7,13 @@ import (
 
 type CLIServerOptions struct {
 	Config                   string
+	ServerConfig             string
 	Version                  bool
 	Debug                    bool
 	Domains                  goflags.StringSlice
 	DnsPort                  int
 	IPAddress                string
+	IPv6Address              string
 	ListenIP                 string
 	HttpPort                 int
 	HttpsPort                int
@@ -24,6 +26,9 @@ type CLIServerOptions struct {
 	SmtpPort                 int
 	SmtpsPort                int
 	SmtpAutoTLSPort          int
+	SMTPBanner               string
+	SMTPRelay                string
+	SMTPMaxSize              string
 	FtpPort                  int
 	LdapPort                 int
 	Ftp                      bool
@@ -35,18 +40,47 @@ type CLIServerOptions struct {
 	RootTLD                  bool
 	FTPDirectory             string
 	SkipAcme                 bool
+	AcmeChallenge            string
 	DynamicResp              bool
 	CorrelationIdLength      int
 	CorrelationIdNonceLength int
 	ScanEverywhere           bool
 	CertificatePath          string
 	CustomRecords            string
+	DNSZone                  string
+	InteractionRules         string
+	NoiseFilter              string
+	NoiseScanners            string
+	LogFile                  string
 	PrivateKeyPath           string
 	OriginIPHeader           string
 	DiskStorage              bool
 	DiskStoragePath          string
+	Search                   bool
+	RetentionAge             int
+	RetentionSize            string
+	Archive                  string
+	ArchiveEndpoint          string
 	EnablePprof              bool
 	EnableMetrics            bool
+	SyslogAddress            string
+	SyslogNetwork            string
+	SyslogFormat             string
+	Dashboard                bool
+	DashboardHistory         int
+	ReplayWindow             int
+	QuotaInteractions        int
+	QuotaBytes               string
+	QuotaOverflow            string
+	MemoryBudget             string
+	ShutdownTimeout          int
+	APIKeysFile              string
+	Tenants                  string
+	Unprivileged             bool
+	PublicPorts              goflags.StringSlice
+	Protocols                goflags.StringSlice
+	DisabledServices         goflags.StringSlice
+	NonceEncodings           goflags.StringSlice
 }
 
 func (cliServerOptions *CLIServerOptions) AsServerOptions() *server.Options {
@@ -54,6 +88,7 @@ func (cliServerOptions *CLIServerOptions) AsServerOptions() *server.Options {
 		Domains:                  cliServerOptions.Domains,
 		DnsPort:                  cliServerOptions.DnsPort,
 		IPAddress:                cliServerOptions.IPAddress,
+		IPv6Address:              cliServerOptions.IPv6Address,
 		ListenIP:                 cliServerOptions.ListenIP,
 		HttpPort:                 cliServerOptions.HttpPort,
 		HttpsPort:                cliServerOptions.HttpsPort,
@@ -78,10 +113,12 @@ func (cliServerOptions *CLIServerOptions) AsServerOptions() *server.Options {
 		ScanEverywhere:           cliServerOptions.ScanEverywhere,
 		CertificatePath:          cliServerOptions.CertificatePath,
 		CustomRecords:            cliServerOptions.CustomRecords,
+		DNSZone:                  cliServerOptions.DNSZone,
 		PrivateKeyPath:           cliServerOptions.PrivateKeyPath,
 		OriginIPHeader:           cliServerOptions.OriginIPHeader,
 		DiskStorage:              cliServerOptions.DiskStorage,
 		DiskStoragePath:          cliServerOptions.DiskStoragePath,
 		EnableMetrics:            cliServerOptions.EnableMetrics,
+		LdapWithFullLogger:       cliServerOptions.LdapWithFullLogger,
 	}
 }
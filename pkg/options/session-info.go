@@ -1,9 +1,6 @@
 package options
 
-type SessionInfo struct {
-	ServerURL     string `yaml:"server-url"`
-	Token         string `yaml:"server-token"`
-	PrivateKey    string `yaml:"private-key"`
-	CorrelationID string `yaml:"correlation-id"`
-	SecretKey     string `yaml:"secret-key"`
-}
+import "github.com/projectdiscovery/interactsh/pkg/types"
+
+// SessionInfo is the session of a client saved to resume it later
+type SessionInfo = types.SessionInfo
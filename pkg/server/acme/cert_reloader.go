@@ -0,0 +1,57 @@
+package acme
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// CertificateReloader serves a certificate loaded from files, which can be
+// loaded again without restarting the listeners using it.
+type CertificateReloader struct {
+	sync.RWMutex
+	certificate *tls.Certificate
+}
+
+// NewCertificateReloader loads the certificate and private key at the paths
+func NewCertificateReloader(certPath, privKeyPath string) (*CertificateReloader, error) {
+	reloader := &CertificateReloader{}
+	if err := reloader.Reload(certPath, privKeyPath); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// Reload loads the certificate and private key at the paths, keeping the
+// current certificate if they can't be loaded.
+func (r *CertificateReloader) Reload(certPath, privKeyPath string) error {
+	cert, err := tls.LoadX509KeyPair(certPath, privKeyPath)
+	if err != nil {
+		return errors.Wrap(err, "could not load certs and private key")
+	}
+	r.Lock()
+	r.certificate = &cert
+	r.Unlock()
+	return nil
+}
+
+// GetCertificate returns the current certificate for the tls handshakes
+func (r *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.RLock()
+	defer r.RUnlock()
+	return r.certificate, nil
+}
+
+// BuildTlsConfigWithCertificateReloader with a reloadable certificate
+func BuildTlsConfigWithCertificateReloader(domain string, reloader *CertificateReloader) *tls.Config {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: true,
+		GetCertificate:     reloader.GetCertificate,
+	}
+	if domain != "" {
+		tlsConfig.ServerName = domain
+	}
+	tlsConfig.NextProtos = []string{"h2", "http/1.1"}
+	return tlsConfig
+}
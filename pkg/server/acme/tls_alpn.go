@@ -0,0 +1,65 @@
+package acme
+
+import (
+	"context"
+	"crypto/tls"
+	"strings"
+
+	"github.com/caddyserver/certmagic"
+	"github.com/projectdiscovery/gologger"
+	"go.uber.org/zap"
+)
+
+// Challenges supported for issuing the certificates
+const (
+	// ChallengeDNS01 issues wildcard certificates through the dns server
+	ChallengeDNS01 = "dns-01"
+	// ChallengeTLSALPN01 issues certificates for the domains through the https listener
+	ChallengeTLSALPN01 = "tls-alpn-01"
+)
+
+// HandleTLSALPNCertificates handles ACME cert generation for the domains with the
+// TLS-ALPN-01 challenge using certmagic library from caddyserver. Wildcard certs
+// can't be issued with this challenge. The challenges are answered on httpsPort,
+// by a temporary listener for the first issuance and by the returned tls config
+// for the renewals, which are managed in the background.
+func HandleTLSALPNCertificates(domains []string, email, listenIP string, httpsPort int, debug bool) (*tls.Config, error) {
+	logger, err := zap.NewProduction()
+	if err != nil {
+		return nil, err
+	}
+	certmagic.DefaultACME.Agreed = true
+	certmagic.DefaultACME.Email = email
+	certmagic.DefaultACME.CA = certmagic.LetsEncryptProductionCA
+	if debug {
+		certmagic.DefaultACME.Logger = logger
+	}
+	certmagic.DefaultACME.DisableHTTPChallenge = true
+	certmagic.DefaultACME.DisableTLSALPNChallenge = false
+	certmagic.DefaultACME.DNS01Solver = nil
+	certmagic.DefaultACME.AltTLSALPNPort = httpsPort
+	if listenIP != "0.0.0.0" && listenIP != "::" {
+		certmagic.DefaultACME.ListenHost = listenIP
+	}
+
+	cfg := certmagic.NewDefault()
+	if debug {
+		cfg.Logger = logger
+	}
+
+	var names []string
+	for _, domain := range domains {
+		names = append(names, strings.TrimSuffix(domain, "."))
+	}
+	gologger.Info().Msgf("Requesting or loading SSL Certificates with TLS-ALPN-01 for: %s", strings.Join(names, ", "))
+
+	// this obtains certificates or loads them, and keeps renewing them
+	if err := cfg.ManageSync(context.Background(), names); err != nil {
+		return nil, err
+	}
+
+	// the certmagic tls config answers the acme-tls/1 challenges of the renewals
+	tlsConfig := cfg.TLSConfig()
+	tlsConfig.InsecureSkipVerify = true
+	return tlsConfig, nil
+}
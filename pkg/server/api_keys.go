@@ -0,0 +1,390 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+// Scopes of the admin api keys
+const (
+	// ScopeStats allows reading the server metrics and statistics
+	ScopeStats = "stats"
+	// ScopeEvict allows evicting the sessions of clients
+	ScopeEvict = "evict"
+	// ScopeReload allows reloading the server configuration
+	ScopeReload = "reload"
+	// ScopeKeys allows creating, listing and revoking api keys
+	ScopeKeys = "keys"
+	// ScopeSearch allows searching the interactions history
+	ScopeSearch = "search"
+)
+
+// apiKeyHeader is the header carrying the admin api key
+const apiKeyHeader = "X-API-Key"
+
+// apiKeyPrefix is the prefix of the generated api keys
+const apiKeyPrefix = "isk_"
+
+// APIScopes are the supported scopes of the admin api keys
+var APIScopes = []string{ScopeStats, ScopeEvict, ScopeReload, ScopeKeys, ScopeSearch}
+
+// APIKey is an admin api key, of which only the hash is kept
+type APIKey struct {
+	ID      string    `json:"id"`
+	Name    string    `json:"name,omitempty"`
+	Scopes  []string  `json:"scopes"`
+	Hash    string    `json:"hash,omitempty"`
+	Created time.Time `json:"created"`
+}
+
+// hasScope returns true if the key carries the scope
+func (k *APIKey) hasScope(scope string) bool {
+	for _, keyScope := range k.Scopes {
+		if keyScope == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeys contains the admin api keys, optionally persisted to a file
+type APIKeys struct {
+	sync.RWMutex
+	path string
+	keys map[string]*APIKey
+}
+
+// NewAPIKeys returns the api keys persisted in path, which is created on the first
+// change if it doesn't exist. The keys are kept in memory only if path is empty.
+func NewAPIKeys(path string) (*APIKeys, error) {
+	apiKeys := &APIKeys{path: path}
+	keys, err := apiKeys.load()
+	if err != nil {
+		return nil, err
+	}
+	apiKeys.keys = keys
+	return apiKeys, nil
+}
+
+// errNoAPIKeys is returned when managing the keys of a server without api keys
+var errNoAPIKeys = errors.New("api keys are not enabled")
+
+// Reload loads again the keys from the file, keeping the current keys if it can't be read
+func (a *APIKeys) Reload() error {
+	if a == nil {
+		return nil
+	}
+	keys, err := a.load()
+	if err != nil {
+		return err
+	}
+	a.Lock()
+	a.keys = keys
+	a.Unlock()
+	return nil
+}
+
+// load reads the keys from the file, if any
+func (a *APIKeys) load() (map[string]*APIKey, error) {
+	keys := make(map[string]*APIKey)
+	if a.path == "" {
+		return keys, nil
+	}
+	data, err := os.ReadFile(a.path)
+	if os.IsNotExist(err) {
+		return keys, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read api keys")
+	}
+	var stored []*APIKey
+	if err := jsoniter.Unmarshal(data, &stored); err != nil {
+		return nil, errors.Wrap(err, "could not decode api keys")
+	}
+	for _, key := range stored {
+		keys[key.ID] = key
+	}
+	return keys, nil
+}
+
+// Create creates a key with scopes, returning it with the plaintext key which can't be retrieved later.
+func (a *APIKeys) Create(name string, scopes []string) (*APIKey, string, error) {
+	if a == nil {
+		return nil, "", errNoAPIKeys
+	}
+	if len(scopes) == 0 {
+		return nil, "", errors.New("no scopes specified")
+	}
+	for _, scope := range scopes {
+		if !isAPIScope(scope) {
+			return nil, "", fmt.Errorf("unknown scope %s", scope)
+		}
+	}
+	secret, err := randomHex(24)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "could not generate api key")
+	}
+	id, err := randomHex(8)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "could not generate api key id")
+	}
+	plaintext := apiKeyPrefix + secret
+	key := &APIKey{ID: id, Name: name, Scopes: scopes, Hash: hashAPIKey(plaintext), Created: time.Now().UTC()}
+
+	a.Lock()
+	defer a.Unlock()
+	a.keys[id] = key
+	if err := a.save(); err != nil {
+		delete(a.keys, id)
+		return nil, "", err
+	}
+	return key, plaintext, nil
+}
+
+// Revoke removes the key with id
+func (a *APIKeys) Revoke(id string) error {
+	if a == nil {
+		return errNoAPIKeys
+	}
+	a.Lock()
+	defer a.Unlock()
+
+	key, ok := a.keys[id]
+	if !ok {
+		return errors.New("could not find api key")
+	}
+	delete(a.keys, id)
+	if err := a.save(); err != nil {
+		a.keys[id] = key
+		return err
+	}
+	return nil
+}
+
+// List returns the keys without their hashes, sorted by creation time
+func (a *APIKeys) List() []APIKey {
+	if a == nil {
+		return []APIKey{}
+	}
+	a.RLock()
+	defer a.RUnlock()
+
+	keys := make([]APIKey, 0, len(a.keys))
+	for _, key := range a.keys {
+		listed := *key
+		listed.Hash = ""
+		keys = append(keys, listed)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Created.Before(keys[j].Created)
+	})
+	return keys
+}
+
+// Allowed returns true if the plaintext key exists and carries the scope
+func (a *APIKeys) Allowed(plaintext, scope string) bool {
+	key := a.find(plaintext)
+	return key != nil && key.hasScope(scope)
+}
+
+// Valid returns true if the plaintext key exists, whatever its scopes
+func (a *APIKeys) Valid(plaintext string) bool {
+	return a.find(plaintext) != nil
+}
+
+// find returns the key matching plaintext, or nil if there is none
+func (a *APIKeys) find(plaintext string) *APIKey {
+	if a == nil || plaintext == "" {
+		return nil
+	}
+	hash := hashAPIKey(plaintext)
+
+	a.RLock()
+	defer a.RUnlock()
+	for _, key := range a.keys {
+		if subtle.ConstantTimeCompare([]byte(key.Hash), []byte(hash)) == 1 {
+			return key
+		}
+	}
+	return nil
+}
+
+// save writes the keys to the file, if any
+func (a *APIKeys) save() error {
+	if a.path == "" {
+		return nil
+	}
+	keys := make([]*APIKey, 0, len(a.keys))
+	for _, key := range a.keys {
+		keys = append(keys, key)
+	}
+	data, err := jsoniter.MarshalIndent(keys, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "could not encode api keys")
+	}
+	if err := os.WriteFile(a.path, data, 0600); err != nil {
+		return errors.Wrap(err, "could not write api keys")
+	}
+	return nil
+}
+
+func isAPIScope(scope string) bool {
+	for _, apiScope := range APIScopes {
+		if scope == apiScope {
+			return true
+		}
+	}
+	return false
+}
+
+func hashAPIKey(plaintext string) string {
+	hash := sha256.Sum256([]byte(plaintext))
+	return hex.EncodeToString(hash[:])
+}
+
+func randomHex(size int) (string, error) {
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(data), nil
+}
+
+// CreateAPIKeyRequest is a request for creating an admin api key
+type CreateAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// CreateAPIKeyResponse contains a created admin api key
+type CreateAPIKeyResponse struct {
+	APIKey
+	// Key is the plaintext key, returned only on creation
+	Key string `json:"key"`
+}
+
+// RevokeAPIKeyRequest is a request for revoking an admin api key
+type RevokeAPIKeyRequest struct {
+	ID string `json:"id"`
+}
+
+// EvictRequest is a request for evicting the session of a client
+type EvictRequest struct {
+	CorrelationID string `json:"correlation-id"`
+}
+
+// scopeMiddleware allows the requests carrying an api key with scope, or the
+// server token. Without authentication the stats scope is not restricted.
+func (h *HTTPServer) scopeMiddleware(scope string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !h.checkScope(req, scope) {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (h *HTTPServer) checkScope(req *http.Request, scope string) bool {
+	if h.options.APIKeys.Allowed(req.Header.Get(apiKeyHeader), scope) {
+		return true
+	}
+	// the tenants search their own interactions
+	if scope == ScopeSearch && h.tenant(req) != nil {
+		return true
+	}
+	if !h.options.Auth {
+		return scope == ScopeStats
+	}
+	return h.options.Token == req.Header.Get("Authorization")
+}
+
+// apiKeysHandler is a handler for listing (GET) and creating (POST) admin api keys
+func (h *HTTPServer) apiKeysHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	switch req.Method {
+	case http.MethodGet:
+		_ = jsoniter.NewEncoder(w).Encode(h.options.APIKeys.List())
+	case http.MethodPost:
+		r := &CreateAPIKeyRequest{}
+		if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+			gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+			jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+			return
+		}
+		key, plaintext, err := h.options.APIKeys.Create(r.Name, r.Scopes)
+		if err != nil {
+			gologger.Warning().Msgf("Could not create api key: %s\n", err)
+			jsonError(w, fmt.Sprintf("could not create api key: %s", err), http.StatusBadRequest)
+			return
+		}
+		response := &CreateAPIKeyResponse{APIKey: *key, Key: plaintext}
+		response.Hash = ""
+		_ = jsoniter.NewEncoder(w).Encode(response)
+		gologger.Info().Msgf("Created api key %s with scopes %s\n", key.ID, strings.Join(key.Scopes, ","))
+	default:
+		jsonError(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// revokeAPIKeyHandler is a handler for revoking admin api keys
+func (h *HTTPServer) revokeAPIKeyHandler(w http.ResponseWriter, req *http.Request) {
+	r := &RevokeAPIKeyRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.options.APIKeys.Revoke(r.ID); err != nil {
+		gologger.Warning().Msgf("Could not revoke api key %s: %s\n", r.ID, err)
+		jsonError(w, fmt.Sprintf("could not revoke api key: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "api key revoked", http.StatusOK)
+	gologger.Info().Msgf("Revoked api key %s\n", r.ID)
+}
+
+// evictHandler is a handler for evicting the session of a client
+func (h *HTTPServer) evictHandler(w http.ResponseWriter, req *http.Request) {
+	r := &EvictRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.options.Storage.EvictID(r.CorrelationID); err != nil {
+		gologger.Warning().Msgf("Could not evict %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not evict session: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "session evicted", http.StatusOK)
+	gologger.Info().Msgf("Evicted session of correlationID %s\n", r.CorrelationID)
+}
+
+// reloadHandler is a handler for reloading the server configuration
+func (h *HTTPServer) reloadHandler(w http.ResponseWriter, req *http.Request) {
+	if h.options.Reload == nil {
+		jsonError(w, "configuration reload is not supported", http.StatusNotImplemented)
+		return
+	}
+	if err := h.options.Reload(); err != nil {
+		gologger.Warning().Msgf("Could not reload configuration: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not reload configuration: %s", err), http.StatusInternalServerError)
+		return
+	}
+	jsonMsg(w, "configuration reloaded", http.StatusOK)
+}
@@ -0,0 +1,105 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeys(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "api-keys.json")
+	apiKeys, err := NewAPIKeys(path)
+	require.Nil(t, err, "could not create api keys")
+
+	_, _, err = apiKeys.Create("ci", []string{"unknown"})
+	require.NotNil(t, err, "could not reject unknown scope")
+
+	key, plaintext, err := apiKeys.Create("ci", []string{ScopeStats})
+	require.Nil(t, err, "could not create api key")
+	require.True(t, strings.HasPrefix(plaintext, apiKeyPrefix), "could not prefix api key")
+	require.True(t, apiKeys.Allowed(plaintext, ScopeStats), "could not allow key scope")
+	require.False(t, apiKeys.Allowed(plaintext, ScopeEvict), "could not deny missing scope")
+	require.False(t, apiKeys.Allowed(plaintext+"x", ScopeStats), "could not deny unknown key")
+
+	loaded, err := NewAPIKeys(path)
+	require.Nil(t, err, "could not load api keys")
+	require.True(t, loaded.Allowed(plaintext, ScopeStats), "could not persist api key")
+	require.Empty(t, loaded.List()[0].Hash, "could not hide key hash")
+
+	_, other, err := apiKeys.Create("ops", []string{ScopeEvict})
+	require.Nil(t, err, "could not create api key")
+	require.Nil(t, loaded.Reload(), "could not reload api keys")
+	require.True(t, loaded.Allowed(other, ScopeEvict), "could not reload key created elsewhere")
+
+	require.Nil(t, loaded.Revoke(key.ID), "could not revoke api key")
+	require.False(t, loaded.Allowed(plaintext, ScopeStats), "could not deny revoked key")
+	reloaded, err := NewAPIKeys(path)
+	require.Nil(t, err, "could not load api keys")
+	require.Len(t, reloaded.List(), 1, "could not persist revocation")
+}
+
+func TestAdminAPIScopes(t *testing.T) {
+	store := newTestStorage(t, "c6rj61aciaeutn2ae680", "secret")
+	apiKeys, err := NewAPIKeys("")
+	require.Nil(t, err, "could not create api keys")
+	server, err := NewHTTPServer(&Options{Auth: true, Token: "token", Storage: store, APIKeys: apiKeys})
+	require.Nil(t, err, "could not create http server")
+	router := server.nontlsserver.Handler
+
+	request := func(path, header, value, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", "http://example.com"+path, strings.NewReader(body))
+		req.Header.Set(header, value)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("/admin/keys", "Authorization", "token", `{"name":"ops","scopes":["evict"]}`)
+	require.Equal(t, http.StatusOK, w.Code, "could not create key with server token")
+	created := &CreateAPIKeyResponse{}
+	require.Nil(t, jsoniter.NewDecoder(w.Body).Decode(created), "could not decode created key")
+
+	w = request("/admin/keys", apiKeyHeader, created.Key, `{"name":"escalated","scopes":["keys"]}`)
+	require.Equal(t, http.StatusUnauthorized, w.Code, "could not deny key management without scope")
+	w = request("/admin/reload", apiKeyHeader, created.Key, "")
+	require.Equal(t, http.StatusUnauthorized, w.Code, "could not deny reload without scope")
+
+	w = request("/admin/evict", apiKeyHeader, created.Key, `{"correlation-id":"c6rj61aciaeutn2ae680"}`)
+	require.Equal(t, http.StatusOK, w.Code, "could not evict session with scoped key")
+	_, err = store.GetCacheItem("c6rj61aciaeutn2ae680")
+	require.NotNil(t, err, "could not remove evicted session")
+}
+
+func TestAdminAPIHost(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	options := &Options{Domains: []string{"oast.example"}, Stats: &Metrics{}, Storage: store, CorrelationIdLength: 20, CorrelationIdNonceLength: 13}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err, "could not create http server")
+	require.Nil(t, options.APIKeys, "could set api keys in the options")
+	router := server.nontlsserver.Handler
+
+	for _, path := range []string{"/admin/stats", "/admin/evict", "/admin/reload", "/admin/keys", "/admin/keys/revoke"} {
+		req := httptest.NewRequest("POST", "http://"+correlationID+"abcdefghijklm.oast.example"+path, nil)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		require.NotEqual(t, http.StatusUnauthorized, w.Code, "could not record %s callback", path)
+	}
+	interactions, _, err := store.GetInteractions(correlationID, "secret")
+	require.Nil(t, err, "could not get interactions")
+	require.Len(t, interactions, 5, "could not record admin api callbacks")
+
+	// without api keys, the keys can't be managed
+	req := httptest.NewRequest("GET", "http://oast.example/admin/keys", nil)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	require.Equal(t, http.StatusUnauthorized, w.Code, "could not deny key management without key")
+	_, _, err = options.APIKeys.Create("ops", []string{ScopeKeys})
+	require.NotNil(t, err, "could create key without api keys")
+	require.Empty(t, options.APIKeys.List(), "could list keys without api keys")
+}
@@ -0,0 +1,182 @@
+package server
+
+import (
+	_ "embed"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+//go:embed dashboard.html
+var dashboardPage []byte
+
+// Dashboard keeps the most recent interactions in memory
+// so that they can be browsed from the web dashboard.
+type Dashboard struct {
+	sync.RWMutex
+	correlationIdLength int
+	items               []*Interaction
+	next                int
+	full                bool
+	breakdown           map[string]uint64
+	tenantBreakdown     map[string]map[string]uint64
+}
+
+// DashboardQuery contains the filters for a dashboard search
+type DashboardQuery struct {
+	// Search is a case insensitive text searched in id, remote address and raw request
+	Search string
+	// Protocol restricts results to the given protocol
+	Protocol string
+	// CorrelationID restricts results to the given correlation id (unique ids are truncated)
+	CorrelationID string
+	// Tenant restricts results to the given tenant
+	Tenant string
+	// Limit is the maximum number of returned interactions
+	Limit int
+}
+
+// NewDashboard returns a new dashboard holding up to size interactions.
+func NewDashboard(size, correlationIdLength int) *Dashboard {
+	return &Dashboard{
+		correlationIdLength: correlationIdLength,
+		items:               make([]*Interaction, size),
+		breakdown:           make(map[string]uint64),
+		tenantBreakdown:     make(map[string]map[string]uint64),
+	}
+}
+
+// Add records an interaction, evicting the oldest one if the history is full
+func (d *Dashboard) Add(interaction *Interaction) {
+	if len(d.items) == 0 {
+		return
+	}
+	d.Lock()
+	defer d.Unlock()
+
+	d.items[d.next] = interaction
+	d.next = (d.next + 1) % len(d.items)
+	if d.next == 0 {
+		d.full = true
+	}
+	d.breakdown[interaction.Protocol]++
+	if interaction.Tenant != "" {
+		breakdown, ok := d.tenantBreakdown[interaction.Tenant]
+		if !ok {
+			breakdown = make(map[string]uint64)
+			d.tenantBreakdown[interaction.Tenant] = breakdown
+		}
+		breakdown[interaction.Protocol]++
+	}
+}
+
+// Query returns the interactions matching the query, most recent first
+func (d *Dashboard) Query(query DashboardQuery) []*Interaction {
+	d.RLock()
+	defer d.RUnlock()
+
+	search := strings.ToLower(query.Search)
+	correlationID := query.CorrelationID
+	if len(correlationID) > d.correlationIdLength {
+		correlationID = correlationID[:d.correlationIdLength]
+	}
+	results := []*Interaction{}
+	count := d.next
+	if d.full {
+		count = len(d.items)
+	}
+	for i := 1; i <= count; i++ {
+		interaction := d.items[(d.next-i+len(d.items))%len(d.items)]
+		if query.Protocol != "" && !strings.EqualFold(interaction.Protocol, query.Protocol) {
+			continue
+		}
+		if correlationID != "" && !strings.EqualFold(d.correlationID(interaction), correlationID) {
+			continue
+		}
+		if query.Tenant != "" && interaction.Tenant != query.Tenant {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(interaction.FullId), search) &&
+			!strings.Contains(strings.ToLower(interaction.RemoteAddress), search) &&
+			!strings.Contains(strings.ToLower(interaction.RawRequest), search) {
+			continue
+		}
+		results = append(results, interaction)
+		if query.Limit > 0 && len(results) >= query.Limit {
+			break
+		}
+	}
+	return results
+}
+
+// Breakdown returns the number of interactions received for each protocol,
+// restricted to the given tenant if not empty
+func (d *Dashboard) Breakdown(tenant string) map[string]uint64 {
+	d.RLock()
+	defer d.RUnlock()
+
+	counts := d.breakdown
+	if tenant != "" {
+		counts = d.tenantBreakdown[tenant]
+	}
+	breakdown := make(map[string]uint64, len(counts))
+	for protocol, count := range counts {
+		breakdown[protocol] = count
+	}
+	return breakdown
+}
+
+func (d *Dashboard) correlationID(interaction *Interaction) string {
+	if len(interaction.UniqueID) < d.correlationIdLength {
+		return ""
+	}
+	return interaction.UniqueID[:d.correlationIdLength]
+}
+
+// dashboardHandler serves the dashboard page. The page itself holds no data,
+// interactions are fetched from the authenticated api endpoints.
+func (h *HTTPServer) dashboardHandler(w http.ResponseWriter, req *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("X-Frame-Options", "DENY")
+	_, _ = w.Write(dashboardPage)
+}
+
+// dashboardInteractionsHandler is a handler for dashboard interaction searches
+func (h *HTTPServer) dashboardInteractionsHandler(w http.ResponseWriter, req *http.Request) {
+	values := req.URL.Query()
+	limit, _ := strconv.Atoi(values.Get("limit"))
+	if limit <= 0 {
+		limit = 100
+	}
+	query := DashboardQuery{
+		Search:        values.Get("q"),
+		Protocol:      values.Get("protocol"),
+		CorrelationID: values.Get("id"),
+		Limit:         limit,
+	}
+	// tenants only browse their own interactions
+	if tenant := h.tenant(req); tenant != nil {
+		query.Tenant = tenant.Name
+	}
+	results := h.options.Dashboard.Query(query)
+
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = jsoniter.NewEncoder(w).Encode(results)
+}
+
+// dashboardStatsHandler is a handler for dashboard protocol breakdown
+func (h *HTTPServer) dashboardStatsHandler(w http.ResponseWriter, req *http.Request) {
+	var name string
+	// tenants only get the breakdown of their own interactions
+	if tenant := h.tenant(req); tenant != nil {
+		name = tenant.Name
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = jsoniter.NewEncoder(w).Encode(h.options.Dashboard.Breakdown(name))
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDashboardQuery(t *testing.T) {
+	dashboard := NewDashboard(2, 4)
+	dashboard.Add(&Interaction{Protocol: "dns", UniqueID: "aaaa1234"})
+	dashboard.Add(&Interaction{Protocol: "http", UniqueID: "bbbb1234", RawRequest: "GET /secret"})
+	dashboard.Add(&Interaction{Protocol: "dns", UniqueID: "bbbb5678"})
+
+	results := dashboard.Query(DashboardQuery{})
+	require.Len(t, results, 2, "could not evict oldest interaction")
+	require.Equal(t, "bbbb5678", results[0].UniqueID, "could not get most recent interaction first")
+
+	results = dashboard.Query(DashboardQuery{CorrelationID: "bbbb0000"})
+	require.Len(t, results, 2, "could not filter by correlation id")
+
+	results = dashboard.Query(DashboardQuery{Search: "SECRET"})
+	require.Len(t, results, 1, "could not search raw request")
+
+	require.Equal(t, map[string]uint64{"dns": 2, "http": 1}, dashboard.Breakdown(""), "could not get protocol breakdown")
+}
+
+func TestDashboardTenantStats(t *testing.T) {
+	tenants, err := NewTenants([]*Tenant{{Name: "red", Token: "red-token"}, {Name: "blue", Token: "blue-token"}})
+	require.Nil(t, err, "could not create tenants")
+	options := &Options{Domains: []string{"oast.example"}, Auth: true, Token: "token", Stats: &Metrics{}, Tenants: tenants, Dashboard: NewDashboard(10, 20)}
+	options.Dashboard.Add(&Interaction{Protocol: "dns", Tenant: "red"})
+	options.Dashboard.Add(&Interaction{Protocol: "http", Tenant: "blue"})
+	options.Dashboard.Add(&Interaction{Protocol: "smtp"})
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err, "could not create http server")
+	router := server.nontlsserver.Handler
+
+	request := func(token string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest("GET", "http://oast.example/dashboard/stats", nil)
+		req.Header.Set("Authorization", token)
+		router.ServeHTTP(w, req)
+		return w
+	}
+	w := request("red-token")
+	require.Equal(t, http.StatusOK, w.Code, "could not get tenant stats")
+	require.JSONEq(t, `{"dns":1}`, w.Body.String(), "could not scope breakdown to tenant")
+
+	w = request("token")
+	require.Equal(t, http.StatusOK, w.Code, "could not get stats")
+	require.JSONEq(t, `{"dns":1,"http":1,"smtp":1}`, w.Body.String(), "could not get full breakdown")
+
+	w = request("unknown")
+	require.Equal(t, http.StatusUnauthorized, w.Code, "could get stats with unknown token")
+}
+
+func TestDashboardHost(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	options := &Options{Domains: []string{"oast.example"}, Auth: true, Token: "token", Stats: &Metrics{}, Storage: store, CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Dashboard: NewDashboard(10, 20)}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err, "could not create http server")
+	router := server.nontlsserver.Handler
+
+	request := func(host, path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("GET", "http://"+host+path, nil))
+		return w
+	}
+	w := request("oast.example", "/dashboard")
+	require.Contains(t, w.Body.String(), "Interactsh Dashboard", "could not get dashboard on server domain")
+
+	for _, path := range []string{"/dashboard", "/dashboard/interactions", "/dashboard/stats"} {
+		w = request(correlationID+"abcdefghijklm.oast.example", path)
+		require.NotContains(t, w.Body.String(), "Interactsh Dashboard", "could get dashboard on interaction domain")
+		require.NotEqual(t, http.StatusUnauthorized, w.Code, "could not record %s callback", path)
+	}
+	interactions, _, err := store.GetInteractions(correlationID, "secret")
+	require.Nil(t, err, "could not get interactions")
+	require.Len(t, interactions, 3, "could not record dashboard callbacks")
+}
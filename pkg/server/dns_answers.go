@@ -0,0 +1,159 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+const (
+	// maxDNSAnswers is the maximum number of records of each type in custom answers
+	maxDNSAnswers = 16
+	// maxTXTStringLength is the maximum length of a character string in a TXT record
+	maxTXTStringLength = 255
+)
+
+// DNSAnswers are the dns answers returned for the subdomains of a client
+type DNSAnswers = storage.DNSAnswers
+
+// DNSAnswersRequest is a request for setting the custom dns answers of a client.
+type DNSAnswersRequest = types.DNSAnswersRequest
+
+// validateDNSAnswers checks that custom dns answers contain valid records
+func validateDNSAnswers(answers *DNSAnswers) error {
+	if len(answers.A) > maxDNSAnswers || len(answers.AAAA) > maxDNSAnswers || len(answers.TXT) > maxDNSAnswers {
+		return fmt.Errorf("too many records, at most %d of each type are allowed", maxDNSAnswers)
+	}
+	for _, value := range answers.A {
+		if ip := net.ParseIP(value); ip == nil || ip.To4() == nil {
+			return fmt.Errorf("invalid A record %s", value)
+		}
+	}
+	for _, value := range answers.AAAA {
+		if ip := net.ParseIP(value); ip == nil || ip.To4() != nil {
+			return fmt.Errorf("invalid AAAA record %s", value)
+		}
+	}
+	if answers.CNAME != "" {
+		if _, ok := dns.IsDomainName(answers.CNAME); !ok {
+			return fmt.Errorf("invalid CNAME record %s", answers.CNAME)
+		}
+	}
+	return nil
+}
+
+// isEmptyDNSAnswers returns true if the answers contain no record
+func isEmptyDNSAnswers(answers *DNSAnswers) bool {
+	return answers == nil || len(answers.A) == 0 && len(answers.AAAA) == 0 && len(answers.TXT) == 0 && answers.CNAME == ""
+}
+
+// dnsAnswersHandler is a handler for setting the custom dns answers of a client
+func (h *HTTPServer) dnsAnswersHandler(w http.ResponseWriter, req *http.Request) {
+	r := &DNSAnswersRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if err := h.setDNSAnswers(r.CorrelationID, r.SecretKey, r.Answers); err != nil {
+		gologger.Warning().Msgf("Could not set dns answers for %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set dns answers: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "dns answers set", http.StatusOK)
+	gologger.Debug().Msgf("Set dns answers for correlationID %s\n", r.CorrelationID)
+}
+
+// setDNSAnswers validates and stores the custom dns answers of a client
+func (h *HTTPServer) setDNSAnswers(correlationID, secretKey string, answers *DNSAnswers) error {
+	if isEmptyDNSAnswers(answers) {
+		return h.options.Storage.SetDNSAnswers(correlationID, secretKey, nil)
+	}
+	if err := validateDNSAnswers(answers); err != nil {
+		return err
+	}
+	return h.options.Storage.SetDNSAnswers(correlationID, secretKey, answers)
+}
+
+// customDNSAnswers returns the custom dns answers of the client owning domain, if any
+func (h *DNSServer) customDNSAnswers(domain string) *DNSAnswers {
+	correlationID := h.options.correlationIDFromDomain(domain)
+	if correlationID == "" {
+		return nil
+	}
+	return h.options.Storage.GetDNSAnswers(correlationID)
+}
+
+// handleCustomDNSAnswers answers a question with the custom answers of the client
+// owning the domain. It returns false if the client has no answer for the question.
+func (h *DNSServer) handleCustomDNSAnswers(domain string, qtype uint16, m *dns.Msg) bool {
+	answers := h.customDNSAnswers(domain)
+	if answers == nil {
+		return false
+	}
+	header := func(rrtype uint16) dns.RR_Header {
+		return dns.RR_Header{Name: domain, Rrtype: rrtype, Class: dns.ClassINET, Ttl: answers.TTL}
+	}
+	var rrs []dns.RR
+	cname := func() {
+		rrs = append(rrs, &dns.CNAME{Hdr: header(dns.TypeCNAME), Target: dns.Fqdn(answers.CNAME)})
+	}
+	a := func() {
+		for _, value := range answers.A {
+			rrs = append(rrs, &dns.A{Hdr: header(dns.TypeA), A: net.ParseIP(value)})
+		}
+	}
+	aaaa := func() {
+		for _, value := range answers.AAAA {
+			rrs = append(rrs, &dns.AAAA{Hdr: header(dns.TypeAAAA), AAAA: net.ParseIP(value)})
+		}
+	}
+	txt := func() {
+		for _, value := range answers.TXT {
+			rrs = append(rrs, &dns.TXT{Hdr: header(dns.TypeTXT), Txt: splitTXT(value)})
+		}
+	}
+
+	switch qtype {
+	case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME:
+		switch {
+		case answers.CNAME != "":
+			cname()
+		case qtype == dns.TypeA:
+			a()
+		case qtype == dns.TypeAAAA:
+			aaaa()
+		}
+	case dns.TypeTXT:
+		txt()
+	case dns.TypeANY:
+		if answers.CNAME != "" {
+			cname()
+		} else {
+			a()
+			aaaa()
+		}
+		txt()
+	}
+	if len(rrs) == 0 {
+		return false
+	}
+	m.Answer = append(m.Answer, rrs...)
+	return true
+}
+
+// splitTXT splits a TXT value in character strings of the maximum allowed length
+func splitTXT(value string) []string {
+	var parts []string
+	for len(value) > maxTXTStringLength {
+		parts = append(parts, value[:maxTXTStringLength])
+		value = value[maxTXTStringLength:]
+	}
+	return append(parts, value)
+}
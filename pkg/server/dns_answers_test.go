@@ -0,0 +1,57 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDNSAnswers(t *testing.T) {
+	require.Nil(t, validateDNSAnswers(&DNSAnswers{A: []string{"10.0.0.1"}, AAAA: []string{"::1"}, CNAME: "internal.example.com"}), "could not validate answers")
+	require.NotNil(t, validateDNSAnswers(&DNSAnswers{A: []string{"::1"}}), "could validate ipv6 A record")
+	require.NotNil(t, validateDNSAnswers(&DNSAnswers{AAAA: []string{"10.0.0.1"}}), "could validate ipv4 AAAA record")
+}
+
+func TestHandleCustomDNSAnswers(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+
+	server := &DNSServer{options: &Options{CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Storage: store}}
+	domain := "x." + correlationID + "abcdefghijklm.oast.pro."
+
+	require.False(t, server.handleCustomDNSAnswers(domain, dns.TypeA, new(dns.Msg)), "could answer without custom answers")
+
+	err := store.SetDNSAnswers(correlationID, "secret", &DNSAnswers{A: []string{"10.0.0.1"}, TXT: []string{"token"}})
+	require.Nil(t, err, "could not set dns answers")
+
+	m := new(dns.Msg)
+	require.True(t, server.handleCustomDNSAnswers(domain, dns.TypeA, m), "could not answer with custom answers")
+	require.Len(t, m.Answer, 1, "could not get custom answer")
+	require.Equal(t, "10.0.0.1", m.Answer[0].(*dns.A).A.String(), "could not get custom A record")
+
+	require.False(t, server.handleCustomDNSAnswers(domain, dns.TypeAAAA, new(dns.Msg)), "could answer without custom AAAA records")
+}
+
+// newTestStorage returns a memory storage with correlationID registered with secret
+func newTestStorage(t *testing.T, correlationID, secret string) *storage.StorageDB {
+	store, err := storage.New(&storage.Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err, "could not create storage")
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err, "could not generate rsa key")
+	pubkeyBytes, err := x509.MarshalPKIXPublicKey(priv.Public())
+	require.Nil(t, err, "could not marshal public key")
+	pubkeyPem := pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: pubkeyBytes})
+
+	err = store.SetIDPublicKey(correlationID, secret, base64.StdEncoding.EncodeToString(pubkeyPem))
+	require.Nil(t, err, "could not register correlation id")
+	return store
+}
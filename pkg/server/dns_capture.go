@@ -0,0 +1,158 @@
+package server
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// DNSQuery contains the details of a dns query
+type DNSQuery = types.DNSQuery
+
+// DNSEDNS contains the EDNS0 parameters of a dns query
+type DNSEDNS = types.DNSEDNS
+
+// DNSEDNSOption is a single EDNS0 option
+type DNSEDNSOption = types.DNSEDNSOption
+
+// maxDNSWires is the maximum number of received queries waiting to be handled
+// whose bytes are kept, the oldest ones being forgotten first
+const maxDNSWires = 4096
+
+// captureDNSQuery returns the details of the first question of a query, with
+// the bytes it was received as if any
+func captureDNSQuery(transport string, r *dns.Msg, wire []byte) *DNSQuery {
+	query := &DNSQuery{
+		Transport:        transport,
+		RecursionDesired: r.RecursionDesired,
+	}
+	if len(r.Question) > 0 {
+		query.QType = dns.Type(r.Question[0].Qtype).String()
+		query.QClass = dns.Class(r.Question[0].Qclass).String()
+	}
+	if len(wire) > 0 {
+		query.Wire = base64.StdEncoding.EncodeToString(wire)
+	}
+	if opt := r.IsEdns0(); opt != nil {
+		edns := &DNSEDNS{
+			Version:  opt.Version(),
+			UDPSize:  opt.UDPSize(),
+			DNSSECOk: opt.Do(),
+		}
+		for i, option := range opt.Option {
+			if i >= maxDNSEDNSOptions {
+				break
+			}
+			switch value := option.(type) {
+			case *dns.EDNS0_SUBNET:
+				edns.ClientSubnet = fmt.Sprintf("%s/%d", value.Address, value.SourceNetmask)
+			case *dns.EDNS0_COOKIE:
+				edns.Cookie = value.Cookie
+			}
+			edns.Options = append(edns.Options, DNSEDNSOption{Code: option.Option(), Value: option.String()})
+		}
+		query.EDNS = edns
+	}
+	return query
+}
+
+// dnsWireRecorder keeps the bytes of the queries read by a dns server until they
+// are handled, as the dns library only passes the parsed messages to the handlers.
+// The queries are identified by their remote address and message id.
+type dnsWireRecorder struct {
+	sync.Mutex
+	wires map[string][]byte
+	order []string
+}
+
+func newDNSWireRecorder() *dnsWireRecorder {
+	return &dnsWireRecorder{wires: make(map[string][]byte)}
+}
+
+// decorate is the DecorateReader of the dns server recording the queries read
+func (r *dnsWireRecorder) decorate(reader dns.Reader) dns.Reader {
+	return &dnsWireReader{Reader: reader, recorder: r}
+}
+
+func dnsWireKey(addr net.Addr, id uint16) string {
+	return addr.String() + "/" + strconv.Itoa(int(id))
+}
+
+// add records a copy of the bytes of a query, which are reused by the dns library
+func (r *dnsWireRecorder) add(addr net.Addr, wire []byte) {
+	if addr == nil || len(wire) < 2 {
+		return
+	}
+	key := dnsWireKey(addr, binary.BigEndian.Uint16(wire))
+	wire = append([]byte(nil), wire...)
+
+	r.Lock()
+	defer r.Unlock()
+
+	if _, ok := r.wires[key]; !ok {
+		if len(r.order) >= maxDNSWires {
+			delete(r.wires, r.order[0])
+			r.order = r.order[1:]
+		}
+		r.order = append(r.order, key)
+	}
+	r.wires[key] = wire
+}
+
+// take returns and forgets the bytes of the query id received from addr, if recorded
+func (r *dnsWireRecorder) take(addr net.Addr, id uint16) []byte {
+	if r == nil || addr == nil {
+		return nil
+	}
+	key := dnsWireKey(addr, id)
+
+	r.Lock()
+	defer r.Unlock()
+
+	wire := r.wires[key]
+	delete(r.wires, key)
+	return wire
+}
+
+// dnsWireReader records the messages read on the udp and tcp connections
+type dnsWireReader struct {
+	dns.Reader
+	recorder *dnsWireRecorder
+}
+
+func (r *dnsWireReader) ReadTCP(conn net.Conn, timeout time.Duration) ([]byte, error) {
+	wire, err := r.Reader.ReadTCP(conn, timeout)
+	if err == nil {
+		r.recorder.add(conn.RemoteAddr(), wire)
+	}
+	return wire, err
+}
+
+func (r *dnsWireReader) ReadUDP(conn *net.UDPConn, timeout time.Duration) ([]byte, *dns.SessionUDP, error) {
+	wire, session, err := r.Reader.ReadUDP(conn, timeout)
+	if err == nil && session != nil {
+		r.recorder.add(session.RemoteAddr(), wire)
+	}
+	return wire, session, err
+}
+
+func (r *dnsWireReader) ReadPacketConn(conn net.PacketConn, timeout time.Duration) ([]byte, net.Addr, error) {
+	reader, ok := r.Reader.(dns.PacketConnReader)
+	if !ok {
+		return nil, nil, errors.New("dns reader can't read packet connections")
+	}
+	wire, addr, err := reader.ReadPacketConn(conn, timeout)
+	if err == nil {
+		r.recorder.add(addr, wire)
+	}
+	return wire, addr, err
+}
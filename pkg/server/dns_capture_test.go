@@ -0,0 +1,72 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureDNSQuery(t *testing.T) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("Test.Example.com.", dns.TypeTXT)
+	msg.SetEdns0(1232, true)
+	opt := msg.IsEdns0()
+	opt.Option = append(opt.Option, &dns.EDNS0_SUBNET{Code: dns.EDNS0SUBNET, Family: 1, SourceNetmask: 24, Address: net.ParseIP("192.168.1.0").To4()})
+
+	packed, err := msg.Pack()
+	require.Nil(t, err, "could not pack query")
+	query := captureDNSQuery("udp", msg, packed)
+	require.Equal(t, "udp", query.Transport, "could not get transport")
+	require.Equal(t, "TXT", query.QType, "could not get qtype")
+	require.Equal(t, "IN", query.QClass, "could not get qclass")
+	require.NotNil(t, query.EDNS, "could not get edns")
+	require.Equal(t, uint16(1232), query.EDNS.UDPSize, "could not get udp size")
+	require.True(t, query.EDNS.DNSSECOk, "could not get do flag")
+	require.Equal(t, "192.168.1.0/24", query.EDNS.ClientSubnet, "could not get client subnet")
+
+	wire, err := base64.StdEncoding.DecodeString(query.Wire)
+	require.Nil(t, err, "could not decode wire format")
+	unpacked := new(dns.Msg)
+	require.Nil(t, unpacked.Unpack(wire), "could not unpack wire format")
+	require.Equal(t, "Test.Example.com.", unpacked.Question[0].Name, "could not preserve name case")
+}
+
+func TestDNSWireRecorder(t *testing.T) {
+	recorder := newDNSWireRecorder()
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen")
+	wires := make(chan []byte, 1)
+	server := &dns.Server{PacketConn: conn, DecorateReader: recorder.decorate, Handler: dns.HandlerFunc(func(w dns.ResponseWriter, r *dns.Msg) {
+		wires <- recorder.take(w.RemoteAddr(), r.Id)
+		m := new(dns.Msg)
+		_ = w.WriteMsg(m.SetReply(r))
+	})}
+	go func() { _ = server.ActivateAndServe() }()
+	defer func() { _ = server.Shutdown() }()
+
+	msg := new(dns.Msg)
+	msg.SetQuestion("Test.Example.com.", dns.TypeA)
+	packed, err := msg.Pack()
+	require.Nil(t, err, "could not pack query")
+	// trailing data is ignored by the parser but kept in the received bytes
+	sent := append(packed, []byte("trailing")...)
+
+	client, err := net.Dial("udp", conn.LocalAddr().String())
+	require.Nil(t, err, "could not dial")
+	defer client.Close()
+	_, err = client.Write(sent)
+	require.Nil(t, err, "could not send query")
+
+	select {
+	case wire := <-wires:
+		require.True(t, bytes.Equal(sent, wire), "could not record received bytes")
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "could not handle query")
+	}
+	require.Nil(t, recorder.take(client.LocalAddr(), msg.Id), "could take query twice")
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/projectdiscovery/stringsutil"
+)
+
+// Failure modes of the dns responses, requested with a dns-<mode> label in the
+// queried name, e.g. dns-nxdomain.c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.pro
+const (
+	// DNSFailureNXDomain answers the query with NXDOMAIN
+	DNSFailureNXDomain = "nxdomain"
+	// DNSFailureServFail answers the query with SERVFAIL
+	DNSFailureServFail = "servfail"
+	// DNSFailureNoError answers the query with an empty NOERROR response (NODATA)
+	DNSFailureNoError = "noerror"
+	// DNSFailureTimeout doesn't answer the query
+	DNSFailureTimeout = "timeout"
+)
+
+// dnsFailurePrefix is the prefix of the subdomain label requesting a dns failure mode
+const dnsFailurePrefix = "dns-"
+
+// dnsFailureModes are the supported dns failure modes
+var dnsFailureModes = map[string]struct{}{
+	DNSFailureNXDomain: {},
+	DNSFailureServFail: {},
+	DNSFailureNoError:  {},
+	DNSFailureTimeout:  {},
+}
+
+// dnsFailureMode returns the failure mode requested by a label of domain, if any
+func dnsFailureMode(domain string) string {
+	for _, part := range strings.Split(domain, ".") {
+		part = strings.ToLower(part)
+		if !strings.HasPrefix(part, dnsFailurePrefix) {
+			continue
+		}
+		if _, ok := dnsFailureModes[part[len(dnsFailurePrefix):]]; ok {
+			return part[len(dnsFailurePrefix):]
+		}
+	}
+	return ""
+}
+
+// applyFailureMode rewrites the response m to a query of zone according to the
+// failure mode. The negative responses carry the SOA of the domain in their
+// authority section, so that resolvers can cache them (RFC2308).
+func (h *DNSServer) applyFailureMode(mode, zone string, m *dns.Msg) {
+	switch mode {
+	case DNSFailureNXDomain, DNSFailureNoError:
+		if mode == DNSFailureNXDomain {
+			m.Rcode = dns.RcodeNameError
+		}
+		m.Answer, m.Ns, m.Extra = nil, nil, nil
+		if soa := h.negativeSOA(zone); soa != nil {
+			m.Ns = append(m.Ns, soa)
+		}
+	case DNSFailureServFail:
+		m.Rcode = dns.RcodeServerFailure
+		m.Answer, m.Ns, m.Extra = nil, nil, nil
+	}
+}
+
+// negativeSOA returns the SOA record of the domain serving zone, if any
+func (h *DNSServer) negativeSOA(zone string) dns.RR {
+	z := h.getZone()
+	if z == nil {
+		return nil
+	}
+	for _, domain := range h.options.Domains {
+		dotDomain := dns.Fqdn(strings.ToLower(domain))
+		if !stringsutil.HasSuffixI(zone, "."+dotDomain) && !strings.EqualFold(zone, dotDomain) {
+			continue
+		}
+		if soa, ok := z.soa[dotDomain]; ok {
+			record := *soa
+			record.Hdr.Name = dotDomain
+			return &record
+		}
+	}
+	return nil
+}
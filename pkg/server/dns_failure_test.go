@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDNSFailureMode(t *testing.T) {
+	domain := "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.example."
+	require.Empty(t, dnsFailureMode(domain), "could get failure mode without label")
+	require.Empty(t, dnsFailureMode("dns-refused."+domain), "could get unsupported failure mode")
+	require.Equal(t, DNSFailureNXDomain, dnsFailureMode("DNS-NXDOMAIN."+domain), "could not get nxdomain failure mode")
+	require.Equal(t, DNSFailureTimeout, dnsFailureMode("dns-timeout."+domain), "could not get timeout failure mode")
+
+	server := &DNSServer{options: &Options{Domains: []string{"oast.example"}}, ipAddress: net.ParseIP("192.0.2.10")}
+	require.Nil(t, server.ReloadZone(""), "could not load zone")
+
+	newResponse := func(name string) *dns.Msg {
+		m := new(dns.Msg)
+		m.SetQuestion(name, dns.TypeA)
+		server.handleACNAMEANY(name, dns.TypeA, m)
+		require.NotEmpty(t, m.Answer, "could not get answer")
+		return m
+	}
+
+	m := newResponse("dns-nxdomain." + domain)
+	server.applyFailureMode(DNSFailureNXDomain, "dns-nxdomain."+domain, m)
+	require.Equal(t, dns.RcodeNameError, m.Rcode, "could not get nxdomain rcode")
+	require.Empty(t, m.Answer, "could answer nxdomain query")
+	require.Len(t, m.Ns, 1, "could not get negative soa")
+	require.Equal(t, "oast.example.", m.Ns[0].Header().Name, "could not get soa of the domain")
+
+	m = newResponse("dns-noerror." + domain)
+	server.applyFailureMode(DNSFailureNoError, "dns-noerror."+domain, m)
+	require.Equal(t, dns.RcodeSuccess, m.Rcode, "could not get noerror rcode")
+	require.Empty(t, m.Answer, "could answer noerror query")
+	require.Len(t, m.Ns, 1, "could not get negative soa")
+
+	m = newResponse("dns-servfail." + domain)
+	server.applyFailureMode(DNSFailureServFail, "dns-servfail."+domain, m)
+	require.Equal(t, dns.RcodeServerFailure, m.Rcode, "could not get servfail rcode")
+	require.Empty(t, m.Answer, "could answer servfail query")
+	require.Empty(t, m.Ns, "could get authority for servfail query")
+}
@@ -7,6 +7,7 @@ import (
 	"net"
 	"os"
 	"strings"
+	"sync"
 	"sync/atomic"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/miekg/dns"
 	"github.com/pkg/errors"
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/settings"
 	"github.com/projectdiscovery/stringsutil"
 	"gopkg.in/yaml.v3"
 )
@@ -22,10 +24,15 @@ import (
 type DNSServer struct {
 	options       *Options
 	mxDomains     map[string]string
-	nsDomains     map[string][]string
+	zoneMutex     sync.RWMutex
+	zone          *dnsZone
 	ipAddress     net.IP
+	ipv6Address   net.IP
 	timeToLive    uint32
 	server        *dns.Server
+	wires         *dnsWireRecorder
+	done          chan struct{}
+	doneOnce      sync.Once
 	customRecords *customDNSRecords
 	TxtRecord     string // used for ACME verification
 }
@@ -33,44 +40,111 @@ type DNSServer struct {
 // NewDNSServer returns a new DNS server.
 func NewDNSServer(network string, options *Options) *DNSServer {
 	mxDomains := make(map[string]string)
-	nsDomains := make(map[string][]string)
 
 	for _, domain := range options.Domains {
 		dotdomain := dns.Fqdn(domain)
 
 		mxDomain := fmt.Sprintf("mail.%s", dotdomain)
 		mxDomains[dotdomain] = mxDomain
-
-		ns1Domain := fmt.Sprintf("ns1.%s", dotdomain)
-		ns2Domain := fmt.Sprintf("ns2.%s", dotdomain)
-		nsDomains[dotdomain] = []string{ns1Domain, ns2Domain}
 	}
 
 	server := &DNSServer{
 		options:       options,
-		ipAddress:     net.ParseIP(options.IPAddress),
+		ipAddress:     net.ParseIP(options.IPAddress).To4(),
+		ipv6Address:   net.ParseIP(options.IPv6Address),
 		mxDomains:     mxDomains,
-		nsDomains:     nsDomains,
 		timeToLive:    3600,
+		wires:         newDNSWireRecorder(),
+		done:          make(chan struct{}),
 		customRecords: newCustomDNSRecordsServer(options.CustomRecords),
 	}
+	if err := server.ReloadZone(options.DNSZone); err != nil {
+		gologger.Error().Msgf("Could not read dns zone: %s\n", err)
+		server.zone, _ = newDNSZone(options.Domains, server.addresses(), nil)
+	}
 	server.server = &dns.Server{
-		Addr:    options.ListenIP + fmt.Sprintf(":%d", options.DnsPort),
-		Net:     network,
-		Handler: server,
+		Addr:           options.listenAddress(options.DnsPort),
+		Net:            network,
+		Handler:        server,
+		DecorateReader: server.wires.decorate,
+		ReadTimeout:    dnsReadTimeout,
+		WriteTimeout:   dnsWriteTimeout,
+		IdleTimeout:    func() time.Duration { return dnsIdleTimeout },
+		MaxTCPQueries:  maxDNSTCPQueries,
 	}
 	return server
 }
 
+// ReloadCustomRecords loads again the custom dns records from the input file
+func (h *DNSServer) ReloadCustomRecords(input string) error {
+	return h.customRecords.reload(input)
+}
+
+// ReloadZone loads again the zone configuration from the input file, the default
+// zone being served if input is empty. The current zone is kept on error.
+func (h *DNSServer) ReloadZone(input string) error {
+	var config *DNSZoneConfig
+	if input != "" {
+		var err error
+		if config, err = LoadDNSZoneConfig(input); err != nil {
+			return err
+		}
+	}
+	zone, err := newDNSZone(h.options.Domains, h.addresses(), config)
+	if err != nil {
+		return err
+	}
+	h.zoneMutex.Lock()
+	h.zone = zone
+	h.zoneMutex.Unlock()
+	return nil
+}
+
+// addresses returns the ipv4 and ipv6 addresses of the server
+func (h *DNSServer) addresses() []net.IP {
+	var addresses []net.IP
+	for _, ip := range []net.IP{h.ipAddress, h.ipv6Address} {
+		if ip != nil {
+			addresses = append(addresses, ip)
+		}
+	}
+	return addresses
+}
+
+func (h *DNSServer) getZone() *dnsZone {
+	h.zoneMutex.RLock()
+	defer h.zoneMutex.RUnlock()
+	return h.zone
+}
+
 // ListenAndServe listens on dns ports for the server.
 func (h *DNSServer) ListenAndServe(dnsAlive chan bool) {
 	dnsAlive <- true
-	if err := h.server.ListenAndServe(); err != nil {
+	// use the socket inherited from systemd socket activation if any
+	switch h.server.Net {
+	case "udp":
+		h.server.PacketConn = h.options.Listeners.PacketConn(h.options.DnsPort)
+	case "tcp":
+		h.server.Listener = h.options.Listeners.Listener(h.options.DnsPort)
+	}
+	serve := h.server.ListenAndServe
+	if h.server.PacketConn != nil || h.server.Listener != nil {
+		serve = h.server.ActivateAndServe
+	}
+	if err := serve(); err != nil {
 		gologger.Error().Msgf("Could not listen for %s DNS on %s (%s)\n", strings.ToUpper(h.server.Net), h.server.Addr, err)
+		h.options.Health.SetError("DNS", strings.ToUpper(h.server.Net), err)
 		dnsAlive <- false
 	}
 }
 
+// Shutdown stops the dns server, waiting for the in-flight queries until ctx is done.
+func (h *DNSServer) Shutdown(ctx context.Context) error {
+	// the delayed responses are sent right away
+	h.doneOnce.Do(func() { close(h.done) })
+	return h.server.ShutdownContext(ctx)
+}
+
 const (
 	dnsChallengeString   = "_acme-challenge."
 	certificateAuthority = "letsencrypt.org."
@@ -79,6 +153,7 @@ const (
 // ServeDNS is the default handler for DNS queries.
 func (h *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 	atomic.AddUint64(&h.options.Stats.Dns, 1)
+	wire := h.wires.take(w.RemoteAddr(), r.Id)
 
 	m := new(dns.Msg)
 	m.SetReply(r)
@@ -111,14 +186,21 @@ func (h *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 			case dns.TypeNS:
 				h.handleNS(domain, m)
 			case dns.TypeA, dns.TypeAAAA:
-				h.handleACNAMEANY(domain, m)
+				h.handleACNAMEANY(domain, question.Qtype, m)
 			}
 
 			gologger.Debug().Msgf("Got acme dns response: \n%s\n", m.String())
 		} else {
+			if h.handleCustomDNSAnswers(domain, question.Qtype, m) {
+				continue
+			}
+			if rrs := h.getZone().staticRecords(domain, question.Qtype); len(rrs) > 0 {
+				m.Answer = append(m.Answer, rrs...)
+				continue
+			}
 			switch question.Qtype {
 			case dns.TypeA, dns.TypeAAAA, dns.TypeCNAME, dns.TypeANY:
-				h.handleACNAMEANY(domain, m)
+				h.handleACNAMEANY(domain, question.Qtype, m)
 			case dns.TypeMX:
 				h.handleMX(domain, m)
 			case dns.TypeNS:
@@ -131,8 +213,22 @@ func (h *DNSServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
 		}
 	}
 	if !isDNSChallenge {
+		failureMode := dnsFailureMode(r.Question[0].Name)
+		h.applyFailureMode(failureMode, r.Question[0].Name, m)
+
 		// Write interaction for first question and dns request
-		h.handleInteraction(r.Question[0].Name, w, r, m)
+		h.handleInteraction(r.Question[0].Name, w, r, m, wire)
+
+		// the interaction is recorded, but the query is left unanswered
+		if failureMode == DNSFailureTimeout {
+			return
+		}
+		if delay := h.options.responseDelay(r.Question[0].Name); delay > 0 {
+			select {
+			case <-h.done:
+			case <-time.After(delay):
+			}
+		}
 	}
 
 	if err := w.WriteMsg(m); err != nil {
@@ -156,30 +252,49 @@ func (h *DNSServer) handleACMETXTChallenge(zone string, m *dns.Msg) error {
 	return nil
 }
 
-// handleACNAMEANY handles A, CNAME or ANY queries for DNS server
-func (h *DNSServer) handleACNAMEANY(zone string, m *dns.Msg) {
+// handleACNAMEANY handles A, AAAA, CNAME or ANY queries for DNS server
+func (h *DNSServer) handleACNAMEANY(zone string, qtype uint16, m *dns.Msg) {
 	nsHeader := dns.RR_Header{Name: zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: h.timeToLive}
 
 	// If we have a custom record serve it, or default IP
-	record := h.customRecords.checkCustomResponse(zone)
-	switch {
-	case record != "":
-		h.resultFunction(nsHeader, zone, net.ParseIP(record), m)
-	default:
-		h.resultFunction(nsHeader, zone, h.ipAddress, m)
+	ipAddress, ipv6Address := h.ipAddress, h.ipv6Address
+	if record := h.customRecords.checkCustomResponse(zone); record != "" {
+		ipAddress, ipv6Address = nil, nil
+		if ip := net.ParseIP(record); ip.To4() != nil {
+			ipAddress = ip.To4()
+		} else {
+			ipv6Address = ip
+		}
+	} else if h.isIPv6Only(zone) {
+		ipAddress = nil
 	}
+	h.resultFunction(nsHeader, zone, qtype, ipAddress, ipv6Address, m)
 }
 
-func (h *DNSServer) resultFunction(nsHeader dns.RR_Header, zone string, ipAddress net.IP, m *dns.Msg) {
-	m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: h.timeToLive}, A: ipAddress})
-	dotDomains := []string{zone, dns.Fqdn(h.options.Domains[0])}
-	for _, dotDomain := range dotDomains {
-		if nsDomains, ok := h.nsDomains[dotDomain]; ok {
-			for _, nsDomain := range nsDomains {
-				m.Ns = append(m.Ns, &dns.NS{Hdr: nsHeader, Ns: nsDomain})
-				m.Extra = append(m.Extra, &dns.A{Hdr: dns.RR_Header{Name: nsDomain, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: h.timeToLive}, A: h.ipAddress})
-			}
-			return
+// isIPv6Only returns true if the name is an interaction host resolved only to
+// the ipv6 address, the label preceding the domain being settings.IPv6OnlyLabel.
+func (h *DNSServer) isIPv6Only(name string) bool {
+	name = strings.ToLower(dns.Fqdn(name))
+	for _, domain := range h.options.Domains {
+		if strings.HasSuffix(name, "."+settings.IPv6OnlyLabel+"."+strings.ToLower(dns.Fqdn(domain))) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h *DNSServer) resultFunction(nsHeader dns.RR_Header, zone string, qtype uint16, ipAddress, ipv6Address net.IP, m *dns.Msg) {
+	if ipAddress != nil && qtype != dns.TypeAAAA {
+		m.Answer = append(m.Answer, &dns.A{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: h.timeToLive}, A: ipAddress})
+	}
+	if ipv6Address != nil && qtype != dns.TypeA {
+		m.Answer = append(m.Answer, &dns.AAAA{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: h.timeToLive}, AAAA: ipv6Address})
+	}
+	z := h.getZone()
+	if dotDomain := z.lookupDomain(zone, h.options.Domains[0]); dotDomain != "" {
+		for _, nsDomain := range z.nameservers[dotDomain] {
+			m.Ns = append(m.Ns, &dns.NS{Hdr: nsHeader, Ns: nsDomain})
+			m.Extra = append(m.Extra, z.glueRecords(nsDomain, h.timeToLive)...)
 		}
 	}
 }
@@ -199,27 +314,21 @@ func (h *DNSServer) handleMX(zone string, m *dns.Msg) {
 func (h *DNSServer) handleNS(zone string, m *dns.Msg) {
 	nsHeader := dns.RR_Header{Name: zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: h.timeToLive}
 
-	dotDomains := []string{zone, dns.Fqdn(h.options.Domains[0])}
-	for _, dotDomain := range dotDomains {
-		if nsDomains, ok := h.nsDomains[dotDomain]; ok {
-			for _, nsDomain := range nsDomains {
-				m.Answer = append(m.Answer, &dns.NS{Hdr: nsHeader, Ns: nsDomain})
-			}
-			return
+	z := h.getZone()
+	if dotDomain := z.lookupDomain(zone, h.options.Domains[0]); dotDomain != "" {
+		for _, nsDomain := range z.nameservers[dotDomain] {
+			m.Answer = append(m.Answer, &dns.NS{Hdr: nsHeader, Ns: nsDomain})
+			m.Extra = append(m.Extra, z.glueRecords(nsDomain, h.timeToLive)...)
 		}
 	}
 }
 
 func (h *DNSServer) handleSOA(zone string, m *dns.Msg) {
-	nsHdr := dns.RR_Header{Name: zone, Rrtype: dns.TypeSOA, Class: dns.ClassINET}
-	dotDomains := []string{zone, dns.Fqdn(h.options.Domains[0])}
-	for _, dotDomain := range dotDomains {
-		if nsDomains, ok := h.nsDomains[dotDomain]; ok {
-			for _, nsDomain := range nsDomains {
-				m.Answer = append(m.Answer, &dns.SOA{Hdr: nsHdr, Ns: nsDomain, Mbox: certificateAuthority, Serial: 1, Expire: 60, Minttl: 60})
-				return
-			}
-		}
+	z := h.getZone()
+	if dotDomain := z.lookupDomain(zone, h.options.Domains[0]); dotDomain != "" {
+		soa := *z.soa[dotDomain]
+		soa.Hdr.Name = zone
+		m.Answer = append(m.Answer, &soa)
 	}
 }
 
@@ -250,11 +359,15 @@ func toQType(ttype uint16) (rtype string) {
 }
 
 // handleInteraction handles an interaction for the DNS server
-func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dns.Msg, m *dns.Msg) {
+func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dns.Msg, m *dns.Msg, wire []byte) {
 	var uniqueID, fullID string
 
 	requestMsg := r.String()
 	responseMsg := m.String()
+	query := captureDNSQuery(h.server.Net, r, wire)
+	if query.FailureMode = dnsFailureMode(domain); query.FailureMode == DNSFailureTimeout {
+		responseMsg = ""
+	}
 
 	gologger.Debug().Msgf("New DNS request: %s\n", requestMsg)
 
@@ -278,17 +391,21 @@ func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dn
 			QType:         toQType(r.Question[0].Qtype),
 			RawRequest:    requestMsg,
 			RawResponse:   responseMsg,
+			DNS:           query,
 			RemoteAddress: host,
 			Timestamp:     time.Now(),
 		}
 		buffer := &bytes.Buffer{}
-		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-			gologger.Warning().Msgf("Could not encode root tld dns interaction: %s\n", err)
+		if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+			if err != errInteractionDropped {
+				gologger.Warning().Msgf("Could not encode root tld dns interaction: %s\n", err)
+			}
 		} else {
 			gologger.Debug().Msgf("Root TLD DNS Interaction: \n%s\n", buffer.String())
 			if err := h.options.Storage.AddInteractionWithId(correlationID, buffer.Bytes()); err != nil {
 				gologger.Warning().Msgf("Could not store dns interaction: %s\n", err)
 			}
+			h.options.exportInteraction(interaction)
 		}
 	}
 
@@ -316,23 +433,28 @@ func (h *DNSServer) handleInteraction(domain string, w dns.ResponseWriter, r *dn
 			QType:         toQType(r.Question[0].Qtype),
 			RawRequest:    requestMsg,
 			RawResponse:   responseMsg,
+			DNS:           query,
 			RemoteAddress: host,
 			Timestamp:     time.Now(),
 		}
 		buffer := &bytes.Buffer{}
-		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-			gologger.Warning().Msgf("Could not encode dns interaction: %s\n", err)
+		if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+			if err != errInteractionDropped {
+				gologger.Warning().Msgf("Could not encode dns interaction: %s\n", err)
+			}
 		} else {
 			gologger.Debug().Msgf("DNS Interaction: \n%s\n", buffer.String())
 			if err := h.options.Storage.AddInteraction(correlationID, buffer.Bytes()); err != nil {
 				gologger.Warning().Msgf("Could not store dns interaction: %s\n", err)
 			}
+			h.options.exportInteraction(interaction)
 		}
 	}
 }
 
 // customDNSRecords is a server for custom dns records
 type customDNSRecords struct {
+	sync.RWMutex
 	records map[string]string
 }
 
@@ -350,14 +472,14 @@ func newCustomDNSRecordsServer(input string) *customDNSRecords {
 		server.records[k] = v
 	}
 	if input != "" {
-		if err := server.readRecordsFromFile(input); err != nil {
+		if err := server.readRecordsFromFile(server.records, input); err != nil {
 			gologger.Error().Msgf("Could not read custom DNS records: %s", err)
 		}
 	}
 	return server
 }
 
-func (c *customDNSRecords) readRecordsFromFile(input string) error {
+func (c *customDNSRecords) readRecordsFromFile(records map[string]string, input string) error {
 	file, err := os.Open(input)
 	if err != nil {
 		return errors.Wrap(err, "could not open file")
@@ -369,8 +491,26 @@ func (c *customDNSRecords) readRecordsFromFile(input string) error {
 		return errors.Wrap(err, "could not decode file")
 	}
 	for k, v := range data {
-		c.records[strings.ToLower(k)] = v
+		records[strings.ToLower(k)] = v
+	}
+	return nil
+}
+
+// reload replaces the records with the default ones and the ones of the input
+// file, keeping the current records if the file can't be read.
+func (c *customDNSRecords) reload(input string) error {
+	records := make(map[string]string)
+	for k, v := range defaultCustomRecords {
+		records[k] = v
+	}
+	if input != "" {
+		if err := c.readRecordsFromFile(records, input); err != nil {
+			return err
+		}
 	}
+	c.Lock()
+	c.records = records
+	c.Unlock()
 	return nil
 }
 
@@ -379,6 +519,8 @@ func (c *customDNSRecords) checkCustomResponse(zone string) string {
 	if len(parts) != 2 {
 		return ""
 	}
+	c.RLock()
+	defer c.RUnlock()
 	if value, ok := c.records[strings.ToLower(parts[0])]; ok {
 		return value
 	}
@@ -0,0 +1,108 @@
+package server
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCustomDNSRecordsReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "records.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("gcp: 169.254.169.254\n"), 0600), "could not write records")
+
+	records := newCustomDNSRecordsServer(path)
+	require.Equal(t, "169.254.169.254", records.checkCustomResponse("gcp.oast.example"), "could not get custom record")
+
+	require.Nil(t, os.WriteFile(path, []byte("Azure: 168.63.129.16\n"), 0600), "could not write records")
+	require.Nil(t, records.reload(path), "could not reload records")
+	require.Equal(t, "168.63.129.16", records.checkCustomResponse("azure.oast.example"), "could not get reloaded record")
+	require.Empty(t, records.checkCustomResponse("gcp.oast.example"), "could not remove record missing after reload")
+	require.Equal(t, "127.0.0.1", records.checkCustomResponse("localhost.oast.example"), "could not keep default record")
+
+	require.Nil(t, os.WriteFile(path, []byte("invalid: [yaml"), 0600), "could not write records")
+	require.NotNil(t, records.reload(path), "could not report invalid records")
+	require.Equal(t, "168.63.129.16", records.checkCustomResponse("azure.oast.example"), "could not keep records on failed reload")
+}
+
+func TestDNSZone(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "zone.yaml")
+	config := `
+soa:
+  mbox: hostmaster
+  serial: 2024010101
+nameservers:
+  - name: ns1
+    ips: [192.0.2.1, "2001:db8::1"]
+  - name: ns.provider.example.
+records:
+  - "@ 3600 IN MX 10 mx.provider.example."
+  - "@ 3600 IN TXT \"v=spf1 -all\""
+  - "_dmarc 3600 IN TXT \"v=DMARC1; p=reject\""
+`
+	require.Nil(t, os.WriteFile(path, []byte(config), 0600), "could not write zone")
+
+	server := &DNSServer{options: &Options{Domains: []string{"oast.example"}}, ipAddress: net.ParseIP("192.0.2.10")}
+	require.Nil(t, server.ReloadZone(path), "could not load zone")
+
+	m := new(dns.Msg)
+	server.handleSOA("oast.example.", m)
+	require.Len(t, m.Answer, 1, "could not get soa record")
+	soa := m.Answer[0].(*dns.SOA)
+	require.Equal(t, "ns1.oast.example.", soa.Ns, "could not get soa name server")
+	require.Equal(t, "hostmaster.oast.example.", soa.Mbox, "could not get soa mailbox")
+	require.Equal(t, uint32(2024010101), soa.Serial, "could not get soa serial")
+
+	m = new(dns.Msg)
+	server.handleNS("oast.example.", m)
+	require.Len(t, m.Answer, 2, "could not get name servers")
+	require.Equal(t, "ns.provider.example.", m.Answer[1].(*dns.NS).Ns, "could not keep absolute name server")
+	require.Len(t, m.Extra, 3, "could not get glue records")
+	require.Equal(t, "2001:db8::1", m.Extra[1].(*dns.AAAA).AAAA.String(), "could not get ipv6 glue record")
+	require.Equal(t, "192.0.2.10", m.Extra[2].(*dns.A).A.String(), "could not default glue record to server ip")
+
+	zone := server.getZone()
+	require.Len(t, zone.staticRecords("OAST.example.", dns.TypeTXT), 1, "could not get static txt record")
+	require.Len(t, zone.staticRecords("oast.example.", dns.TypeANY), 2, "could not get static records for any")
+	require.Empty(t, zone.staticRecords("oast.example.", dns.TypeA), "could not skip static records of other types")
+	require.Equal(t, "v=DMARC1; p=reject", zone.staticRecords("_dmarc.oast.example.", dns.TypeTXT)[0].(*dns.TXT).Txt[0], "could not get relative static record")
+
+	require.Nil(t, os.WriteFile(path, []byte("records: [\"@ IN BOGUS\"]\n"), 0600), "could not write zone")
+	require.NotNil(t, server.ReloadZone(path), "could not reject invalid records")
+	require.Equal(t, zone, server.getZone(), "could not keep zone on failed reload")
+}
+
+func TestDNSServerIPv6(t *testing.T) {
+	server := &DNSServer{
+		options:       &Options{Domains: []string{"oast.example"}},
+		ipAddress:     net.ParseIP("192.0.2.10").To4(),
+		ipv6Address:   net.ParseIP("2001:db8::10"),
+		customRecords: newCustomDNSRecordsServer(""),
+	}
+	require.Nil(t, server.ReloadZone(""), "could not load zone")
+
+	answers := func(name string, qtype uint16) []dns.RR {
+		m := new(dns.Msg)
+		server.handleACNAMEANY(name, qtype, m)
+		return m.Answer
+	}
+	rrs := answers("c6rj61aciaeutn2ae680cg5ugboyyyyyn.oast.example.", dns.TypeA)
+	require.Len(t, rrs, 1, "could not get a record")
+	require.Equal(t, "192.0.2.10", rrs[0].(*dns.A).A.String(), "could not get ipv4 address")
+
+	rrs = answers("c6rj61aciaeutn2ae680cg5ugboyyyyyn.oast.example.", dns.TypeAAAA)
+	require.Len(t, rrs, 1, "could not get aaaa record")
+	require.Equal(t, "2001:db8::10", rrs[0].(*dns.AAAA).AAAA.String(), "could not get ipv6 address")
+
+	require.Len(t, answers("c6rj61aciaeutn2ae680cg5ugboyyyyyn.oast.example.", dns.TypeANY), 2, "could not get both records for any")
+	require.Empty(t, answers("c6rj61aciaeutn2ae680cg5ugboyyyyyn.v6.oast.example.", dns.TypeA), "could answer a record for ipv6 only host")
+	require.Len(t, answers("c6rj61aciaeutn2ae680cg5ugboyyyyyn.V6.oast.example.", dns.TypeAAAA), 1, "could not answer aaaa record for ipv6 only host")
+	require.Empty(t, answers("localhost.oast.example.", dns.TypeAAAA), "could answer server ipv6 address for ipv4 custom record")
+
+	m := new(dns.Msg)
+	server.handleNS("oast.example.", m)
+	require.Len(t, m.Extra, 4, "could not get ipv4 and ipv6 glue records")
+}
@@ -0,0 +1,207 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// DNSZoneConfig is the configuration of the zone served for each domain, allowing
+// the server to own its zone without external dns. Relative names are expanded
+// with each domain, "@" being the domain itself.
+type DNSZoneConfig struct {
+	SOA struct {
+		Ns      string `yaml:"ns"`
+		Mbox    string `yaml:"mbox"`
+		Serial  uint32 `yaml:"serial"`
+		Refresh uint32 `yaml:"refresh"`
+		Retry   uint32 `yaml:"retry"`
+		Expire  uint32 `yaml:"expire"`
+		Minttl  uint32 `yaml:"minttl"`
+		TTL     uint32 `yaml:"ttl"`
+	} `yaml:"soa"`
+	// Nameservers are the NS records of the domains with their glue addresses
+	Nameservers []DNSNameserver `yaml:"nameservers"`
+	// Records are static records in zone file format, e.g. "@ 3600 IN MX 10 mail"
+	Records []string `yaml:"records"`
+}
+
+// DNSNameserver is a name server of the domains
+type DNSNameserver struct {
+	Name string   `yaml:"name"`
+	IPs  []string `yaml:"ips"`
+}
+
+// LoadDNSZoneConfig reads a zone configuration YAML file
+func LoadDNSZoneConfig(input string) (*DNSZoneConfig, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read file")
+	}
+	config := &DNSZoneConfig{}
+	decoder := yaml.NewDecoder(strings.NewReader(string(data)))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(config); err != nil {
+		return nil, errors.Wrap(err, "could not decode file")
+	}
+	return config, nil
+}
+
+// dnsZone contains the zone records of the domains
+type dnsZone struct {
+	// soa contains the SOA record of each dot domain
+	soa map[string]*dns.SOA
+	// nameservers contains the name servers of each dot domain
+	nameservers map[string][]string
+	// glue contains the addresses of each name server
+	glue map[string][]net.IP
+	// records contains the static records by lower case name
+	records map[string][]dns.RR
+}
+
+// newDNSZone returns the zone of the domains, the default one serving ns1 and
+// ns2 subdomains pointing to addresses if config is nil.
+func newDNSZone(domains []string, addresses []net.IP, config *DNSZoneConfig) (*dnsZone, error) {
+	if config == nil {
+		config = &DNSZoneConfig{}
+	}
+	zone := &dnsZone{
+		soa:         make(map[string]*dns.SOA),
+		nameservers: make(map[string][]string),
+		glue:        make(map[string][]net.IP),
+		records:     make(map[string][]dns.RR),
+	}
+	seen := make(map[string]struct{})
+
+	for _, domain := range domains {
+		dotdomain := dns.Fqdn(strings.ToLower(domain))
+
+		nameservers := config.Nameservers
+		if len(nameservers) == 0 {
+			nameservers = []DNSNameserver{{Name: "ns1"}, {Name: "ns2"}}
+		}
+		for _, nameserver := range nameservers {
+			name := expandZoneName(nameserver.Name, dotdomain)
+			zone.nameservers[dotdomain] = append(zone.nameservers[dotdomain], name)
+			if _, ok := zone.glue[name]; ok {
+				continue
+			}
+			if len(nameserver.IPs) == 0 {
+				zone.glue[name] = addresses
+				continue
+			}
+			for _, value := range nameserver.IPs {
+				ip := net.ParseIP(value)
+				if ip == nil {
+					return nil, fmt.Errorf("invalid ip %s for name server %s", value, nameserver.Name)
+				}
+				zone.glue[name] = append(zone.glue[name], ip)
+			}
+		}
+
+		soa := &dns.SOA{
+			Hdr:     dns.RR_Header{Rrtype: dns.TypeSOA, Class: dns.ClassINET, Ttl: config.SOA.TTL},
+			Ns:      zone.nameservers[dotdomain][0],
+			Mbox:    certificateAuthority,
+			Serial:  1,
+			Refresh: config.SOA.Refresh,
+			Retry:   config.SOA.Retry,
+			Expire:  60,
+			Minttl:  60,
+		}
+		if config.SOA.Ns != "" {
+			soa.Ns = expandZoneName(config.SOA.Ns, dotdomain)
+		}
+		if config.SOA.Mbox != "" {
+			soa.Mbox = expandZoneName(config.SOA.Mbox, dotdomain)
+		}
+		if config.SOA.Serial != 0 {
+			soa.Serial = config.SOA.Serial
+		}
+		if config.SOA.Expire != 0 {
+			soa.Expire = config.SOA.Expire
+		}
+		if config.SOA.Minttl != 0 {
+			soa.Minttl = config.SOA.Minttl
+		}
+		zone.soa[dotdomain] = soa
+
+		if len(config.Records) == 0 {
+			continue
+		}
+		parser := dns.NewZoneParser(strings.NewReader(strings.Join(config.Records, "\n")), dotdomain, "")
+		for rr, ok := parser.Next(); ok; rr, ok = parser.Next() {
+			// absolute records are shared by all the domains
+			if _, ok := seen[rr.String()]; ok {
+				continue
+			}
+			seen[rr.String()] = struct{}{}
+			name := strings.ToLower(rr.Header().Name)
+			zone.records[name] = append(zone.records[name], rr)
+		}
+		if err := parser.Err(); err != nil {
+			return nil, errors.Wrap(err, "could not parse static records")
+		}
+	}
+	return zone, nil
+}
+
+// expandZoneName returns the fully qualified name of a name relative to dotdomain
+func expandZoneName(name, dotdomain string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	switch {
+	case name == "@" || name == "":
+		return dotdomain
+	case dns.IsFqdn(name):
+		return name
+	default:
+		return name + "." + dotdomain
+	}
+}
+
+// lookupDomain returns the value for the dot domain of zone, or the one of the first domain
+func (z *dnsZone) lookupDomain(zone, firstDomain string) string {
+	for _, dotDomain := range []string{strings.ToLower(zone), dns.Fqdn(strings.ToLower(firstDomain))} {
+		if _, ok := z.nameservers[dotDomain]; ok {
+			return dotDomain
+		}
+	}
+	return ""
+}
+
+// staticRecords returns the static records of name matching qtype, CNAME records
+// matching any type.
+func (z *dnsZone) staticRecords(name string, qtype uint16) []dns.RR {
+	var rrs []dns.RR
+	for _, rr := range z.records[strings.ToLower(name)] {
+		rrtype := rr.Header().Rrtype
+		if qtype == dns.TypeANY || rrtype == qtype || rrtype == dns.TypeCNAME {
+			rr = dns.Copy(rr)
+			// answers keep the case of the question
+			rr.Header().Name = name
+			rrs = append(rrs, rr)
+		}
+	}
+	return rrs
+}
+
+// glueRecords returns the address records of a name server
+func (z *dnsZone) glueRecords(nameserver string, ttl uint32) []dns.RR {
+	var rrs []dns.RR
+	for _, ip := range z.glue[nameserver] {
+		if len(ip) == 0 {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			rrs = append(rrs, &dns.A{Hdr: dns.RR_Header{Name: nameserver, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: ttl}, A: ip4})
+		} else {
+			rrs = append(rrs, &dns.AAAA{Hdr: dns.RR_Header{Name: nameserver, Rrtype: dns.TypeAAAA, Class: dns.ClassINET, Ttl: ttl}, AAAA: ip})
+		}
+	}
+	return rrs
+}
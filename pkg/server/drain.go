@@ -0,0 +1,126 @@
+package server
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+)
+
+// drainPollInterval is the interval between the checks of the open connections on shutdown
+const drainPollInterval = 100 * time.Millisecond
+
+// connDrainer tracks the listeners of the servers without graceful shutdown
+// and the connections they accepted, so that the sessions in progress can be
+// finished before exiting.
+type connDrainer struct {
+	sync.Mutex
+	listeners []net.Listener
+	conns     map[net.Conn]struct{}
+	closed    bool
+}
+
+func newConnDrainer() *connDrainer {
+	return &connDrainer{conns: make(map[net.Conn]struct{})}
+}
+
+// Listener returns listener tracking its accepted connections. The listener
+// is closed right away if the drainer is already shut down.
+func (d *connDrainer) Listener(listener net.Listener) net.Listener {
+	d.Lock()
+	defer d.Unlock()
+
+	if d.closed {
+		_ = listener.Close()
+	}
+	d.listeners = append(d.listeners, listener)
+	return &drainListener{Listener: listener, drainer: d}
+}
+
+// Shutdown closes the listeners and waits for the open connections to be closed.
+// The connections still open when ctx is done are closed.
+func (d *connDrainer) Shutdown(ctx context.Context) error {
+	d.Lock()
+	d.closed = true
+	for _, listener := range d.listeners {
+		_ = listener.Close()
+	}
+	d.Unlock()
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	for {
+		if d.open() == 0 {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			d.Lock()
+			conns := make([]net.Conn, 0, len(d.conns))
+			for conn := range d.conns {
+				conns = append(conns, conn)
+			}
+			d.Unlock()
+			// the connections remove themselves once closed
+			for _, conn := range conns {
+				_ = conn.Close()
+			}
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// isClosed returns true once the drainer is shut down, the errors of the
+// closed listeners being expected
+func (d *connDrainer) isClosed() bool {
+	d.Lock()
+	defer d.Unlock()
+	return d.closed
+}
+
+// open returns the number of open connections
+func (d *connDrainer) open() int {
+	d.Lock()
+	defer d.Unlock()
+	return len(d.conns)
+}
+
+func (d *connDrainer) add(conn net.Conn) {
+	d.Lock()
+	d.conns[conn] = struct{}{}
+	d.Unlock()
+}
+
+func (d *connDrainer) remove(conn net.Conn) {
+	d.Lock()
+	delete(d.conns, conn)
+	d.Unlock()
+}
+
+type drainListener struct {
+	net.Listener
+	drainer *connDrainer
+}
+
+func (l *drainListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	tracked := &drainConn{Conn: conn, drainer: l.drainer}
+	l.drainer.add(tracked)
+	return tracked, nil
+}
+
+// drainConn removes itself from the open connections once closed
+type drainConn struct {
+	net.Conn
+	drainer   *connDrainer
+	closeOnce sync.Once
+}
+
+func (c *drainConn) Close() error {
+	c.closeOnce.Do(func() { c.drainer.remove(c) })
+	return c.Conn.Close()
+}
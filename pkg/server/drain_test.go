@@ -0,0 +1,57 @@
+package server
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestConnDrainer(t *testing.T) {
+	drainer := newConnDrainer()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen")
+	drained := drainer.Listener(listener)
+
+	accept := func() net.Conn {
+		client, err := net.Dial("tcp", listener.Addr().String())
+		require.Nil(t, err, "could not dial")
+		t.Cleanup(func() { _ = client.Close() })
+		conn, err := drained.Accept()
+		require.Nil(t, err, "could not accept")
+		return conn
+	}
+	first := accept()
+	second := accept()
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = first.Close()
+	}()
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	now := time.Now()
+	require.Equal(t, context.DeadlineExceeded, drainer.Shutdown(ctx), "could not stop waiting for open connection")
+	require.GreaterOrEqual(t, time.Since(now), time.Second, "could not wait for open connection")
+	require.Equal(t, 0, drainer.open(), "could not close open connection")
+
+	_, err = second.Write([]byte("data"))
+	require.NotNil(t, err, "could write to closed connection")
+	_, err = drained.Accept()
+	require.NotNil(t, err, "could accept after shutdown")
+	require.True(t, drainer.isClosed(), "could not close drainer")
+
+	// the sessions finished before the deadline don't delay the shutdown
+	drainer = newConnDrainer()
+	listener, err = net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen")
+	drained = drainer.Listener(listener)
+	first = accept()
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		_ = first.Close()
+	}()
+	require.Nil(t, drainer.Shutdown(context.Background()), "could not drain connections")
+}
@@ -67,6 +67,7 @@ func (h *FTPServer) ListenAndServe(tlsConfig *tls.Config, ftpAlive chan bool) {
 	ftpAlive <- true
 	if err := h.ftpServer.ListenAndServe(); err != nil {
 		gologger.Error().Msgf("Could not serve ftp on port 21: %s\n", err)
+		h.options.Health.SetError("FTP", "TCP", err)
 		ftpAlive <- false
 	}
 }
@@ -88,13 +89,16 @@ func (h *FTPServer) recordInteraction(remoteAddress, data string) {
 		Timestamp:     time.Now(),
 	}
 	buffer := &bytes.Buffer{}
-	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-		gologger.Warning().Msgf("Could not encode ftp interaction: %s\n", err)
+	if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+		if err != errInteractionDropped {
+			gologger.Warning().Msgf("Could not encode ftp interaction: %s\n", err)
+		}
 	} else {
 		gologger.Debug().Msgf("FTP Interaction: \n%s\n", buffer.String())
 		if err := h.options.Storage.AddInteractionWithId(h.options.Token, buffer.Bytes()); err != nil {
 			gologger.Warning().Msgf("Could not store ftp interaction: %s\n", err)
 		}
+		h.options.exportInteraction(interaction)
 	}
 }
 
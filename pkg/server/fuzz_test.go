@@ -0,0 +1,62 @@
+//go:build go1.18
+
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"net/http"
+	"testing"
+
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/require"
+)
+
+func FuzzParseSMTPMessage(f *testing.F) {
+	f.Add([]byte("Subject: test\r\n\r\nbody"))
+	f.Add([]byte("Content-Type: multipart/mixed; boundary=b\r\n\r\n--b\r\nContent-Type: text/plain\r\n\r\ntext\r\n--b\r\nContent-Disposition: attachment; filename=a.bin\r\nContent-Transfer-Encoding: base64\r\n\r\nAAEC\r\n--b--\r\n"))
+	f.Add([]byte("Content-Type: multipart/mixed; boundary=a\r\n\r\n--a\r\nContent-Type: multipart/alternative; boundary=b\r\n\r\n--b\r\n\r\nnested\r\n--b--\r\n--a--\r\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		message, err := parseSMTPMessage(data)
+		if err != nil {
+			return
+		}
+		require.LessOrEqual(t, len(message.TextParts)+len(message.Attachments), maxMIMEParts, "could not limit parts")
+		for _, part := range message.TextParts {
+			require.LessOrEqual(t, len(part.Content), maxMIMETextSize, "could not limit text part")
+		}
+	})
+}
+
+func FuzzCaptureHTTPRequest(f *testing.F) {
+	f.Add([]byte("GET /path?q=1 HTTP/1.1\r\nHost: oast.example\r\n\r\n"))
+	f.Add([]byte("POST / HTTP/1.1\r\nHost: oast.example\r\nContent-Length: 4\r\n\r\n\x00\x01\x02\x03"))
+	f.Add([]byte("POST / HTTP/1.1\r\nHost: oast.example\r\nTransfer-Encoding: chunked\r\n\r\n4\r\ndata\r\n0\r\n\r\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			return
+		}
+		truncateBody(r)
+		request := captureHTTPRequest(r)
+		require.LessOrEqual(t, request.BodySize, maxHTTPBodySize, "could not limit body")
+	})
+}
+
+func FuzzCaptureDNSQuery(f *testing.F) {
+	msg := new(dns.Msg)
+	msg.SetQuestion("abc.oast.example.", dns.TypeA)
+	msg.SetEdns0(4096, true)
+	wire, _ := msg.Pack()
+	f.Add(wire)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		r := new(dns.Msg)
+		if err := r.Unpack(data); err != nil {
+			return
+		}
+		query := captureDNSQuery("udp", r, data)
+		if query.EDNS != nil {
+			require.LessOrEqual(t, len(query.EDNS.Options), maxDNSEDNSOptions, "could not limit edns options")
+		}
+	})
+}
@@ -0,0 +1,141 @@
+package server
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Health tracks the status of the protocol listeners of the server
+type Health struct {
+	sync.RWMutex
+	version   string
+	started   time.Time
+	listeners map[string]*ListenerStatus
+	disabled  []string
+}
+
+// ListenerStatus is the status of a protocol listener
+type ListenerStatus struct {
+	Service string `json:"service"`
+	Network string `json:"network"`
+	Port    int    `json:"port"`
+	Up      bool   `json:"up"`
+	// Since is the time of the last status change
+	Since         time.Time  `json:"since"`
+	LastError     string     `json:"last-error,omitempty"`
+	LastErrorTime *time.Time `json:"last-error-time,omitempty"`
+}
+
+// HealthReport is the response of the health endpoint
+type HealthReport struct {
+	// Status is up if all the listeners are up, degraded otherwise
+	Status        string           `json:"status"`
+	Version       string           `json:"version"`
+	Uptime        string           `json:"uptime"`
+	UptimeSeconds int64            `json:"uptime-seconds"`
+	Listeners     []ListenerStatus `json:"listeners"`
+	// Disabled are the services disabled by the configuration, which are
+	// not reported as listeners
+	Disabled []string `json:"disabled,omitempty"`
+}
+
+// NewHealth creates a new health tracker for a server version
+func NewHealth(version string) *Health {
+	return &Health{version: version, started: time.Now(), listeners: make(map[string]*ListenerStatus)}
+}
+
+func (h *Health) listener(service, network string) *ListenerStatus {
+	key := service + "/" + network
+	listener, ok := h.listeners[key]
+	if !ok {
+		listener = &ListenerStatus{Service: service, Network: network}
+		h.listeners[key] = listener
+	}
+	return listener
+}
+
+// SetStatus sets the status of a listener
+func (h *Health) SetStatus(service, network string, port int, up bool) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	listener := h.listener(service, network)
+	listener.Port = port
+	if listener.Up != up || listener.Since.IsZero() {
+		listener.Since = time.Now()
+	}
+	listener.Up = up
+}
+
+// SetDisabled sets the services disabled by the configuration
+func (h *Health) SetDisabled(services []string) {
+	if h == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	h.disabled = append([]string{}, services...)
+	sort.Strings(h.disabled)
+}
+
+// SetError records the last error of a listener
+func (h *Health) SetError(service, network string, err error) {
+	if h == nil || err == nil {
+		return
+	}
+	h.Lock()
+	defer h.Unlock()
+
+	now := time.Now()
+	listener := h.listener(service, network)
+	listener.LastError = err.Error()
+	listener.LastErrorTime = &now
+}
+
+// Report returns the status of the server and of its listeners
+func (h *Health) Report() *HealthReport {
+	h.RLock()
+	defer h.RUnlock()
+
+	uptime := time.Since(h.started)
+	report := &HealthReport{
+		Status:        "up",
+		Version:       h.version,
+		Uptime:        uptime.Round(time.Second).String(),
+		UptimeSeconds: int64(uptime / time.Second),
+		Disabled:      h.disabled,
+	}
+	for _, listener := range h.listeners {
+		if !listener.Up {
+			report.Status = "degraded"
+		}
+		report.Listeners = append(report.Listeners, *listener)
+	}
+	sort.Slice(report.Listeners, func(i, j int) bool {
+		if report.Listeners[i].Service == report.Listeners[j].Service {
+			return report.Listeners[i].Network < report.Listeners[j].Network
+		}
+		return report.Listeners[i].Service < report.Listeners[j].Service
+	})
+	return report
+}
+
+// healthHandler is a handler for the /health endpoint. The status code is
+// 503 if any of the listeners is down.
+func (h *HTTPServer) healthHandler(w http.ResponseWriter, req *http.Request) {
+	report := h.options.Health.Report()
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	if report.Status != "up" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	_ = jsoniter.NewEncoder(w).Encode(report)
+}
@@ -0,0 +1,94 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHealthHandler(t *testing.T) {
+	health := NewHealth("1.0.0")
+	server := &HTTPServer{options: &Options{Health: health}}
+
+	health.SetStatus("DNS", "UDP", 53, true)
+	health.SetStatus("HTTP", "TCP", 80, true)
+
+	w := httptest.NewRecorder()
+	server.healthHandler(w, httptest.NewRequest("GET", "http://example.com/health", nil))
+	require.Equal(t, http.StatusOK, w.Code, "could not get healthy status code")
+
+	health.SetError("HTTP", "TCP", errors.New("address already in use"))
+	health.SetStatus("HTTP", "TCP", 80, false)
+
+	w = httptest.NewRecorder()
+	server.healthHandler(w, httptest.NewRequest("GET", "http://example.com/health", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code, "could not get unhealthy status code")
+
+	report := &HealthReport{}
+	require.Nil(t, jsoniter.NewDecoder(w.Body).Decode(report), "could not decode health report")
+	require.Equal(t, "degraded", report.Status, "could not get degraded status")
+	require.Equal(t, "1.0.0", report.Version, "could not get version")
+	require.Len(t, report.Listeners, 2, "could not get listeners")
+	require.Equal(t, "HTTP", report.Listeners[1].Service, "could not sort listeners")
+	require.Equal(t, "address already in use", report.Listeners[1].LastError, "could not get last error")
+}
+
+func TestHealthHandlerHost(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	options := &Options{Domains: []string{"oast.example"}, Auth: true, Token: "token", Health: NewHealth("1.0.0"), Stats: &Metrics{}, Storage: store, CorrelationIdLength: 20, CorrelationIdNonceLength: 13}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err, "could not create http server")
+	router := server.nontlsserver.Handler
+
+	request := func(host, token string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "http://"+host+"/health", nil)
+		req.Header.Set("Authorization", token)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := request("oast.example:80", "")
+	require.Contains(t, w.Body.String(), `"listeners"`, "could not get health report on server domain")
+
+	w = request(correlationID+"abcdefghijklm.oast.example", "")
+	require.NotContains(t, w.Body.String(), `"listeners"`, "could get health report on interaction domain")
+	interactions, _, err := store.GetInteractions(correlationID, "secret")
+	require.Nil(t, err, "could not get interactions")
+	require.Len(t, interactions, 1, "could not record health interaction")
+
+	w = request(correlationID+"abcdefghijklm.oast.example", "token")
+	require.Contains(t, w.Body.String(), `"listeners"`, "could not get health report with token")
+}
+
+func TestClientAPIHost(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	options := &Options{Domains: []string{"oast.example"}, Stats: &Metrics{}, Storage: store, CorrelationIdLength: 20, CorrelationIdNonceLength: 13}
+	server, err := NewHTTPServer(options)
+	require.Nil(t, err, "could not create http server")
+	router := server.nontlsserver.Handler
+
+	paths := []string{"/rotate", "/dns-answers", "/http-response", "/response-delay", "/replay"}
+	for _, path := range paths {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "http://"+correlationID+"abcdefghijklm.oast.example"+path, nil))
+		require.Equal(t, http.StatusOK, w.Code, "could not record %s callback", path)
+	}
+	interactions, _, err := store.GetInteractions(correlationID, "secret")
+	require.Nil(t, err, "could not get interactions")
+	require.Len(t, interactions, len(paths), "could not record client api callbacks")
+
+	// the api is served on the domain and on the ip address of the server
+	for _, host := range []string{"oast.example", "192.0.2.10:80", "[2001:db8::10]:80"} {
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, httptest.NewRequest("POST", "http://"+host+"/rotate", strings.NewReader("{")))
+		require.Equal(t, http.StatusBadRequest, w.Code, "could not serve the api on %s", host)
+	}
+}
@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"unicode/utf8"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// maxCapturedBodySize is the maximum size of the http bodies stored in interactions
+const maxCapturedBodySize = 64 * 1024
+
+// HTTPRequest contains the structured fields of a http request
+type HTTPRequest = types.HTTPRequest
+
+// HTTPResponse contains the structured fields of the http response served
+type HTTPResponse = types.HTTPResponse
+
+// HTTPBody is a http body truncated to maxCapturedBodySize
+type HTTPBody = types.HTTPBody
+
+// newHTTPBody returns the captured representation of a body
+func newHTTPBody(body []byte) HTTPBody {
+	captured := HTTPBody{BodySize: len(body)}
+	if len(body) > maxCapturedBodySize {
+		body = body[:maxCapturedBodySize]
+		captured.BodyTruncated = true
+	}
+	if utf8.Valid(body) {
+		captured.Body = string(body)
+	} else {
+		captured.Body = base64.StdEncoding.EncodeToString(body)
+		captured.BodyBase64 = true
+	}
+	return captured
+}
+
+// captureHTTPRequest returns the structured fields of a request. The body is
+// restored so that it can still be consumed by the handlers.
+func captureHTTPRequest(r *http.Request) *HTTPRequest {
+	var body []byte
+	if r.Body != nil {
+		body, _ = ioutil.ReadAll(r.Body)
+		_ = r.Body.Close()
+		r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	}
+	return &HTTPRequest{
+		Method:   r.Method,
+		Path:     r.URL.Path,
+		Query:    r.URL.RawQuery,
+		Proto:    r.Proto,
+		Host:     r.Host,
+		Headers:  r.Header.Clone(),
+		HTTPBody: newHTTPBody(body),
+	}
+}
+
+// captureHTTPResponse returns the structured fields of a recorded response
+func captureHTTPResponse(rec *httptest.ResponseRecorder) *HTTPResponse {
+	return &HTTPResponse{
+		StatusCode: rec.Code,
+		Headers:    rec.Header().Clone(),
+		HTTPBody:   newHTTPBody(rec.Body.Bytes()),
+	}
+}
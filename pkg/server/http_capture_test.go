@@ -0,0 +1,37 @@
+package server
+
+import (
+	"io/ioutil"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureHTTPRequest(t *testing.T) {
+	req := httptest.NewRequest("POST", "http://test.example.com/path?a=b", strings.NewReader("body"))
+	req.Header.Set("Authorization", "Bearer token")
+
+	captured := captureHTTPRequest(req)
+	require.Equal(t, "POST", captured.Method, "could not get method")
+	require.Equal(t, "/path", captured.Path, "could not get path")
+	require.Equal(t, "a=b", captured.Query, "could not get query")
+	require.Equal(t, "test.example.com", captured.Host, "could not get host")
+	require.Equal(t, "Bearer token", captured.Headers.Get("Authorization"), "could not get header")
+	require.Equal(t, "body", captured.Body, "could not get body")
+
+	body, _ := ioutil.ReadAll(req.Body)
+	require.Equal(t, "body", string(body), "could not restore body")
+}
+
+func TestNewHTTPBody(t *testing.T) {
+	body := newHTTPBody([]byte(strings.Repeat("a", maxCapturedBodySize+1)))
+	require.True(t, body.BodyTruncated, "could not truncate body")
+	require.Equal(t, maxCapturedBodySize+1, body.BodySize, "could not get body size")
+	require.Len(t, body.Body, maxCapturedBodySize, "could not truncate body")
+
+	binary := newHTTPBody([]byte{0xff, 0xfe})
+	require.True(t, binary.BodyBase64, "could not encode binary body")
+	require.Equal(t, "//4=", binary.Body, "could not encode binary body")
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+const (
+	// redirectPath is the path of the interaction urls answering with redirects
+	redirectPath = "/redirect"
+	// maxRedirectHops is the maximum number of hops through the server of a redirect chain
+	maxRedirectHops = 10
+)
+
+// HTTPRedirect is a hop of a redirect chain requested by a payload
+type HTTPRedirect = types.HTTPRedirect
+
+// redirectChain is a chain of redirects through the server before a target url,
+// encoded in the query of the payload:
+//
+//	to (url the chain finally redirects to)
+//	hops (number of hops through the server before the target)
+//	schemes (comma separated schemes of the hops through the server, e.g. https,http)
+//	status (status code of the redirects, 302 by default)
+//	hop (position of the request in the chain, set by the server)
+type redirectChain struct {
+	target  string
+	schemes []string
+	status  int
+	hop     int
+}
+
+// parseRedirectChain returns the redirect chain requested by the query of req
+func parseRedirectChain(req *http.Request) (*redirectChain, error) {
+	values := req.URL.Query()
+
+	chain := &redirectChain{target: values.Get("to"), status: http.StatusFound}
+	if target, err := url.Parse(chain.target); err != nil || target.Scheme == "" {
+		return nil, errors.New("redirect target must be an absolute url")
+	}
+	if status := values.Get("status"); status != "" {
+		parsed, err := strconv.Atoi(status)
+		if err != nil || !isRedirectStatus(parsed) {
+			return nil, errors.Errorf("invalid redirect status %s", status)
+		}
+		chain.status = parsed
+	}
+	if hop := values.Get("hop"); hop != "" {
+		parsed, err := strconv.Atoi(hop)
+		if err != nil || parsed < 0 || parsed > maxRedirectHops {
+			return nil, errors.Errorf("invalid redirect hop %s", hop)
+		}
+		chain.hop = parsed
+	}
+	if schemes := values.Get("schemes"); schemes != "" {
+		for _, scheme := range strings.Split(strings.ToLower(schemes), ",") {
+			if scheme != "http" && scheme != "https" {
+				return nil, errors.Errorf("invalid redirect scheme %s", scheme)
+			}
+			chain.schemes = append(chain.schemes, scheme)
+		}
+	}
+	if hops := values.Get("hops"); hops != "" {
+		parsed, err := strconv.Atoi(hops)
+		if err != nil || parsed < 0 {
+			return nil, errors.Errorf("invalid redirect hops %s", hops)
+		}
+		// the hops without an explicit scheme keep the scheme of the request
+		for len(chain.schemes) < parsed {
+			chain.schemes = append(chain.schemes, requestScheme(req))
+		}
+	}
+	if len(chain.schemes) > maxRedirectHops {
+		return nil, errors.Errorf("redirect chains are limited to %d hops", maxRedirectHops)
+	}
+	return chain, nil
+}
+
+// isRedirectStatus returns true if status is a redirect status code
+func isRedirectStatus(status int) bool {
+	switch status {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther, http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+		return true
+	}
+	return false
+}
+
+// requestScheme returns the scheme req was received with
+func requestScheme(req *http.Request) string {
+	if req.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
+
+// info describes the hop of the chain
+func (c *redirectChain) info() *HTTPRedirect {
+	return &HTTPRedirect{Hop: c.hop, Remaining: len(c.schemes), Target: c.target}
+}
+
+// location returns the location of the redirect answering req, which is the
+// next hop through the server or the target once there are no hops left.
+func (h *HTTPServer) location(c *redirectChain, req *http.Request) string {
+	if len(c.schemes) == 0 {
+		return c.target
+	}
+	values := url.Values{}
+	values.Set("to", c.target)
+	values.Set("hop", strconv.Itoa(c.hop+1))
+	if c.status != http.StatusFound {
+		values.Set("status", strconv.Itoa(c.status))
+	}
+	if len(c.schemes) > 1 {
+		values.Set("schemes", strings.Join(c.schemes[1:], ","))
+	}
+	scheme := c.schemes[0]
+	host := req.Host
+	if scheme != requestScheme(req) {
+		host = h.options.schemeHost(req.Host, scheme)
+	}
+	return fmt.Sprintf("%s://%s%s?%s", scheme, host, redirectPath, values.Encode())
+}
+
+// schemeHost returns host with the public port of the server for scheme
+func (options *Options) schemeHost(host, scheme string) string {
+	if hostname, _, err := net.SplitHostPort(host); err == nil {
+		host = hostname
+	}
+	port, ok := options.publicPorts()[scheme]
+	if !ok || (scheme == "http" && port == 80) || (scheme == "https" && port == 443) || port == 0 {
+		return host
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// writeRedirect answers req with the next redirect of the chain of its query
+func (h *HTTPServer) writeRedirect(w http.ResponseWriter, req *http.Request) {
+	chain, err := parseRedirectChain(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Location", h.location(chain, req))
+	w.WriteHeader(chain.status)
+}
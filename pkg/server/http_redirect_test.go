@@ -0,0 +1,43 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRedirectChain(t *testing.T) {
+	server := &HTTPServer{options: &Options{HttpPort: 80, HttpsPort: 8443}}
+	host := "c59e3crp82ke7bcnedq0cfjqdpeyyyyyy.oast.example"
+
+	w := httptest.NewRecorder()
+	server.writeRedirect(w, httptest.NewRequest("GET", "http://"+host+"/redirect?to=http://169.254.169.254/latest/meta-data/", nil))
+	require.Equal(t, 302, w.Code, "could not get redirect status")
+	require.Equal(t, "http://169.254.169.254/latest/meta-data/", w.Header().Get("Location"), "could not redirect to target")
+
+	// https -> http -> file
+	w = httptest.NewRecorder()
+	server.writeRedirect(w, httptest.NewRequest("GET", "https://"+host+"/redirect?schemes=http&status=307&to=file:///etc/passwd", nil))
+	require.Equal(t, 307, w.Code, "could not get custom redirect status")
+	location := w.Header().Get("Location")
+	require.Equal(t, "http://"+host+"/redirect?hop=1&status=307&to=file%3A%2F%2F%2Fetc%2Fpasswd", location, "could not redirect to next hop")
+
+	req := httptest.NewRequest("GET", location, nil)
+	chain, err := parseRedirectChain(req)
+	require.Nil(t, err, "could not parse redirect chain")
+	require.Equal(t, &HTTPRedirect{Hop: 1, Remaining: 0, Target: "file:///etc/passwd"}, chain.info(), "could not describe hop")
+	w = httptest.NewRecorder()
+	server.writeRedirect(w, req)
+	require.Equal(t, "file:///etc/passwd", w.Header().Get("Location"), "could not redirect to target after last hop")
+
+	w = httptest.NewRecorder()
+	server.writeRedirect(w, httptest.NewRequest("GET", "http://"+host+"/redirect?schemes=https&hops=2&to=http://127.0.0.1/", nil))
+	require.Equal(t, "https://"+host+":8443/redirect?hop=1&schemes=http&to=http%3A%2F%2F127.0.0.1%2F", w.Header().Get("Location"), "could not switch scheme with public port")
+
+	for _, query := range []string{"", "to=/relative", "to=http://127.0.0.1/&status=200", "to=http://127.0.0.1/&schemes=gopher", "to=http://127.0.0.1/&hops=11"} {
+		w = httptest.NewRecorder()
+		server.writeRedirect(w, httptest.NewRequest("GET", "http://"+host+"/redirect?"+query, nil))
+		require.Equal(t, 400, w.Code, "could redirect invalid chain %s", query)
+	}
+}
@@ -0,0 +1,76 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// maxHTTPResponseBodySize is the maximum size of the body of custom http responses
+const maxHTTPResponseBodySize = 1024 * 1024
+
+// HTTPResponseDefinition is the http response served for the URLs of a client
+type HTTPResponseDefinition = storage.HTTPResponseDefinition
+
+// HTTPResponseRequest is a request for setting the custom http response of a client.
+type HTTPResponseRequest = types.HTTPResponseRequest
+
+// validateHTTPResponse checks that a custom http response can be served
+func validateHTTPResponse(response *HTTPResponseDefinition) error {
+	if response.StatusCode != 0 && (response.StatusCode < 100 || response.StatusCode > 999) {
+		return fmt.Errorf("invalid status code %d", response.StatusCode)
+	}
+	if len(response.Body) > maxHTTPResponseBodySize {
+		return fmt.Errorf("body is larger than %d bytes", maxHTTPResponseBodySize)
+	}
+	return nil
+}
+
+// httpResponseHandler is a handler for setting the custom http response of a client
+func (h *HTTPServer) httpResponseHandler(w http.ResponseWriter, req *http.Request) {
+	r := &HTTPResponseRequest{}
+	if err := jsoniter.NewDecoder(http.MaxBytesReader(w, req.Body, 2*maxHTTPResponseBodySize)).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if r.Response != nil {
+		if err := validateHTTPResponse(r.Response); err != nil {
+			jsonError(w, fmt.Sprintf("could not set http response: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+	if err := h.options.Storage.SetHTTPResponse(r.CorrelationID, r.SecretKey, r.Response); err != nil {
+		gologger.Warning().Msgf("Could not set http response for %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set http response: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "http response set", http.StatusOK)
+	gologger.Debug().Msgf("Set http response for correlationID %s\n", r.CorrelationID)
+}
+
+// writeCustomHTTPResponse writes the custom http response of the client owning
+// the requested host. It returns false if the client has no custom response.
+func (h *HTTPServer) writeCustomHTTPResponse(w http.ResponseWriter, req *http.Request) bool {
+	correlationID := h.options.correlationIDFromDomain(req.Host)
+	if correlationID == "" {
+		return false
+	}
+	response := h.options.Storage.GetHTTPResponse(correlationID)
+	if response == nil {
+		return false
+	}
+	// custom headers replace the default ones
+	for key, values := range response.Headers {
+		w.Header()[http.CanonicalHeaderKey(key)] = append([]string(nil), values...)
+	}
+	if response.StatusCode != 0 {
+		w.WriteHeader(response.StatusCode)
+	}
+	_, _ = w.Write(response.Body)
+	return true
+}
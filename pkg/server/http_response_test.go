@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteCustomHTTPResponse(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	server := &HTTPServer{options: &Options{CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Storage: store}}
+
+	req := httptest.NewRequest("GET", "http://"+correlationID+"abcdefghijklm.oast.pro/callback", nil)
+	require.False(t, server.writeCustomHTTPResponse(httptest.NewRecorder(), req), "could write response without custom response")
+
+	response := &HTTPResponseDefinition{
+		StatusCode: 302,
+		Headers:    map[string][]string{"location": {"http://169.254.169.254/"}},
+		Body:       []byte("redirect"),
+	}
+	require.Nil(t, validateHTTPResponse(response), "could not validate response")
+	require.Nil(t, store.SetHTTPResponse(correlationID, "secret", response), "could not set http response")
+
+	w := httptest.NewRecorder()
+	require.True(t, server.writeCustomHTTPResponse(w, req), "could not write custom response")
+	require.Equal(t, 302, w.Code, "could not get custom status code")
+	require.Equal(t, "http://169.254.169.254/", w.Header().Get("Location"), "could not get custom header")
+	require.Equal(t, "redirect", w.Body.String(), "could not get custom body")
+
+	require.NotNil(t, validateHTTPResponse(&HTTPResponseDefinition{StatusCode: 42}), "could validate invalid status code")
+}
@@ -2,10 +2,13 @@ package server
 
 import (
 	"bytes"
+	"compress/gzip"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"io/ioutil"
 	"log"
+	"math"
 	"net"
 	"net/http"
 	"net/http/httptest"
@@ -18,7 +21,9 @@ import (
 
 	jsoniter "github.com/json-iterator/go"
 	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/types"
 	"github.com/projectdiscovery/stringsutil"
+	"go.uber.org/multierr"
 )
 
 // HTTPServer is a http server instance that listens both
@@ -29,6 +34,7 @@ type HTTPServer struct {
 	nontlsserver  http.Server
 	customBanner  string
 	staticHandler http.Handler
+	fingerprints  *fingerprintRegistry
 }
 
 type noopLogger struct {
@@ -51,7 +57,8 @@ func disableDirectoryListing(next http.Handler) http.Handler {
 
 // NewHTTPServer returns a new TLS & Non-TLS HTTP server.
 func NewHTTPServer(options *Options) (*HTTPServer, error) {
-	server := &HTTPServer{options: options}
+	server := &HTTPServer{options: options, fingerprints: newFingerprintRegistry()}
+	server.fingerprints.abandoned = options.sniHandler()
 
 	// If a static directory is specified, also serve it.
 	if options.HTTPDirectory != "" {
@@ -72,41 +79,114 @@ func NewHTTPServer(options *Options) (*HTTPServer, error) {
 	router.Handle("/", server.logger(http.HandlerFunc(server.defaultHandler)))
 	router.Handle("/register", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.registerHandler))))
 	router.Handle("/deregister", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.deregisterHandler))))
-	router.Handle("/poll", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.pollHandler))))
+	router.Handle("/rotate", server.serverHostMiddleware(server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.rotateHandler)))))
+	router.Handle("/dns-answers", server.serverHostMiddleware(server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.dnsAnswersHandler)))))
+	router.Handle("/http-response", server.serverHostMiddleware(server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.httpResponseHandler)))))
+	router.Handle("/response-delay", server.serverHostMiddleware(server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.responseDelayHandler)))))
+	router.Handle("/poll", server.corsMiddleware(server.authMiddleware(server.gzipMiddleware(http.HandlerFunc(server.pollHandler)))))
+	router.Handle("/replay", server.serverHostMiddleware(server.corsMiddleware(server.authMiddleware(server.gzipMiddleware(http.HandlerFunc(server.replayHandler))))))
+	if server.options.Health != nil {
+		router.Handle("/health", server.serverHostMiddleware(http.HandlerFunc(server.healthHandler)))
+	}
 	if server.options.EnableMetrics {
-		router.Handle("/metrics", server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.metricsHandler))))
+		router.Handle("/metrics", server.corsMiddleware(server.scopeMiddleware(ScopeStats, http.HandlerFunc(server.metricsHandler))))
+	}
+	router.Handle("/admin/stats", server.serverHostMiddleware(server.scopeMiddleware(ScopeStats, http.HandlerFunc(server.metricsHandler))))
+	router.Handle("/stats", server.serverHostMiddleware(server.corsMiddleware(server.scopeMiddleware(ScopeStats, http.HandlerFunc(server.statsHandler)))))
+	router.Handle("/admin/evict", server.serverHostMiddleware(server.scopeMiddleware(ScopeEvict, http.HandlerFunc(server.evictHandler))))
+	router.Handle("/admin/reload", server.serverHostMiddleware(server.scopeMiddleware(ScopeReload, http.HandlerFunc(server.reloadHandler))))
+	router.Handle("/admin/keys", server.serverHostMiddleware(server.scopeMiddleware(ScopeKeys, http.HandlerFunc(server.apiKeysHandler))))
+	router.Handle("/admin/keys/revoke", server.serverHostMiddleware(server.scopeMiddleware(ScopeKeys, http.HandlerFunc(server.revokeAPIKeyHandler))))
+	if server.options.Tenants != nil {
+		router.Handle("/tenant", server.serverHostMiddleware(server.corsMiddleware(server.authMiddleware(http.HandlerFunc(server.tenantHandler)))))
+	}
+	if server.options.History != nil {
+		router.Handle("/admin/search", server.serverHostMiddleware(server.scopeMiddleware(ScopeSearch, http.HandlerFunc(server.searchHandler))))
 	}
-	server.tlsserver = http.Server{Addr: options.ListenIP + fmt.Sprintf(":%d", options.HttpsPort), Handler: router, ErrorLog: log.New(&noopLogger{}, "", 0)}
-	server.nontlsserver = http.Server{Addr: options.ListenIP + fmt.Sprintf(":%d", options.HttpPort), Handler: router, ErrorLog: log.New(&noopLogger{}, "", 0)}
+	if server.options.Dashboard != nil {
+		router.Handle("/dashboard", server.serverHostMiddleware(http.HandlerFunc(server.dashboardHandler)))
+		router.Handle("/dashboard/interactions", server.serverHostMiddleware(server.authMiddleware(http.HandlerFunc(server.dashboardInteractionsHandler))))
+		router.Handle("/dashboard/stats", server.serverHostMiddleware(server.tenantScopeMiddleware(ScopeStats, http.HandlerFunc(server.dashboardStatsHandler))))
+	}
+	server.tlsserver = newLimitedHTTPServer(options.listenAddress(options.HttpsPort), server.limitMiddleware(router))
+	// the requests outside of the interactions (eg. the api) also serve the connections
+	server.tlsserver.ConnState = func(conn net.Conn, state http.ConnState) {
+		if state == http.StateActive {
+			server.fingerprints.serve(conn.RemoteAddr().String())
+		}
+	}
+	server.nontlsserver = newLimitedHTTPServer(options.listenAddress(options.HttpPort), server.limitMiddleware(router))
 	return server, nil
 }
 
+// newLimitedHTTPServer returns a http server with the timeouts and the header
+// size limit of the captured requests.
+func newLimitedHTTPServer(addr string, handler http.Handler) http.Server {
+	return http.Server{
+		Addr:              addr,
+		Handler:           handler,
+		ReadHeaderTimeout: httpReadHeaderTimeout,
+		ReadTimeout:       httpReadTimeout,
+		WriteTimeout:      httpWriteTimeout,
+		IdleTimeout:       httpIdleTimeout,
+		MaxHeaderBytes:    maxHTTPHeaderBytes,
+		ErrorLog:          log.New(&noopLogger{}, "", 0),
+	}
+}
+
 // ListenAndServe listens on http and/or https ports for the server.
 func (h *HTTPServer) ListenAndServe(tlsConfig *tls.Config, httpAlive, httpsAlive chan bool) {
 	go func() {
-		if tlsConfig == nil {
+		if tlsConfig == nil || !h.options.serviceEnabled("https") {
 			return
 		}
-		h.tlsserver.TLSConfig = tlsConfig
+		h.tlsserver.TLSConfig = requestClientCertificates(tlsConfig)
 
+		listener, err := h.options.listen(h.tlsserver.Addr, h.options.HttpsPort)
+		if err != nil {
+			gologger.Error().Msgf("Could not listen http on tls: %s\n", err)
+			h.options.Health.SetError("HTTPS", "TCP", err)
+			httpsAlive <- false
+			return
+		}
 		httpsAlive <- true
-		if err := h.tlsserver.ListenAndServeTLS("", ""); err != nil {
+		if err := h.tlsserver.ServeTLS(h.fingerprints.Listener(listener), "", ""); err != nil && err != http.ErrServerClosed {
 			gologger.Error().Msgf("Could not serve http on tls: %s\n", err)
+			h.options.Health.SetError("HTTPS", "TCP", err)
 			httpsAlive <- false
 		}
 	}()
 
+	if !h.options.serviceEnabled("http") {
+		return
+	}
+	listener, err := h.options.listen(h.nontlsserver.Addr, h.options.HttpPort)
+	if err != nil {
+		gologger.Error().Msgf("Could not listen http: %s\n", err)
+		h.options.Health.SetError("HTTP", "TCP", err)
+		httpAlive <- false
+		return
+	}
 	httpAlive <- true
-	if err := h.nontlsserver.ListenAndServe(); err != nil {
+	if err := h.nontlsserver.Serve(listener); err != nil && err != http.ErrServerClosed {
+		h.options.Health.SetError("HTTP", "TCP", err)
 		httpAlive <- false
 		gologger.Error().Msgf("Could not serve http: %s\n", err)
 	}
 }
 
+// Shutdown stops the http servers from accepting new connections and waits
+// for the in-flight requests to be completed, until ctx is done.
+func (h *HTTPServer) Shutdown(ctx context.Context) error {
+	return multierr.Combine(h.tlsserver.Shutdown(ctx), h.nontlsserver.Shutdown(ctx))
+}
+
 func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
+		truncateBody(r)
 		req, _ := httputil.DumpRequest(r, true)
 		reqString := string(req)
+		httpRequest := captureHTTPRequest(r)
 
 		gologger.Debug().Msgf("New HTTP request: %s\n", reqString)
 		rec := httptest.NewRecorder()
@@ -114,6 +194,13 @@ func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 
 		resp, _ := httputil.DumpResponse(rec.Result(), true)
 		respString := string(resp)
+		httpResponse := captureHTTPResponse(rec)
+		if h.options.DynamicResp && strings.EqualFold(r.URL.Path, redirectPath) {
+			// the custom responses of the clients take precedence over the chains
+			if chain, err := parseRedirectChain(r); err == nil && rec.Header().Get("Location") == h.location(chain, r) {
+				httpResponse.Redirect = chain.info()
+			}
+		}
 
 		for k, v := range rec.Header() {
 			w.Header()[k] = v
@@ -131,29 +218,47 @@ func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 			host, _, _ = net.SplitHostPort(r.RemoteAddr)
 		}
 
+		// base holds the fields shared by all the interactions of the request
+		base := Interaction{
+			Protocol:      "http",
+			RawRequest:    reqString,
+			RawResponse:   respString,
+			HTTPRequest:   httpRequest,
+			HTTPResponse:  httpResponse,
+			RemoteAddress: host,
+		}
+		if r.TLS != nil {
+			base.TLS = h.fingerprints.Get(r.RemoteAddr)
+			if len(r.TLS.PeerCertificates) > 0 {
+				if base.TLS == nil {
+					base.TLS = &TLSInfo{}
+				}
+				base.TLS.ClientCertificates = parseClientCertificates(peerCertificates(r.TLS.PeerCertificates))
+			}
+		}
+
 		// if root-tld is enabled stores any interaction towards the main domain
 		if h.options.RootTLD {
 			for _, domain := range h.options.Domains {
 				if h.options.RootTLD && stringsutil.HasSuffixI(r.Host, domain) {
 					ID := domain
 					host, _, _ := net.SplitHostPort(r.RemoteAddr)
-					interaction := &Interaction{
-						Protocol:      "http",
-						UniqueID:      r.Host,
-						FullId:        r.Host,
-						RawRequest:    reqString,
-						RawResponse:   respString,
-						RemoteAddress: host,
-						Timestamp:     time.Now(),
-					}
+					interaction := base
+					interaction.UniqueID = r.Host
+					interaction.FullId = r.Host
+					interaction.RemoteAddress = host
+					interaction.Timestamp = time.Now()
 					buffer := &bytes.Buffer{}
-					if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-						gologger.Warning().Msgf("Could not encode root tld http interaction: %s\n", err)
+					if err := h.options.encodeInteraction(buffer, &interaction); err != nil {
+						if err != errInteractionDropped {
+							gologger.Warning().Msgf("Could not encode root tld http interaction: %s\n", err)
+						}
 					} else {
 						gologger.Debug().Msgf("Root TLD HTTP Interaction: \n%s\n", buffer.String())
 						if err := h.options.Storage.AddInteractionWithId(ID, buffer.Bytes()); err != nil {
 							gologger.Warning().Msgf("Could not store root tld http interaction: %s\n", err)
 						}
+						h.options.exportInteraction(&interaction)
 					}
 				}
 			}
@@ -165,7 +270,7 @@ func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 				for part := range stringsutil.SlideWithLength(chunk, h.options.GetIdLength()) {
 					normalizedPart := strings.ToLower(part)
 					if h.options.isCorrelationID(normalizedPart) {
-						h.handleInteraction(normalizedPart, part, reqString, respString, host)
+						h.handleInteraction(normalizedPart, part, base)
 					}
 				}
 			}
@@ -179,7 +284,7 @@ func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 						if i+1 <= len(parts) {
 							fullID = strings.Join(parts[:i+1], ".")
 						}
-						h.handleInteraction(normalizedPartChunk, fullID, reqString, respString, host)
+						h.handleInteraction(normalizedPartChunk, fullID, base)
 					}
 				}
 			}
@@ -187,28 +292,27 @@ func (h *HTTPServer) logger(handler http.Handler) http.HandlerFunc {
 	}
 }
 
-func (h *HTTPServer) handleInteraction(uniqueID, fullID, reqString, respString, hostPort string) {
+// handleInteraction stores a copy of the base interaction for the unique id
+func (h *HTTPServer) handleInteraction(uniqueID, fullID string, base Interaction) {
 	correlationID := uniqueID[:h.options.CorrelationIdLength]
 
-	// host, _, _ := net.SplitHostPort(hostPort)
-	interaction := &Interaction{
-		Protocol:      "http",
-		UniqueID:      uniqueID,
-		FullId:        fullID,
-		RawRequest:    reqString,
-		RawResponse:   respString,
-		RemoteAddress: hostPort,
-		Timestamp:     time.Now(),
-	}
+	interaction := &base
+	interaction.UniqueID = uniqueID
+	interaction.FullId = fullID
+	interaction.Timestamp = time.Now()
+
 	buffer := &bytes.Buffer{}
-	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-		gologger.Warning().Msgf("Could not encode http interaction: %s\n", err)
+	if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+		if err != errInteractionDropped {
+			gologger.Warning().Msgf("Could not encode http interaction: %s\n", err)
+		}
 	} else {
 		gologger.Debug().Msgf("HTTP Interaction: \n%s\n", buffer.String())
 
 		if err := h.options.Storage.AddInteraction(correlationID, buffer.Bytes()); err != nil {
 			gologger.Warning().Msgf("Could not store http interaction: %s\n", err)
 		}
+		h.options.exportInteraction(interaction)
 	}
 }
 
@@ -244,6 +348,21 @@ func (h *HTTPServer) defaultHandler(w http.ResponseWriter, req *http.Request) {
 	w.Header().Set("Server", domain)
 	w.Header().Set("X-Interactsh-Version", h.options.Version)
 
+	if delay := h.options.responseDelay(req.Host); delay > 0 {
+		// stop waiting if the client goes away
+		select {
+		case <-req.Context().Done():
+			return
+		case <-time.After(delay):
+		}
+	}
+	if h.writeCustomHTTPResponse(w, req) {
+		return
+	}
+	if h.options.DynamicResp && strings.EqualFold(req.URL.Path, redirectPath) {
+		h.writeRedirect(w, req)
+		return
+	}
 	if stringsutil.HasPrefixI(req.URL.Path, "/s/") && h.staticHandler != nil {
 		h.staticHandler.ServeHTTP(w, req)
 	} else if req.URL.Path == "/" && reflection == "" {
@@ -301,19 +420,25 @@ func writeResponseFromDynamicRequest(w http.ResponseWriter, req *http.Request) {
 }
 
 // RegisterRequest is a request for client registration to interactsh server.
-type RegisterRequest struct {
-	// PublicKey is the public RSA Key of the client.
-	PublicKey string `json:"public-key"`
-	// SecretKey is the secret-key for correlation ID registered for the client.
-	SecretKey string `json:"secret-key"`
-	// CorrelationID is an ID for correlation with requests.
-	CorrelationID string `json:"correlation-id"`
-}
+type RegisterRequest = types.RegisterRequest
+
+// RegisterResponse is the response of the interactsh server to a registration.
+//
+// The correlation id and nonce lengths used by the server are always returned,
+// so that clients with different lengths can adapt and register again.
+type RegisterResponse = types.RegisterResponse
 
 // registerHandler is a handler for client register requests
 func (h *HTTPServer) registerHandler(w http.ResponseWriter, req *http.Request) {
 	atomic.AddInt64(&h.options.Stats.Sessions, 1)
 
+	response := &RegisterResponse{
+		CorrelationIdLength:      h.options.CorrelationIdLength,
+		CorrelationIdNonceLength: h.options.CorrelationIdNonceLength,
+		PublicPorts:              h.options.publicPorts(),
+		DisabledServices:         h.options.DisabledServices,
+		NonceEncodings:           h.options.nonceEncodings(),
+	}
 	r := &RegisterRequest{}
 	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
 		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
@@ -321,23 +446,63 @@ func (h *HTTPServer) registerHandler(w http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	// ids with different lengths are never matched, the client has to adapt
+	if len(r.CorrelationID) != h.options.CorrelationIdLength || (r.CorrelationIdNonceLength != 0 && r.CorrelationIdNonceLength != h.options.CorrelationIdNonceLength) {
+		gologger.Debug().Msgf("Rejected correlationID %s with unsupported lengths\n", r.CorrelationID)
+		response.Error = fmt.Sprintf("unsupported correlation id lengths, server requires %d (correlation id) and %d (nonce)", h.options.CorrelationIdLength, h.options.CorrelationIdNonceLength)
+		registerResponse(w, response, http.StatusBadRequest)
+		return
+	}
+	if err := h.options.checkNonceEncoding(r.NonceEncoding); err != nil {
+		gologger.Debug().Msgf("Rejected correlationID %s: %s\n", r.CorrelationID, err)
+		response.Error = err.Error()
+		registerResponse(w, response, http.StatusBadRequest)
+		return
+	}
+
+	tenant := h.tenant(req)
+	if err := h.checkTenant(tenant, r.CorrelationID); err != nil {
+		jsonError(w, fmt.Sprintf("could not register: %s", err), http.StatusForbidden)
+		return
+	}
+	if err := h.checkTenantSessions(tenant, r.CorrelationID); err != nil {
+		gologger.Warning().Msgf("Rejected correlationID %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not register: %s", err), http.StatusTooManyRequests)
+		return
+	}
 	if err := h.options.Storage.SetIDPublicKey(r.CorrelationID, r.SecretKey, r.PublicKey); err != nil {
 		gologger.Warning().Msgf("Could not set id and public key for %s: %s\n", r.CorrelationID, err)
 		jsonError(w, fmt.Sprintf("could not set id and public key: %s", err), http.StatusBadRequest)
 		return
 	}
-	jsonMsg(w, "registration successful", http.StatusOK)
+	if tenant != nil {
+		if err := h.options.Storage.SetTenant(r.CorrelationID, r.SecretKey, tenant.Name, tenant.quota); err != nil {
+			gologger.Warning().Msgf("Could not set tenant for %s: %s\n", r.CorrelationID, err)
+			jsonError(w, fmt.Sprintf("could not set tenant: %s", err), http.StatusBadRequest)
+			return
+		}
+		response.Subdomain = tenant.Subdomain
+	}
+	if err := h.setDNSAnswers(r.CorrelationID, r.SecretKey, r.DNSAnswers); err != nil {
+		gologger.Warning().Msgf("Could not set dns answers for %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set dns answers: %s", err), http.StatusBadRequest)
+		return
+	}
+	response.Message = "registration successful"
+	registerResponse(w, response, http.StatusOK)
 	gologger.Debug().Msgf("Registered correlationID %s for key\n", r.CorrelationID)
 }
 
-// DeregisterRequest is a request for client deregistration to interactsh server.
-type DeregisterRequest struct {
-	// CorrelationID is an ID for correlation with requests.
-	CorrelationID string `json:"correlation-id"`
-	// SecretKey is the secretKey for the interactsh client.
-	SecretKey string `json:"secret-key"`
+func registerResponse(w http.ResponseWriter, response *RegisterResponse, code int) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(code)
+	_ = jsoniter.NewEncoder(w).Encode(response)
 }
 
+// DeregisterRequest is a request for client deregistration to interactsh server.
+type DeregisterRequest = types.DeregisterRequest
+
 // deregisterHandler is a handler for client deregister requests
 func (h *HTTPServer) deregisterHandler(w http.ResponseWriter, req *http.Request) {
 	atomic.AddInt64(&h.options.Stats.Sessions, -1)
@@ -358,14 +523,30 @@ func (h *HTTPServer) deregisterHandler(w http.ResponseWriter, req *http.Request)
 	gologger.Debug().Msgf("Deregistered correlationID %s for key\n", r.CorrelationID)
 }
 
-// PollResponse is the response for a polling request
-type PollResponse struct {
-	Data    []string `json:"data"`
-	Extra   []string `json:"extra"`
-	AESKey  string   `json:"aes_key"`
-	TLDData []string `json:"tlddata,omitempty"`
+// RotateRequest is a request for rotating the keys of a registered client.
+type RotateRequest = types.RotateRequest
+
+// rotateHandler is a handler for client key rotation requests
+func (h *HTTPServer) rotateHandler(w http.ResponseWriter, req *http.Request) {
+	r := &RotateRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+
+	if err := h.options.Storage.RotateKeys(r.CorrelationID, r.SecretKey, r.NewSecretKey, r.PublicKey); err != nil {
+		gologger.Warning().Msgf("Could not rotate keys for %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not rotate keys: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "rotation successful", http.StatusOK)
+	gologger.Debug().Msgf("Rotated keys for correlationID %s\n", r.CorrelationID)
 }
 
+// PollResponse is the response for a polling request
+type PollResponse = types.PollResponse
+
 // pollHandler is a handler for client poll requests
 func (h *HTTPServer) pollHandler(w http.ResponseWriter, req *http.Request) {
 	ID := req.URL.Query().Get("id")
@@ -378,32 +559,121 @@ func (h *HTTPServer) pollHandler(w http.ResponseWriter, req *http.Request) {
 		jsonError(w, "no secret specified for poll", http.StatusBadRequest)
 		return
 	}
+	tenant := h.tenant(req)
+	if err := h.checkTenant(tenant, ID); err != nil {
+		jsonError(w, fmt.Sprintf("could not get interactions: %s", err), http.StatusForbidden)
+		return
+	}
 
-	data, aesKey, err := h.options.Storage.GetInteractions(ID, secret)
+	var data []string
+	var aesKey string
+	var sequence uint64
+	var err error
+	// with since the interactions are kept until acknowledged by a later poll
+	if since := req.URL.Query().Get("since"); since != "" {
+		parsed, parseErr := strconv.ParseUint(since, 10, 64)
+		if parseErr != nil {
+			jsonError(w, fmt.Sprintf("invalid since specified for poll: %s", parseErr), http.StatusBadRequest)
+			return
+		}
+		data, aesKey, sequence, err = h.options.Storage.GetInteractionsSince(ID, secret, parsed)
+	} else {
+		data, aesKey, err = h.options.Storage.GetInteractions(ID, secret)
+	}
 	if err != nil {
 		gologger.Warning().Msgf("Could not get interactions for %s: %s\n", ID, err)
 		jsonError(w, fmt.Sprintf("could not get interactions: %s", err), http.StatusBadRequest)
 		return
 	}
 
-	// At this point the client is authenticated, so we return also the data related to the auth token
+	// At this point the client is authenticated, so we return also the data related to the auth token,
+	// which is not shared with the tenants
 	var tlddata, extradata []string
-	if h.options.RootTLD {
+	if h.options.RootTLD && tenant == nil {
 		for _, domain := range h.options.Domains {
 			tlddata, _ = h.options.Storage.GetInteractionsWithId(domain)
 		}
 		extradata, _ = h.options.Storage.GetInteractionsWithId(h.options.Token)
 	}
-	response := &PollResponse{Data: data, AESKey: aesKey, TLDData: tlddata, Extra: extradata}
+	response := &PollResponse{Data: data, AESKey: aesKey, TLDData: tlddata, Extra: extradata, Sequence: sequence, Metadata: h.options.Storage.GetMetadata(ID)}
 
 	if err := jsoniter.NewEncoder(w).Encode(response); err != nil {
+		// the response may be partially written, only the error is logged
 		gologger.Warning().Msgf("Could not encode interactions for %s: %s\n", ID, err)
-		jsonError(w, fmt.Sprintf("could not encode interactions: %s", err), http.StatusBadRequest)
 		return
 	}
 	gologger.Debug().Msgf("Polled %d interactions for %s correlationID\n", len(data), ID)
 }
 
+// gzipResponseWriter compresses the body written to the response
+type gzipResponseWriter struct {
+	http.ResponseWriter
+	writer *gzip.Writer
+}
+
+func (w *gzipResponseWriter) Write(b []byte) (int, error) {
+	return w.writer.Write(b)
+}
+
+// gzipMiddleware compresses the responses for the clients accepting gzip encoding
+func (h *HTTPServer) gzipMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !strings.Contains(req.Header.Get("Accept-Encoding"), "gzip") {
+			next.ServeHTTP(w, req)
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		writer := gzip.NewWriter(w)
+		defer writer.Close()
+		next.ServeHTTP(&gzipResponseWriter{ResponseWriter: w, writer: writer}, req)
+	})
+}
+
+// replayHandler is a handler for client replay requests of delivered interactions
+func (h *HTTPServer) replayHandler(w http.ResponseWriter, req *http.Request) {
+	values := req.URL.Query()
+	ID := values.Get("id")
+	if ID == "" {
+		jsonError(w, "no id specified for replay", http.StatusBadRequest)
+		return
+	}
+	secret := values.Get("secret")
+	if secret == "" {
+		jsonError(w, "no secret specified for replay", http.StatusBadRequest)
+		return
+	}
+	if err := h.checkTenant(h.tenant(req), ID); err != nil {
+		jsonError(w, fmt.Sprintf("could not get replay interactions: %s", err), http.StatusForbidden)
+		return
+	}
+	from, err := strconv.ParseUint(values.Get("from"), 10, 64)
+	if err != nil {
+		jsonError(w, fmt.Sprintf("invalid from specified for replay: %s", err), http.StatusBadRequest)
+		return
+	}
+	to := uint64(math.MaxUint64)
+	if value := values.Get("to"); value != "" {
+		if to, err = strconv.ParseUint(value, 10, 64); err != nil {
+			jsonError(w, fmt.Sprintf("invalid to specified for replay: %s", err), http.StatusBadRequest)
+			return
+		}
+	}
+
+	data, aesKey, err := h.options.Storage.GetReplay(ID, secret, from, to)
+	if err != nil {
+		gologger.Warning().Msgf("Could not get replay interactions for %s: %s\n", ID, err)
+		jsonError(w, fmt.Sprintf("could not get replay interactions: %s", err), http.StatusBadRequest)
+		return
+	}
+	response := &PollResponse{Data: data, AESKey: aesKey}
+	if err := jsoniter.NewEncoder(w).Encode(response); err != nil {
+		gologger.Warning().Msgf("Could not encode replay interactions for %s: %s\n", ID, err)
+		return
+	}
+	gologger.Debug().Msgf("Replayed %d interactions for %s correlationID\n", len(data), ID)
+}
+
 func (h *HTTPServer) corsMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
 		// Set CORS headers for the preflight request
@@ -447,13 +717,70 @@ func (h *HTTPServer) authMiddleware(next http.Handler) http.Handler {
 }
 
 func (h *HTTPServer) checkToken(req *http.Request) bool {
-	return !h.options.Auth || h.options.Auth && h.options.Token == req.Header.Get("Authorization")
+	return !h.options.Auth || h.options.Auth && h.options.Token == req.Header.Get("Authorization") || h.tenant(req) != nil
+}
+
+// serverHostMiddleware serves the requests to the domains of the server, or
+// authenticated, with next. The other ones are callbacks to common paths (eg.
+// ssrf to /health) and are handled as interactions.
+func (h *HTTPServer) serverHostMiddleware(next http.Handler) http.Handler {
+	interactionHandler := h.logger(http.HandlerFunc(h.defaultHandler))
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if !h.isServerHost(req.Host) && !h.isAuthenticated(req) {
+			interactionHandler.ServeHTTP(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// isServerHost returns true if host is one of the domains of the server, or
+// an ip address for the clients connecting to the server without its domain
+func (h *HTTPServer) isServerHost(host string) bool {
+	if value, _, err := net.SplitHostPort(host); err == nil {
+		host = value
+	}
+	host = strings.TrimSuffix(host, ".")
+	if net.ParseIP(strings.Trim(host, "[]")) != nil {
+		return true
+	}
+	for _, domain := range h.options.Domains {
+		if strings.EqualFold(host, domain) {
+			return true
+		}
+	}
+	return false
+}
+
+// isAuthenticated returns true if the request has the server token, an api key
+// or the token of a tenant. Unlike checkToken, servers without auth never
+// authenticate the requests.
+func (h *HTTPServer) isAuthenticated(req *http.Request) bool {
+	if h.options.Auth && h.options.Token == req.Header.Get("Authorization") {
+		return true
+	}
+	return h.options.APIKeys.Valid(req.Header.Get(apiKeyHeader)) || h.tenant(req) != nil
+}
+
+// statsHandler is a handler for /stats endpoint, returning the storage statistics
+func (h *HTTPServer) statsHandler(w http.ResponseWriter, req *http.Request) {
+	stats, err := h.options.Storage.GetStats()
+	if err != nil {
+		gologger.Warning().Msgf("Could not get storage stats: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not get storage stats: %s", err), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = jsoniter.NewEncoder(w).Encode(stats)
 }
 
 // metricsHandler is a handler for /metrics endpoint
 func (h *HTTPServer) metricsHandler(w http.ResponseWriter, req *http.Request) {
 	interactMetrics := h.options.Stats
 	interactMetrics.Cache = GetCacheMetrics(h.options)
+	interactMetrics.Overflow = GetOverflowMetrics(h.options)
+	interactMetrics.Noise = h.options.NoiseFilter.Metrics()
 	interactMetrics.Cpu = GetCpuMetrics()
 	interactMetrics.Memory = GetMemoryMetrics()
 	interactMetrics.Network = GetNetworkMetrics()
@@ -1,11 +1,15 @@
 package server
 
 import (
+	"compress/gzip"
 	"io/ioutil"
+	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 	"time"
 
+	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/require"
 )
 
@@ -46,3 +50,42 @@ func TestWriteResponseFromDynamicRequest(t *testing.T) {
 		require.Equal(t, resp.Header.Get("Test"), "Another", "could not get correct result")
 	})
 }
+
+func TestRegisterHandlerLengths(t *testing.T) {
+	server := &HTTPServer{options: &Options{CorrelationIdLength: 4, CorrelationIdNonceLength: 6, Stats: &Metrics{}}}
+
+	req := httptest.NewRequest("POST", "http://example.com/register", strings.NewReader(`{"correlation-id":"c8rf4e8xm4c8rf4e8xm4","correlation-id-nonce-length":13}`))
+	w := httptest.NewRecorder()
+	server.registerHandler(w, req)
+
+	require.Equal(t, http.StatusBadRequest, w.Code, "could not reject unsupported lengths")
+	response := &RegisterResponse{}
+	require.Nil(t, jsoniter.NewDecoder(w.Body).Decode(response), "could not decode register response")
+	require.Equal(t, 4, response.CorrelationIdLength, "could not get correlation id length")
+	require.Equal(t, 6, response.CorrelationIdNonceLength, "could not get nonce length")
+	require.NotEmpty(t, response.Error, "could not get error")
+}
+
+func TestGzipMiddleware(t *testing.T) {
+	server := &HTTPServer{options: &Options{}}
+	handler := server.gzipMiddleware(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		_, _ = w.Write([]byte("interactions"))
+	}))
+
+	req := httptest.NewRequest("GET", "http://example.com/poll", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+
+	require.Equal(t, "gzip", w.Header().Get("Content-Encoding"), "could not get gzip encoding")
+	reader, err := gzip.NewReader(w.Body)
+	require.Nil(t, err, "could not create gzip reader")
+	data, _ := ioutil.ReadAll(reader)
+	require.Equal(t, "interactions", string(data), "could not decompress body")
+
+	req = httptest.NewRequest("GET", "http://example.com/poll", nil)
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	require.Empty(t, w.Header().Get("Content-Encoding"), "could not skip gzip encoding")
+	require.Equal(t, "interactions", w.Body.String(), "could not get plain body")
+}
@@ -63,8 +63,9 @@ func NewLDAPServer(options *Options, withLogger bool) (*LDAPServer, error) {
 func (ldapServer *LDAPServer) ListenAndServe(tlsConfig *tls.Config, ldapAlive chan bool) {
 	ldapAlive <- true
 	ldapServer.tlsConfig = tlsConfig
-	if err := ldapServer.server.ListenAndServe(fmt.Sprintf("%s:%d", ldapServer.options.ListenIP, ldapServer.options.LdapPort)); err != nil {
+	if err := ldapServer.server.ListenAndServe(ldapServer.options.listenAddress(ldapServer.options.LdapPort)); err != nil {
 		gologger.Error().Msgf("Could not serve ldap on port 10389: %s\n", err)
+		ldapServer.options.Health.SetError("LDAP", "TCP", err)
 		ldapAlive <- false
 	}
 }
@@ -150,13 +151,16 @@ func (ldapServer *LDAPServer) handleInteraction(uniqueID, fullID, reqString, hos
 			Timestamp:     time.Now(),
 		}
 		buffer := &bytes.Buffer{}
-		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-			gologger.Warning().Msgf("Could not encode ldap interaction: %s\n", err)
+		if err := ldapServer.options.encodeInteraction(buffer, interaction); err != nil {
+			if err != errInteractionDropped {
+				gologger.Warning().Msgf("Could not encode ldap interaction: %s\n", err)
+			}
 		} else {
 			gologger.Debug().Msgf("LDAP Interaction: \n%s\n", buffer.String())
 			if err := ldapServer.options.Storage.AddInteraction(correlationID, buffer.Bytes()); err != nil {
 				gologger.Warning().Msgf("Could not store ldap interaction: %s\n", err)
 			}
+			ldapServer.options.exportInteraction(interaction)
 		}
 
 	}
@@ -445,13 +449,16 @@ func (ldapServer *LDAPServer) logInteraction(interaction Interaction) {
 	interaction.Protocol = "ldap"
 	interaction.Timestamp = time.Now()
 	buffer := &bytes.Buffer{}
-	if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-		gologger.Warning().Msgf("Could not encode ldap interaction: %s\n", err)
+	if err := ldapServer.options.encodeInteraction(buffer, &interaction); err != nil {
+		if err != errInteractionDropped {
+			gologger.Warning().Msgf("Could not encode ldap interaction: %s\n", err)
+		}
 	} else {
 		gologger.Debug().Msgf("LDAP Interaction: \n%s\n", buffer.String())
 		if err := ldapServer.options.Storage.AddInteractionWithId(ldapServer.options.Token, buffer.Bytes()); err != nil {
 			gologger.Warning().Msgf("Could not store ldap interaction: %s\n", err)
 		}
+		ldapServer.options.exportInteraction(&interaction)
 	}
 }
 
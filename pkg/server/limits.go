@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// The callbacks received by the server are expected to be malformed or
+// malicious, the listeners limit the time and the memory spent on a request.
+const (
+	// httpReadHeaderTimeout is the time allowed to read the headers of a http request
+	httpReadHeaderTimeout = 10 * time.Second
+	// httpReadTimeout is the time allowed to read a http request
+	httpReadTimeout = 30 * time.Second
+	// httpWriteTimeout is the time allowed to handle a http request, including the response delay
+	httpWriteTimeout = maxResponseDelay + 30*time.Second
+	// httpIdleTimeout is the time a keep-alive http connection is kept idle
+	httpIdleTimeout = 60 * time.Second
+	// maxHTTPHeaderBytes is the maximum size of the headers of a http request
+	maxHTTPHeaderBytes = 64 * 1024
+	// maxHTTPHeaders is the maximum number of header values of a http request
+	maxHTTPHeaders = 100
+	// maxHTTPBodySize is the maximum size of the http bodies read, the rest being discarded
+	maxHTTPBodySize = 1024 * 1024
+
+	// smtpTimeout is the time allowed to read a smtp command
+	smtpTimeout = 60 * time.Second
+	// maxSMTPMessageSize is the maximum size of a smtp message
+	maxSMTPMessageSize = 10 * 1024 * 1024
+	// maxSMTPHeaders is the maximum number of header values of a smtp message or part
+	maxSMTPHeaders = 100
+
+	// dnsReadTimeout is the time allowed to read a dns query
+	dnsReadTimeout = 2 * time.Second
+	// dnsWriteTimeout is the time allowed to write a dns response
+	dnsWriteTimeout = 2 * time.Second
+	// dnsIdleTimeout is the time a dns connection over tcp is kept idle
+	dnsIdleTimeout = 8 * time.Second
+	// maxDNSTCPQueries is the maximum number of queries of a dns connection over tcp
+	maxDNSTCPQueries = 32
+	// maxDNSEDNSOptions is the maximum number of captured EDNS0 options
+	maxDNSEDNSOptions = 16
+)
+
+// countHeaders returns the number of values of a header
+func countHeaders(header map[string][]string) int {
+	var count int
+	for _, values := range header {
+		count += len(values)
+	}
+	return count
+}
+
+// limitMiddleware rejects the http requests with too many headers and limits
+// the size of their body.
+func (h *HTTPServer) limitMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if countHeaders(r.Header) > maxHTTPHeaders {
+			http.Error(w, http.StatusText(http.StatusRequestHeaderFieldsTooLarge), http.StatusRequestHeaderFieldsTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, maxHTTPBodySize)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// truncateBody reads the body of a http request up to maxHTTPBodySize and
+// replaces it with the data read, so that it can be consumed several times.
+func truncateBody(r *http.Request) {
+	if r.Body == nil {
+		return
+	}
+	body, _ := ioutil.ReadAll(io.LimitReader(r.Body, maxHTTPBodySize))
+	_ = r.Body.Close()
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+}
+
+// readLimited reads up to limit bytes of reader, returning them with the size
+// of the whole content.
+func readLimited(reader io.Reader, limit int) ([]byte, int) {
+	content, _ := ioutil.ReadAll(io.LimitReader(reader, int64(limit)))
+	rest, _ := io.Copy(ioutil.Discard, reader)
+	return content, len(content) + int(rest)
+}
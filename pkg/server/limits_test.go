@@ -0,0 +1,47 @@
+package server
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimitMiddleware(t *testing.T) {
+	server := &HTTPServer{options: &Options{}}
+	var body []byte
+	handler := server.limitMiddleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		truncateBody(r)
+		body, _ = ioutil.ReadAll(r.Body)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(strings.Repeat("a", maxHTTPBodySize+10)))
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, "could not accept large body")
+	require.Len(t, body, maxHTTPBodySize, "could not truncate body")
+
+	req = httptest.NewRequest(http.MethodGet, "/", nil)
+	for i := 0; i <= maxHTTPHeaders; i++ {
+		req.Header.Add(fmt.Sprintf("X-Header-%d", i%10), "value")
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusRequestHeaderFieldsTooLarge, rec.Code, "could not reject too many headers")
+}
+
+func TestReadLimited(t *testing.T) {
+	content, size := readLimited(strings.NewReader("abcdef"), 4)
+	require.Equal(t, "abcd", string(content), "could not limit content")
+	require.Equal(t, 6, size, "could not get content size")
+}
+
+func TestParseSMTPMessageHeaderLimit(t *testing.T) {
+	message := strings.Repeat("X-Header: value\r\n", maxSMTPHeaders+1) + "\r\nbody"
+	_, err := parseSMTPMessage([]byte(message))
+	require.NotNil(t, err, "could parse message with too many headers")
+}
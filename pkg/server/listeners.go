@@ -0,0 +1,102 @@
+package server
+
+import (
+	"net"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// listenFdsStart is the first file descriptor passed by systemd socket activation
+const listenFdsStart = 3
+
+// InheritedListeners contains the sockets passed to the process by systemd
+// socket activation (LISTEN_FDS), matched to the services by their port.
+type InheritedListeners struct {
+	sync.Mutex
+	listeners   []net.Listener
+	packetConns []net.PacketConn
+}
+
+// InheritListeners returns the sockets passed by systemd socket activation.
+// The activation environment variables are unset, so that they are not
+// inherited by child processes.
+func InheritListeners() *InheritedListeners {
+	inherited := &InheritedListeners{}
+	defer func() {
+		_ = os.Unsetenv("LISTEN_PID")
+		_ = os.Unsetenv("LISTEN_FDS")
+		_ = os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if pid, err := strconv.Atoi(os.Getenv("LISTEN_PID")); err != nil || pid != os.Getpid() {
+		return inherited
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil {
+		return inherited
+	}
+	for fd := listenFdsStart; fd < listenFdsStart+count; fd++ {
+		file := os.NewFile(uintptr(fd), "listen-fd-"+strconv.Itoa(fd))
+		if listener, err := net.FileListener(file); err == nil {
+			inherited.listeners = append(inherited.listeners, listener)
+		} else if packetConn, err := net.FilePacketConn(file); err == nil {
+			inherited.packetConns = append(inherited.packetConns, packetConn)
+		}
+		// the listeners use a duplicate of the descriptor
+		_ = file.Close()
+	}
+	return inherited
+}
+
+// Len returns the number of inherited sockets not yet used by a service
+func (l *InheritedListeners) Len() int {
+	if l == nil {
+		return 0
+	}
+	l.Lock()
+	defer l.Unlock()
+	return len(l.listeners) + len(l.packetConns)
+}
+
+// Listener returns the inherited stream socket bound to port, or nil if there is none.
+func (l *InheritedListeners) Listener(port int) net.Listener {
+	if l == nil {
+		return nil
+	}
+	l.Lock()
+	defer l.Unlock()
+
+	for i, listener := range l.listeners {
+		if addr, ok := listener.Addr().(*net.TCPAddr); ok && addr.Port == port {
+			l.listeners = append(l.listeners[:i], l.listeners[i+1:]...)
+			return listener
+		}
+	}
+	return nil
+}
+
+// PacketConn returns the inherited datagram socket bound to port, or nil if there is none.
+func (l *InheritedListeners) PacketConn(port int) net.PacketConn {
+	if l == nil {
+		return nil
+	}
+	l.Lock()
+	defer l.Unlock()
+
+	for i, packetConn := range l.packetConns {
+		if addr, ok := packetConn.LocalAddr().(*net.UDPAddr); ok && addr.Port == port {
+			l.packetConns = append(l.packetConns[:i], l.packetConns[i+1:]...)
+			return packetConn
+		}
+	}
+	return nil
+}
+
+// listen returns the inherited stream socket bound to port if any, or a new listener on address.
+func (options *Options) listen(address string, port int) (net.Listener, error) {
+	if listener := options.Listeners.Listener(port); listener != nil {
+		return listener, nil
+	}
+	return net.Listen("tcp", address)
+}
@@ -0,0 +1,30 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInheritedListeners(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen tcp")
+	defer listener.Close()
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen udp")
+	defer packetConn.Close()
+
+	inherited := &InheritedListeners{listeners: []net.Listener{listener}, packetConns: []net.PacketConn{packetConn}}
+	require.Equal(t, 2, inherited.Len(), "could not get inherited sockets")
+
+	tcpPort := listener.Addr().(*net.TCPAddr).Port
+	udpPort := packetConn.LocalAddr().(*net.UDPAddr).Port
+	require.Equal(t, listener, inherited.Listener(tcpPort), "could not get listener by port")
+	require.Nil(t, inherited.Listener(tcpPort), "could get listener twice")
+	require.Equal(t, packetConn, inherited.PacketConn(udpPort), "could not get packet conn by port")
+	require.Equal(t, 0, inherited.Len(), "could not use inherited sockets")
+
+	var none *InheritedListeners
+	require.Nil(t, none.Listener(tcpPort), "could get listener without inherited sockets")
+}
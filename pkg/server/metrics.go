@@ -15,8 +15,11 @@ type Metrics struct {
 	Ldap     uint64                `json:"ldap"`
 	Smb      uint64                `json:"smb"`
 	Smtp     uint64                `json:"smtp"`
+	Tls      uint64                `json:"tls"`
 	Sessions int64                 `json:"sessions"`
 	Cache    *storage.CacheMetrics `json:"cache"`
+	Overflow *OverflowMetrics      `json:"overflow"`
+	Noise    *NoiseMetrics         `json:"noise,omitempty"`
 	Memory   *MemoryMetrics        `json:"memory"`
 	Cpu      *CpuStats             `json:"cpu"`
 	Network  *NetworkStats         `json:"network"`
@@ -27,6 +30,21 @@ func GetCacheMetrics(options *Options) *storage.CacheMetrics {
 	return cacheMetrics
 }
 
+// OverflowMetrics are the interactions dropped by the bounded buffers of the server
+type OverflowMetrics struct {
+	*storage.OverflowMetrics
+	// SyslogDropped is the number of interactions dropped because the syslog queue was full
+	SyslogDropped uint64 `json:"syslog-dropped"`
+}
+
+func GetOverflowMetrics(options *Options) *OverflowMetrics {
+	overflowMetrics := &OverflowMetrics{OverflowMetrics: options.Storage.GetOverflowMetrics()}
+	if options.SyslogExporter != nil {
+		overflowMetrics.SyslogDropped = options.SyslogExporter.Dropped()
+	}
+	return overflowMetrics
+}
+
 type MemoryMetrics struct {
 	Alloc        string `json:"alloc"`
 	TotalAlloc   string `json:"total_alloc"`
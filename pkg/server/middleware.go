@@ -0,0 +1,260 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"gopkg.in/yaml.v3"
+)
+
+// errInteractionDropped is returned when encoding an interaction dropped by a middleware
+var errInteractionDropped = errors.New("interaction dropped")
+
+// InteractionMiddleware is invoked for every captured interaction before it is
+// stored. It can enrich or tag the interaction by modifying it, and drops it by
+// returning false, in which case the next middlewares aren't invoked.
+type InteractionMiddleware func(interaction *Interaction) bool
+
+// Actions of the interaction rules
+const (
+	// RuleActionDrop drops the matching interactions
+	RuleActionDrop = "drop"
+	// RuleActionTag adds the tags of the rule to the matching interactions
+	RuleActionTag = "tag"
+	// RuleActionMirror posts the matching interactions to the url of the rule
+	RuleActionMirror = "mirror"
+)
+
+// InteractionRule is a rule of the configuration applied to the interactions
+// matching all of its conditions, the empty ones matching any interaction.
+type InteractionRule struct {
+	Name string `yaml:"name"`
+	// Protocols are the protocols of the matching interactions
+	Protocols []string `yaml:"protocols"`
+	// RemoteAddresses are the ips or cidr ranges of the matching interactions
+	RemoteAddresses []string `yaml:"remote-addresses"`
+	// Match is a regular expression matching the raw request
+	Match  string   `yaml:"match"`
+	Action string   `yaml:"action"`
+	Tags   []string `yaml:"tags"`
+	// URL receives the matching interactions in json format with the mirror action
+	URL string `yaml:"url"`
+
+	networks []*net.IPNet
+	match    *regexp.Regexp
+}
+
+// LoadInteractionRules reads the interaction rules from a YAML file
+func LoadInteractionRules(input string) ([]*InteractionRule, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read file")
+	}
+	var config struct {
+		Rules []*InteractionRule `yaml:"rules"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, errors.Wrap(err, "could not decode file")
+	}
+	for i, rule := range config.Rules {
+		if err := rule.compile(); err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %d", i+1)
+		}
+	}
+	return config.Rules, nil
+}
+
+// compile validates the rule and prepares its conditions
+func (rule *InteractionRule) compile() error {
+	switch rule.Action {
+	case RuleActionDrop:
+	case RuleActionTag:
+		if len(rule.Tags) == 0 {
+			return errors.New("no tags specified")
+		}
+	case RuleActionMirror:
+		if !strings.HasPrefix(rule.URL, "http://") && !strings.HasPrefix(rule.URL, "https://") {
+			return errors.Errorf("invalid mirror url %s", rule.URL)
+		}
+	default:
+		return errors.Errorf("unknown action %s", rule.Action)
+	}
+	for _, value := range rule.RemoteAddresses {
+		if !strings.Contains(value, "/") {
+			if strings.Contains(value, ":") {
+				value += "/128"
+			} else {
+				value += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid remote address %s", value)
+		}
+		rule.networks = append(rule.networks, network)
+	}
+	if rule.Match != "" {
+		match, err := regexp.Compile(rule.Match)
+		if err != nil {
+			return errors.Wrap(err, "invalid match")
+		}
+		rule.match = match
+	}
+	return nil
+}
+
+// matches returns true if the interaction matches the conditions of the rule
+func (rule *InteractionRule) matches(interaction *Interaction) bool {
+	if len(rule.Protocols) > 0 {
+		found := false
+		for _, protocol := range rule.Protocols {
+			if strings.EqualFold(protocol, interaction.Protocol) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(rule.networks) > 0 {
+		host := interaction.RemoteAddress
+		if value, _, err := net.SplitHostPort(host); err == nil {
+			host = value
+		}
+		ip := net.ParseIP(host)
+		found := false
+		for _, network := range rule.networks {
+			if ip != nil && network.Contains(ip) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.match != nil && !rule.match.MatchString(interaction.RawRequest) {
+		return false
+	}
+	return true
+}
+
+// MiddlewareChain is the chain of middlewares invoked for the captured interactions,
+// the middlewares added in code followed by the rules of the configuration.
+type MiddlewareChain struct {
+	sync.RWMutex
+	middlewares []InteractionMiddleware
+	rules       []*InteractionRule
+	httpClient  *http.Client
+}
+
+// NewMiddlewareChain returns an empty middleware chain
+func NewMiddlewareChain() *MiddlewareChain {
+	return &MiddlewareChain{httpClient: &http.Client{Timeout: 10 * time.Second}}
+}
+
+// Use appends middlewares to the chain
+func (c *MiddlewareChain) Use(middlewares ...InteractionMiddleware) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.middlewares = append(c.middlewares, middlewares...)
+}
+
+// SetRules replaces the rules of the chain
+func (c *MiddlewareChain) SetRules(rules []*InteractionRule) {
+	c.Lock()
+	defer c.Unlock()
+
+	c.rules = rules
+}
+
+// ReloadRules loads again the rules from input, keeping the current ones if it
+// can't be read. The rules are removed if input is empty.
+func (c *MiddlewareChain) ReloadRules(input string) error {
+	var rules []*InteractionRule
+	if input != "" {
+		var err error
+		if rules, err = LoadInteractionRules(input); err != nil {
+			return err
+		}
+	}
+	c.SetRules(rules)
+	return nil
+}
+
+// Run invokes the middlewares and rules of the chain for the interaction,
+// returning false if it has been dropped.
+func (c *MiddlewareChain) Run(interaction *Interaction) bool {
+	c.RLock()
+	middlewares := c.middlewares
+	rules := c.rules
+	c.RUnlock()
+
+	for _, middleware := range middlewares {
+		if !middleware(interaction) {
+			return false
+		}
+	}
+	for _, rule := range rules {
+		if !rule.matches(interaction) {
+			continue
+		}
+		switch rule.Action {
+		case RuleActionDrop:
+			return false
+		case RuleActionTag:
+			interaction.Tags = appendTags(interaction.Tags, rule.Tags...)
+		case RuleActionMirror:
+			c.mirror(rule.URL, interaction)
+		}
+	}
+	return true
+}
+
+// mirror posts the interaction in json format to url in the background
+func (c *MiddlewareChain) mirror(url string, interaction *Interaction) {
+	data, err := jsoniter.Marshal(interaction)
+	if err != nil {
+		gologger.Warning().Msgf("Could not mirror %s interaction: %s\n", interaction.Protocol, err)
+		return
+	}
+	protocol := interaction.Protocol
+	go func() {
+		resp, err := c.httpClient.Post(url, "application/json", bytes.NewReader(data))
+		if err != nil {
+			gologger.Warning().Msgf("Could not mirror %s interaction: %s\n", protocol, err)
+			return
+		}
+		resp.Body.Close()
+	}()
+}
+
+// appendTags appends the tags not already present
+func appendTags(tags []string, values ...string) []string {
+	for _, value := range values {
+		found := false
+		for _, tag := range tags {
+			if tag == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			tags = append(tags, value)
+		}
+	}
+	return tags
+}
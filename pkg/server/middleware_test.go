@@ -0,0 +1,69 @@
+package server
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMiddlewareChain(t *testing.T) {
+	mirrored := make(chan []byte, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		mirrored <- data
+	}))
+	defer ts.Close()
+
+	rulesFile := filepath.Join(t.TempDir(), "rules.yaml")
+	rules := `rules:
+  - name: scanners
+    protocols: [http]
+    remote-addresses: [192.0.2.0/24]
+    action: drop
+  - protocols: [dns]
+    match: "(?i)txt"
+    action: tag
+    tags: [txt]
+  - protocols: [smtp]
+    action: mirror
+    url: ` + ts.URL + `
+`
+	require.Nil(t, os.WriteFile(rulesFile, []byte(rules), 0600), "could not write rules")
+
+	chain := NewMiddlewareChain()
+	require.Nil(t, chain.ReloadRules(rulesFile), "could not load rules")
+	chain.Use(func(interaction *Interaction) bool {
+		interaction.Tags = append(interaction.Tags, "enriched")
+		return true
+	})
+
+	require.False(t, chain.Run(&Interaction{Protocol: "http", RemoteAddress: "192.0.2.1:4444"}), "could not drop interaction")
+	require.True(t, chain.Run(&Interaction{Protocol: "http", RemoteAddress: "198.51.100.1:4444"}), "could drop interaction")
+
+	interaction := &Interaction{Protocol: "dns", RawRequest: "IN TXT"}
+	require.True(t, chain.Run(interaction), "could drop interaction")
+	require.Equal(t, []string{"enriched", "txt"}, interaction.Tags, "could not tag interaction")
+
+	require.True(t, chain.Run(&Interaction{Protocol: "smtp", RawRequest: "HELO"}), "could drop interaction")
+	select {
+	case data := <-mirrored:
+		require.True(t, bytes.Contains(data, []byte("HELO")), "could not mirror interaction")
+	case <-time.After(5 * time.Second):
+		require.Fail(t, "could not mirror interaction")
+	}
+
+	options := &Options{Middlewares: chain}
+	err := options.encodeInteraction(&bytes.Buffer{}, &Interaction{Protocol: "http", RemoteAddress: "192.0.2.1"})
+	require.Equal(t, errInteractionDropped, err, "could encode dropped interaction")
+
+	require.Nil(t, os.WriteFile(rulesFile, []byte("rules:\n  - action: unknown\n"), 0600), "could not write rules")
+	require.NotNil(t, chain.ReloadRules(rulesFile), "could load invalid rules")
+	require.False(t, chain.Run(&Interaction{Protocol: "http", RemoteAddress: "192.0.2.1"}), "could not keep rules")
+}
@@ -0,0 +1,266 @@
+package server
+
+import (
+	"bytes"
+	"net"
+	"os"
+	"regexp"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/stringsutil"
+	"gopkg.in/yaml.v3"
+)
+
+// Tags added to the noise interactions with the tag action
+const (
+	// noiseTag is added to all the noise interactions
+	noiseTag = "noise"
+	// noiseUnregisteredTag is added to the interactions without a registered correlation id
+	noiseUnregisteredTag = "unregistered"
+	// noiseScannerTagPrefix prefixes the name of the scanner of the interactions
+	noiseScannerTagPrefix = "scanner:"
+)
+
+// Scanner describes the interactions of a known benign internet scanner, by
+// the ranges of its addresses or the user agents of its http requests.
+type Scanner struct {
+	Name string `yaml:"name"`
+	// Ranges are the ips or cidr ranges of the scanner
+	Ranges []string `yaml:"ranges"`
+	// UserAgents are case insensitive regular expressions matching the user agents of the scanner
+	UserAgents []string `yaml:"user-agents"`
+
+	networks   []*net.IPNet
+	userAgents []*regexp.Regexp
+}
+
+// defaultScanners are the scanners recognized by their user agents without configuration
+var defaultScanners = []*Scanner{
+	{Name: "censys", UserAgents: []string{`CensysInspect`}},
+	{Name: "zgrab", UserAgents: []string{`zgrab`}},
+	{Name: "masscan", UserAgents: []string{`masscan`}},
+	{Name: "nmap", UserAgents: []string{`Nmap Scripting Engine`}},
+	{Name: "expanse", UserAgents: []string{`Expanse, a Palo Alto Networks company`}},
+	{Name: "internet-measurement", UserAgents: []string{`InternetMeasurement`}},
+	{Name: "leakix", UserAgents: []string{`l9explore`, `l9tcpid`}},
+	{Name: "netcraft", UserAgents: []string{`NetcraftSurveyAgent`}},
+	{Name: "onyphe", UserAgents: []string{`onyphe`}},
+}
+
+// compile validates the scanner and prepares its conditions
+func (scanner *Scanner) compile() error {
+	if scanner.Name == "" {
+		return errors.New("no name specified")
+	}
+	if len(scanner.Ranges) == 0 && len(scanner.UserAgents) == 0 {
+		return errors.New("no ranges or user agents specified")
+	}
+	for _, value := range scanner.Ranges {
+		if !strings.Contains(value, "/") {
+			if strings.Contains(value, ":") {
+				value += "/128"
+			} else {
+				value += "/32"
+			}
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid range %s", value)
+		}
+		scanner.networks = append(scanner.networks, network)
+	}
+	for _, value := range scanner.UserAgents {
+		userAgent, err := regexp.Compile("(?i)" + value)
+		if err != nil {
+			return errors.Wrapf(err, "invalid user agent %s", value)
+		}
+		scanner.userAgents = append(scanner.userAgents, userAgent)
+	}
+	return nil
+}
+
+// matches returns true if the interaction comes from the scanner
+func (scanner *Scanner) matches(ip net.IP, userAgent string) bool {
+	for _, network := range scanner.networks {
+		if ip != nil && network.Contains(ip) {
+			return true
+		}
+	}
+	for _, pattern := range scanner.userAgents {
+		if userAgent != "" && pattern.MatchString(userAgent) {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadScanners reads the scanners from a YAML file
+func LoadScanners(input string) ([]*Scanner, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read file")
+	}
+	var config struct {
+		Scanners []*Scanner `yaml:"scanners"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, errors.Wrap(err, "could not decode file")
+	}
+	for i, scanner := range config.Scanners {
+		if err := scanner.compile(); err != nil {
+			return nil, errors.Wrapf(err, "invalid scanner %d", i+1)
+		}
+	}
+	return config.Scanners, nil
+}
+
+// NoiseMetrics are the interactions filtered as background noise
+type NoiseMetrics struct {
+	// Action is the action of the filter on the noise (tag or drop)
+	Action string `json:"action"`
+	// Unregistered is the number of interactions without a registered correlation id
+	Unregistered uint64 `json:"unregistered"`
+	// Scanners is the number of interactions of each known scanner
+	Scanners map[string]uint64 `json:"scanners"`
+}
+
+// NoiseFilter is a middleware tagging or dropping the background noise hitting
+// the public instances: the interactions without a registered correlation id,
+// and the ones of known benign internet scanners.
+type NoiseFilter struct {
+	sync.Mutex
+	options      *Options
+	action       string
+	defaults     []*Scanner
+	scanners     []*Scanner
+	unregistered uint64
+	counters     map[string]uint64
+}
+
+// NewNoiseFilter returns a noise filter with the action (tag or drop), recognizing
+// the default scanners.
+func NewNoiseFilter(options *Options, action string) (*NoiseFilter, error) {
+	if action != RuleActionTag && action != RuleActionDrop {
+		return nil, errors.Errorf("noise filter action must be %s or %s", RuleActionTag, RuleActionDrop)
+	}
+	defaults := make([]*Scanner, 0, len(defaultScanners))
+	for _, defaultScanner := range defaultScanners {
+		scanner := *defaultScanner
+		if err := scanner.compile(); err != nil {
+			return nil, errors.Wrapf(err, "invalid default scanner %s", scanner.Name)
+		}
+		defaults = append(defaults, &scanner)
+	}
+	return &NoiseFilter{options: options, action: action, defaults: defaults, scanners: defaults, counters: make(map[string]uint64)}, nil
+}
+
+// ReloadScanners loads again the scanners of input in addition to the default
+// ones, keeping the current ones if it can't be read.
+func (f *NoiseFilter) ReloadScanners(input string) error {
+	scanners := f.defaults
+	if input != "" {
+		loaded, err := LoadScanners(input)
+		if err != nil {
+			return err
+		}
+		scanners = append(append([]*Scanner{}, f.defaults...), loaded...)
+	}
+	f.Lock()
+	f.scanners = scanners
+	f.Unlock()
+	return nil
+}
+
+// Filter is the InteractionMiddleware of the filter
+func (f *NoiseFilter) Filter(interaction *Interaction) bool {
+	unregistered := !f.registered(interaction.UniqueID)
+	scanner := f.scanner(interaction)
+	if !unregistered && scanner == "" {
+		return true
+	}
+
+	f.Lock()
+	if unregistered {
+		f.unregistered++
+	}
+	if scanner != "" {
+		f.counters[scanner]++
+	}
+	f.Unlock()
+
+	if f.action == RuleActionDrop {
+		return false
+	}
+	interaction.Tags = appendTags(interaction.Tags, noiseTag)
+	if unregistered {
+		interaction.Tags = appendTags(interaction.Tags, noiseUnregisteredTag)
+	}
+	if scanner != "" {
+		interaction.Tags = appendTags(interaction.Tags, noiseScannerTagPrefix+scanner)
+	}
+	return true
+}
+
+// registered returns true if the unique id has the correlation id of a
+// registered session, or is a root-tld interaction
+func (f *NoiseFilter) registered(uniqueID string) bool {
+	uniqueID = strings.ToLower(uniqueID)
+	if f.options.RootTLD {
+		for _, domain := range f.options.Domains {
+			if stringsutil.HasSuffixI(uniqueID, domain) {
+				return true
+			}
+		}
+	}
+	if f.options.Storage == nil {
+		return true
+	}
+	if f.options.CorrelationIdLength <= 0 || len(uniqueID) < f.options.CorrelationIdLength {
+		return false
+	}
+	_, err := f.options.Storage.GetCacheItem(uniqueID[:f.options.CorrelationIdLength])
+	return err == nil
+}
+
+// scanner returns the name of the known scanner of the interaction, if any
+func (f *NoiseFilter) scanner(interaction *Interaction) string {
+	host := interaction.RemoteAddress
+	if value, _, err := net.SplitHostPort(host); err == nil {
+		host = value
+	}
+	ip := net.ParseIP(host)
+	var userAgent string
+	if interaction.HTTPRequest != nil {
+		userAgent = interaction.HTTPRequest.Headers.Get("User-Agent")
+	}
+
+	f.Lock()
+	scanners := f.scanners
+	f.Unlock()
+
+	for _, scanner := range scanners {
+		if scanner.matches(ip, userAgent) {
+			return scanner.Name
+		}
+	}
+	return ""
+}
+
+// Metrics returns the interactions filtered as noise, or nil without filter
+func (f *NoiseFilter) Metrics() *NoiseMetrics {
+	if f == nil {
+		return nil
+	}
+	f.Lock()
+	defer f.Unlock()
+
+	metrics := &NoiseMetrics{Action: f.action, Unregistered: f.unregistered, Scanners: make(map[string]uint64, len(f.counters))}
+	for name, count := range f.counters {
+		metrics.Scanners[name] = count
+	}
+	return metrics
+}
@@ -0,0 +1,48 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNoiseFilter(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	options := &Options{CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Storage: newTestStorage(t, correlationID, "secret")}
+
+	_, err := NewNoiseFilter(options, "mirror")
+	require.NotNil(t, err, "could create filter with invalid action")
+
+	filter, err := NewNoiseFilter(options, RuleActionTag)
+	require.Nil(t, err, "could not create filter")
+
+	path := filepath.Join(t.TempDir(), "scanners.yaml")
+	require.Nil(t, os.WriteFile(path, []byte("scanners:\n  - name: acme-asm\n    ranges: [198.51.100.0/24]\n"), 0600), "could not write scanners")
+	require.Nil(t, filter.ReloadScanners(path), "could not load scanners")
+
+	registered := &Interaction{Protocol: "dns", UniqueID: correlationID + "abcdefghijklm", RemoteAddress: "192.0.2.1"}
+	require.True(t, filter.Filter(registered), "could not keep registered interaction")
+	require.Empty(t, registered.Tags, "could tag registered interaction")
+
+	unregistered := &Interaction{Protocol: "dns", UniqueID: "c8rf4e8xm4c8rf4e8xm5abcdefghijklm", RemoteAddress: "192.0.2.1"}
+	require.True(t, filter.Filter(unregistered), "could drop interaction with tag action")
+	require.Equal(t, []string{noiseTag, noiseUnregisteredTag}, unregistered.Tags, "could not tag unregistered interaction")
+
+	scanner := &Interaction{Protocol: "http", UniqueID: registered.UniqueID, RemoteAddress: "192.0.2.1", HTTPRequest: &HTTPRequest{Headers: http.Header{"User-Agent": {"Mozilla/5.0 zgrab/0.x"}}}}
+	require.True(t, filter.Filter(scanner), "could drop interaction with tag action")
+	require.Equal(t, []string{noiseTag, "scanner:zgrab"}, scanner.Tags, "could not tag scanner interaction")
+
+	filter.action = RuleActionDrop
+	require.False(t, filter.Filter(&Interaction{Protocol: "dns", UniqueID: registered.UniqueID, RemoteAddress: "198.51.100.7"}), "could not drop scanner range interaction")
+
+	metrics := filter.Metrics()
+	require.Equal(t, uint64(1), metrics.Unregistered, "could not count unregistered interactions")
+	require.Equal(t, map[string]uint64{"zgrab": 1, "acme-asm": 1}, metrics.Scanners, "could not count scanner interactions")
+	require.Nil(t, (*NoiseFilter)(nil).Metrics(), "could get metrics without filter")
+
+	require.Nil(t, os.WriteFile(path, []byte("scanners:\n  - name: invalid\n"), 0600), "could not write scanners")
+	require.NotNil(t, filter.ReloadScanners(path), "could load invalid scanner")
+}
@@ -0,0 +1,484 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/stringsutil"
+	"go.uber.org/multierr"
+)
+
+// ProtocolHandler is a protocol capture module of the server. The built-in
+// listeners are protocol handlers, and custom ones can be compiled in by
+// registering them with RegisterProtocol.
+type ProtocolHandler interface {
+	// Name returns the name of the protocol handler
+	Name() string
+	// Start starts the listeners of the handler in the background, reporting
+	// the status of each listener on status when it starts or stops.
+	Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error
+	// Stop stops the listeners, waiting for the in-flight interactions until ctx is done.
+	Stop(ctx context.Context) error
+}
+
+// ServiceStatus is the status of a listener of a protocol handler
+type ServiceStatus struct {
+	// Service is the name of the service, e.g. HTTPS
+	Service string
+	// Network is the network of the listener, TCP or UDP
+	Network string
+	// Port is the port of the listener
+	Port int
+	// Up is true if the listener is serving
+	Up bool
+	// Fatal is true if the server can't run without the listener
+	Fatal bool
+}
+
+// DNSReloader is implemented by the protocol handlers serving the dns zone,
+// which reload the custom records and the zone without restarting.
+type DNSReloader interface {
+	ReloadCustomRecords(input string) error
+	ReloadZone(input string) error
+}
+
+// ProtocolFactory creates a protocol handler with the options of the server
+type ProtocolFactory func(options *Options) (ProtocolHandler, error)
+
+var (
+	protocolsMutex sync.RWMutex
+	protocols      = make(map[string]ProtocolFactory)
+)
+
+// Names of the built-in protocol handlers
+const (
+	ProtocolDNS       = "dns"
+	ProtocolHTTP      = "http"
+	ProtocolSMTP      = "smtp"
+	ProtocolLDAP      = "ldap"
+	ProtocolFTP       = "ftp"
+	ProtocolSMB       = "smb"
+	ProtocolResponder = "responder"
+)
+
+// DefaultProtocols are the protocol handlers started unless disabled
+var DefaultProtocols = []string{ProtocolDNS, ProtocolHTTP, ProtocolSMTP, ProtocolLDAP}
+
+// protocolServices are the services of the built-in protocol handlers with
+// several listeners, which can be disabled individually. The other handlers
+// have a single service named after them.
+var protocolServices = map[string][]string{
+	ProtocolHTTP: {"http", "https"},
+	ProtocolSMTP: {"smtp", "smtps", "smtp-autotls"},
+}
+
+func init() {
+	RegisterProtocol(ProtocolDNS, newDNSProtocol)
+	RegisterProtocol(ProtocolHTTP, newHTTPProtocol)
+	RegisterProtocol(ProtocolSMTP, newSMTPProtocol)
+	RegisterProtocol(ProtocolLDAP, newLDAPProtocol)
+	RegisterProtocol(ProtocolFTP, newFTPProtocol)
+	RegisterProtocol(ProtocolSMB, newSMBProtocol)
+	RegisterProtocol(ProtocolResponder, newResponderProtocol)
+}
+
+// RegisterProtocol registers a protocol handler factory by name, usually from
+// the init function of the package of the handler. It panics if the name is
+// already registered.
+func RegisterProtocol(name string, factory ProtocolFactory) {
+	protocolsMutex.Lock()
+	defer protocolsMutex.Unlock()
+
+	name = strings.ToLower(name)
+	if _, ok := protocols[name]; ok {
+		panic("protocol " + name + " is already registered")
+	}
+	protocols[name] = factory
+}
+
+// RegisteredProtocols returns the sorted names of the registered protocol handlers
+func RegisteredProtocols() []string {
+	protocolsMutex.RLock()
+	defer protocolsMutex.RUnlock()
+
+	names := make([]string, 0, len(protocols))
+	for name := range protocols {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Services returns the sorted names of the services which can be disabled
+func Services() []string {
+	var services []string
+	for _, name := range RegisteredProtocols() {
+		if protocolServices, ok := protocolServices[name]; ok {
+			services = append(services, protocolServices...)
+		} else {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// ValidateServices returns an error if a service is unknown
+func ValidateServices(services []string) error {
+	known := Services()
+	for _, service := range services {
+		found := false
+		for _, name := range known {
+			if strings.EqualFold(service, name) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return errors.Errorf("unknown service %s (%s)", service, strings.Join(known, ","))
+		}
+	}
+	return nil
+}
+
+// protocolEnabled returns true unless all the services of a protocol are disabled
+func (options *Options) protocolEnabled(name string) bool {
+	services, ok := protocolServices[name]
+	if !ok {
+		services = []string{name}
+	}
+	for _, service := range services {
+		if options.serviceEnabled(service) {
+			return true
+		}
+	}
+	return false
+}
+
+// NewProtocolHandlers creates the handlers of the protocols enabled in options,
+// in their order, skipping the ones whose services are all disabled.
+func NewProtocolHandlers(options *Options) ([]ProtocolHandler, error) {
+	protocolsMutex.RLock()
+	defer protocolsMutex.RUnlock()
+
+	var handlers []ProtocolHandler
+	seen := make(map[string]struct{})
+	for _, name := range options.Protocols {
+		name = strings.ToLower(name)
+		if _, ok := seen[name]; ok {
+			continue
+		}
+		seen[name] = struct{}{}
+
+		factory, ok := protocols[name]
+		if !ok {
+			return nil, errors.Errorf("unknown protocol %s", name)
+		}
+		if !options.protocolEnabled(name) {
+			continue
+		}
+		handler, err := factory(options)
+		if err != nil {
+			return nil, errors.Wrapf(err, "could not create %s protocol", name)
+		}
+		handlers = append(handlers, handler)
+	}
+	return handlers, nil
+}
+
+// RecordInteraction stores an interaction captured by a protocol handler for
+// each unique id found in data, like the domains or payloads of the request,
+// and exports it. The interaction is stored for the token if there is none.
+func (options *Options) RecordInteraction(interaction *Interaction, data string) {
+	if interaction.Timestamp.IsZero() {
+		interaction.Timestamp = time.Now()
+	}
+	ids := options.findUniqueIDs(data)
+	if len(ids) == 0 {
+		if options.Token == "" {
+			return
+		}
+		options.storeInteraction(interaction, "", true)
+		return
+	}
+	for _, id := range ids {
+		stored := *interaction
+		stored.UniqueID = id[0]
+		stored.FullId = id[1]
+		options.storeInteraction(&stored, strings.ToLower(id[0][:options.CorrelationIdLength]), false)
+	}
+}
+
+// storeInteraction encodes and stores an interaction for a correlation-id, or
+// for the token if withToken is set.
+func (options *Options) storeInteraction(interaction *Interaction, correlationID string, withToken bool) {
+	buffer := &bytes.Buffer{}
+	if err := options.encodeInteraction(buffer, interaction); err != nil {
+		if err != errInteractionDropped {
+			gologger.Warning().Msgf("Could not encode %s interaction: %s\n", interaction.Protocol, err)
+		}
+		return
+	}
+	gologger.Debug().Msgf("%s Interaction: \n%s\n", strings.ToUpper(interaction.Protocol), buffer.String())
+	var err error
+	if withToken {
+		err = options.Storage.AddInteractionWithId(options.Token, buffer.Bytes())
+	} else {
+		err = options.Storage.AddInteraction(correlationID, buffer.Bytes())
+	}
+	if err != nil {
+		gologger.Warning().Msgf("Could not store %s interaction: %s\n", interaction.Protocol, err)
+	}
+	options.exportInteraction(interaction)
+}
+
+// findUniqueIDs returns the unique ids found in data with their full ids, which
+// are the labels of the domains up to the unique id.
+func (options *Options) findUniqueIDs(data string) [][2]string {
+	var ids [][2]string
+	seen := make(map[string]struct{})
+	domains := strings.FieldsFunc(data, func(r rune) bool {
+		return r != '.' && r != '-' && !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, domain := range domains {
+		labels := strings.Split(domain, ".")
+		for i, label := range labels {
+			for chunk := range stringsutil.SlideWithLength(label, options.GetIdLength()) {
+				if !options.isCorrelationID(chunk) {
+					continue
+				}
+				if _, ok := seen[chunk]; ok {
+					continue
+				}
+				seen[chunk] = struct{}{}
+				ids = append(ids, [2]string{chunk, strings.Join(labels[:i+1], ".")})
+			}
+		}
+	}
+	return ids
+}
+
+// forwardStatus reports the statuses of a listener of a built-in server on status
+func forwardStatus(alive <-chan bool, status chan<- ServiceStatus, service, network string, port int, fatal bool) {
+	for up := range alive {
+		status <- ServiceStatus{Service: service, Network: network, Port: port, Up: up, Fatal: fatal}
+	}
+}
+
+// dnsProtocol serves dns over udp and tcp
+type dnsProtocol struct {
+	options *Options
+	udp     *DNSServer
+	tcp     *DNSServer
+}
+
+func newDNSProtocol(options *Options) (ProtocolHandler, error) {
+	return &dnsProtocol{options: options, udp: NewDNSServer("udp", options), tcp: NewDNSServer("tcp", options)}, nil
+}
+
+func (p *dnsProtocol) Name() string { return ProtocolDNS }
+
+func (p *dnsProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	udpAlive := make(chan bool, 1)
+	tcpAlive := make(chan bool, 1)
+	go forwardStatus(udpAlive, status, "DNS", "UDP", p.options.DnsPort, true)
+	go forwardStatus(tcpAlive, status, "DNS", "TCP", p.options.DnsPort, false)
+	go p.udp.ListenAndServe(udpAlive)
+	go p.tcp.ListenAndServe(tcpAlive)
+	return nil
+}
+
+func (p *dnsProtocol) Stop(ctx context.Context) error {
+	return multierr.Combine(p.udp.Shutdown(ctx), p.tcp.Shutdown(ctx))
+}
+
+func (p *dnsProtocol) ReloadCustomRecords(input string) error {
+	return multierr.Combine(p.udp.ReloadCustomRecords(input), p.tcp.ReloadCustomRecords(input))
+}
+
+func (p *dnsProtocol) ReloadZone(input string) error {
+	return multierr.Combine(p.udp.ReloadZone(input), p.tcp.ReloadZone(input))
+}
+
+// httpProtocol serves http and https
+type httpProtocol struct {
+	options *Options
+	server  *HTTPServer
+}
+
+func newHTTPProtocol(options *Options) (ProtocolHandler, error) {
+	httpServer, err := NewHTTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &httpProtocol{options: options, server: httpServer}, nil
+}
+
+func (p *httpProtocol) Name() string { return ProtocolHTTP }
+
+func (p *httpProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	httpAlive := make(chan bool)
+	httpsAlive := make(chan bool)
+	// the api is served over https only if http is disabled
+	httpEnabled := p.options.serviceEnabled("http")
+	go forwardStatus(httpAlive, status, "HTTP", "TCP", p.options.HttpPort, true)
+	go forwardStatus(httpsAlive, status, "HTTPS", "TCP", p.options.HttpsPort, !httpEnabled)
+	go p.server.ListenAndServe(tlsConfig, httpAlive, httpsAlive)
+	return nil
+}
+
+func (p *httpProtocol) Stop(ctx context.Context) error {
+	return p.server.Shutdown(ctx)
+}
+
+// smtpProtocol serves smtp and smtps
+type smtpProtocol struct {
+	options *Options
+	server  *SMTPServer
+}
+
+func newSMTPProtocol(options *Options) (ProtocolHandler, error) {
+	smtpServer, err := NewSMTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &smtpProtocol{options: options, server: smtpServer}, nil
+}
+
+func (p *smtpProtocol) Name() string { return ProtocolSMTP }
+
+func (p *smtpProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	smtpAlive := make(chan bool)
+	smtpsAlive := make(chan bool)
+	smtpPort := p.options.SmtpPort
+	if !p.options.serviceEnabled("smtp") {
+		smtpPort = p.options.SmtpsPort
+	}
+	go forwardStatus(smtpAlive, status, "SMTP", "TCP", smtpPort, false)
+	go forwardStatus(smtpsAlive, status, "SMTPS", "TCP", p.options.SmtpsPort, false)
+	go p.server.ListenAndServe(tlsConfig, smtpAlive, smtpsAlive)
+	return nil
+}
+
+func (p *smtpProtocol) Stop(ctx context.Context) error {
+	return p.server.Shutdown(ctx)
+}
+
+// ldapProtocol serves ldap
+type ldapProtocol struct {
+	options *Options
+	server  *LDAPServer
+}
+
+func newLDAPProtocol(options *Options) (ProtocolHandler, error) {
+	ldapServer, err := NewLDAPServer(options, options.LdapWithFullLogger)
+	if err != nil {
+		return nil, err
+	}
+	return &ldapProtocol{options: options, server: ldapServer}, nil
+}
+
+func (p *ldapProtocol) Name() string { return ProtocolLDAP }
+
+func (p *ldapProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	ldapAlive := make(chan bool)
+	go forwardStatus(ldapAlive, status, "LDAP", "TCP", p.options.LdapPort, false)
+	go p.server.ListenAndServe(tlsConfig, ldapAlive)
+	return nil
+}
+
+func (p *ldapProtocol) Stop(ctx context.Context) error {
+	return p.server.Close()
+}
+
+// ftpProtocol serves ftp
+type ftpProtocol struct {
+	options *Options
+	server  *FTPServer
+}
+
+func newFTPProtocol(options *Options) (ProtocolHandler, error) {
+	ftpServer, err := NewFTPServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &ftpProtocol{options: options, server: ftpServer}, nil
+}
+
+func (p *ftpProtocol) Name() string { return ProtocolFTP }
+
+func (p *ftpProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	ftpAlive := make(chan bool)
+	go forwardStatus(ftpAlive, status, "FTP", "TCP", p.options.FtpPort, false)
+	go p.server.ListenAndServe(tlsConfig, ftpAlive)
+	return nil
+}
+
+func (p *ftpProtocol) Stop(ctx context.Context) error {
+	p.server.Close()
+	return nil
+}
+
+// smbProtocol runs the smb agent
+type smbProtocol struct {
+	options *Options
+	server  *SMBServer
+}
+
+func newSMBProtocol(options *Options) (ProtocolHandler, error) {
+	smbServer, err := NewSMBServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &smbProtocol{options: options, server: smbServer}, nil
+}
+
+func (p *smbProtocol) Name() string { return ProtocolSMB }
+
+func (p *smbProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	smbAlive := make(chan bool)
+	go forwardStatus(smbAlive, status, "SMB", "TCP", p.options.SmbPort, false)
+	go p.server.ListenAndServe(smbAlive) //nolint
+	return nil
+}
+
+func (p *smbProtocol) Stop(ctx context.Context) error {
+	p.server.Close()
+	return nil
+}
+
+// responderProtocol runs the responder agent
+type responderProtocol struct {
+	options *Options
+	server  *ResponderServer
+}
+
+func newResponderProtocol(options *Options) (ProtocolHandler, error) {
+	responderServer, err := NewResponderServer(options)
+	if err != nil {
+		return nil, err
+	}
+	return &responderProtocol{options: options, server: responderServer}, nil
+}
+
+func (p *responderProtocol) Name() string { return ProtocolResponder }
+
+func (p *responderProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	responderAlive := make(chan bool)
+	go forwardStatus(responderAlive, status, "Responder", "TCP", 445, false)
+	go p.server.ListenAndServe(responderAlive) //nolint
+	return nil
+}
+
+func (p *responderProtocol) Stop(ctx context.Context) error {
+	p.server.Close()
+	return nil
+}
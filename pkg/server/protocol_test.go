@@ -0,0 +1,78 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testProtocol struct {
+	options *Options
+	stopped bool
+}
+
+func (p *testProtocol) Name() string { return "test" }
+
+func (p *testProtocol) Start(tlsConfig *tls.Config, status chan<- ServiceStatus) error {
+	status <- ServiceStatus{Service: "TEST", Network: "TCP", Port: 7777, Up: true}
+	return nil
+}
+
+func (p *testProtocol) Stop(ctx context.Context) error {
+	p.stopped = true
+	return nil
+}
+
+func TestProtocolRegistry(t *testing.T) {
+	RegisterProtocol("test", func(options *Options) (ProtocolHandler, error) {
+		return &testProtocol{options: options}, nil
+	})
+	require.Contains(t, RegisteredProtocols(), "test", "could not register protocol")
+	require.Panics(t, func() { RegisterProtocol("TEST", nil) }, "could register protocol twice")
+
+	handlers, err := NewProtocolHandlers(&Options{Protocols: []string{"test", "Test"}})
+	require.Nil(t, err, "could not create protocol handlers")
+	require.Len(t, handlers, 1, "could not deduplicate protocols")
+
+	status := make(chan ServiceStatus, 1)
+	require.Nil(t, handlers[0].Start(nil, status), "could not start protocol")
+	require.Equal(t, ServiceStatus{Service: "TEST", Network: "TCP", Port: 7777, Up: true}, <-status, "could not get status")
+	require.Nil(t, handlers[0].Stop(context.Background()), "could not stop protocol")
+	require.True(t, handlers[0].(*testProtocol).stopped, "could not stop protocol")
+
+	_, err = NewProtocolHandlers(&Options{Protocols: []string{"unknown"}})
+	require.NotNil(t, err, "could create unknown protocol")
+}
+
+func TestDisabledServices(t *testing.T) {
+	require.Subset(t, Services(), []string{"dns", "http", "https", "smtp", "smtps", "smtp-autotls", "ldap"}, "could not get services")
+	require.Nil(t, ValidateServices([]string{"SMTP", "https"}), "could not validate services")
+	require.NotNil(t, ValidateServices([]string{"gopher"}), "could validate unknown service")
+
+	options := &Options{Protocols: []string{ProtocolHTTP, ProtocolSMTP, ProtocolLDAP}, DisabledServices: []string{"http", "smtp", "smtps", "smtp-autotls", "ldap"}}
+	require.True(t, options.protocolEnabled(ProtocolHTTP), "could not keep protocol with an enabled service")
+	require.False(t, options.protocolEnabled(ProtocolSMTP), "could enable protocol with all services disabled")
+	require.False(t, options.protocolEnabled(ProtocolLDAP), "could enable disabled protocol")
+
+	ports := options.publicPorts()
+	require.NotContains(t, ports, "http", "could advertise disabled service")
+	require.Contains(t, ports, "https", "could not advertise enabled service")
+}
+
+func TestRecordInteraction(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	options := &Options{CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Storage: store}
+
+	options.RecordInteraction(&Interaction{Protocol: "test", RawRequest: "HELLO"}, "GET x."+correlationID+"abcdefghijklm.oast.pro\r\n")
+	interactions, _, err := store.GetInteractions(correlationID, "secret")
+	require.Nil(t, err, "could not get interactions")
+	require.Len(t, interactions, 1, "could not record interaction")
+
+	options.RecordInteraction(&Interaction{Protocol: "test"}, "no correlation id")
+	interactions, _, err = store.GetInteractions(correlationID, "secret")
+	require.Nil(t, err, "could not get interactions")
+	require.Empty(t, interactions, "could record interaction without correlation id")
+}
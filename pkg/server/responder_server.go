@@ -41,9 +41,10 @@ func NewResponderServer(options *Options) (*ResponderServer, error) {
 }
 
 // ListenAndServe listens on various responder ports
-func (h *ResponderServer) ListenAndServe(responderAlive chan bool) error {
+func (h *ResponderServer) ListenAndServe(responderAlive chan bool) (err error) {
 	responderAlive <- true
 	defer func() {
+		h.options.Health.SetError("Responder", "TCP", err)
 		responderAlive <- false
 	}()
 	tmpFolder, err := ioutil.TempDir("", "")
@@ -97,13 +98,16 @@ func (h *ResponderServer) ListenAndServe(responderAlive chan bool) error {
 						Timestamp:  time.Now(),
 					}
 					buffer := &bytes.Buffer{}
-					if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-						gologger.Warning().Msgf("Could not encode responder interaction: %s\n", err)
+					if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+						if err != errInteractionDropped {
+							gologger.Warning().Msgf("Could not encode responder interaction: %s\n", err)
+						}
 					} else {
 						gologger.Debug().Msgf("Responder Interaction: \n%s\n", buffer.String())
 						if err := h.options.Storage.AddInteractionWithId(h.options.Token, buffer.Bytes()); err != nil {
 							gologger.Warning().Msgf("Could not store dns interaction: %s\n", err)
 						}
+						h.options.exportInteraction(interaction)
 					}
 				}
 			}
@@ -114,7 +118,9 @@ func (h *ResponderServer) ListenAndServe(responderAlive chan bool) error {
 }
 
 func (h *ResponderServer) Close() {
-	_ = h.cmd.Process.Kill()
+	if h.cmd != nil && h.cmd.Process != nil {
+		_ = h.cmd.Process.Kill()
+	}
 	if fileutil.FolderExists(h.tmpFolder) {
 		os.RemoveAll(h.tmpFolder)
 	}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/projectdiscovery/gologger"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+const (
+	// responseDelayPrefix is the prefix of the subdomain label requesting a delayed response
+	responseDelayPrefix = "delay-"
+	// maxResponseDelay is the maximum delay of the responses
+	maxResponseDelay = 30 * time.Second
+)
+
+// ResponseDelayRequest is a request for setting the response delay of a client.
+type ResponseDelayRequest = types.ResponseDelayRequest
+
+// responseDelay returns the delay of the responses for a domain. A delay-<seconds>
+// label takes precedence over the delay set by the client owning the domain.
+func (options *Options) responseDelay(domain string) time.Duration {
+	for _, part := range strings.Split(domain, ".") {
+		if !strings.HasPrefix(strings.ToLower(part), responseDelayPrefix) {
+			continue
+		}
+		if seconds, err := strconv.Atoi(part[len(responseDelayPrefix):]); err == nil && seconds > 0 {
+			return responseDelayFromSeconds(seconds)
+		}
+	}
+	if correlationID := options.correlationIDFromDomain(domain); correlationID != "" {
+		return options.Storage.GetResponseDelay(correlationID)
+	}
+	return 0
+}
+
+// responseDelayFromSeconds returns a delay of seconds capped to maxResponseDelay
+func responseDelayFromSeconds(seconds int) time.Duration {
+	if seconds > int(maxResponseDelay/time.Second) {
+		return maxResponseDelay
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// responseDelayHandler is a handler for setting the response delay of a client
+func (h *HTTPServer) responseDelayHandler(w http.ResponseWriter, req *http.Request) {
+	r := &ResponseDelayRequest{}
+	if err := jsoniter.NewDecoder(req.Body).Decode(r); err != nil {
+		gologger.Warning().Msgf("Could not decode json body: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not decode json body: %s", err), http.StatusBadRequest)
+		return
+	}
+	if r.Delay < 0 {
+		jsonError(w, "could not set response delay: negative delay", http.StatusBadRequest)
+		return
+	}
+	delay := responseDelayFromSeconds(r.Delay)
+	if err := h.options.Storage.SetResponseDelay(r.CorrelationID, r.SecretKey, delay); err != nil {
+		gologger.Warning().Msgf("Could not set response delay for %s: %s\n", r.CorrelationID, err)
+		jsonError(w, fmt.Sprintf("could not set response delay: %s", err), http.StatusBadRequest)
+		return
+	}
+	jsonMsg(w, "response delay set", http.StatusOK)
+	gologger.Debug().Msgf("Set response delay %s for correlationID %s\n", delay, r.CorrelationID)
+}
@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResponseDelay(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	options := &Options{CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Storage: store}
+	domain := correlationID + "abcdefghijklm.oast.pro"
+
+	require.Equal(t, time.Duration(0), options.responseDelay(domain), "could delay response without delay")
+	require.Equal(t, 5*time.Second, options.responseDelay("delay-5."+domain), "could not get subdomain delay")
+	require.Equal(t, maxResponseDelay, options.responseDelay("delay-99999999999."+domain), "could not cap subdomain delay")
+
+	require.Nil(t, store.SetResponseDelay(correlationID, "secret", 2*time.Second), "could not set response delay")
+	require.Equal(t, 2*time.Second, options.responseDelay(domain), "could not get client delay")
+	require.Equal(t, 1*time.Second, options.responseDelay("delay-1."+domain), "could not prefer subdomain delay")
+}
+
+func TestResponseDelayCancelled(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	server := &HTTPServer{options: &Options{Domains: []string{"oast.pro"}, CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Storage: store, Stats: &Metrics{}}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	req := httptest.NewRequest("GET", "http://delay-30.oast.pro/", nil).WithContext(ctx)
+	now := time.Now()
+	server.defaultHandler(httptest.NewRecorder(), req)
+	require.Less(t, time.Since(now), 5*time.Second, "could not stop waiting for a disconnected client")
+}
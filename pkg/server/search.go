@@ -0,0 +1,71 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+)
+
+// searchHandler is a handler for searching the interactions history
+func (h *HTTPServer) searchHandler(w http.ResponseWriter, req *http.Request) {
+	query, err := parseSearchQuery(req.URL.Query())
+	if err != nil {
+		jsonError(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	// tenants only search their own interactions
+	if tenant := h.tenant(req); tenant != nil {
+		query.Tenant = tenant.Name
+	}
+	result, err := h.options.History.Search(query)
+	if err != nil {
+		gologger.Warning().Msgf("Could not search history: %s\n", err)
+		jsonError(w, fmt.Sprintf("could not search history: %s", err), http.StatusBadRequest)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = jsoniter.NewEncoder(w).Encode(result)
+}
+
+// parseSearchQuery returns the search query of the url parameters
+func parseSearchQuery(values url.Values) (*storage.SearchQuery, error) {
+	query := &storage.SearchQuery{
+		CorrelationID: values.Get("id"),
+		Protocol:      values.Get("protocol"),
+		Remote:        values.Get("remote"),
+		Text:          values.Get("q"),
+		Tenant:        values.Get("tenant"),
+		Cursor:        values.Get("cursor"),
+	}
+	if value := values.Get("limit"); value != "" {
+		limit, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, errors.Wrap(err, "invalid limit")
+		}
+		query.Limit = limit
+	}
+	var err error
+	if query.From, err = parseSearchTime(values.Get("from")); err != nil {
+		return nil, errors.Wrap(err, "invalid from")
+	}
+	if query.To, err = parseSearchTime(values.Get("to")); err != nil {
+		return nil, errors.Wrap(err, "invalid to")
+	}
+	return query, nil
+}
+
+// parseSearchTime parses a RFC3339 time, returning the zero time if empty
+func parseSearchTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
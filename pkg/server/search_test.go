@@ -0,0 +1,28 @@
+package server
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSearchQuery(t *testing.T) {
+	values := url.Values{}
+	values.Set("id", "abcd")
+	values.Set("protocol", "dns")
+	values.Set("from", "2022-01-02T15:04:05Z")
+	values.Set("limit", "10")
+
+	query, err := parseSearchQuery(values)
+	require.Nil(t, err, "could not parse search query")
+	require.Equal(t, "abcd", query.CorrelationID, "could not parse correlation id")
+	require.Equal(t, 10, query.Limit, "could not parse limit")
+	require.Equal(t, time.Date(2022, 1, 2, 15, 4, 5, 0, time.UTC), query.From, "could not parse from")
+	require.True(t, query.To.IsZero(), "could set empty to")
+
+	values.Set("to", "yesterday")
+	_, err = parseSearchQuery(values)
+	require.NotNil(t, err, "could parse invalid time")
+}
@@ -1,35 +1,23 @@
 package server
 
 import (
+	"io"
+	"net"
+	"strconv"
 	"strings"
 	"time"
 
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/projectdiscovery/gologger"
 	"github.com/projectdiscovery/interactsh/pkg/server/acme"
 	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"github.com/projectdiscovery/interactsh/pkg/types"
 	"github.com/projectdiscovery/stringsutil"
 )
 
 // Interaction is an interaction received to the server.
-type Interaction struct {
-	// Protocol for interaction, can contains HTTP/DNS/SMTP,etc.
-	Protocol string `json:"protocol"`
-	// UniqueID is the uniqueID for the subdomain receiving the interaction.
-	UniqueID string `json:"unique-id"`
-	// FullId is the full path for the subdomain receiving the interaction.
-	FullId string `json:"full-id"`
-	// QType is the question type for the interaction
-	QType string `json:"q-type,omitempty"`
-	// RawRequest is the raw request received by the interactsh server.
-	RawRequest string `json:"raw-request,omitempty"`
-	// RawResponse is the raw response sent by the interactsh server.
-	RawResponse string `json:"raw-response,omitempty"`
-	// SMTPFrom is the mail form field
-	SMTPFrom string `json:"smtp-from,omitempty"`
-	// RemoteAddress is the remote address for interaction
-	RemoteAddress string `json:"remote-address"`
-	// Timestamp is the timestamp for the interaction
-	Timestamp time.Time `json:"timestamp"`
-}
+type Interaction = types.Interaction
 
 // Options contains configuration options for the servers
 type Options struct {
@@ -37,6 +25,9 @@ type Options struct {
 	Domains []string
 	// IPAddress is the IP address of the current server.
 	IPAddress string
+	// IPv6Address is the IPv6 address of the current server, answered in the
+	// AAAA records of the interaction hosts
+	IPv6Address string
 	// ListenIP is the IP address to listen servers on
 	ListenIP string
 	// DomainPort is the port to listen DNS servers on
@@ -87,6 +78,8 @@ type Options struct {
 	PrivateKeyPath string
 	// CustomRecords is a file containing custom DNS records
 	CustomRecords string
+	// DNSZone is a file containing the SOA, name servers and static records of the zone
+	DNSZone string
 	// HTTP header containing origin IP
 	OriginIPHeader string
 	// Version is the version of interactsh server
@@ -102,6 +95,148 @@ type Options struct {
 
 	ACMEStore *acme.Provider
 	Stats     *Metrics
+	// SyslogExporter forwards interactions to a syslog collector if configured
+	SyslogExporter *SyslogExporter
+	// Dashboard keeps the recent interactions for the web dashboard if enabled
+	Dashboard *Dashboard
+	// Tenants are the tenants sharing the server if configured
+	Tenants *Tenants
+	// History keeps all the interactions on disk for the search api if enabled
+	History *storage.History
+	// Health tracks the status of the listeners for the health endpoint
+	Health *Health
+	// Listeners contains the sockets inherited from systemd socket activation
+	Listeners *InheritedListeners
+	// PublicPorts contains the public ports of the services behind port
+	// forwarding, by service name (dns, http, https, smtp, ...)
+	PublicPorts map[string]int
+	// APIKeys contains the scoped keys of the admin api
+	APIKeys *APIKeys
+	// Reload reloads the server configuration if supported
+	Reload func() error
+	// Protocols are the names of the registered protocol handlers to start
+	Protocols []string
+	// NonceEncodings are the nonce encodings accepted from the clients, all
+	// of them if empty (zbase32, hex, base36, custom)
+	NonceEncodings []string
+	// SMTPProfile controls the responses of the smtp listeners, the defaults if nil
+	SMTPProfile *SMTPProfile
+	// DisabledServices are the services whose listeners aren't started
+	// (dns, http, https, smtp, smtps, smtp-autotls, ldap, ...)
+	DisabledServices []string
+	// LdapWithFullLogger logs all the ldap interactions for the token
+	LdapWithFullLogger bool
+	// Middlewares are invoked for the captured interactions before they are stored
+	Middlewares *MiddlewareChain
+	// NoiseFilter tags or drops the background noise of the internet if enabled
+	NoiseFilter *NoiseFilter
+}
+
+// encodeInteraction runs the middlewares for an interaction, normalizes its timestamp
+// to UTC and writes its json encoding to w, the sequence number being assigned by
+// the storage. It returns errInteractionDropped if a middleware dropped it.
+func (options *Options) encodeInteraction(w io.Writer, interaction *Interaction) error {
+	if options.Middlewares != nil && !options.Middlewares.Run(interaction) {
+		return errInteractionDropped
+	}
+	if interaction.Timestamp.IsZero() {
+		interaction.Timestamp = time.Now()
+	}
+	interaction.Timestamp = interaction.Timestamp.UTC()
+	return jsoniter.NewEncoder(w).Encode(interaction)
+}
+
+// publicPorts returns the ports on which the services are reachable from outside,
+// which are the listening ports unless they are forwarded from other ports.
+func (options *Options) publicPorts() map[string]int {
+	ports := map[string]int{
+		"dns":          options.DnsPort,
+		"http":         options.HttpPort,
+		"https":        options.HttpsPort,
+		"smtp":         options.SmtpPort,
+		"smtps":        options.SmtpsPort,
+		"smtp-autotls": options.SmtpAutoTLSPort,
+		"ldap":         options.LdapPort,
+		"ftp":          options.FtpPort,
+		"smb":          options.SmbPort,
+	}
+	for service, port := range options.PublicPorts {
+		ports[service] = port
+	}
+	for _, service := range options.DisabledServices {
+		delete(ports, service)
+	}
+	return ports
+}
+
+// recordMetadata counts an interaction in the metadata of the session of its correlation id
+func (options *Options) recordMetadata(interaction *Interaction) {
+	if options.Storage == nil || options.CorrelationIdLength <= 0 || len(interaction.UniqueID) < options.CorrelationIdLength {
+		return
+	}
+	correlationID := strings.ToLower(interaction.UniqueID[:options.CorrelationIdLength])
+	_ = options.Storage.RecordMetadata(correlationID, interaction.Protocol, interaction.Timestamp, interactionTruncated(interaction))
+}
+
+// interactionTruncated returns true if some content of an interaction
+// exceeded the size captured by the server
+func interactionTruncated(interaction *Interaction) bool {
+	if interaction.HTTPRequest != nil && interaction.HTTPRequest.BodyTruncated {
+		return true
+	}
+	if message := interaction.SMTPMessage; message != nil {
+		for _, part := range message.TextParts {
+			if part.Truncated {
+				return true
+			}
+		}
+		for _, attachment := range message.Attachments {
+			if attachment.Content == "" && attachment.Size > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// serviceEnabled returns false if the service is disabled
+func (options *Options) serviceEnabled(service string) bool {
+	for _, disabled := range options.DisabledServices {
+		if strings.EqualFold(disabled, service) {
+			return false
+		}
+	}
+	return true
+}
+
+// exportInteraction counts the interaction in the metadata of its session and
+// forwards it to the configured exporters
+func (options *Options) exportInteraction(interaction *Interaction) {
+	options.recordMetadata(interaction)
+	interaction.Tenant = options.tenantOf(interaction.UniqueID)
+	if options.Dashboard != nil {
+		options.Dashboard.Add(interaction)
+	}
+	if options.History != nil {
+		if err := options.History.Add(interaction); err != nil {
+			gologger.Warning().Msgf("Could not add %s interaction to history: %s\n", interaction.Protocol, err)
+		}
+	}
+	if options.SyslogExporter != nil {
+		if err := options.SyslogExporter.Export(interaction); err != nil {
+			gologger.Warning().Msgf("Could not export %s interaction: %s\n", interaction.Protocol, err)
+		}
+	}
+}
+
+// listenAddress returns the address the listeners of port bind, which are all
+// the ipv4 and ipv6 addresses if the listen ip is unspecified.
+func (options *Options) listenAddress(port int) string {
+	host := options.ListenIP
+	if host == "0.0.0.0" || host == "::" {
+		host = ""
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
 }
 
 func (options *Options) GetIdLength() int {
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
+	jsoniter "github.com/json-iterator/go"
 	"github.com/projectdiscovery/interactsh/pkg/settings"
 	"github.com/stretchr/testify/require"
 )
@@ -12,3 +15,45 @@ func TestGetURLIDComponent(t *testing.T) {
 	random := options.getURLIDComponent("c6rj61aciaeutn2ae680cg5ugboyyyyyn.interactsh.com")
 	require.Equal(t, "c6rj61aciaeutn2ae680cg5ugboyyyyyn", random, "could not get correct component")
 }
+
+func TestEncodeInteraction(t *testing.T) {
+	options := &Options{}
+	location := time.FixedZone("UTC+2", 2*60*60)
+	timestamp := time.Date(2022, 1, 2, 3, 4, 5, 123456789, location)
+
+	buffer := &bytes.Buffer{}
+	err := options.encodeInteraction(buffer, &Interaction{Protocol: "dns", Timestamp: timestamp})
+	require.Nil(t, err, "could not encode interaction")
+
+	decoded := &Interaction{}
+	require.Nil(t, jsoniter.Unmarshal(buffer.Bytes(), decoded), "could not decode interaction")
+	require.True(t, decoded.Timestamp.Equal(timestamp), "could not keep nanosecond timestamp")
+	require.Contains(t, buffer.String(), `"timestamp":"2022-01-02T01:04:05.123456789Z"`, "could not encode utc timestamp")
+	require.NotContains(t, buffer.String(), `"sequence"`, "could assign sequence before storing")
+}
+
+func TestInteractionTruncated(t *testing.T) {
+	require.False(t, interactionTruncated(&Interaction{Protocol: "dns"}), "could detect truncation without content")
+	require.True(t, interactionTruncated(&Interaction{HTTPRequest: &HTTPRequest{HTTPBody: HTTPBody{BodyTruncated: true}}}), "could not detect truncated http body")
+	require.True(t, interactionTruncated(&Interaction{SMTPMessage: &SMTPMessage{TextParts: []SMTPTextPart{{Truncated: true}}}}), "could not detect truncated smtp part")
+	require.True(t, interactionTruncated(&Interaction{SMTPMessage: &SMTPMessage{Attachments: []SMTPAttachment{{Size: 10}}}}), "could not detect dropped smtp attachment")
+}
+
+func TestCheckNonceEncoding(t *testing.T) {
+	options := &Options{}
+	require.Nil(t, options.checkNonceEncoding(""), "could not accept default encoding")
+	require.Nil(t, options.checkNonceEncoding("custom:0123456789"), "could not accept custom encoding")
+	require.NotNil(t, options.checkNonceEncoding("custom:0-1"), "could accept invalid custom encoding")
+
+	options.NonceEncodings = []string{"zbase32", "hex"}
+	require.Nil(t, options.checkNonceEncoding("HEX"), "could not accept allowed encoding")
+	require.NotNil(t, options.checkNonceEncoding("base36"), "could accept disallowed encoding")
+	require.NotNil(t, options.checkNonceEncoding("custom:0123456789"), "could accept disallowed custom encoding")
+}
+
+func TestListenAddress(t *testing.T) {
+	require.Equal(t, ":53", (&Options{ListenIP: "0.0.0.0"}).listenAddress(53), "could not listen on all addresses")
+	require.Equal(t, ":53", (&Options{ListenIP: "::"}).listenAddress(53), "could not listen on all addresses")
+	require.Equal(t, "[2001:db8::1]:53", (&Options{ListenIP: "2001:db8::1"}).listenAddress(53), "could not listen on ipv6 address")
+	require.Equal(t, "192.0.2.1:53", (&Options{ListenIP: "192.0.2.1"}).listenAddress(53), "could not listen on ipv4 address")
+}
@@ -42,9 +42,10 @@ func NewSMBServer(options *Options) (*SMBServer, error) {
 }
 
 // ListenAndServe listens on smb port
-func (h *SMBServer) ListenAndServe(smbAlive chan bool) error {
+func (h *SMBServer) ListenAndServe(smbAlive chan bool) (err error) {
 	smbAlive <- true
 	defer func() {
+		h.options.Health.SetError("SMB", "TCP", err)
 		smbAlive <- false
 	}()
 	tmpFile, err := ioutil.TempFile("", "")
@@ -100,13 +101,16 @@ func (h *SMBServer) ListenAndServe(smbAlive chan bool) error {
 						Timestamp:  time.Now(),
 					}
 					buffer := &bytes.Buffer{}
-					if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-						gologger.Warning().Msgf("Could not encode smb interaction: %s\n", err)
+					if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+						if err != errInteractionDropped {
+							gologger.Warning().Msgf("Could not encode smb interaction: %s\n", err)
+						}
 					} else {
 						gologger.Debug().Msgf("SMB Interaction: \n%s\n", buffer.String())
 						if err := h.options.Storage.AddInteractionWithId(h.options.Token, buffer.Bytes()); err != nil {
 							gologger.Warning().Msgf("Could not store dns interaction: %s\n", err)
 						}
+						h.options.exportInteraction(interaction)
 					}
 				}
 			}
@@ -117,7 +121,9 @@ func (h *SMBServer) ListenAndServe(smbAlive chan bool) error {
 }
 
 func (h *SMBServer) Close() {
-	_ = h.cmd.Process.Kill()
+	if h.cmd != nil && h.cmd.Process != nil {
+		_ = h.cmd.Process.Kill()
+	}
 	if fileutil.FileExists(h.tmpFile) {
 		os.RemoveAll(h.tmpFile)
 	}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"net/textproto"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+const (
+	// maxMIMEParts is the maximum number of parts extracted from a message
+	maxMIMEParts = 32
+	// maxMIMEDepth is the maximum nesting of multipart bodies
+	maxMIMEDepth = 5
+	// maxMIMETextSize is the maximum size of the stored text parts
+	maxMIMETextSize = 64 * 1024
+	// maxMIMEAttachmentSize is the maximum size of the stored attachments
+	maxMIMEAttachmentSize = 1024 * 1024
+)
+
+// SMTPMessage contains the structured content of a smtp message
+type SMTPMessage = types.SMTPMessage
+
+// SMTPTextPart is a text part of a smtp message
+type SMTPTextPart = types.SMTPTextPart
+
+// SMTPAttachment is an attachment of a smtp message
+type SMTPAttachment = types.SMTPAttachment
+
+// parseSMTPMessage parses the data of a smtp message into its headers, text parts and attachments
+func parseSMTPMessage(data []byte) (*SMTPMessage, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if countHeaders(msg.Header) > maxSMTPHeaders {
+		return nil, errors.Errorf("message has more than %d headers", maxSMTPHeaders)
+	}
+	message := &SMTPMessage{Headers: msg.Header}
+	if subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject")); err == nil {
+		message.Subject = subject
+	} else {
+		message.Subject = msg.Header.Get("Subject")
+	}
+	parseSMTPPart(message, textproto.MIMEHeader(msg.Header), msg.Body, 0)
+	return message, nil
+}
+
+// parseSMTPPart extracts a part of a message, recursing into multipart bodies
+func parseSMTPPart(m *SMTPMessage, header textproto.MIMEHeader, body io.Reader, depth int) {
+	if len(m.TextParts)+len(m.Attachments) >= maxMIMEParts {
+		return
+	}
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType = "text/plain"
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMIMEDepth || params["boundary"] == "" {
+			return
+		}
+		reader := multipart.NewReader(body, params["boundary"])
+		for len(m.TextParts)+len(m.Attachments) < maxMIMEParts {
+			part, err := reader.NextPart()
+			if err != nil {
+				return
+			}
+			if countHeaders(part.Header) > maxSMTPHeaders {
+				continue
+			}
+			parseSMTPPart(m, part.Header, part, depth+1)
+		}
+		return
+	}
+
+	reader := decodeTransferEncoding(header.Get("Content-Transfer-Encoding"), body)
+	disposition, dispositionParams, _ := mime.ParseMediaType(header.Get("Content-Disposition"))
+	filename := dispositionParams["filename"]
+	if filename == "" {
+		filename = params["name"]
+	}
+
+	if strings.HasPrefix(mediaType, "text/") && disposition != "attachment" && filename == "" {
+		content, size := readLimited(reader, maxMIMETextSize)
+		m.TextParts = append(m.TextParts, SMTPTextPart{
+			ContentType: mediaType,
+			Content:     string(content),
+			Truncated:   size > len(content),
+		})
+		return
+	}
+
+	// the attachments are hashed whole but only kept up to maxMIMEAttachmentSize
+	hash := sha256.New()
+	content, size := readLimited(io.TeeReader(reader, hash), maxMIMEAttachmentSize)
+	attachment := SMTPAttachment{
+		Filename:    filename,
+		ContentType: mediaType,
+		Size:        size,
+		SHA256:      hex.EncodeToString(hash.Sum(nil)),
+	}
+	if size <= maxMIMEAttachmentSize {
+		attachment.Content = base64.StdEncoding.EncodeToString(content)
+	}
+	m.Attachments = append(m.Attachments, attachment)
+}
+
+// decodeTransferEncoding returns a reader decoding the content transfer encoding.
+// Quoted printable parts are already decoded by the multipart reader.
+func decodeTransferEncoding(encoding string, body io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, body)
+	case "quoted-printable":
+		return quotedprintable.NewReader(body)
+	}
+	return body
+}
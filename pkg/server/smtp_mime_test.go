@@ -0,0 +1,39 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSMTPMessage(t *testing.T) {
+	data := strings.ReplaceAll(`From: test@example.com
+Subject: =?UTF-8?B?aGVsbG8gd29ybGQ=?=
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="boundary"
+
+--boundary
+Content-Type: text/plain; charset=utf-8
+Content-Transfer-Encoding: quoted-printable
+
+hello =3D world
+--boundary
+Content-Type: application/octet-stream
+Content-Disposition: attachment; filename="secret.txt"
+Content-Transfer-Encoding: base64
+
+c2VjcmV0
+--boundary--
+`, "\n", "\r\n")
+
+	message, err := parseSMTPMessage([]byte(data))
+	require.Nil(t, err, "could not parse message")
+	require.Equal(t, "hello world", message.Subject, "could not decode subject")
+	require.Len(t, message.TextParts, 1, "could not get text parts")
+	require.Equal(t, "hello = world", message.TextParts[0].Content, "could not decode text part")
+	require.Len(t, message.Attachments, 1, "could not get attachments")
+	require.Equal(t, "secret.txt", message.Attachments[0].Filename, "could not get attachment filename")
+	require.Equal(t, 6, message.Attachments[0].Size, "could not get attachment size")
+	require.Equal(t, "2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b", message.Attachments[0].SHA256, "could not get attachment hash")
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/projectdiscovery/stringsutil"
+)
+
+// Responses to the recipients of the domains not served by the server
+const (
+	// SMTPRelayAccept accepts the recipients of any domain, like an open relay
+	SMTPRelayAccept = "accept"
+	// SMTPRelayReject rejects the recipients of the external domains
+	SMTPRelayReject = "reject"
+)
+
+const (
+	// defaultSMTPBanner is the text of the greeting after the hostname
+	defaultSMTPBanner = "interactsh"
+	// smtpCodeAccepted is the reply code of an accepted recipient
+	smtpCodeAccepted = 250
+	// smtpCodeRejected is the reply code of a rejected recipient
+	smtpCodeRejected = 550
+	// maxSMTPRecipients is the maximum number of recipients recorded for a message
+	maxSMTPRecipients = 100
+	// maxSMTPPendingSessions is the maximum number of connections whose recipients are recorded
+	maxSMTPPendingSessions = 10000
+)
+
+// SMTPSession contains the responses of the server during the smtp session of a message
+type SMTPSession = types.SMTPSession
+
+// SMTPRecipient is the response of the server to a recipient of a message
+type SMTPRecipient = types.SMTPRecipient
+
+// SMTPProfile controls the responses of the smtp listeners, so that the behavior
+// of the targets can be observed against different mail server personalities.
+type SMTPProfile struct {
+	// Banner is the text of the greeting after the hostname (default interactsh)
+	Banner string
+	// Relay is the response to the recipients of external domains (accept or reject)
+	Relay string
+	// MaxSize is the maximum size of the messages (default 10MB)
+	MaxSize int
+}
+
+// smtpProfile returns the smtp profile of the server with the defaults applied
+func (options *Options) smtpProfile() SMTPProfile {
+	var profile SMTPProfile
+	if options.SMTPProfile != nil {
+		profile = *options.SMTPProfile
+	}
+	if profile.Banner == "" {
+		profile.Banner = defaultSMTPBanner
+	}
+	if profile.Relay == "" {
+		profile.Relay = SMTPRelayAccept
+	}
+	if profile.MaxSize <= 0 {
+		profile.MaxSize = maxSMTPMessageSize
+	}
+	return profile
+}
+
+// ValidateSMTPRelay returns an error if relay isn't a supported relay policy
+func ValidateSMTPRelay(relay string) error {
+	if relay != SMTPRelayAccept && relay != SMTPRelayReject {
+		return fmt.Errorf("smtp relay must be %s or %s", SMTPRelayAccept, SMTPRelayReject)
+	}
+	return nil
+}
+
+// isLocalRecipient returns true if the address is in one of the domains of the server
+func (options *Options) isLocalRecipient(address string) bool {
+	index := strings.LastIndex(address, "@")
+	if index == -1 {
+		return false
+	}
+	domain := strings.TrimSuffix(address[index+1:], ">")
+	for _, serverDomain := range options.Domains {
+		if strings.EqualFold(domain, serverDomain) || stringsutil.HasSuffixI(domain, "."+serverDomain) {
+			return true
+		}
+	}
+	return false
+}
+
+// smtpSessions records the responses to the recipients of the open smtp
+// sessions, indexed by remote address, until their message is received.
+type smtpSessions struct {
+	sync.Mutex
+	recipients map[string][]SMTPRecipient
+}
+
+func newSMTPSessions() *smtpSessions {
+	return &smtpSessions{recipients: make(map[string][]SMTPRecipient)}
+}
+
+// add records the response to a recipient of the session of remoteAddr
+func (s *smtpSessions) add(remoteAddr net.Addr, recipient SMTPRecipient) {
+	s.Lock()
+	defer s.Unlock()
+
+	key := remoteAddr.String()
+	recipients, ok := s.recipients[key]
+	if !ok && len(s.recipients) >= maxSMTPPendingSessions {
+		// the sessions which never sent a message are forgotten
+		s.recipients = make(map[string][]SMTPRecipient)
+	}
+	if len(recipients) < maxSMTPRecipients {
+		s.recipients[key] = append(recipients, recipient)
+	}
+}
+
+// take returns and forgets the responses to the recipients of the message of remoteAddr
+func (s *smtpSessions) take(remoteAddr net.Addr) []SMTPRecipient {
+	s.Lock()
+	defer s.Unlock()
+
+	key := remoteAddr.String()
+	recipients := s.recipients[key]
+	delete(s.recipients, key)
+	return recipients
+}
+
+// rcptHandler accepts or rejects a recipient according to the smtp profile
+func (h *SMTPServer) rcptHandler(remoteAddr net.Addr, from string, to string) bool {
+	accepted := h.profile.Relay == SMTPRelayAccept || h.options.isLocalRecipient(to)
+	code := smtpCodeAccepted
+	if !accepted {
+		code = smtpCodeRejected
+	}
+	h.sessions.add(remoteAddr, SMTPRecipient{Address: to, Code: code})
+	return accepted
+}
+
+// session returns the responses of the server during the session of the message of remoteAddr
+func (h *SMTPServer) session(remoteAddr net.Addr) *SMTPSession {
+	return &SMTPSession{
+		Greeting:   fmt.Sprintf("220 %s %s ESMTP Service ready", h.options.Domains[0], h.profile.Banner),
+		Relay:      h.profile.Relay,
+		MaxSize:    h.profile.MaxSize,
+		Recipients: h.sessions.take(remoteAddr),
+	}
+}
@@ -0,0 +1,35 @@
+package server
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMTPProfile(t *testing.T) {
+	options := &Options{Domains: []string{"oast.example"}}
+	profile := options.smtpProfile()
+	require.Equal(t, defaultSMTPBanner, profile.Banner, "could not get default banner")
+	require.Equal(t, SMTPRelayAccept, profile.Relay, "could not get default relay")
+	require.Equal(t, maxSMTPMessageSize, profile.MaxSize, "could not get default max size")
+
+	options.SMTPProfile = &SMTPProfile{Banner: "Microsoft ESMTP MAIL Service", Relay: SMTPRelayReject, MaxSize: 1024}
+	h := &SMTPServer{options: options, profile: options.smtpProfile(), sessions: newSMTPSessions()}
+	remoteAddr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 25000}
+
+	require.True(t, h.rcptHandler(remoteAddr, "attacker@example.com", "admin@c59e3crp82ke7bcnedq0.oast.example"), "could not accept local recipient")
+	require.True(t, h.rcptHandler(remoteAddr, "attacker@example.com", "postmaster@OAST.example"), "could not accept local recipient")
+	require.False(t, h.rcptHandler(remoteAddr, "attacker@example.com", "billing@notoast.example"), "could accept external recipient")
+
+	session := h.session(remoteAddr)
+	require.Equal(t, "220 oast.example Microsoft ESMTP MAIL Service ESMTP Service ready", session.Greeting, "could not get greeting")
+	require.Equal(t, SMTPRelayReject, session.Relay, "could not get relay")
+	require.Equal(t, 1024, session.MaxSize, "could not get max size")
+	require.Equal(t, []SMTPRecipient{
+		{Address: "admin@c59e3crp82ke7bcnedq0.oast.example", Code: smtpCodeAccepted},
+		{Address: "postmaster@OAST.example", Code: smtpCodeAccepted},
+		{Address: "billing@notoast.example", Code: smtpCodeRejected},
+	}, session.Recipients, "could not record recipients")
+	require.Empty(t, h.session(remoteAddr).Recipients, "could not reset recipients after message")
+}
@@ -2,8 +2,8 @@ package server
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
-	"fmt"
 	"net"
 	"strings"
 	"sync/atomic"
@@ -21,33 +21,43 @@ type SMTPServer struct {
 	options     *Options
 	smtpServer  smtpd.Server
 	smtpsServer smtpd.Server
+	// fingerprints records the tls handshakes on the auto tls and smtps ports
+	fingerprints *fingerprintRegistry
+	// profile controls the responses of the listeners
+	profile SMTPProfile
+	// sessions records the responses to the recipients until the messages are received
+	sessions *smtpSessions
+	// drainer tracks the smtp sessions to finish them on shutdown
+	drainer *connDrainer
 }
 
 // NewSMTPServer returns a new TLS & Non-TLS SMTP server.
 func NewSMTPServer(options *Options) (*SMTPServer, error) {
-	server := &SMTPServer{options: options}
+	server := &SMTPServer{options: options, fingerprints: newFingerprintRegistry(), profile: options.smtpProfile(), sessions: newSMTPSessions(), drainer: newConnDrainer()}
+	server.fingerprints.abandoned = options.sniHandler()
 
 	authHandler := func(remoteAddr net.Addr, mechanism string, username []byte, password []byte, shared []byte) (bool, error) {
 		return true, nil
 	}
-	rcptHandler := func(remoteAddr net.Addr, from string, to string) bool {
-		return true
-	}
 	server.smtpServer = smtpd.Server{
-		Addr:        fmt.Sprintf("%s:%d", options.ListenIP, options.SmtpPort),
+		Addr:        options.listenAddress(options.SmtpPort),
 		AuthHandler: authHandler,
-		HandlerRcpt: rcptHandler,
+		HandlerRcpt: server.rcptHandler,
 		Hostname:    options.Domains[0],
-		Appname:     "interactsh",
+		Appname:     server.profile.Banner,
 		Handler:     smtpd.Handler(server.defaultHandler),
+		Timeout:     smtpTimeout,
+		MaxSize:     server.profile.MaxSize,
 	}
 	server.smtpsServer = smtpd.Server{
-		Addr:        fmt.Sprintf("%s:%d", options.ListenIP, options.SmtpsPort),
+		Addr:        options.listenAddress(options.SmtpsPort),
 		AuthHandler: authHandler,
-		HandlerRcpt: rcptHandler,
+		HandlerRcpt: server.rcptHandler,
 		Hostname:    options.Domains[0],
-		Appname:     "interactsh",
+		Appname:     server.profile.Banner,
 		Handler:     smtpd.Handler(server.defaultHandler),
+		Timeout:     smtpTimeout,
+		MaxSize:     server.profile.MaxSize,
 	}
 	return server, nil
 }
@@ -55,33 +65,69 @@ func NewSMTPServer(options *Options) (*SMTPServer, error) {
 // ListenAndServe listens on smtp and/or smtps ports for the server.
 func (h *SMTPServer) ListenAndServe(tlsConfig *tls.Config, smtpAlive, smtpsAlive chan bool) {
 	go func() {
-		if tlsConfig == nil {
+		if tlsConfig == nil || !h.options.serviceEnabled("smtp-autotls") {
 			return
 		}
-		srv := &smtpd.Server{Addr: fmt.Sprintf("%s:%d", h.options.ListenIP, h.options.SmtpAutoTLSPort), Handler: h.defaultHandler, Appname: "interactsh", Hostname: h.options.Domains[0]}
-		srv.TLSConfig = tlsConfig
+		srv := &smtpd.Server{Addr: h.options.listenAddress(h.options.SmtpAutoTLSPort), Handler: h.defaultHandler, HandlerRcpt: h.rcptHandler, Appname: h.profile.Banner, Hostname: h.options.Domains[0], Timeout: smtpTimeout, MaxSize: h.profile.MaxSize}
+		srv.TLSConfig = h.fingerprints.TLSConfig(tlsConfig)
 
-		smtpsAlive <- true
-		err := srv.ListenAndServe()
+		listener, err := h.options.listen(srv.Addr, h.options.SmtpAutoTLSPort)
 		if err != nil {
+			gologger.Error().Msgf("Could not listen smtp with tls on port %d: %s\n", h.options.SmtpAutoTLSPort, err)
+			h.options.Health.SetError("SMTPS", "TCP", err)
+			smtpsAlive <- false
+			return
+		}
+		smtpsAlive <- true
+		if err := srv.Serve(h.drainer.Listener(h.fingerprints.Listener(listener))); err != nil && !h.drainer.isClosed() {
 			gologger.Error().Msgf("Could not serve smtp with tls on port %d: %s\n", h.options.SmtpAutoTLSPort, err)
+			h.options.Health.SetError("SMTPS", "TCP", err)
 			smtpsAlive <- false
 		}
 	}()
 
+	if !h.options.serviceEnabled("smtp") && !h.options.serviceEnabled("smtps") {
+		return
+	}
 	smtpAlive <- true
 	go func() {
-		if err := h.smtpServer.ListenAndServe(); err != nil {
+		if !h.options.serviceEnabled("smtp") {
+			return
+		}
+		listener, err := h.options.listen(h.smtpServer.Addr, h.options.SmtpPort)
+		if err == nil {
+			err = h.smtpServer.Serve(h.drainer.Listener(listener))
+		}
+		if err != nil && !h.drainer.isClosed() {
+			h.options.Health.SetError("SMTP", "TCP", err)
 			smtpAlive <- false
 			gologger.Error().Msgf("Could not serve smtp on port %d: %s\n", h.options.SmtpPort, err)
 		}
 	}()
-	if err := h.smtpsServer.ListenAndServe(); err != nil {
+	if !h.options.serviceEnabled("smtps") {
+		return
+	}
+	listener, err := h.options.listen(h.smtpsServer.Addr, h.options.SmtpsPort)
+	if err != nil {
+		gologger.Error().Msgf("Could not listen smtp on port %d: %s\n", h.options.SmtpsPort, err)
+		h.options.Health.SetError("SMTP", "TCP", err)
+		smtpAlive <- false
+		return
+	}
+	// the tls clients sending their handshake to the smtps port are recorded from their sni
+	if err := h.smtpsServer.Serve(h.drainer.Listener(h.fingerprints.Listener(listener))); err != nil && !h.drainer.isClosed() {
 		gologger.Error().Msgf("Could not serve smtp on port %d: %s\n", h.options.SmtpsPort, err)
+		h.options.Health.SetError("SMTP", "TCP", err)
 		smtpAlive <- false
 	}
 }
 
+// Shutdown stops accepting smtp connections and waits for the sessions in
+// progress until ctx is done.
+func (h *SMTPServer) Shutdown(ctx context.Context) error {
+	return h.drainer.Shutdown(ctx)
+}
+
 // defaultHandler is a handler for default collaborator requests
 func (h *SMTPServer) defaultHandler(remoteAddr net.Addr, from string, to []string, data []byte) error {
 	atomic.AddUint64(&h.options.Stats.Smtp, 1)
@@ -91,11 +137,17 @@ func (h *SMTPServer) defaultHandler(remoteAddr net.Addr, from string, to []strin
 	dataString := string(data)
 	gologger.Debug().Msgf("New SMTP request: %s %s %s %s\n", remoteAddr, from, to, dataString)
 
+	message, err := parseSMTPMessage(data)
+	if err != nil {
+		gologger.Debug().Msgf("Could not parse smtp message: %s\n", err)
+	}
+	session := h.session(remoteAddr)
+
 	// if root-tld is enabled stores any interaction towards the main domain
 	for _, addr := range to {
 		if h.options.RootTLD {
 			for _, domain := range h.options.Domains {
-				if stringsutil.HasSuffixI(addr, domain) {
+				if stringsutil.HasSuffixI(addr, domain) && strings.Contains(addr, "@") {
 					ID := domain
 					host, _, _ := net.SplitHostPort(remoteAddr.String())
 					address := addr[strings.Index(addr, "@"):]
@@ -105,17 +157,23 @@ func (h *SMTPServer) defaultHandler(remoteAddr net.Addr, from string, to []strin
 						FullId:        address,
 						RawRequest:    dataString,
 						SMTPFrom:      from,
+						SMTPMessage:   message,
+						SMTPSession:   session,
 						RemoteAddress: host,
+						TLS:           h.fingerprints.Get(remoteAddr.String()),
 						Timestamp:     time.Now(),
 					}
 					buffer := &bytes.Buffer{}
-					if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-						gologger.Warning().Msgf("Could not encode root tld SMTP interaction: %s\n", err)
+					if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+						if err != errInteractionDropped {
+							gologger.Warning().Msgf("Could not encode root tld SMTP interaction: %s\n", err)
+						}
 					} else {
 						gologger.Debug().Msgf("Root TLD SMTP Interaction: \n%s\n", buffer.String())
 						if err := h.options.Storage.AddInteractionWithId(ID, buffer.Bytes()); err != nil {
 							gologger.Warning().Msgf("Could not store root tld smtp interaction: %s\n", err)
 						}
+						h.options.exportInteraction(interaction)
 					}
 				}
 			}
@@ -146,17 +204,23 @@ func (h *SMTPServer) defaultHandler(remoteAddr net.Addr, from string, to []strin
 			FullId:        fullID,
 			RawRequest:    dataString,
 			SMTPFrom:      from,
+			SMTPMessage:   message,
+			SMTPSession:   session,
 			RemoteAddress: host,
+			TLS:           h.fingerprints.Get(remoteAddr.String()),
 			Timestamp:     time.Now(),
 		}
 		buffer := &bytes.Buffer{}
-		if err := jsoniter.NewEncoder(buffer).Encode(interaction); err != nil {
-			gologger.Warning().Msgf("Could not encode smtp interaction: %s\n", err)
+		if err := h.options.encodeInteraction(buffer, interaction); err != nil {
+			if err != errInteractionDropped {
+				gologger.Warning().Msgf("Could not encode smtp interaction: %s\n", err)
+			}
 		} else {
 			gologger.Debug().Msgf("%s\n", buffer.String())
 			if err := h.options.Storage.AddInteraction(correlationID, buffer.Bytes()); err != nil {
 				gologger.Warning().Msgf("Could not store smtp interaction: %s\n", err)
 			}
+			h.options.exportInteraction(interaction)
 		}
 	}
 	return nil
@@ -0,0 +1,310 @@
+package server
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/gologger"
+)
+
+const (
+	// syslogFacilityLocal0 is the facility used for the forwarded messages
+	syslogFacilityLocal0 = 16
+	// syslogSeverityNotice is the severity used for the forwarded messages
+	syslogSeverityNotice = 5
+	// syslogQueueSize is the maximum number of interactions waiting to be forwarded
+	syslogQueueSize = 4096
+	// syslogMaxRawLength is the maximum length of the raw request embedded in a message
+	syslogMaxRawLength = 2048
+)
+
+// SyslogExporter forwards interactions to a remote syslog collector
+// (RFC5424) with the message body formatted as CEF or LEEF.
+type SyslogExporter struct {
+	network  string
+	address  string
+	format   string
+	version  string
+	hostname string
+
+	mutex sync.Mutex
+	conn  net.Conn
+	queue chan *Interaction
+	done  chan struct{}
+	// queueMutex protects the queue from being closed while exporting
+	queueMutex sync.RWMutex
+	closed     bool
+	// dropped is the number of interactions dropped because the queue was full
+	dropped uint64
+}
+
+// NewSyslogExporter returns a new syslog exporter sending messages to address
+// over network (udp, tcp or tls) using format (cef or leef).
+func NewSyslogExporter(network, address, format, version string) (*SyslogExporter, error) {
+	network = strings.ToLower(network)
+	switch network {
+	case "udp", "tcp", "tls":
+	default:
+		return nil, fmt.Errorf("unsupported syslog network: %s", network)
+	}
+	format = strings.ToLower(format)
+	switch format {
+	case "cef", "leef":
+	default:
+		return nil, fmt.Errorf("unsupported syslog format: %s", format)
+	}
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+	exporter := &SyslogExporter{
+		network:  network,
+		address:  address,
+		format:   format,
+		version:  version,
+		hostname: hostname,
+		queue:    make(chan *Interaction, syslogQueueSize),
+		done:     make(chan struct{}),
+	}
+	if err := exporter.connect(); err != nil {
+		return nil, errors.Wrap(err, "could not connect to syslog server")
+	}
+	go exporter.run()
+	return exporter, nil
+}
+
+// Export queues an interaction to be forwarded. Interactions are dropped
+// if the queue is full so that a slow collector never blocks capture.
+func (s *SyslogExporter) Export(interaction *Interaction) error {
+	s.queueMutex.RLock()
+	defer s.queueMutex.RUnlock()
+	if s.closed {
+		return errors.New("syslog exporter is closed")
+	}
+	select {
+	case s.queue <- interaction:
+		return nil
+	default:
+		atomic.AddUint64(&s.dropped, 1)
+		return errors.New("syslog queue is full")
+	}
+}
+
+// Dropped returns the number of interactions dropped because the queue was full
+func (s *SyslogExporter) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close flushes the queued interactions and closes the connection.
+func (s *SyslogExporter) Close() error {
+	s.queueMutex.Lock()
+	if s.closed {
+		s.queueMutex.Unlock()
+		return nil
+	}
+	s.closed = true
+	close(s.queue)
+	s.queueMutex.Unlock()
+	<-s.done
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+func (s *SyslogExporter) run() {
+	defer close(s.done)
+
+	for interaction := range s.queue {
+		if err := s.write(s.frame(s.message(interaction))); err != nil {
+			gologger.Warning().Msgf("Could not forward interaction to syslog: %s\n", err)
+		}
+	}
+}
+
+func (s *SyslogExporter) connect() error {
+	var conn net.Conn
+	var err error
+	switch s.network {
+	case "tls":
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		conn, err = tls.DialWithDialer(dialer, "tcp", s.address, &tls.Config{MinVersion: tls.VersionTLS12})
+	default:
+		conn, err = net.DialTimeout(s.network, s.address, 10*time.Second)
+	}
+	if err != nil {
+		return err
+	}
+	s.conn = conn
+	return nil
+}
+
+// write sends the message, reconnecting once if the connection was dropped
+func (s *SyslogExporter) write(data []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.conn != nil {
+		if _, err := s.conn.Write(data); err == nil {
+			return nil
+		}
+		_ = s.conn.Close()
+		s.conn = nil
+	}
+	if err := s.connect(); err != nil {
+		return err
+	}
+	_, err := s.conn.Write(data)
+	return err
+}
+
+// frame applies the transport framing. Stream transports use the octet
+// counting method described in RFC6587 so that multi-line payloads are kept intact.
+func (s *SyslogExporter) frame(message string) []byte {
+	if s.network == "udp" {
+		return []byte(message)
+	}
+	return []byte(strconv.Itoa(len(message)) + " " + message)
+}
+
+// message returns the RFC5424 formatted message for the interaction
+func (s *SyslogExporter) message(interaction *Interaction) string {
+	var body string
+	switch s.format {
+	case "leef":
+		body = FormatLEEF(interaction, s.version)
+	default:
+		body = FormatCEF(interaction, s.version)
+	}
+	priority := syslogFacilityLocal0*8 + syslogSeverityNotice
+	timestamp := interaction.Timestamp
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	return fmt.Sprintf("<%d>1 %s %s interactsh %d %s - %s", priority, timestamp.UTC().Format(time.RFC3339Nano), s.hostname, os.Getpid(), strings.ToUpper(interaction.Protocol), body)
+}
+
+// FormatCEF formats an interaction as an ArcSight Common Event Format message.
+func FormatCEF(interaction *Interaction, version string) string {
+	var builder strings.Builder
+	builder.WriteString("CEF:0|ProjectDiscovery|Interactsh|")
+	builder.WriteString(cefHeaderEscape(version))
+	builder.WriteString("|")
+	builder.WriteString(cefHeaderEscape(interaction.Protocol))
+	builder.WriteString("|")
+	builder.WriteString(cefHeaderEscape(fmt.Sprintf("%s interaction", strings.ToUpper(interaction.Protocol))))
+	builder.WriteString("|5|")
+
+	extensions := [][2]string{
+		{"rt", strconv.FormatInt(interaction.Timestamp.UnixNano()/int64(time.Millisecond), 10)},
+		{"src", interaction.RemoteAddress},
+		{"app", interaction.Protocol},
+		{"dhost", interaction.FullId},
+		{"cs1Label", "unique-id"},
+		{"cs1", interaction.UniqueID},
+	}
+	if interaction.QType != "" {
+		extensions = append(extensions, [2]string{"cs2Label", "q-type"}, [2]string{"cs2", interaction.QType})
+	}
+	if interaction.Tenant != "" {
+		extensions = append(extensions, [2]string{"cs3Label", "tenant"}, [2]string{"cs3", interaction.Tenant})
+	}
+	if interaction.SMTPFrom != "" {
+		extensions = append(extensions, [2]string{"suser", interaction.SMTPFrom})
+	}
+	if interaction.RawRequest != "" {
+		extensions = append(extensions, [2]string{"msg", truncateString(interaction.RawRequest, syslogMaxRawLength)})
+	}
+	first := true
+	for _, extension := range extensions {
+		if extension[1] == "" {
+			continue
+		}
+		if !first {
+			builder.WriteString(" ")
+		}
+		first = false
+		builder.WriteString(extension[0])
+		builder.WriteString("=")
+		builder.WriteString(cefExtensionEscape(extension[1]))
+	}
+	return builder.String()
+}
+
+// FormatLEEF formats an interaction as an IBM QRadar Log Event Extended Format (2.0) message.
+func FormatLEEF(interaction *Interaction, version string) string {
+	var builder strings.Builder
+	builder.WriteString("LEEF:2.0|ProjectDiscovery|Interactsh|")
+	builder.WriteString(leefHeaderEscape(version))
+	builder.WriteString("|")
+	builder.WriteString(leefHeaderEscape(interaction.Protocol))
+	builder.WriteString("|x09|")
+
+	// without devTimeFormat, devTime is read as milliseconds since the epoch
+	attributes := [][2]string{
+		{"devTime", strconv.FormatInt(interaction.Timestamp.UnixNano()/int64(time.Millisecond), 10)},
+		{"src", interaction.RemoteAddress},
+		{"proto", interaction.Protocol},
+		{"dhost", interaction.FullId},
+		{"uniqueId", interaction.UniqueID},
+		{"qType", interaction.QType},
+		{"smtpFrom", interaction.SMTPFrom},
+		{"tenant", interaction.Tenant},
+		{"rawRequest", truncateString(interaction.RawRequest, syslogMaxRawLength)},
+	}
+	first := true
+	for _, attribute := range attributes {
+		if attribute[1] == "" {
+			continue
+		}
+		if !first {
+			builder.WriteString("\t")
+		}
+		first = false
+		builder.WriteString(attribute[0])
+		builder.WriteString("=")
+		builder.WriteString(leefValueEscape(attribute[1]))
+	}
+	return builder.String()
+}
+
+var (
+	cefHeaderReplacer    = strings.NewReplacer(`\`, `\\`, `|`, `\|`, "\r", " ", "\n", " ")
+	cefExtensionReplacer = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+	leefHeaderReplacer   = strings.NewReplacer(`|`, `\|`, "\r", " ", "\n", " ")
+	leefValueReplacer    = strings.NewReplacer("\t", `\t`, "\r\n", `\n`, "\n", `\n`, "\r", `\r`)
+)
+
+func cefHeaderEscape(value string) string {
+	return cefHeaderReplacer.Replace(value)
+}
+
+func cefExtensionEscape(value string) string {
+	return cefExtensionReplacer.Replace(value)
+}
+
+func leefHeaderEscape(value string) string {
+	return leefHeaderReplacer.Replace(value)
+}
+
+func leefValueEscape(value string) string {
+	return leefValueReplacer.Replace(value)
+}
+
+func truncateString(value string, size int) string {
+	if len(value) > size {
+		return value[:size]
+	}
+	return value
+}
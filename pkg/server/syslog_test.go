@@ -0,0 +1,47 @@
+package server
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatCEF(t *testing.T) {
+	interaction := &Interaction{
+		Protocol:      "dns",
+		UniqueID:      "c6rj61aciaeutn2ae680cg5ugboyyyyyn",
+		FullId:        "c6rj61aciaeutn2ae680cg5ugboyyyyyn.interactsh.com",
+		QType:         "A",
+		RawRequest:    "a=b|c\nd",
+		RemoteAddress: "127.0.0.1",
+		Timestamp:     time.Unix(1, 0),
+	}
+	message := FormatCEF(interaction, "1.0.7")
+	require.Equal(t, "CEF:0|ProjectDiscovery|Interactsh|1.0.7|dns|DNS interaction|5|rt=1000 src=127.0.0.1 app=dns dhost=c6rj61aciaeutn2ae680cg5ugboyyyyyn.interactsh.com cs1Label=unique-id cs1=c6rj61aciaeutn2ae680cg5ugboyyyyyn cs2Label=q-type cs2=A msg=a\\=b|c\\nd", message, "could not get correct cef message")
+}
+
+func TestFormatLEEF(t *testing.T) {
+	interaction := &Interaction{
+		Protocol:      "http",
+		RawRequest:    "GET /\tx",
+		RemoteAddress: "127.0.0.1",
+		Timestamp:     time.Unix(1, 0),
+	}
+	message := FormatLEEF(interaction, "1.0.7")
+	require.Equal(t, "LEEF:2.0|ProjectDiscovery|Interactsh|1.0.7|http|x09|devTime=1000\tsrc=127.0.0.1\tproto=http\trawRequest=GET /\\tx", message, "could not get correct leef message")
+}
+
+func TestSyslogExporterClose(t *testing.T) {
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen syslog collector")
+	defer listener.Close()
+
+	exporter, err := NewSyslogExporter("udp", listener.LocalAddr().String(), "cef", "1.0.0")
+	require.Nil(t, err, "could not create syslog exporter")
+	require.Nil(t, exporter.Export(&Interaction{Protocol: "dns"}), "could not export interaction")
+	require.Nil(t, exporter.Close(), "could not close syslog exporter")
+	require.NotNil(t, exporter.Export(&Interaction{Protocol: "dns"}), "could export interaction after close")
+	require.Nil(t, exporter.Close(), "could not close syslog exporter twice")
+}
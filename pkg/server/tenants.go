@@ -0,0 +1,219 @@
+package server
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"net/http"
+	"os"
+	"regexp"
+	"sync"
+
+	units "github.com/docker/go-units"
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/settings"
+	"github.com/projectdiscovery/interactsh/pkg/storage"
+	"gopkg.in/yaml.v3"
+)
+
+// subdomainLabel matches the valid dedicated subdomains of the tenants
+var subdomainLabel = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?$`)
+
+// Tenant is a team sharing the server, with its own token and quotas
+type Tenant struct {
+	// Name is the unique name of the tenant
+	Name string `yaml:"name"`
+	// Token is the token of the clients of the tenant
+	Token string `yaml:"token"`
+	// Subdomain is the label prepended to the domain in the payloads of the tenant
+	Subdomain string `yaml:"subdomain"`
+	// MaxSessions is the maximum number of registered correlation ids (unlimited if zero)
+	MaxSessions int `yaml:"max-sessions"`
+	// MaxInteractions is the maximum number of pending interactions per correlation id
+	// (server quota if zero)
+	MaxInteractions int `yaml:"max-interactions"`
+	// MaxBytes is the maximum size of the pending interactions per correlation id,
+	// e.g. 10MB (server quota if empty)
+	MaxBytes string `yaml:"max-bytes"`
+
+	quota *storage.Quota
+}
+
+// TenantStats are the statistics of a tenant returned to its clients
+type TenantStats struct {
+	Name            string `json:"name"`
+	Subdomain       string `json:"subdomain,omitempty"`
+	Sessions        int    `json:"sessions"`
+	MaxSessions     int    `json:"max-sessions,omitempty"`
+	MaxInteractions int    `json:"max-interactions,omitempty"`
+	MaxBytes        int    `json:"max-bytes,omitempty"`
+}
+
+// Tenants are the tenants of a multi-tenant server
+type Tenants struct {
+	sync.RWMutex
+	tenants []*Tenant
+}
+
+// LoadTenants loads the tenants from a YAML file
+func LoadTenants(input string) (*Tenants, error) {
+	data, err := os.ReadFile(input)
+	if err != nil {
+		return nil, errors.Wrap(err, "could not read file")
+	}
+	var config struct {
+		Tenants []*Tenant `yaml:"tenants"`
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return nil, errors.Wrap(err, "could not decode file")
+	}
+	return NewTenants(config.Tenants)
+}
+
+// NewTenants validates the tenants and returns them
+func NewTenants(tenants []*Tenant) (*Tenants, error) {
+	names := make(map[string]struct{}, len(tenants))
+	tokens := make(map[string]struct{}, len(tenants))
+	for i, tenant := range tenants {
+		if tenant.Name == "" || tenant.Token == "" {
+			return nil, errors.Errorf("tenant %d has no name or token", i+1)
+		}
+		if _, ok := names[tenant.Name]; ok {
+			return nil, errors.Errorf("duplicate tenant %s", tenant.Name)
+		}
+		if _, ok := tokens[tenant.Token]; ok {
+			return nil, errors.Errorf("tenant %s reuses the token of another tenant", tenant.Name)
+		}
+		names[tenant.Name], tokens[tenant.Token] = struct{}{}, struct{}{}
+
+		if tenant.Subdomain != "" && (!subdomainLabel.MatchString(tenant.Subdomain) || tenant.Subdomain == settings.IPv6OnlyLabel) {
+			return nil, errors.Errorf("invalid subdomain %s for tenant %s", tenant.Subdomain, tenant.Name)
+		}
+		quota := &storage.Quota{MaxInteractions: tenant.MaxInteractions}
+		if tenant.MaxBytes != "" {
+			maxBytes, err := units.RAMInBytes(tenant.MaxBytes)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid max bytes for tenant %s", tenant.Name)
+			}
+			quota.MaxBytes = int(maxBytes)
+		}
+		tenant.quota = quota
+	}
+	return &Tenants{tenants: tenants}, nil
+}
+
+// Reload replaces the tenants with the ones of a YAML file, the current
+// ones being kept if it can't be loaded. The registered sessions keep the
+// quotas they were registered with.
+func (t *Tenants) Reload(input string) error {
+	tenants, err := LoadTenants(input)
+	if err != nil {
+		return err
+	}
+	t.Lock()
+	t.tenants = tenants.tenants
+	t.Unlock()
+	return nil
+}
+
+// ByToken returns the tenant of a token, or nil if there is none
+func (t *Tenants) ByToken(token string) *Tenant {
+	if t == nil || token == "" {
+		return nil
+	}
+	t.RLock()
+	defer t.RUnlock()
+	for _, tenant := range t.tenants {
+		if subtle.ConstantTimeCompare([]byte(tenant.Token), []byte(token)) == 1 {
+			return tenant
+		}
+	}
+	return nil
+}
+
+// tenant returns the tenant authenticated by the request, or nil if there is none
+func (h *HTTPServer) tenant(req *http.Request) *Tenant {
+	return h.options.Tenants.ByToken(req.Header.Get("Authorization"))
+}
+
+// tenantScopeMiddleware lets the tenants through to next, which scopes the
+// response to their own interactions. The other requests need the scope.
+func (h *HTTPServer) tenantScopeMiddleware(scope string, next http.Handler) http.Handler {
+	scoped := h.scopeMiddleware(scope, next)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if h.tenant(req) != nil {
+			next.ServeHTTP(w, req)
+			return
+		}
+		scoped.ServeHTTP(w, req)
+	})
+}
+
+// checkTenant returns an error if the correlationID isn't registered by the tenant
+func (h *HTTPServer) checkTenant(tenant *Tenant, correlationID string) error {
+	if tenant == nil {
+		return nil
+	}
+	value, err := h.options.Storage.GetCacheItem(correlationID)
+	if err != nil {
+		return nil
+	}
+	value.Lock()
+	owner := value.Tenant
+	value.Unlock()
+	if owner != tenant.Name {
+		return errors.New("correlation-id belongs to another tenant")
+	}
+	return nil
+}
+
+// checkTenantSessions returns an error if the tenant can't register another correlationID
+func (h *HTTPServer) checkTenantSessions(tenant *Tenant, correlationID string) error {
+	if tenant == nil || tenant.MaxSessions <= 0 {
+		return nil
+	}
+	if _, err := h.options.Storage.GetCacheItem(correlationID); err == nil {
+		// resuming a session doesn't count as a new one
+		return nil
+	}
+	if h.options.Storage.GetTenantSessions(tenant.Name) >= tenant.MaxSessions {
+		return errors.Errorf("session quota of tenant %s exceeded", tenant.Name)
+	}
+	return nil
+}
+
+// tenantOf returns the tenant which registered the correlation id of a unique id
+func (options *Options) tenantOf(uniqueID string) string {
+	if options.Tenants == nil || len(uniqueID) < options.CorrelationIdLength {
+		return ""
+	}
+	value, err := options.Storage.GetCacheItem(uniqueID[:options.CorrelationIdLength])
+	if err != nil {
+		return ""
+	}
+	value.Lock()
+	defer value.Unlock()
+	return value.Tenant
+}
+
+// tenantHandler is a handler returning the statistics of the tenant of the request
+func (h *HTTPServer) tenantHandler(w http.ResponseWriter, req *http.Request) {
+	tenant := h.tenant(req)
+	if tenant == nil {
+		jsonError(w, "no tenant for the token", http.StatusBadRequest)
+		return
+	}
+	stats := &TenantStats{
+		Name:            tenant.Name,
+		Subdomain:       tenant.Subdomain,
+		Sessions:        h.options.Storage.GetTenantSessions(tenant.Name),
+		MaxSessions:     tenant.MaxSessions,
+		MaxInteractions: tenant.quota.MaxInteractions,
+		MaxBytes:        tenant.quota.MaxBytes,
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	_ = jsoniter.NewEncoder(w).Encode(stats)
+}
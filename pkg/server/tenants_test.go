@@ -0,0 +1,48 @@
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadTenants(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.yaml")
+	err := os.WriteFile(path, []byte(`tenants:
+  - name: red
+    token: red-token
+    subdomain: red
+    max-sessions: 10
+    max-bytes: 1MB
+  - name: blue
+    token: blue-token
+    max-interactions: 50
+`), 0600)
+	require.Nil(t, err, "could not write tenants file")
+
+	tenants, err := LoadTenants(path)
+	require.Nil(t, err, "could not load tenants")
+	tenant := tenants.ByToken("red-token")
+	require.NotNil(t, tenant, "could not get tenant by token")
+	require.Equal(t, "red", tenant.Name, "could not get tenant name")
+	require.Equal(t, 1024*1024, tenant.quota.MaxBytes, "could not parse max bytes")
+	require.Equal(t, 50, tenants.ByToken("blue-token").quota.MaxInteractions, "could not get max interactions")
+	require.Nil(t, tenants.ByToken("green-token"), "could get tenant of unknown token")
+	require.Nil(t, tenants.ByToken(""), "could get tenant of empty token")
+
+	var nilTenants *Tenants
+	require.Nil(t, nilTenants.ByToken("red-token"), "could get tenant without tenants")
+}
+
+func TestNewTenantsValidation(t *testing.T) {
+	_, err := NewTenants([]*Tenant{{Name: "red", Token: "token"}, {Name: "blue", Token: "token"}})
+	require.NotNil(t, err, "could accept duplicate tokens")
+
+	_, err = NewTenants([]*Tenant{{Name: "red", Token: "token", Subdomain: "Red.Team"}})
+	require.NotNil(t, err, "could accept invalid subdomain")
+
+	_, err = NewTenants([]*Tenant{{Name: "red"}})
+	require.NotNil(t, err, "could accept tenant without token")
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// tlsMaxClientCertificates is the maximum number of certificates of a client chain recorded
+const tlsMaxClientCertificates = 8
+
+// TLSCertificate describes a certificate presented by a client during the TLS handshake
+type TLSCertificate = types.TLSCertificate
+
+// requestClientCertificates returns a copy of config requesting, without requiring,
+// a certificate from the clients. The presented chains aren't verified, the
+// handshake never fails because of them.
+func requestClientCertificates(config *tls.Config) *tls.Config {
+	requestConfig := config.Clone()
+	requestConfig.ClientAuth = tls.RequestClientCert
+	return requestConfig
+}
+
+// TLSConfig returns a copy of config requesting a certificate from the clients,
+// for the listeners without access to the state of the TLS connections, like
+// SMTP with STARTTLS. The chains presented on the connections of the registry
+// are recorded in their TLS information.
+func (r *fingerprintRegistry) TLSConfig(config *tls.Config) *tls.Config {
+	requestConfig := requestClientCertificates(config)
+	getConfigForClient := config.GetConfigForClient
+	requestConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+		connConfig := config
+		if getConfigForClient != nil {
+			clientConfig, err := getConfigForClient(hello)
+			if err != nil {
+				return nil, err
+			}
+			if clientConfig != nil {
+				connConfig = clientConfig
+			}
+		}
+		connConfig = requestClientCertificates(connConfig)
+		connConfig.GetConfigForClient = nil
+		if conn, ok := hello.Conn.(*fingerprintConn); ok {
+			connConfig.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				conn.setClientCertificates(rawCerts)
+				return nil
+			}
+		}
+		return connConfig, nil
+	}
+	return requestConfig
+}
+
+// setClientCertificates records the certificate chain presented by the client
+func (c *fingerprintConn) setClientCertificates(rawCerts [][]byte) {
+	if len(rawCerts) > tlsMaxClientCertificates {
+		rawCerts = rawCerts[:tlsMaxClientCertificates]
+	}
+	certificates := make([][]byte, len(rawCerts))
+	for i, rawCert := range rawCerts {
+		certificates[i] = append([]byte(nil), rawCert...)
+	}
+	c.mutex.Lock()
+	c.clientCertificates = certificates
+	c.mutex.Unlock()
+}
+
+// peerCertificates returns the raw certificates presented on a TLS connection
+func peerCertificates(certificates []*x509.Certificate) [][]byte {
+	if len(certificates) > tlsMaxClientCertificates {
+		certificates = certificates[:tlsMaxClientCertificates]
+	}
+	rawCerts := make([][]byte, len(certificates))
+	for i, certificate := range certificates {
+		rawCerts[i] = certificate.Raw
+	}
+	return rawCerts
+}
+
+// parseClientCertificates describes the certificates of a client chain. The
+// certificates which can't be parsed are only described by their fingerprints.
+func parseClientCertificates(rawCerts [][]byte) []*TLSCertificate {
+	certificates := make([]*TLSCertificate, 0, len(rawCerts))
+	for _, rawCert := range rawCerts {
+		sha1Sum := sha1.Sum(rawCert)
+		sha256Sum := sha256.Sum256(rawCert)
+		certificate := &TLSCertificate{
+			SHA1:   hex.EncodeToString(sha1Sum[:]),
+			SHA256: hex.EncodeToString(sha256Sum[:]),
+			Raw:    base64.StdEncoding.EncodeToString(rawCert),
+		}
+		if parsed, err := x509.ParseCertificate(rawCert); err == nil {
+			certificate.Subject = parsed.Subject.String()
+			certificate.Issuer = parsed.Issuer.String()
+			certificate.SerialNumber = hex.EncodeToString(parsed.SerialNumber.Bytes())
+			certificate.DNSNames = parsed.DNSNames
+			certificate.EmailAddresses = parsed.EmailAddresses
+			for _, uri := range parsed.URIs {
+				certificate.URIs = append(certificate.URIs, uri.String())
+			}
+			certificate.NotBefore = parsed.NotBefore
+			certificate.NotAfter = parsed.NotAfter
+		}
+		certificates = append(certificates, certificate)
+	}
+	return certificates
+}
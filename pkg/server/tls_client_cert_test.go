@@ -0,0 +1,78 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"math/big"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// newTestCertificate returns a self-signed certificate for commonName
+func newTestCertificate(t *testing.T, commonName string) tls.Certificate {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.Nil(t, err, "could not generate key")
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(42),
+		Subject:      pkix.Name{CommonName: commonName, Organization: []string{"Internal"}},
+		DNSNames:     []string{commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	require.Nil(t, err, "could not create certificate")
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestClientCertificateCapture(t *testing.T) {
+	registry := newFingerprintRegistry()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen")
+	defer listener.Close()
+
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, "oast.example")}}
+	tlsListener := tls.NewListener(registry.Listener(listener), registry.TLSConfig(serverConfig))
+
+	for _, clientCertificates := range [][]tls.Certificate{nil, {newTestCertificate(t, "billing.internal")}} {
+		handshakes := make(chan net.Conn, 1)
+		go func() {
+			conn, err := tlsListener.Accept()
+			if err != nil {
+				close(handshakes)
+				return
+			}
+			_ = conn.(*tls.Conn).Handshake()
+			handshakes <- conn
+		}()
+
+		client, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, Certificates: clientCertificates})
+		require.Nil(t, err, "could not complete handshake")
+		_, _ = client.Write([]byte("ping"))
+
+		conn := <-handshakes
+		require.NotNil(t, conn, "could not accept connection")
+		info := registry.Get(conn.RemoteAddr().String())
+		require.NotNil(t, info, "could not get tls info")
+		if clientCertificates == nil {
+			require.Empty(t, info.ClientCertificates, "could get client certificates without any presented")
+		} else {
+			require.Len(t, info.ClientCertificates, 1, "could not get client certificates")
+			certificate := info.ClientCertificates[0]
+			require.Equal(t, "CN=billing.internal,O=Internal", certificate.Subject, "could not get subject")
+			require.Equal(t, certificate.Subject, certificate.Issuer, "could not get issuer")
+			require.Equal(t, []string{"billing.internal"}, certificate.DNSNames, "could not get dns names")
+			require.Equal(t, "2a", certificate.SerialNumber, "could not get serial number")
+			require.Len(t, certificate.SHA256, 64, "could not get sha256 fingerprint")
+		}
+		_ = client.Close()
+		_ = conn.Close()
+	}
+}
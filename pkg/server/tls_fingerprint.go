@@ -0,0 +1,374 @@
+package server
+
+import (
+	"crypto/md5"
+	"crypto/tls"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// TLSInfo contains the parameters of the TLS handshake for an interaction
+type TLSInfo = types.TLSInfo
+
+const (
+	tlsRecordHeaderLength     = 5
+	tlsRecordTypeHandshake    = 0x16
+	tlsHandshakeClientHello   = 0x01
+	tlsHandshakeServerHello   = 0x02
+	tlsMaxRecordLength        = 16384 + 2048
+	tlsExtensionServerName    = 0
+	tlsExtensionGroups        = 10
+	tlsExtensionPointFormats  = 11
+	tlsExtensionALPN          = 16
+	tlsHelloRandomLength      = 32
+	tlsCipherSuiteValueLength = 2
+)
+
+// fingerprintRegistry keeps track of the TLS handshakes of the
+// open connections, indexed by remote address.
+type fingerprintRegistry struct {
+	sync.RWMutex
+	conns map[string]*fingerprintConn
+	// abandoned is called with the TLS information of the connections closed
+	// without being served, if any
+	abandoned func(remoteAddr net.Addr, info *TLSInfo)
+}
+
+func newFingerprintRegistry() *fingerprintRegistry {
+	return &fingerprintRegistry{conns: make(map[string]*fingerprintConn)}
+}
+
+// Listener wraps a listener so that the handshakes of the accepted connections are recorded
+func (r *fingerprintRegistry) Listener(listener net.Listener) net.Listener {
+	return &fingerprintListener{Listener: listener, registry: r}
+}
+
+// Get returns the TLS information for the connection from remoteAddr, if any,
+// marking the connection as served.
+func (r *fingerprintRegistry) Get(remoteAddr string) *TLSInfo {
+	conn := r.serve(remoteAddr)
+	if conn == nil {
+		return nil
+	}
+	return conn.info()
+}
+
+// serve marks the connection from remoteAddr as served by the application layer
+func (r *fingerprintRegistry) serve(remoteAddr string) *fingerprintConn {
+	r.RLock()
+	conn, ok := r.conns[remoteAddr]
+	r.RUnlock()
+	if !ok {
+		return nil
+	}
+	conn.mutex.Lock()
+	conn.served = true
+	conn.mutex.Unlock()
+	return conn
+}
+
+func (r *fingerprintRegistry) add(conn *fingerprintConn) {
+	r.Lock()
+	r.conns[conn.RemoteAddr().String()] = conn
+	r.Unlock()
+}
+
+func (r *fingerprintRegistry) remove(conn *fingerprintConn) {
+	r.Lock()
+	if r.conns[conn.RemoteAddr().String()] == conn {
+		delete(r.conns, conn.RemoteAddr().String())
+	}
+	r.Unlock()
+}
+
+type fingerprintListener struct {
+	net.Listener
+	registry *fingerprintRegistry
+}
+
+func (l *fingerprintListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	fingerprinted := &fingerprintConn{Conn: conn, registry: l.registry}
+	l.registry.add(fingerprinted)
+	return fingerprinted, nil
+}
+
+// fingerprintConn records the first handshake record
+// read (client hello) and written (server hello).
+type fingerprintConn struct {
+	net.Conn
+	registry *fingerprintRegistry
+
+	mutex       sync.Mutex
+	clientHello handshakeRecorder
+	serverHello handshakeRecorder
+	closeOnce   sync.Once
+	// served is set once a request of the connection reached the application layer
+	served bool
+	// clientCertificates is the certificate chain presented by the client, if requested
+	clientCertificates [][]byte
+}
+
+func (c *fingerprintConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	if n > 0 {
+		c.mutex.Lock()
+		c.clientHello.record(b[:n])
+		c.mutex.Unlock()
+	}
+	return n, err
+}
+
+func (c *fingerprintConn) Write(b []byte) (int, error) {
+	c.mutex.Lock()
+	c.serverHello.record(b)
+	c.mutex.Unlock()
+	return c.Conn.Write(b)
+}
+
+func (c *fingerprintConn) Close() error {
+	c.closeOnce.Do(func() {
+		c.registry.remove(c)
+		if c.registry.abandoned == nil {
+			return
+		}
+		c.mutex.Lock()
+		served := c.served
+		c.mutex.Unlock()
+		if info := c.info(); !served && info != nil && info.SNI != "" {
+			c.registry.abandoned(c.RemoteAddr(), info)
+		}
+	})
+	return c.Conn.Close()
+}
+
+// info returns the TLS information parsed from the recorded handshake
+func (c *fingerprintConn) info() *TLSInfo {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.clientHello.complete() {
+		return nil
+	}
+	info, err := parseClientHello(c.clientHello.handshake())
+	if err != nil {
+		return nil
+	}
+	if c.serverHello.complete() {
+		if ja3s, err := parseServerHello(c.serverHello.handshake()); err == nil {
+			info.JA3S = ja3s
+			info.JA3SHash = md5Hex(ja3s)
+		}
+	}
+	if len(c.clientCertificates) > 0 {
+		info.ClientCertificates = parseClientCertificates(c.clientCertificates)
+	}
+	return info
+}
+
+// handshakeRecorder accumulates the first TLS handshake record of a stream.
+// Plaintext data preceding the record (eg. SMTP STARTTLS) is ignored.
+type handshakeRecorder struct {
+	data []byte
+	done bool
+}
+
+func (h *handshakeRecorder) record(b []byte) {
+	if h.done {
+		return
+	}
+	if len(h.data) == 0 {
+		if len(b) < 3 || b[0] != tlsRecordTypeHandshake || b[1] != 0x03 {
+			return
+		}
+	}
+	h.data = append(h.data, b...)
+	if h.complete() || len(h.data) > tlsMaxRecordLength {
+		h.done = true
+	}
+}
+
+func (h *handshakeRecorder) complete() bool {
+	if len(h.data) < tlsRecordHeaderLength {
+		return false
+	}
+	return len(h.data) >= tlsRecordHeaderLength+int(binary.BigEndian.Uint16(h.data[3:5]))
+}
+
+// handshake returns the body of the recorded handshake record
+func (h *handshakeRecorder) handshake() []byte {
+	return h.data[tlsRecordHeaderLength : tlsRecordHeaderLength+int(binary.BigEndian.Uint16(h.data[3:5]))]
+}
+
+// byteReader is a bounds checked reader for handshake messages
+type byteReader struct {
+	data []byte
+	err  error
+}
+
+func (r *byteReader) bytes(n int) []byte {
+	if r.err != nil || n > len(r.data) {
+		r.err = fmt.Errorf("truncated handshake message")
+		return nil
+	}
+	value := r.data[:n]
+	r.data = r.data[n:]
+	return value
+}
+
+func (r *byteReader) uint8() int {
+	if value := r.bytes(1); value != nil {
+		return int(value[0])
+	}
+	return 0
+}
+
+func (r *byteReader) uint16() int {
+	if value := r.bytes(2); value != nil {
+		return int(binary.BigEndian.Uint16(value))
+	}
+	return 0
+}
+
+func (r *byteReader) uint24() int {
+	if value := r.bytes(3); value != nil {
+		return int(value[0])<<16 | int(value[1])<<8 | int(value[2])
+	}
+	return 0
+}
+
+// parseClientHello parses a client hello handshake message and computes its JA3 fingerprint
+func parseClientHello(data []byte) (*TLSInfo, error) {
+	reader := &byteReader{data: data}
+	if reader.uint8() != tlsHandshakeClientHello {
+		return nil, fmt.Errorf("not a client hello")
+	}
+	reader = &byteReader{data: reader.bytes(reader.uint24())}
+	version := reader.uint16()
+	reader.bytes(tlsHelloRandomLength)
+	reader.bytes(reader.uint8())
+
+	info := &TLSInfo{}
+	var ciphers, extensions, groups, pointFormats []string
+	cipherReader := &byteReader{data: reader.bytes(reader.uint16())}
+	for len(cipherReader.data) >= tlsCipherSuiteValueLength {
+		cipher := uint16(cipherReader.uint16())
+		if isGREASE(cipher) {
+			continue
+		}
+		ciphers = append(ciphers, strconv.Itoa(int(cipher)))
+		info.CipherSuites = append(info.CipherSuites, tls.CipherSuiteName(cipher))
+	}
+	reader.bytes(reader.uint8())
+	if reader.err != nil {
+		return nil, reader.err
+	}
+
+	// extensions are optional
+	if len(reader.data) > 0 {
+		extensionsReader := &byteReader{data: reader.bytes(reader.uint16())}
+		for len(extensionsReader.data) > 0 && extensionsReader.err == nil {
+			extensionType := uint16(extensionsReader.uint16())
+			extensionReader := &byteReader{data: extensionsReader.bytes(extensionsReader.uint16())}
+			if isGREASE(extensionType) {
+				continue
+			}
+			extensions = append(extensions, strconv.Itoa(int(extensionType)))
+
+			switch extensionType {
+			case tlsExtensionServerName:
+				namesReader := &byteReader{data: extensionReader.bytes(extensionReader.uint16())}
+				for len(namesReader.data) > 0 && namesReader.err == nil {
+					nameType := namesReader.uint8()
+					name := namesReader.bytes(namesReader.uint16())
+					if nameType == 0 && namesReader.err == nil {
+						info.SNI = string(name)
+					}
+				}
+			case tlsExtensionGroups:
+				groupsReader := &byteReader{data: extensionReader.bytes(extensionReader.uint16())}
+				for len(groupsReader.data) >= 2 {
+					group := uint16(groupsReader.uint16())
+					if !isGREASE(group) {
+						groups = append(groups, strconv.Itoa(int(group)))
+					}
+				}
+			case tlsExtensionPointFormats:
+				for _, pointFormat := range extensionReader.bytes(extensionReader.uint8()) {
+					pointFormats = append(pointFormats, strconv.Itoa(int(pointFormat)))
+				}
+			case tlsExtensionALPN:
+				protocolsReader := &byteReader{data: extensionReader.bytes(extensionReader.uint16())}
+				for len(protocolsReader.data) > 0 && protocolsReader.err == nil {
+					if protocol := protocolsReader.bytes(protocolsReader.uint8()); protocolsReader.err == nil {
+						info.ALPN = append(info.ALPN, string(protocol))
+					}
+				}
+			}
+		}
+		if extensionsReader.err != nil {
+			return nil, extensionsReader.err
+		}
+	}
+
+	info.JA3 = strings.Join([]string{
+		strconv.Itoa(version),
+		strings.Join(ciphers, "-"),
+		strings.Join(extensions, "-"),
+		strings.Join(groups, "-"),
+		strings.Join(pointFormats, "-"),
+	}, ",")
+	info.JA3Hash = md5Hex(info.JA3)
+	return info, nil
+}
+
+// parseServerHello parses a server hello handshake message and returns its JA3S string
+func parseServerHello(data []byte) (string, error) {
+	reader := &byteReader{data: data}
+	if reader.uint8() != tlsHandshakeServerHello {
+		return "", fmt.Errorf("not a server hello")
+	}
+	reader = &byteReader{data: reader.bytes(reader.uint24())}
+	version := reader.uint16()
+	reader.bytes(tlsHelloRandomLength)
+	reader.bytes(reader.uint8())
+	cipher := reader.uint16()
+	reader.uint8()
+	if reader.err != nil {
+		return "", reader.err
+	}
+
+	var extensions []string
+	if len(reader.data) > 0 {
+		extensionsReader := &byteReader{data: reader.bytes(reader.uint16())}
+		for len(extensionsReader.data) > 0 && extensionsReader.err == nil {
+			extensionType := extensionsReader.uint16()
+			extensionsReader.bytes(extensionsReader.uint16())
+			extensions = append(extensions, strconv.Itoa(extensionType))
+		}
+		if extensionsReader.err != nil {
+			return "", extensionsReader.err
+		}
+	}
+	return fmt.Sprintf("%d,%d,%s", version, cipher, strings.Join(extensions, "-")), nil
+}
+
+// isGREASE returns true for the reserved GREASE values (RFC8701) which are ignored by JA3
+func isGREASE(value uint16) bool {
+	return value&0x0f0f == 0x0a0a && value>>8 == value&0xff
+}
+
+func md5Hex(value string) string {
+	hash := md5.Sum([]byte(value))
+	return hex.EncodeToString(hash[:])
+}
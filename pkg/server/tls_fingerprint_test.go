@@ -0,0 +1,45 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseClientHello(t *testing.T) {
+	clientConn, serverConn := net.Pipe()
+	defer serverConn.Close()
+
+	go func() {
+		client := tls.Client(clientConn, &tls.Config{ServerName: "test.example.com", NextProtos: []string{"h2", "http/1.1"}, InsecureSkipVerify: true})
+		_ = client.Handshake()
+	}()
+
+	recorder := &handshakeRecorder{}
+	buffer := make([]byte, 1024)
+	for !recorder.complete() {
+		n, err := serverConn.Read(buffer)
+		require.Nil(t, err, "could not read client hello")
+		recorder.record(buffer[:n])
+	}
+	_ = clientConn.Close()
+
+	info, err := parseClientHello(recorder.handshake())
+	require.Nil(t, err, "could not parse client hello")
+	require.Equal(t, "test.example.com", info.SNI, "could not get sni")
+	require.Equal(t, []string{"h2", "http/1.1"}, info.ALPN, "could not get alpn")
+	require.NotEmpty(t, info.CipherSuites, "could not get cipher suites")
+	require.True(t, strings.HasPrefix(info.JA3, "771,"), "could not get ja3 version")
+	require.Len(t, strings.Split(info.JA3, ","), 5, "could not get ja3 fields")
+	require.Equal(t, md5Hex(info.JA3), info.JA3Hash, "could not get ja3 hash")
+}
+
+func TestIsGREASE(t *testing.T) {
+	require.True(t, isGREASE(0x0a0a), "could not detect grease value")
+	require.True(t, isGREASE(0xfafa), "could not detect grease value")
+	require.False(t, isGREASE(0x0a1a), "could not ignore non grease value")
+	require.False(t, isGREASE(0x1301), "could not ignore non grease value")
+}
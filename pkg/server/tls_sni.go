@@ -0,0 +1,32 @@
+package server
+
+import (
+	"net"
+	"sync/atomic"
+)
+
+// ProtocolTLS is the protocol of the interactions of the TLS connections
+// presenting a correlation id in their SNI, closed before any application-layer
+// request was served (abandoned handshake, protocol mismatch).
+const ProtocolTLS = "tls"
+
+// sniHandler returns the hook of the fingerprint registries recording the
+// abandoned TLS connections with a correlation id in their SNI, so that the
+// callbacks which never complete a request are not lost.
+func (options *Options) sniHandler() func(remoteAddr net.Addr, info *TLSInfo) {
+	return func(remoteAddr net.Addr, info *TLSInfo) {
+		if len(options.findUniqueIDs(info.SNI)) == 0 {
+			return
+		}
+		if options.Stats != nil {
+			atomic.AddUint64(&options.Stats.Tls, 1)
+		}
+		host, _, _ := net.SplitHostPort(remoteAddr.String())
+		interaction := &Interaction{
+			Protocol:      ProtocolTLS,
+			TLS:           info,
+			RemoteAddress: host,
+		}
+		options.RecordInteraction(interaction, info.SNI)
+	}
+}
@@ -0,0 +1,77 @@
+package server
+
+import (
+	"crypto/tls"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSNIOnlyInteraction(t *testing.T) {
+	correlationID := "c8rf4e8xm4c8rf4e8xm4"
+	store := newTestStorage(t, correlationID, "secret")
+	options := &Options{CorrelationIdLength: 20, CorrelationIdNonceLength: 13, Storage: store, Stats: &Metrics{}}
+
+	registry := newFingerprintRegistry()
+	registry.abandoned = options.sniHandler()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err, "could not listen")
+	defer listener.Close()
+	fingerprintListener := registry.Listener(listener)
+	serverConfig := &tls.Config{Certificates: []tls.Certificate{newTestCertificate(t, "oast.example")}}
+
+	// accept serves a connection with the tls handshake or, like smtps, in plaintext
+	accept := func(handshake, serve bool) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			defer close(done)
+			conn, err := fingerprintListener.Accept()
+			if err != nil {
+				return
+			}
+			if handshake {
+				_ = tls.Server(conn, serverConfig).Handshake()
+			} else {
+				_, _ = conn.Read(make([]byte, 4096))
+			}
+			if serve {
+				registry.Get(conn.RemoteAddr().String())
+			}
+			_ = conn.Close()
+		}()
+		return done
+	}
+	dial := func(serverName string) {
+		client, err := tls.Dial("tcp", listener.Addr().String(), &tls.Config{InsecureSkipVerify: true, ServerName: serverName})
+		if err == nil {
+			_ = client.Close()
+		}
+	}
+	count := func() int {
+		interactions, _, err := store.GetInteractions(correlationID, "secret")
+		require.Nil(t, err, "could not get interactions")
+		return len(interactions)
+	}
+
+	done := accept(true, false)
+	dial(correlationID + "abcdefghijklm.oast.example")
+	<-done
+	require.Equal(t, 1, count(), "could not record abandoned handshake")
+
+	done = accept(false, false)
+	dial("x." + correlationID + "abcdefghijklm.oast.example")
+	<-done
+	require.Equal(t, 1, count(), "could not record protocol mismatch")
+
+	done = accept(true, true)
+	dial(correlationID + "abcdefghijklm.oast.example")
+	<-done
+	require.Equal(t, 0, count(), "could record served connection")
+
+	done = accept(true, false)
+	dial("oast.example")
+	<-done
+	require.Equal(t, 0, count(), "could record sni without correlation id")
+	require.Equal(t, uint64(2), options.Stats.Tls, "could not count tls interactions")
+}
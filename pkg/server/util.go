@@ -4,9 +4,22 @@ import (
 	"strings"
 
 	"github.com/asaskevich/govalidator"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/settings"
 	"github.com/rs/xid"
 )
 
+// correlationIDFromDomain returns the correlation id of the first unique id
+// found in the labels of domain, or an empty string if there is none.
+func (options *Options) correlationIDFromDomain(domain string) string {
+	for _, part := range strings.Split(domain, ".") {
+		if options.isCorrelationID(part) {
+			return strings.ToLower(part[:options.CorrelationIdLength])
+		}
+	}
+	return ""
+}
+
 func (options *Options) isCorrelationID(s string) bool {
 	if len(s) == options.GetIdLength() && govalidator.IsAlphanumeric(s) {
 		// xid should be 12
@@ -18,3 +31,26 @@ func (options *Options) isCorrelationID(s string) bool {
 	}
 	return false
 }
+
+// nonceEncodings returns the names of the nonce encodings accepted from the clients
+func (options *Options) nonceEncodings() []string {
+	if len(options.NonceEncodings) == 0 {
+		return settings.NonceEncodingNames()
+	}
+	return options.NonceEncodings
+}
+
+// checkNonceEncoding returns an error if the nonce encoding of a client is
+// invalid or not accepted by the server
+func (options *Options) checkNonceEncoding(encoding string) error {
+	if _, err := settings.NonceAlphabet(encoding); err != nil {
+		return err
+	}
+	name := settings.NonceEncodingName(encoding)
+	for _, accepted := range options.nonceEncodings() {
+		if strings.EqualFold(accepted, name) {
+			return nil
+		}
+	}
+	return errors.Errorf("unsupported nonce encoding %s, server accepts %s", name, strings.Join(options.nonceEncodings(), ","))
+}
@@ -3,4 +3,7 @@ package settings
 const (
 	CorrelationIdLengthDefault      = 20
 	CorrelationIdNonceLengthDefault = 13
+	// IPv6OnlyLabel is the label preceding the domain of the interaction hosts
+	// resolved only to the ipv6 address of the server
+	IPv6OnlyLabel = "v6"
 )
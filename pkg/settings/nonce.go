@@ -0,0 +1,79 @@
+package settings
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const (
+	// NonceEncodingDefault is the encoding of the nonces unless negotiated otherwise
+	NonceEncodingDefault = "zbase32"
+	// NonceEncodingCustom prefixes the custom alphabets of the nonces, e.g. custom:0123456789
+	NonceEncodingCustom = "custom"
+	// minNonceAlphabetLength is the minimum number of characters of a nonce alphabet
+	minNonceAlphabetLength = 8
+)
+
+// NonceEncodings are the alphabets of the named nonce encodings. All of them
+// are lowercase alphanumeric, as the hostnames are matched case insensitively.
+var NonceEncodings = map[string]string{
+	NonceEncodingDefault: "ybndrfg8ejkmcpqxot1uwisza345h769",
+	"hex":                "0123456789abcdef",
+	"base36":             "0123456789abcdefghijklmnopqrstuvwxyz",
+}
+
+// NonceEncodingNames returns the sorted names of the nonce encodings, custom included
+func NonceEncodingNames() []string {
+	names := make([]string, 0, len(NonceEncodings)+1)
+	for name := range NonceEncodings {
+		names = append(names, name)
+	}
+	names = append(names, NonceEncodingCustom)
+	sort.Strings(names)
+	return names
+}
+
+// NonceAlphabet returns the alphabet of a nonce encoding, which is either a
+// named encoding or custom:<alphabet> with distinct lowercase letters and digits.
+// An empty encoding is the default one.
+func NonceAlphabet(encoding string) (string, error) {
+	encoding = strings.ToLower(encoding)
+	if encoding == "" {
+		encoding = NonceEncodingDefault
+	}
+	if alphabet, ok := NonceEncodings[encoding]; ok {
+		return alphabet, nil
+	}
+	if !strings.HasPrefix(encoding, NonceEncodingCustom+":") {
+		return "", fmt.Errorf("unknown nonce encoding %s (%s)", encoding, strings.Join(NonceEncodingNames(), ","))
+	}
+	alphabet := strings.TrimPrefix(encoding, NonceEncodingCustom+":")
+	if len(alphabet) < minNonceAlphabetLength {
+		return "", fmt.Errorf("custom nonce alphabet must have at least %d characters", minNonceAlphabetLength)
+	}
+	seen := make(map[rune]struct{}, len(alphabet))
+	for _, r := range alphabet {
+		if (r < 'a' || r > 'z') && (r < '0' || r > '9') {
+			return "", fmt.Errorf("invalid character %q in custom nonce alphabet", r)
+		}
+		if _, ok := seen[r]; ok {
+			return "", fmt.Errorf("duplicate character %q in custom nonce alphabet", r)
+		}
+		seen[r] = struct{}{}
+	}
+	return alphabet, nil
+}
+
+// NonceEncodingName returns the name of a nonce encoding as negotiated with
+// the server, custom for all the custom alphabets.
+func NonceEncodingName(encoding string) string {
+	encoding = strings.ToLower(encoding)
+	if encoding == "" {
+		return NonceEncodingDefault
+	}
+	if strings.HasPrefix(encoding, NonceEncodingCustom+":") {
+		return NonceEncodingCustom
+	}
+	return encoding
+}
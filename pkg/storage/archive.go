@@ -0,0 +1,186 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Archiver stores the interactions purged from the history
+type Archiver interface {
+	// Archive stores the compressed interactions under name
+	Archive(name string, data []byte) error
+}
+
+// NewArchiver returns the archiver of a destination, which is either a local
+// directory or a s3://bucket/prefix url. The s3 credentials and region are read
+// from the standard AWS environment variables, endpoint allowing to use any
+// S3-compatible storage.
+func NewArchiver(destination, endpoint string) (Archiver, error) {
+	if !strings.HasPrefix(destination, "s3://") {
+		if err := os.MkdirAll(destination, 0700); err != nil {
+			return nil, errors.Wrap(err, "could not create archive directory")
+		}
+		return &FileArchiver{Directory: destination}, nil
+	}
+
+	bucket, prefix := strings.TrimPrefix(destination, "s3://"), ""
+	if index := strings.Index(bucket, "/"); index != -1 {
+		bucket, prefix = bucket[:index], strings.Trim(bucket[index+1:], "/")
+	}
+	if bucket == "" {
+		return nil, errors.New("no s3 bucket specified")
+	}
+	archiver := &S3Archiver{
+		Bucket:       bucket,
+		Prefix:       prefix,
+		Region:       firstEnv("AWS_REGION", "AWS_DEFAULT_REGION"),
+		AccessKey:    os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey:    os.Getenv("AWS_SECRET_ACCESS_KEY"),
+		SessionToken: os.Getenv("AWS_SESSION_TOKEN"),
+		Endpoint:     endpoint,
+	}
+	if archiver.AccessKey == "" || archiver.SecretKey == "" {
+		return nil, errors.New("no s3 credentials in AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	if archiver.Region == "" {
+		archiver.Region = "us-east-1"
+	}
+	if archiver.Endpoint == "" {
+		archiver.Endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", archiver.Region)
+	}
+	if _, err := url.Parse(archiver.Endpoint); err != nil {
+		return nil, errors.Wrap(err, "invalid s3 endpoint")
+	}
+	return archiver, nil
+}
+
+func firstEnv(names ...string) string {
+	for _, name := range names {
+		if value := os.Getenv(name); value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// FileArchiver archives the interactions in a local directory
+type FileArchiver struct {
+	Directory string
+}
+
+// Archive writes the interactions to a file of the directory
+func (a *FileArchiver) Archive(name string, data []byte) error {
+	return ioutil.WriteFile(filepath.Join(a.Directory, name), data, 0600)
+}
+
+// S3Archiver archives the interactions in a bucket of a S3-compatible storage
+type S3Archiver struct {
+	Endpoint     string
+	Bucket       string
+	Prefix       string
+	Region       string
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	HTTPClient   *http.Client
+}
+
+// s3Timeout is the time allowed to upload an archive
+const s3Timeout = 5 * time.Minute
+
+// Archive uploads the interactions as an object of the bucket
+func (a *S3Archiver) Archive(name string, data []byte) error {
+	key := name
+	if a.Prefix != "" {
+		key = a.Prefix + "/" + name
+	}
+	// path-style urls are supported by all the S3-compatible storages
+	objectURL := strings.TrimSuffix(a.Endpoint, "/") + "/" + a.Bucket + "/" + key
+	req, err := http.NewRequest(http.MethodPut, objectURL, bytes.NewReader(data))
+	if err != nil {
+		return errors.Wrap(err, "could not create s3 request")
+	}
+	req.Header.Set("Content-Type", "application/gzip")
+	if a.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", a.SessionToken)
+	}
+	payloadHash := sha256.Sum256(data)
+	a.sign(req, hex.EncodeToString(payloadHash[:]), time.Now())
+
+	client := a.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: s3Timeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "could not upload archive")
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return errors.Errorf("could not upload archive: %d %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+// sign adds the AWS signature version 4 of the request, covering all its headers
+func (a *S3Archiver) sign(req *http.Request, payloadHash string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	scope := date + "/" + a.Region + "/s3/aws4_request"
+	canonicalHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(canonicalHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+a.SecretKey), date)
+	key = hmacSHA256(key, a.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s", a.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	_, _ = mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
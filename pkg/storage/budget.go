@@ -0,0 +1,89 @@
+package storage
+
+import "sync/atomic"
+
+// OverflowMetrics are the counters of the bounded interaction buffers, cheap
+// enough to be collected on every metrics request.
+type OverflowMetrics struct {
+	// Policy is the overflow policy applied by the quotas and the memory budget
+	Policy string `json:"policy"`
+	// QuotaDropped is the number of interactions dropped by the per correlation-id quotas
+	QuotaDropped uint64 `json:"quota-dropped"`
+	// BudgetDropped is the number of interactions dropped by the global memory budget
+	BudgetDropped uint64 `json:"budget-dropped"`
+	// PendingBytes is the size of the interactions pending in memory
+	PendingBytes int64 `json:"pending-bytes"`
+	// MemoryBudget is the maximum size of the interactions pending in memory (unlimited if zero)
+	MemoryBudget int64 `json:"memory-budget"`
+}
+
+// GetOverflowMetrics returns the counters of the quotas and of the memory budget
+func (s *StorageDB) GetOverflowMetrics() *OverflowMetrics {
+	return &OverflowMetrics{
+		Policy:        s.Options.OverflowPolicy,
+		QuotaDropped:  atomic.LoadUint64(&s.counters.quotaDropped),
+		BudgetDropped: atomic.LoadUint64(&s.counters.budgetDropped),
+		PendingBytes:  atomic.LoadInt64(&s.pendingBytes),
+		MemoryBudget:  s.Options.MaxMemory,
+	}
+}
+
+// addPending accounts size bytes of interactions pending in memory for value.
+// The correlation data has to be locked by the caller.
+func (s *StorageDB) addPending(value *CorrelationData, size int) {
+	value.pending += size
+	atomic.AddInt64(&s.pendingBytes, int64(size))
+}
+
+// releasePending releases the pending bytes accounted for value, once its
+// interactions are delivered or removed. The correlation data has to be locked
+// by the caller.
+func (s *StorageDB) releasePending(value *CorrelationData) {
+	if value.pending == 0 {
+		return
+	}
+	atomic.AddInt64(&s.pendingBytes, -int64(value.pending))
+	value.pending = 0
+}
+
+// trimPending removes the count oldest interactions pending in memory for value.
+// The correlation data has to be locked by the caller.
+func (s *StorageDB) trimPending(value *CorrelationData, count int) {
+	if count <= 0 {
+		return
+	}
+	size := 0
+	for _, item := range value.Data[:count] {
+		size += len(item)
+	}
+	value.Data = value.Data[count:]
+	value.sequences = value.sequences[count:]
+	s.addPending(value, -size)
+}
+
+// applyBudget returns the number of oldest pending interactions of value to drop
+// for storing an item of size within the global memory budget, and whether the
+// item has to be stored at all. Only the interactions of the correlation-id
+// receiving the item are dropped, so that a flood on one correlation-id never
+// evicts the interactions of the others. The correlation data has to be locked
+// by the caller.
+func (s *StorageDB) applyBudget(value *CorrelationData, size int) (int, bool) {
+	budget := s.Options.MaxMemory
+	excess := atomic.LoadInt64(&s.pendingBytes) + int64(size) - budget
+	if excess <= 0 {
+		return 0, true
+	}
+	if s.Options.OverflowPolicy != OverflowDropOldest || int64(size) > budget {
+		return 0, false
+	}
+	drop := 0
+	for drop < len(value.Data) && excess > 0 {
+		excess -= int64(len(value.Data[drop]))
+		drop++
+	}
+	if excess > 0 {
+		// the other correlation-ids use the budget, the new item is dropped
+		return 0, false
+	}
+	return drop, true
+}
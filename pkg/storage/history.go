@@ -0,0 +1,233 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"net"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+const (
+	// DefaultSearchLimit is the number of interactions returned by a search without limit
+	DefaultSearchLimit = 100
+	// MaxSearchLimit is the maximum number of interactions returned by a search
+	MaxSearchLimit = 1000
+)
+
+// historyKeySize is the size of the history keys, made of the timestamp of
+// the interaction and a sequence number, so that they are sorted by time.
+const historyKeySize = 16
+
+// History keeps all the interactions received by the server on disk, in clear,
+// so that they can be searched after having been delivered to the clients.
+type History struct {
+	db       *leveldb.DB
+	sequence uint64
+	// size is the size of the keys and values of the history
+	size int64
+}
+
+// SearchQuery contains the filters of a history search
+type SearchQuery struct {
+	// CorrelationID restricts results to the unique ids starting with it
+	CorrelationID string
+	// Protocol restricts results to the given protocol
+	Protocol string
+	// Remote restricts results to a remote ip address or cidr range
+	Remote string
+	// From restricts results to the interactions received since then
+	From time.Time
+	// To restricts results to the interactions received before then
+	To time.Time
+	// Text is a case insensitive text searched in the raw request
+	Text string
+	// Tenant restricts results to the given tenant
+	Tenant string
+	// Limit is the maximum number of returned interactions
+	Limit int
+	// Cursor is the cursor returned by the previous page of the search
+	Cursor string
+}
+
+// SearchResult is a page of the interactions matching a search
+type SearchResult struct {
+	Interactions []*types.Interaction `json:"interactions"`
+	// Next is the cursor of the next page, empty on the last page
+	Next string `json:"next,omitempty"`
+}
+
+// OpenHistory opens or creates the interactions history at path
+func OpenHistory(path string) (*History, error) {
+	db, err := leveldb.OpenFile(path, &opt.Options{})
+	if err != nil {
+		return nil, errors.Wrap(err, "could not open history")
+	}
+	history := &History{db: db}
+	iter := db.NewIterator(nil, nil)
+	for iter.Next() {
+		history.size += int64(len(iter.Key()) + len(iter.Value()))
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		_ = db.Close()
+		return nil, errors.Wrap(err, "could not read history")
+	}
+	return history, nil
+}
+
+// Add records an interaction in the history
+func (h *History) Add(interaction *types.Interaction) error {
+	data, err := jsoniter.Marshal(interaction)
+	if err != nil {
+		return errors.Wrap(err, "could not marshal interaction")
+	}
+	if err := h.db.Put(h.key(interaction.Timestamp), data, nil); err != nil {
+		return err
+	}
+	atomic.AddInt64(&h.size, int64(historyKeySize+len(data)))
+	return nil
+}
+
+// Size returns the size of the interactions in the history
+func (h *History) Size() int64 {
+	return atomic.LoadInt64(&h.size)
+}
+
+func (h *History) key(timestamp time.Time) []byte {
+	key := make([]byte, historyKeySize)
+	binary.BigEndian.PutUint64(key, uint64(timestamp.UnixNano()))
+	binary.BigEndian.PutUint64(key[8:], atomic.AddUint64(&h.sequence, 1))
+	return key
+}
+
+// Search returns a page of the interactions matching the query, oldest first
+func (h *History) Search(query *SearchQuery) (*SearchResult, error) {
+	match, err := newSearchMatcher(query)
+	if err != nil {
+		return nil, err
+	}
+	limit := query.Limit
+	if limit <= 0 {
+		limit = DefaultSearchLimit
+	}
+	if limit > MaxSearchLimit {
+		limit = MaxSearchLimit
+	}
+
+	keyRange := &util.Range{}
+	if !query.From.IsZero() {
+		keyRange.Start = timeKey(query.From)
+	}
+	if !query.To.IsZero() {
+		keyRange.Limit = timeKey(query.To)
+	}
+	if query.Cursor != "" {
+		cursor, err := hex.DecodeString(query.Cursor)
+		if err != nil || len(cursor) != historyKeySize {
+			return nil, errors.New("invalid cursor")
+		}
+		// the range starts right after the last returned key
+		keyRange.Start = append(cursor, 0)
+	}
+
+	result := &SearchResult{Interactions: []*types.Interaction{}}
+	var last []byte
+	iter := h.db.NewIterator(keyRange, nil)
+	defer iter.Release()
+
+	for iter.Next() {
+		interaction := &types.Interaction{}
+		if err := jsoniter.Unmarshal(iter.Value(), interaction); err != nil {
+			continue
+		}
+		if !match(interaction) {
+			continue
+		}
+		if len(result.Interactions) == limit {
+			result.Next = hex.EncodeToString(last)
+			break
+		}
+		result.Interactions = append(result.Interactions, interaction)
+		// the iterator key is only valid until the next iteration
+		last = append(last[:0], iter.Key()...)
+	}
+	if err := iter.Error(); err != nil {
+		return nil, errors.Wrap(err, "could not iterate history")
+	}
+	return result, nil
+}
+
+// Close closes the history
+func (h *History) Close() error {
+	return h.db.Close()
+}
+
+func timeKey(t time.Time) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(t.UnixNano()))
+	return key
+}
+
+// newSearchMatcher returns a function matching the interactions with the
+// filters of the query, other than the time range.
+func newSearchMatcher(query *SearchQuery) (func(*types.Interaction) bool, error) {
+	var network *net.IPNet
+	if query.Remote != "" {
+		remote := query.Remote
+		if !strings.Contains(remote, "/") {
+			ip := net.ParseIP(remote)
+			if ip == nil {
+				return nil, errors.Errorf("invalid remote address %s", remote)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			network = &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)}
+		} else {
+			_, ipNet, err := net.ParseCIDR(remote)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid remote range %s", remote)
+			}
+			network = ipNet
+		}
+	}
+	correlationID := strings.ToLower(query.CorrelationID)
+	text := strings.ToLower(query.Text)
+
+	return func(interaction *types.Interaction) bool {
+		if correlationID != "" && !strings.HasPrefix(strings.ToLower(interaction.UniqueID), correlationID) {
+			return false
+		}
+		if query.Protocol != "" && !strings.EqualFold(interaction.Protocol, query.Protocol) {
+			return false
+		}
+		if query.Tenant != "" && interaction.Tenant != query.Tenant {
+			return false
+		}
+		if network != nil && !network.Contains(remoteIP(interaction.RemoteAddress)) {
+			return false
+		}
+		if text != "" && !strings.Contains(strings.ToLower(interaction.RawRequest), text) {
+			return false
+		}
+		return true
+	}, nil
+}
+
+// remoteIP returns the ip of a remote address, which may include a port
+func remoteIP(address string) net.IP {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		address = host
+	}
+	return net.ParseIP(address)
+}
@@ -0,0 +1,88 @@
+package storage
+
+import (
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHistorySearch(t *testing.T) {
+	history, err := OpenHistory(t.TempDir())
+	require.Nil(t, err, "could not open history")
+	defer history.Close()
+
+	now := time.Now()
+	interactions := []*types.Interaction{
+		{Protocol: "dns", UniqueID: "aaaa1234", RemoteAddress: "10.0.0.1", Timestamp: now.Add(-3 * time.Hour)},
+		{Protocol: "http", UniqueID: "bbbb1234", RemoteAddress: "10.0.1.2:4321", RawRequest: "GET /Secret", Timestamp: now.Add(-2 * time.Hour)},
+		{Protocol: "http", UniqueID: "bbbb5678", RemoteAddress: "2001:db8::1", Timestamp: now.Add(-time.Hour)},
+		{Protocol: "smtp", UniqueID: "cccc1234", RemoteAddress: "192.168.0.1", Timestamp: now},
+	}
+	for _, interaction := range interactions {
+		require.Nil(t, history.Add(interaction), "could not add interaction")
+	}
+
+	result, err := history.Search(&SearchQuery{})
+	require.Nil(t, err, "could not search history")
+	require.Len(t, result.Interactions, 4, "could not get all interactions")
+	require.Equal(t, "aaaa1234", result.Interactions[0].UniqueID, "could not get oldest interaction first")
+
+	result, _ = history.Search(&SearchQuery{CorrelationID: "BBBB"})
+	require.Len(t, result.Interactions, 2, "could not filter by correlation id")
+
+	result, _ = history.Search(&SearchQuery{Protocol: "HTTP", Text: "secret"})
+	require.Len(t, result.Interactions, 1, "could not search raw request")
+
+	result, _ = history.Search(&SearchQuery{Remote: "10.0.0.0/16"})
+	require.Len(t, result.Interactions, 2, "could not filter by remote range")
+	result, _ = history.Search(&SearchQuery{Remote: "2001:db8::1"})
+	require.Len(t, result.Interactions, 1, "could not filter by remote address")
+
+	result, _ = history.Search(&SearchQuery{From: now.Add(-150 * time.Minute), To: now.Add(-time.Minute)})
+	require.Len(t, result.Interactions, 2, "could not filter by time range")
+
+	result, _ = history.Search(&SearchQuery{Limit: 3})
+	require.Len(t, result.Interactions, 3, "could not limit results")
+	require.NotEmpty(t, result.Next, "could not get next page cursor")
+	result, _ = history.Search(&SearchQuery{Limit: 3, Cursor: result.Next})
+	require.Len(t, result.Interactions, 1, "could not get next page")
+	require.Equal(t, "cccc1234", result.Interactions[0].UniqueID, "could not resume after cursor")
+	require.Empty(t, result.Next, "could get cursor on last page")
+
+	_, err = history.Search(&SearchQuery{Remote: "invalid"})
+	require.NotNil(t, err, "could search invalid remote")
+	_, err = history.Search(&SearchQuery{Cursor: "zz"})
+	require.NotNil(t, err, "could search invalid cursor")
+}
+
+func TestHistoryPurge(t *testing.T) {
+	history, err := OpenHistory(t.TempDir())
+	require.Nil(t, err, "could not open history")
+	defer history.Close()
+
+	now := time.Now()
+	for i := 4; i >= 0; i-- {
+		interaction := &types.Interaction{Protocol: "dns", UniqueID: "aaaa1234", Timestamp: now.Add(-time.Duration(i) * time.Hour)}
+		require.Nil(t, history.Add(interaction), "could not add interaction")
+	}
+	archiveDirectory := t.TempDir()
+	options := &RetentionOptions{MaxAge: 150 * time.Minute, Archiver: &FileArchiver{Directory: archiveDirectory}}
+
+	purged, err := history.Purge(options)
+	require.Nil(t, err, "could not purge history")
+	require.Equal(t, 2, purged, "could not purge expired interactions")
+	files, _ := ioutil.ReadDir(archiveDirectory)
+	require.Len(t, files, 1, "could not archive purged interactions")
+
+	size := history.Size()
+	purged, err = history.Purge(&RetentionOptions{MaxSize: size - 1})
+	require.Nil(t, err, "could not purge history")
+	require.Equal(t, 1, purged, "could not purge oldest interaction")
+	require.Less(t, history.Size(), size, "could not update history size")
+
+	result, _ := history.Search(&SearchQuery{})
+	require.Len(t, result.Interactions, 2, "could not keep recent interactions")
+}
@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/projectdiscovery/interactsh/pkg/types"
+)
+
+// SessionMetadata contains the counters of the interactions received for a
+// correlation-id since its registration.
+type SessionMetadata = types.SessionMetadata
+
+// ProtocolMetadata contains the counters of the interactions of a protocol
+type ProtocolMetadata = types.ProtocolMetadata
+
+// RecordMetadata counts an interaction of a correlationID in its session metadata
+func (s *StorageDB) RecordMetadata(correlationID, protocol string, timestamp time.Time, truncated bool) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	value.Lock()
+	defer value.Unlock()
+
+	metadata := &value.metadata
+	if metadata.Protocols == nil {
+		metadata.Protocols = make(map[string]*ProtocolMetadata)
+	}
+	protocol = strings.ToLower(protocol)
+	counters, ok := metadata.Protocols[protocol]
+	if !ok {
+		counters = &ProtocolMetadata{}
+		metadata.Protocols[protocol] = counters
+	}
+	counters.Count++
+	if timestamp.After(counters.LastInteraction) {
+		counters.LastInteraction = timestamp
+	}
+	if metadata.LastInteraction == nil || timestamp.After(*metadata.LastInteraction) {
+		metadata.LastInteraction = &timestamp
+	}
+	if truncated {
+		metadata.Truncated++
+	}
+	return nil
+}
+
+// GetMetadata returns a copy of the session metadata of a correlationID, or nil
+// if it isn't registered.
+func (s *StorageDB) GetMetadata(correlationID string) *SessionMetadata {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return nil
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return nil
+	}
+	value.Lock()
+	defer value.Unlock()
+
+	metadata := value.metadata
+	if value.metadata.Protocols != nil {
+		metadata.Protocols = make(map[string]*ProtocolMetadata, len(value.metadata.Protocols))
+		for protocol, counters := range value.metadata.Protocols {
+			copied := *counters
+			metadata.Protocols[protocol] = &copied
+		}
+	}
+	if value.metadata.LastInteraction != nil {
+		lastInteraction := *value.metadata.LastInteraction
+		metadata.LastInteraction = &lastInteraction
+	}
+	return &metadata
+}
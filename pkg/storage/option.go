@@ -6,6 +6,17 @@ type Options struct {
 	DbPath      string
 	EvictionTTL time.Duration
 	MaxSize     int
+	// ReplayWindow is the time delivered interactions are kept for replay (disabled if zero)
+	ReplayWindow time.Duration
+	// MaxInteractions is the maximum number of pending interactions per correlation-id (unlimited if zero)
+	MaxInteractions int
+	// MaxBytes is the maximum size of the pending interactions per correlation-id (unlimited if zero)
+	MaxBytes int
+	// MaxMemory is the maximum size of the interactions pending in memory for all the
+	// correlation-ids, not enforced with the disk storage (unlimited if zero)
+	MaxMemory int64
+	// OverflowPolicy is the policy applied when the quotas or the memory budget are exceeded (drop-oldest or drop-new)
+	OverflowPolicy string
 }
 
 func (options *Options) UseDisk() bool {
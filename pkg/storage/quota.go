@@ -0,0 +1,181 @@
+package storage
+
+import (
+	"bytes"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	jsoniter "github.com/json-iterator/go"
+)
+
+// Overflow policies applied when the interactions of a correlation-id exceed the quotas
+const (
+	// OverflowDropOldest drops the oldest pending interactions to store the new one
+	OverflowDropOldest = "drop-oldest"
+	// OverflowDropNew drops the new interactions until the pending ones are polled
+	OverflowDropNew = "drop-new"
+)
+
+// overflowInteraction is the synthetic interaction reporting the interactions
+// dropped by the quotas or the memory budget, with the fields of the server interactions.
+type overflowInteraction struct {
+	Protocol   string    `json:"protocol"`
+	UniqueID   string    `json:"unique-id"`
+	FullId     string    `json:"full-id"`
+	RawRequest string    `json:"raw-request"`
+	Dropped    uint64    `json:"dropped"`
+	Sequence   uint64    `json:"sequence"`
+	Timestamp  time.Time `json:"timestamp"`
+}
+
+// Quota limits the pending interactions of a correlation-id, the zero
+// values keeping the quotas of the storage options.
+type Quota struct {
+	// MaxInteractions is the maximum number of pending interactions
+	MaxInteractions int
+	// MaxBytes is the maximum size of the pending interactions
+	MaxBytes int
+}
+
+// quotaOptions returns the options applying the quotas of a correlation-id,
+// its own quota overriding the ones of the storage options.
+func (s *StorageDB) quotaOptions(value *CorrelationData) *Options {
+	if value.Quota == nil {
+		return s.Options
+	}
+	options := *s.Options
+	if value.Quota.MaxInteractions > 0 {
+		options.MaxInteractions = value.Quota.MaxInteractions
+	}
+	if value.Quota.MaxBytes > 0 {
+		options.MaxBytes = value.Quota.MaxBytes
+	}
+	return &options
+}
+
+// hasQuota returns true if the pending interactions are limited
+func (options *Options) hasQuota() bool {
+	return options.MaxInteractions > 0 || options.MaxBytes > 0
+}
+
+// applyQuota returns the number of oldest pending items, of the given sizes, to drop
+// for storing an item of size, and whether the item has to be stored at all.
+func (options *Options) applyQuota(sizes []int, size int) (int, bool) {
+	total := size
+	for _, itemSize := range sizes {
+		total += itemSize
+	}
+	fits := func(count, total int) bool {
+		return (options.MaxInteractions <= 0 || count < options.MaxInteractions) && (options.MaxBytes <= 0 || total <= options.MaxBytes)
+	}
+	if fits(len(sizes), total) {
+		return 0, true
+	}
+	if options.OverflowPolicy != OverflowDropOldest || (options.MaxBytes > 0 && size > options.MaxBytes) {
+		return 0, false
+	}
+	drop := 0
+	for drop < len(sizes) && !fits(len(sizes)-drop, total) {
+		total -= sizes[drop]
+		drop++
+	}
+	return drop, true
+}
+
+// appendInteraction appends an item, the plaintext in memory or the ciphertext on disk,
+// to the pending interactions of id applying the quotas if enforced. The item is numbered
+// with the next sequence number by the caller, which has to lock the correlation data.
+func (s *StorageDB) appendInteraction(value *CorrelationData, id, item string, enforceQuota bool) {
+	quota := s.quotaOptions(value)
+	if s.Options.UseDisk() {
+		existingData, _ := s.db.Get([]byte(id), nil)
+		var items [][]byte
+		if len(existingData) > 0 {
+			items = bytes.Split(existingData, []byte("\n"))
+		}
+		if enforceQuota && quota.hasQuota() {
+			sizes := make([]int, len(items))
+			for i, existing := range items {
+				_, ct := parseDiskItem(existing)
+				sizes[i] = len(ct)
+			}
+			drop, store := quota.applyQuota(sizes, len(item))
+			items = items[drop:]
+			s.dropInteractions(value, drop, store, &s.counters.quotaDropped)
+			if !store {
+				return
+			}
+		}
+		value.Sequence++
+		items = append(items, []byte(formatDiskItem(value.Sequence, item)))
+		_ = s.db.Put([]byte(id), AppendMany("\n", items...), nil)
+		return
+	}
+
+	if enforceQuota && quota.hasQuota() {
+		sizes := make([]int, len(value.Data))
+		for i, existing := range value.Data {
+			sizes[i] = len(existing)
+		}
+		drop, store := quota.applyQuota(sizes, len(item))
+		s.trimPending(value, drop)
+		s.dropInteractions(value, drop, store, &s.counters.quotaDropped)
+		if !store {
+			return
+		}
+	}
+	if enforceQuota && s.Options.MaxMemory > 0 {
+		drop, store := s.applyBudget(value, len(item))
+		s.trimPending(value, drop)
+		s.dropInteractions(value, drop, store, &s.counters.budgetDropped)
+		if !store {
+			return
+		}
+	}
+	value.Sequence++
+	value.Data = append(value.Data, item)
+	value.sequences = append(value.sequences, value.Sequence)
+	s.addPending(value, len(item))
+}
+
+// dropInteractions counts the interactions dropped by the quotas or the memory budget
+func (s *StorageDB) dropInteractions(value *CorrelationData, drop int, store bool, counter *uint64) {
+	dropped := uint64(drop)
+	if !store {
+		dropped++
+	}
+	value.dropped += dropped
+	value.metadata.Dropped += dropped
+	atomic.AddUint64(counter, dropped)
+}
+
+// flushOverflow appends the synthetic interaction reporting the interactions dropped
+// by the quotas or the memory budget since the last poll, if any. It is exempt from
+// the quotas and the memory budget. The
+// correlation data has to be locked by the caller.
+func (s *StorageDB) flushOverflow(value *CorrelationData, id string) {
+	if value.dropped == 0 {
+		return
+	}
+	data, err := jsoniter.Marshal(&overflowInteraction{
+		Protocol:   "overflow",
+		UniqueID:   id,
+		FullId:     id,
+		RawRequest: fmt.Sprintf("%d interactions dropped by the quota or the memory budget (%s)", value.dropped, s.Options.OverflowPolicy),
+		Dropped:    value.dropped,
+		Sequence:   value.Sequence + 1,
+		Timestamp:  time.Now().UTC(),
+	})
+	if err != nil {
+		return
+	}
+	item := string(data)
+	if s.Options.UseDisk() {
+		if item, err = AESEncrypt(value.AESKey, data); err != nil {
+			return
+		}
+	}
+	s.appendInteraction(value, id, item, false)
+	value.dropped = 0
+}
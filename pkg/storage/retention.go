@@ -0,0 +1,119 @@
+package storage
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/syndtr/goleveldb/leveldb"
+)
+
+// purgeBatchSize is the maximum number of interactions purged, and archived, at once
+const purgeBatchSize = 10000
+
+// archiveTimeFormat is the format of the time range in the archive names
+const archiveTimeFormat = "20060102T150405.000000000Z"
+
+// RetentionOptions contains the limits of the interactions history
+type RetentionOptions struct {
+	// MaxAge is the time the interactions are kept (unlimited if zero)
+	MaxAge time.Duration
+	// MaxSize is the maximum size of the history, the oldest interactions
+	// being purged first (unlimited if zero)
+	MaxSize int64
+	// Archiver stores the purged interactions if not nil
+	Archiver Archiver
+}
+
+// Enabled returns true if the history is limited
+func (options *RetentionOptions) Enabled() bool {
+	return options.MaxAge > 0 || options.MaxSize > 0
+}
+
+// Purge removes the interactions exceeding the retention limits, oldest first,
+// after archiving them if an archiver is configured. The interactions are only
+// removed once archived, so they are kept if the archiving fails.
+func (h *History) Purge(options *RetentionOptions) (int, error) {
+	var purged int
+	for {
+		count, err := h.purgeBatch(options)
+		purged += count
+		if err != nil || count < purgeBatchSize {
+			return purged, err
+		}
+	}
+}
+
+func (h *History) purgeBatch(options *RetentionOptions) (int, error) {
+	var deadline time.Time
+	if options.MaxAge > 0 {
+		deadline = time.Now().Add(-options.MaxAge)
+	}
+	var excess int64
+	if options.MaxSize > 0 {
+		excess = h.Size() - options.MaxSize
+	}
+
+	var (
+		count       int
+		freed       int64
+		first, last time.Time
+		buffer      bytes.Buffer
+	)
+	batch := new(leveldb.Batch)
+	writer := gzip.NewWriter(&buffer)
+
+	iter := h.db.NewIterator(nil, nil)
+	for count < purgeBatchSize && iter.Next() {
+		key, value := iter.Key(), iter.Value()
+		timestamp := keyTime(key)
+		if freed >= excess && (deadline.IsZero() || !timestamp.Before(deadline)) {
+			break
+		}
+		if options.Archiver != nil {
+			_, _ = writer.Write(value)
+			_, _ = writer.Write([]byte("\n"))
+		}
+		batch.Delete(append([]byte{}, key...))
+		freed += int64(len(key) + len(value))
+		if count == 0 {
+			first = timestamp
+		}
+		last = timestamp
+		count++
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return 0, errors.Wrap(err, "could not iterate history")
+	}
+	if count == 0 {
+		return 0, nil
+	}
+
+	if options.Archiver != nil {
+		if err := writer.Close(); err != nil {
+			return 0, errors.Wrap(err, "could not compress archive")
+		}
+		name := fmt.Sprintf("interactions-%s-%s.jsonl.gz", first.UTC().Format(archiveTimeFormat), last.UTC().Format(archiveTimeFormat))
+		if err := options.Archiver.Archive(name, buffer.Bytes()); err != nil {
+			return 0, errors.Wrap(err, "could not archive interactions")
+		}
+	}
+	if err := h.db.Write(batch, nil); err != nil {
+		return 0, errors.Wrap(err, "could not purge interactions")
+	}
+	atomic.AddInt64(&h.size, -freed)
+	return count, nil
+}
+
+// keyTime returns the time of the interaction of a history key
+func keyTime(key []byte) time.Time {
+	if len(key) < 8 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(key)))
+}
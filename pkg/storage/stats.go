@@ -0,0 +1,185 @@
+package storage
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/goburrow/cache"
+)
+
+// Reasons of the removal of correlation-ids from the storage
+const (
+	// RemovalExpired is the removal of correlation-ids after the eviction ttl
+	RemovalExpired = "expired"
+	// RemovalCapacity is the removal of correlation-ids when the cache is full
+	RemovalCapacity = "capacity"
+	// RemovalDeregistered is the removal of correlation-ids deregistered by their clients
+	RemovalDeregistered = "deregistered"
+	// RemovalEvicted is the removal of correlation-ids through the admin api
+	RemovalEvicted = "evicted"
+)
+
+// entryOverhead is the estimated size of the bookkeeping of a correlation-id in memory
+const entryOverhead = 256
+
+// StorageStats are the statistics of the sessions and queued interactions
+type StorageStats struct {
+	// Sessions is the number of correlation-ids registered by clients
+	Sessions int `json:"sessions"`
+	// QueuedInteractions is the number of interactions pending to be polled
+	QueuedInteractions int `json:"queued-interactions"`
+	// QueuedBytes is the size of the interactions pending to be polled
+	QueuedBytes int64 `json:"queued-bytes"`
+	// ReplayInteractions is the number of delivered interactions kept for replay
+	ReplayInteractions int `json:"replay-interactions"`
+	// MemoryEstimate is the estimated memory used by the sessions, excluding the disk storage
+	MemoryEstimate int64 `json:"memory-estimate"`
+	// QuotaDropped is the number of interactions dropped by the quotas
+	QuotaDropped uint64 `json:"quota-dropped"`
+	// BudgetDropped is the number of interactions dropped by the memory budget
+	BudgetDropped uint64 `json:"budget-dropped"`
+	// MemoryBudget is the maximum size of the interactions pending in memory (unlimited if zero)
+	MemoryBudget int64 `json:"memory-budget"`
+	// Tenants are the numbers of correlation-ids registered by each tenant
+	Tenants map[string]int `json:"tenants,omitempty"`
+	// Evictions are the numbers of removed correlation-ids by reason
+	Evictions map[string]uint64 `json:"evictions"`
+	Cache     *CacheMetrics     `json:"cache"`
+}
+
+// storageCounters are the counters of the storage updated with atomic operations
+type storageCounters struct {
+	quotaDropped  uint64
+	budgetDropped uint64
+	expired       uint64
+	capacity      uint64
+	deregistered  uint64
+	evicted       uint64
+}
+
+// trackEntry records a correlation-id put into the cache for the statistics
+func (s *StorageDB) trackEntry(id string, value *CorrelationData) {
+	value.created = time.Now()
+	// the pending interactions of a replaced entry are no longer reachable
+	if previous, ok := s.entries.Load(id); ok && previous != value {
+		if data, ok := previous.(*CorrelationData); ok {
+			data.Lock()
+			s.releasePending(data)
+			data.Unlock()
+		}
+	}
+	s.entries.Store(id, value)
+}
+
+// onCacheRemoval is called when an item is removed from the cache, whatever the reason
+func (s *StorageDB) onCacheRemoval(key cache.Key, value cache.Value) {
+	if s.Options.UseDisk() {
+		s.OnCacheRemovalCallback(key, value)
+	}
+	data, ok := value.(*CorrelationData)
+	if !ok {
+		return
+	}
+	// the entry may have been replaced by a new registration of the same id
+	if current, ok := s.entries.Load(key); ok && current == data {
+		s.entries.Delete(key)
+	}
+
+	data.Lock()
+	s.releasePending(data)
+	removal := data.removal
+	created := data.created
+	tenant := data.Tenant
+	data.Unlock()
+	if tenant != "" {
+		s.addTenantSession(tenant, -1)
+	}
+	if removal == "" {
+		removal = RemovalCapacity
+		if s.Options.EvictionTTL > 0 && time.Since(created) >= s.Options.EvictionTTL {
+			removal = RemovalExpired
+		}
+	}
+	switch removal {
+	case RemovalExpired:
+		atomic.AddUint64(&s.counters.expired, 1)
+	case RemovalCapacity:
+		atomic.AddUint64(&s.counters.capacity, 1)
+	case RemovalDeregistered:
+		atomic.AddUint64(&s.counters.deregistered, 1)
+	case RemovalEvicted:
+		atomic.AddUint64(&s.counters.evicted, 1)
+	}
+}
+
+// GetStats returns the statistics of the sessions and queued interactions
+func (s *StorageDB) GetStats() (*StorageStats, error) {
+	cacheMetrics, err := s.GetCacheMetrics()
+	if err != nil {
+		return nil, err
+	}
+	stats := &StorageStats{
+		QuotaDropped:  atomic.LoadUint64(&s.counters.quotaDropped),
+		BudgetDropped: atomic.LoadUint64(&s.counters.budgetDropped),
+		MemoryBudget:  s.Options.MaxMemory,
+		Evictions: map[string]uint64{
+			RemovalExpired:      atomic.LoadUint64(&s.counters.expired),
+			RemovalCapacity:     atomic.LoadUint64(&s.counters.capacity),
+			RemovalDeregistered: atomic.LoadUint64(&s.counters.deregistered),
+			RemovalEvicted:      atomic.LoadUint64(&s.counters.evicted),
+		},
+		Cache: cacheMetrics,
+	}
+
+	s.entries.Range(func(key, item interface{}) bool {
+		id, _ := key.(string)
+		value, ok := item.(*CorrelationData)
+		if !ok {
+			return true
+		}
+		value.Lock()
+		defer value.Unlock()
+
+		if value.SecretKeyHash != "" {
+			stats.Sessions++
+		}
+		if value.Tenant != "" {
+			if stats.Tenants == nil {
+				stats.Tenants = make(map[string]int)
+			}
+			stats.Tenants[value.Tenant]++
+		}
+		memory := int64(entryOverhead + len(id) + len(value.SecretKeyHash) + len(value.AESKey) + len(value.AESKeyEncrypted))
+		if s.Options.UseDisk() {
+			if data, err := s.db.Get([]byte(id), nil); err == nil && len(data) > 0 {
+				stats.QueuedInteractions += countDiskItems(data)
+				stats.QueuedBytes += int64(len(data))
+			}
+		} else {
+			for _, item := range value.Data {
+				stats.QueuedBytes += int64(len(item))
+				memory += int64(len(item))
+			}
+			stats.QueuedInteractions += len(value.Data)
+			memory += int64(8 * len(value.sequences))
+		}
+		stats.ReplayInteractions += len(value.replay)
+		for _, item := range value.replay {
+			memory += int64(len(item.data))
+		}
+		stats.MemoryEstimate += memory
+		return true
+	})
+	return stats, nil
+}
+
+// countDiskItems returns the number of newline separated items stored on disk
+func countDiskItems(data []byte) int {
+	count := 1
+	for _, c := range data {
+		if c == '\n' {
+			count++
+		}
+	}
+	return count
+}
@@ -1,15 +1,35 @@
 // storage defines a storage mechanism
 package storage
 
+import "time"
+
 type Storage interface {
 	GetCacheMetrics() (*CacheMetrics, error)
+	GetStats() (*StorageStats, error)
+	GetOverflowMetrics() *OverflowMetrics
 	SetIDPublicKey(correlationID, secretKey, publicKey string) error
+	RotateKeys(correlationID, secretKey, newSecretKey, publicKey string) error
 	SetID(ID string) error
 	AddInteraction(correlationID string, data []byte) error
 	AddInteractionWithId(id string, data []byte) error
 	GetInteractions(correlationID, secret string) ([]string, string, error)
+	GetInteractionsSince(correlationID, secret string, since uint64) ([]string, string, uint64, error)
 	GetInteractionsWithId(id string) ([]string, error)
+	GetReplay(correlationID, secret string, from, to uint64) ([]string, string, error)
 	RemoveID(correlationID, secret string) error
+	EvictID(correlationID string) error
+	SetDNSAnswers(correlationID, secret string, answers *DNSAnswers) error
+	GetDNSAnswers(correlationID string) *DNSAnswers
+	SetHTTPResponse(correlationID, secret string, response *HTTPResponseDefinition) error
+	GetHTTPResponse(correlationID string) *HTTPResponseDefinition
+	SetResponseDelay(correlationID, secret string, delay time.Duration) error
+	GetResponseDelay(correlationID string) time.Duration
+	SetTenant(correlationID, secret, tenant string, quota *Quota) error
+	GetTenantSessions(tenant string) int
+	RecordMetadata(correlationID, protocol string, timestamp time.Time, truncated bool) error
+	GetMetadata(correlationID string) *SessionMetadata
 	GetCacheItem(token string) (*CorrelationData, error)
+	// Shutdown closes the storage keeping the interactions stored on disk
+	Shutdown() error
 	Close() error
 }
@@ -3,16 +3,18 @@ package storage
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"crypto/rand"
-	"crypto/rsa"
 	"crypto/sha256"
-	"encoding/base64"
+	"encoding/hex"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/goburrow/cache"
-	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/projectdiscovery/fileutil"
 	"github.com/rs/xid"
@@ -28,17 +30,27 @@ type StorageDB struct {
 	cache   cache.Cache
 	db      *leveldb.DB
 	dbpath  string
+	// secretHashKey is the key of the HMAC of the client secrets
+	secretHashKey []byte
+	// entries contains the correlation-ids in the cache, for the statistics
+	entries  sync.Map
+	counters storageCounters
+	// pendingBytes is the size of the interactions pending in memory, for the memory budget
+	pendingBytes int64
+	// tenantSessions contains the number of correlation-ids registered by each tenant
+	tenantSessions sync.Map
 }
 
 // New creates a new storage instance for interactsh data.
 func New(options *Options) (*StorageDB, error) {
-	storageDB := &StorageDB{Options: options}
+	storageDB := &StorageDB{Options: options, secretHashKey: make([]byte, 32)}
+	if _, err := rand.Read(storageDB.secretHashKey); err != nil {
+		return nil, errors.Wrap(err, "could not generate secret hash key")
+	}
 	cacheOptions := []cache.Option{
 		cache.WithMaximumSize(options.MaxSize),
 		cache.WithExpireAfterWrite(options.EvictionTTL),
-	}
-	if options.UseDisk() {
-		cacheOptions = append(cacheOptions, cache.WithRemovalListener(storageDB.OnCacheRemovalCallback))
+		cache.WithRemovalListener(storageDB.onCacheRemoval),
 	}
 	cacheDb := cache.New(cacheOptions...)
 	storageDB.cache = cacheDb
@@ -64,6 +76,19 @@ func New(options *Options) (*StorageDB, error) {
 	return storageDB, nil
 }
 
+// hashSecret returns the HMAC of a client secret key. Secret keys are
+// case insensitive, so the lower case key is hashed.
+func (s *StorageDB) hashSecret(secret string) string {
+	mac := hmac.New(sha256.New, s.secretHashKey)
+	_, _ = mac.Write([]byte(strings.ToLower(secret)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// validSecret compares in constant time a client secret key with the one registered
+func (s *StorageDB) validSecret(value *CorrelationData, secret string) bool {
+	return hmac.Equal([]byte(value.SecretKeyHash), []byte(s.hashSecret(secret)))
+}
+
 func (s *StorageDB) OnCacheRemovalCallback(key cache.Key, value cache.Value) {
 	if key, ok := value.([]byte); ok {
 		_ = s.db.Delete(key, &opt.WriteOptions{})
@@ -87,34 +112,108 @@ func (s *StorageDB) GetCacheMetrics() (*CacheMetrics, error) {
 }
 
 // SetIDPublicKey sets the correlation ID and publicKey into the cache for further operations.
+// Registering again an existing correlation ID with its secret key resumes the session,
+// keeping the pending interactions.
 func (s *StorageDB) SetIDPublicKey(correlationID, secretKey, publicKey string) error {
-	// If we already have this correlation ID, return.
-	_, found := s.cache.GetIfPresent(correlationID)
-	if found {
-		return errors.New("correlation-id provided already exists")
+	// If we already have this correlation ID, it can only be resumed by its owner
+	if item, found := s.cache.GetIfPresent(correlationID); found {
+		value, ok := item.(*CorrelationData)
+		if !ok || !s.validSecret(value, secretKey) {
+			return errors.New("correlation-id provided already exists")
+		}
+		// the pending interactions are kept and bound to the public key
+		return s.RotateKeys(correlationID, secretKey, "", publicKey)
 	}
-	publicKeyData, err := ParseB64RSAPublicKeyFromPEM(publicKey)
+	aesKey, aesKeyEncrypted, err := newAESKey(publicKey)
 	if err != nil {
-		return errors.Wrap(err, "could not read public Key")
+		return err
 	}
-	aesKey := uuid.New().String()[:32]
 
-	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKeyData, []byte(aesKey), []byte(""))
+	data := &CorrelationData{
+		SecretKeyHash:   s.hashSecret(secretKey),
+		AESKey:          aesKey,
+		AESKeyEncrypted: aesKeyEncrypted,
+		Sequence:        initialSequence(),
+	}
+	s.trackEntry(correlationID, data)
+	s.cache.Put(correlationID, data)
+	return nil
+}
+
+// RotateKeys replaces the public key registered for a correlation ID, and the secret
+// key if newSecretKey is not empty. A new AES key is generated for the interactions,
+// the ones pending or kept for replay on disk are encrypted again with it.
+func (s *StorageDB) RotateKeys(correlationID, secretKey, newSecretKey, publicKey string) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !s.validSecret(value, secretKey) {
+		return errors.New("invalid secret key passed for rotation")
+	}
+	aesKey, aesKeyEncrypted, err := newAESKey(publicKey)
 	if err != nil {
-		return errors.New("could not encrypt event data")
+		return err
 	}
 
-	data := &CorrelationData{
-		SecretKey:       secretKey,
-		AESKey:          []byte(aesKey),
-		AESKeyEncrypted: base64.StdEncoding.EncodeToString(ciphertext),
+	value.Lock()
+	defer value.Unlock()
+
+	if s.Options.UseDisk() {
+		if err := s.reencryptDiskItems([]byte(correlationID), false, value.AESKey, aesKey); err != nil {
+			return errors.Wrap(err, "could not encrypt stored interactions")
+		}
+		if err := s.reencryptDiskItems(replayKey(correlationID), true, value.AESKey, aesKey); err != nil {
+			return errors.Wrap(err, "could not encrypt replay interactions")
+		}
+	}
+	value.AESKey = aesKey
+	value.AESKeyEncrypted = aesKeyEncrypted
+	if newSecretKey != "" {
+		value.SecretKeyHash = s.hashSecret(newSecretKey)
 	}
-	s.cache.Put(correlationID, data)
 	return nil
 }
 
+// reencryptDiskItems encrypts again the interactions stored on disk at key with a new AES key
+func (s *StorageDB) reencryptDiskItems(key []byte, replay bool, oldAESKey, newAESKey []byte) error {
+	data, err := s.db.Get(key, nil)
+	if err != nil {
+		if errors.Is(err, leveldb.ErrNotFound) {
+			return nil
+		}
+		return err
+	}
+	items := bytes.Split(data, []byte("\n"))
+	for i, item := range items {
+		// replay items are prefixed with the delivery time
+		var prefix []byte
+		if replay {
+			var delivered time.Time
+			delivered, item = parseReplayItem(item)
+			prefix = formatReplayItem(delivered, nil)
+		}
+		sequence, ct := parseDiskItem(item)
+		plaintext, err := AESDecrypt(oldAESKey, ct)
+		if err != nil {
+			return err
+		}
+		newCT, err := AESEncrypt(newAESKey, plaintext)
+		if err != nil {
+			return err
+		}
+		items[i] = append(prefix, formatDiskItem(sequence, newCT)...)
+	}
+	return s.db.Put(key, AppendMany("\n", items...), nil)
+}
+
 func (s *StorageDB) SetID(ID string) error {
-	data := &CorrelationData{}
+	data := &CorrelationData{Sequence: initialSequence()}
+	s.trackEntry(ID, data)
 	s.cache.Put(ID, data)
 	return nil
 }
@@ -130,23 +229,7 @@ func (s *StorageDB) AddInteraction(correlationID string, data []byte) error {
 	if !ok {
 		return errors.New("invalid correlation-id cache value found")
 	}
-
-	if s.Options.UseDisk() {
-		ct, err := AESEncrypt(value.AESKey, data)
-		if err != nil {
-			return errors.Wrap(err, "could not encrypt event data")
-		}
-		value.Lock()
-		existingData, _ := s.db.Get([]byte(correlationID), nil)
-		_ = s.db.Put([]byte(correlationID), AppendMany("\n", existingData, []byte(ct)), nil)
-		value.Unlock()
-	} else {
-		value.Lock()
-		value.Data = append(value.Data, string(data))
-		value.Unlock()
-	}
-
-	return nil
+	return s.addInteraction(value, correlationID, data)
 }
 
 // AddInteractionWithId adds an interaction data to the id bucket
@@ -159,22 +242,26 @@ func (s *StorageDB) AddInteractionWithId(id string, data []byte) error {
 	if !ok {
 		return errors.New("invalid correlation-id cache value found")
 	}
+	return s.addInteraction(value, id, data)
+}
+
+// addInteraction appends the interaction data to the bucket with the next sequence number
+func (s *StorageDB) addInteraction(value *CorrelationData, id string, data []byte) error {
+	value.Lock()
+	defer value.Unlock()
 
+	// the sequence and the key are read under the lock, so that the interactions are
+	// numbered in the order they are stored and not stored with a rotated key
+	data = withSequence(data, value.Sequence+1)
+	item := string(data)
 	if s.Options.UseDisk() {
 		ct, err := AESEncrypt(value.AESKey, data)
 		if err != nil {
 			return errors.Wrap(err, "could not encrypt event data")
 		}
-		value.Lock()
-		existingData, _ := s.db.Get([]byte(id), nil)
-		_ = s.db.Put([]byte(id), AppendMany("\n", existingData, []byte(ct)), nil)
-		value.Unlock()
-	} else {
-		value.Lock()
-		value.Data = append(value.Data, string(data))
-		value.Unlock()
+		item = ct
 	}
-
+	s.appendInteraction(value, id, item, true)
 	return nil
 }
 
@@ -189,11 +276,29 @@ func (s *StorageDB) GetInteractions(correlationID, secret string) ([]string, str
 	if !ok {
 		return nil, "", errors.New("invalid correlation-id cache value found")
 	}
-	if !strings.EqualFold(value.SecretKey, secret) {
+	if !s.validSecret(value, secret) {
 		return nil, "", errors.New("invalid secret key passed for user")
 	}
-	data, err := s.getInteractions(value, correlationID)
-	return data, value.AESKeyEncrypted, err
+	return s.getInteractions(value, correlationID)
+}
+
+// GetInteractionsSince returns the interactions for a correlationID with a sequence
+// number greater than since, without removing them from the storage. The interactions
+// up to since are acknowledged and removed, so that a failed poll can be retried.
+// It also returns the AES Encrypted Key for the IDs and the last sequence number.
+func (s *StorageDB) GetInteractionsSince(correlationID, secret string, since uint64) ([]string, string, uint64, error) {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return nil, "", 0, errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return nil, "", 0, errors.New("invalid correlation-id cache value found")
+	}
+	if !s.validSecret(value, secret) {
+		return nil, "", 0, errors.New("invalid secret key passed for user")
+	}
+	return s.getInteractionsSince(value, correlationID, since)
 }
 
 // GetInteractions returns the interactions for a id and empty the cache
@@ -206,7 +311,8 @@ func (s *StorageDB) GetInteractionsWithId(id string) ([]string, error) {
 	if !ok {
 		return nil, errors.New("invalid id cache value found")
 	}
-	return s.getInteractions(value, id)
+	data, _, err := s.getInteractions(value, id)
+	return data, err
 }
 
 // RemoveID removes data for a correlation ID and data related to it.
@@ -219,20 +325,172 @@ func (s *StorageDB) RemoveID(correlationID, secret string) error {
 	if !ok {
 		return errors.New("invalid correlation-id cache value found")
 	}
-	if !strings.EqualFold(value.SecretKey, secret) {
+	if !s.validSecret(value, secret) {
 		return errors.New("invalid secret key passed for deregister")
 	}
+	return s.removeID(correlationID, value, RemovalDeregistered)
+}
+
+// EvictID removes a correlationID and its interactions without requiring its secret.
+func (s *StorageDB) EvictID(correlationID string) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	return s.removeID(correlationID, value, RemovalEvicted)
+}
+
+func (s *StorageDB) removeID(correlationID string, value *CorrelationData, removal string) error {
 	value.Lock()
+	value.removal = removal
 	value.Data = nil
+	value.sequences = nil
+	s.releasePending(value)
+	value.replay = nil
 	value.Unlock()
 	s.cache.Invalidate(correlationID)
 
 	if s.Options.UseDisk() {
+		_ = s.db.Delete(replayKey(correlationID), nil)
 		return s.db.Delete([]byte(correlationID), nil)
 	}
 	return nil
 }
 
+// SetDNSAnswers sets the custom dns answers for a correlationID, removing them if answers is nil.
+func (s *StorageDB) SetDNSAnswers(correlationID, secret string, answers *DNSAnswers) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !s.validSecret(value, secret) {
+		return errors.New("invalid secret key passed for user")
+	}
+	value.Lock()
+	value.DNSAnswers = answers
+	value.Unlock()
+	return nil
+}
+
+// GetDNSAnswers returns the custom dns answers for a correlationID, or nil if there are none.
+func (s *StorageDB) GetDNSAnswers(correlationID string) *DNSAnswers {
+	value, err := s.GetCacheItem(correlationID)
+	if err != nil {
+		return nil
+	}
+	value.Lock()
+	defer value.Unlock()
+	return value.DNSAnswers
+}
+
+// SetHTTPResponse sets the custom http response for a correlationID, removing it if response is nil.
+func (s *StorageDB) SetHTTPResponse(correlationID, secret string, response *HTTPResponseDefinition) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !s.validSecret(value, secret) {
+		return errors.New("invalid secret key passed for user")
+	}
+	value.Lock()
+	value.HTTPResponse = response
+	value.Unlock()
+	return nil
+}
+
+// GetHTTPResponse returns the custom http response for a correlationID, or nil if there is none.
+func (s *StorageDB) GetHTTPResponse(correlationID string) *HTTPResponseDefinition {
+	value, err := s.GetCacheItem(correlationID)
+	if err != nil {
+		return nil
+	}
+	value.Lock()
+	defer value.Unlock()
+	return value.HTTPResponse
+}
+
+// SetResponseDelay sets the delay of the responses for a correlationID
+func (s *StorageDB) SetResponseDelay(correlationID, secret string, delay time.Duration) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !s.validSecret(value, secret) {
+		return errors.New("invalid secret key passed for user")
+	}
+	value.Lock()
+	value.ResponseDelay = delay
+	value.Unlock()
+	return nil
+}
+
+// GetResponseDelay returns the delay of the responses for a correlationID
+func (s *StorageDB) GetResponseDelay(correlationID string) time.Duration {
+	value, err := s.GetCacheItem(correlationID)
+	if err != nil {
+		return 0
+	}
+	value.Lock()
+	defer value.Unlock()
+	return value.ResponseDelay
+}
+
+// SetTenant binds a correlationID to a tenant and applies the quota of the tenant.
+// A correlationID registered by a tenant can't be bound to another one.
+func (s *StorageDB) SetTenant(correlationID, secret, tenant string, quota *Quota) error {
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return errors.New("invalid correlation-id cache value found")
+	}
+	if !s.validSecret(value, secret) {
+		return errors.New("invalid secret key passed for user")
+	}
+	value.Lock()
+	defer value.Unlock()
+	if value.Tenant != "" && value.Tenant != tenant {
+		return errors.New("correlation-id belongs to another tenant")
+	}
+	if value.Tenant == "" {
+		value.Tenant = tenant
+		s.addTenantSession(tenant, 1)
+	}
+	value.Quota = quota
+	return nil
+}
+
+// GetTenantSessions returns the number of correlationIDs registered by a tenant
+func (s *StorageDB) GetTenantSessions(tenant string) int {
+	if count, ok := s.tenantSessions.Load(tenant); ok {
+		return int(atomic.LoadInt64(count.(*int64)))
+	}
+	return 0
+}
+
+func (s *StorageDB) addTenantSession(tenant string, delta int64) {
+	count, _ := s.tenantSessions.LoadOrStore(tenant, new(int64))
+	atomic.AddInt64(count.(*int64), delta)
+}
+
 // GetCacheItem returns an item as is
 func (s *StorageDB) GetCacheItem(token string) (*CorrelationData, error) {
 	item, ok := s.cache.GetIfPresent(token)
@@ -246,10 +504,14 @@ func (s *StorageDB) GetCacheItem(token string) (*CorrelationData, error) {
 	return value, nil
 }
 
-func (s *StorageDB) getInteractions(correlationData *CorrelationData, id string) ([]string, error) {
+// getInteractions returns and removes the pending interactions of id, with the
+// encrypted AES key they are encrypted with read under the same lock.
+func (s *StorageDB) getInteractions(correlationData *CorrelationData, id string) ([]string, string, error) {
 	correlationData.Lock()
 	defer correlationData.Unlock()
+	s.flushOverflow(correlationData, id)
 
+	key := correlationData.AESKeyEncrypted
 	switch {
 	case s.Options.UseDisk():
 		data, err := s.db.Get([]byte(id), nil)
@@ -257,33 +519,200 @@ func (s *StorageDB) getInteractions(correlationData *CorrelationData, id string)
 			if errors.Is(err, leveldb.ErrNotFound) {
 				err = nil
 			}
-			return nil, err
+			return nil, key, err
 		}
 		var dataString []string
-		for _, d := range bytes.Split(data, []byte("\n")) {
-			dataString = append(dataString, string(d))
+		items := bytes.Split(data, []byte("\n"))
+		for _, d := range items {
+			_, ct := parseDiskItem(d)
+			dataString = append(dataString, ct)
 		}
+		s.addDiskReplay(id, items)
 		_ = s.db.Delete([]byte(id), nil)
-		return dataString, nil
+		return dataString, key, nil
 	default:
 		// in memory data
-		var errs []error
 		data := correlationData.Data
+		s.addReplay(correlationData, correlationData.sequences, data)
 		correlationData.Data = nil
-		if len(data) == 0 {
-			return nil, nil
-		}
+		correlationData.sequences = nil
+		s.releasePending(correlationData)
+		encrypted, err := encryptInteractions(correlationData.AESKey, data)
+		return encrypted, key, err
+	}
+}
 
-		for i, dataItem := range data {
-			encryptedDataItem, err := AESEncrypt(correlationData.AESKey, []byte(dataItem))
-			if err != nil {
-				errs = append(errs, errors.Wrap(err, "could not encrypt event data"))
+func (s *StorageDB) getInteractionsSince(correlationData *CorrelationData, id string, since uint64) ([]string, string, uint64, error) {
+	correlationData.Lock()
+	defer correlationData.Unlock()
+	s.flushOverflow(correlationData, id)
+
+	key := correlationData.AESKeyEncrypted
+	switch {
+	case s.Options.UseDisk():
+		data, err := s.db.Get([]byte(id), nil)
+		if err != nil {
+			if errors.Is(err, leveldb.ErrNotFound) {
+				err = nil
+			}
+			return nil, key, correlationData.Sequence, err
+		}
+		var dataString []string
+		var remaining, acknowledged [][]byte
+		for _, d := range bytes.Split(data, []byte("\n")) {
+			sequence, ct := parseDiskItem(d)
+			if sequence <= since {
+				acknowledged = append(acknowledged, d)
 				continue
 			}
-			data[i] = encryptedDataItem
+			dataString = append(dataString, ct)
+			remaining = append(remaining, d)
+		}
+		s.addDiskReplay(id, acknowledged)
+		if len(remaining) == 0 {
+			_ = s.db.Delete([]byte(id), nil)
+		} else {
+			_ = s.db.Put([]byte(id), AppendMany("\n", remaining...), nil)
+		}
+		return dataString, key, correlationData.Sequence, nil
+	default:
+		// in memory data, the acknowledged items are dropped
+		index := 0
+		for index < len(correlationData.sequences) && correlationData.sequences[index] <= since {
+			index++
+		}
+		s.addReplay(correlationData, correlationData.sequences[:index], correlationData.Data[:index])
+		s.trimPending(correlationData, index)
+
+		data := make([]string, len(correlationData.Data))
+		copy(data, correlationData.Data)
+		encrypted, err := encryptInteractions(correlationData.AESKey, data)
+		return encrypted, key, correlationData.Sequence, err
+	}
+}
+
+// GetReplay returns the delivered interactions for a correlationID with a sequence
+// number between from and to (inclusive) still within the replay window.
+// It also returns the AES Encrypted Key for the IDs.
+func (s *StorageDB) GetReplay(correlationID, secret string, from, to uint64) ([]string, string, error) {
+	if s.Options.ReplayWindow <= 0 {
+		return nil, "", errors.New("interaction replay is not enabled")
+	}
+	item, ok := s.cache.GetIfPresent(correlationID)
+	if !ok {
+		return nil, "", errors.New("could not get correlation-id from cache")
+	}
+	value, ok := item.(*CorrelationData)
+	if !ok {
+		return nil, "", errors.New("invalid correlation-id cache value found")
+	}
+	if !s.validSecret(value, secret) {
+		return nil, "", errors.New("invalid secret key passed for user")
+	}
+
+	value.Lock()
+	defer value.Unlock()
+
+	cutoff := time.Now().Add(-s.Options.ReplayWindow)
+	if s.Options.UseDisk() {
+		data, err := s.db.Get(replayKey(correlationID), nil)
+		if err != nil {
+			if errors.Is(err, leveldb.ErrNotFound) {
+				err = nil
+			}
+			return nil, value.AESKeyEncrypted, err
 		}
-		return data, multierr.Combine(errs...)
+		var dataString []string
+		for _, d := range bytes.Split(data, []byte("\n")) {
+			delivered, item := parseReplayItem(d)
+			sequence, ct := parseDiskItem(item)
+			if delivered.After(cutoff) && sequence >= from && sequence <= to {
+				dataString = append(dataString, ct)
+			}
+		}
+		return dataString, value.AESKeyEncrypted, nil
+	}
+
+	var data []string
+	for _, item := range value.replay {
+		if item.delivered.After(cutoff) && item.sequence >= from && item.sequence <= to {
+			data = append(data, item.data)
+		}
+	}
+	encrypted, err := encryptInteractions(value.AESKey, data)
+	return encrypted, value.AESKeyEncrypted, err
+}
+
+// addReplay keeps the delivered in memory interactions for the replay window
+func (s *StorageDB) addReplay(correlationData *CorrelationData, sequences []uint64, data []string) {
+	if s.Options.ReplayWindow <= 0 {
+		return
+	}
+	now := time.Now()
+	cutoff := now.Add(-s.Options.ReplayWindow)
+	index := 0
+	for index < len(correlationData.replay) && !correlationData.replay[index].delivered.After(cutoff) {
+		index++
+	}
+	correlationData.replay = correlationData.replay[index:]
+	for i, dataItem := range data {
+		correlationData.replay = append(correlationData.replay, replayItem{sequence: sequences[i], data: dataItem, delivered: now})
+	}
+}
+
+// addDiskReplay keeps the delivered on disk interactions for the replay window
+func (s *StorageDB) addDiskReplay(id string, items [][]byte) {
+	if s.Options.ReplayWindow <= 0 {
+		return
+	}
+	now := time.Now()
+	cutoff := now.Add(-s.Options.ReplayWindow)
+	var kept [][]byte
+	existingData, _ := s.db.Get(replayKey(id), nil)
+	if len(existingData) > 0 {
+		for _, d := range bytes.Split(existingData, []byte("\n")) {
+			if delivered, _ := parseReplayItem(d); delivered.After(cutoff) {
+				kept = append(kept, d)
+			}
+		}
+	}
+	for _, item := range items {
+		if len(item) > 0 {
+			kept = append(kept, formatReplayItem(now, item))
+		}
+	}
+	if len(kept) == 0 {
+		_ = s.db.Delete(replayKey(id), nil)
+		return
+	}
+	_ = s.db.Put(replayKey(id), AppendMany("\n", kept...), nil)
+}
+
+// encryptInteractions encrypts in place the interactions with the AES key
+func encryptInteractions(key []byte, data []string) ([]string, error) {
+	if len(data) == 0 {
+		return nil, nil
+	}
+	var errs []error
+	for i, dataItem := range data {
+		encryptedDataItem, err := AESEncrypt(key, []byte(dataItem))
+		if err != nil {
+			errs = append(errs, errors.Wrap(err, "could not encrypt event data"))
+			continue
+		}
+		data[i] = encryptedDataItem
+	}
+	return data, multierr.Combine(errs...)
+}
+
+// Shutdown closes the storage, keeping the interactions queued on disk in
+// the leveldb directory instead of removing it like Close.
+func (s *StorageDB) Shutdown() error {
+	err := s.cache.Close()
+	if s.db != nil {
+		err = multierr.Append(err, s.db.Close())
 	}
+	return err
 }
 
 func (s *StorageDB) Close() error {
@@ -10,11 +10,13 @@ import (
 	"encoding/base64"
 	"encoding/pem"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/goburrow/cache"
 	"github.com/google/uuid"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/karlseguin/ccache/v2"
 	"github.com/rs/xid"
 	"github.com/stretchr/testify/require"
@@ -53,7 +55,9 @@ func TestStorageSetIDPublicKey(t *testing.T) {
 	value, ok := item.(*CorrelationData)
 	require.True(t, ok, "could not assert item value type as correlation data")
 
-	require.Equal(t, secret, value.SecretKey, "could not get correct secret key")
+	require.NotEqual(t, secret, value.SecretKeyHash, "could not hash secret key")
+	require.True(t, mem.validSecret(value, secret), "could not validate secret key")
+	require.False(t, mem.validSecret(value, uuid.New().String()), "could not reject invalid secret key")
 }
 
 func TestStorageAddGetInteractions(t *testing.T) {
@@ -118,6 +122,408 @@ func TestStorageAddGetInteractions(t *testing.T) {
 	require.Equal(t, dataOriginal, decoded, "could not get correct decrypted interaction")
 }
 
+// registerTestID registers a new correlation-id with a new rsa key in the storage
+func registerTestID(t *testing.T, mem *StorageDB) (correlationID, secret string, priv *rsa.PrivateKey) {
+	secret = uuid.New().String()
+	correlationID = xid.New().String()
+
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err, "could not generate rsa key")
+	err = mem.SetIDPublicKey(correlationID, secret, encodeTestPublicKey(t, priv))
+	require.Nil(t, err, "could not set correlation-id and rsa public key in storage")
+	return correlationID, secret, priv
+}
+
+// encodeTestPublicKey returns the public key of priv as registered by the clients
+func encodeTestPublicKey(t *testing.T, priv *rsa.PrivateKey) string {
+	pubkeyBytes, err := x509.MarshalPKIXPublicKey(priv.Public())
+	require.Nil(t, err, "could not marshal public key")
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "RSA PUBLIC KEY", Bytes: pubkeyBytes}))
+}
+
+func TestStorageGetInteractionsSince(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	correlationID, secret, _ := registerTestID(t, mem)
+	initial := lastTestSequence(t, mem, correlationID)
+	require.NotZero(t, initial, "could not seed sequence")
+
+	for i := 0; i < 3; i++ {
+		err = mem.AddInteraction(correlationID, []byte("interaction "+strconv.Itoa(i)))
+		require.Nil(t, err, "could not add interaction to storage")
+	}
+
+	data, _, sequence, err := mem.GetInteractionsSince(correlationID, secret, 0)
+	require.Nil(t, err, "could not get interactions from storage")
+	require.Len(t, data, 3, "could not get all interactions")
+	require.Equal(t, initial+3, sequence, "could not get last sequence")
+
+	// retrying the poll returns the same interactions until acknowledged
+	data, _, _, err = mem.GetInteractionsSince(correlationID, secret, 0)
+	require.Nil(t, err, "could not get interactions from storage")
+	require.Len(t, data, 3, "could not get interactions again")
+
+	err = mem.AddInteraction(correlationID, []byte("interaction 3"))
+	require.Nil(t, err, "could not add interaction to storage")
+	data, _, sequence, err = mem.GetInteractionsSince(correlationID, secret, initial+3)
+	require.Nil(t, err, "could not get interactions from storage")
+	require.Len(t, data, 1, "could not get only new interactions")
+	require.Equal(t, initial+4, sequence, "could not get last sequence")
+}
+
+// lastTestSequence returns the sequence number of the last interaction stored for id
+func lastTestSequence(t *testing.T, mem *StorageDB, id string) uint64 {
+	item, ok := mem.cache.GetIfPresent(id)
+	require.True(t, ok, "could not get correlation-id from cache")
+	value := item.(*CorrelationData)
+	value.Lock()
+	defer value.Unlock()
+	return value.Sequence
+}
+
+func TestStorageInteractionSequence(t *testing.T) {
+	for _, useDisk := range []bool{false, true} {
+		options := &Options{EvictionTTL: 1 * time.Hour}
+		if useDisk {
+			options.DbPath = t.TempDir()
+		}
+		mem, err := New(options)
+		require.Nil(t, err)
+		defer mem.Close()
+
+		correlationID, secret, priv := registerTestID(t, mem)
+		initial := lastTestSequence(t, mem, correlationID)
+		for _, item := range []string{`{"protocol":"dns"}`, `{}`, "interaction"} {
+			err = mem.AddInteraction(correlationID, []byte(item+"\n"))
+			require.Nil(t, err, "could not add interaction to storage")
+		}
+
+		data, key, err := mem.GetInteractions(correlationID, secret)
+		require.Nil(t, err, "could not get interactions from storage")
+		decodedKey, err := base64.StdEncoding.DecodeString(key)
+		require.Nil(t, err, "could not decode key")
+		keyPlaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, decodedKey, nil)
+		require.Nil(t, err, "could not decrypt key to plaintext")
+		var decoded []string
+		for _, item := range data {
+			plaintext, err := AESDecrypt(keyPlaintext, item)
+			require.Nil(t, err, "could not decrypt interaction")
+			decoded = append(decoded, string(plaintext))
+		}
+		require.Equal(t, []string{
+			`{"protocol":"dns","sequence":` + strconv.FormatUint(initial+1, 10) + `}`,
+			`{"sequence":` + strconv.FormatUint(initial+2, 10) + `}`,
+			"interaction\n",
+		}, decoded, "could not number interactions with disk storage %v", useDisk)
+
+		// the sequence keeps increasing for the correlation-ids registered again after a restart
+		restarted, err := New(&Options{EvictionTTL: 1 * time.Hour})
+		require.Nil(t, err)
+		require.Nil(t, restarted.SetIDPublicKey(correlationID, secret, encodeTestPublicKey(t, priv)), "could not register correlation-id again")
+		require.Greater(t, lastTestSequence(t, restarted, correlationID), initial+3, "could not seed sequence after restart")
+	}
+}
+
+func TestStorageGetReplay(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour, ReplayWindow: 1 * time.Hour})
+	require.Nil(t, err)
+
+	correlationID, secret, _ := registerTestID(t, mem)
+	initial := lastTestSequence(t, mem, correlationID)
+
+	for i := 0; i < 3; i++ {
+		err = mem.AddInteraction(correlationID, []byte("interaction "+strconv.Itoa(i)))
+		require.Nil(t, err, "could not add interaction to storage")
+	}
+
+	data, _, err := mem.GetReplay(correlationID, secret, initial+1, initial+3)
+	require.Nil(t, err, "could not get replay from storage")
+	require.Empty(t, data, "could not ignore undelivered interactions")
+
+	_, _, err = mem.GetInteractions(correlationID, secret)
+	require.Nil(t, err, "could not get interactions from storage")
+
+	data, _, err = mem.GetReplay(correlationID, secret, initial+2, initial+3)
+	require.Nil(t, err, "could not get replay from storage")
+	require.Len(t, data, 2, "could not get delivered interactions in range")
+}
+
+func TestStorageRotateKeys(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	correlationID, secret, _ := registerTestID(t, mem)
+
+	dataOriginal := []byte("interaction before rotation")
+	err = mem.AddInteraction(correlationID, dataOriginal)
+	require.Nil(t, err, "could not add interaction to storage")
+
+	newPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err, "could not generate rsa key")
+	encoded := encodeTestPublicKey(t, newPriv)
+	newSecret := uuid.New().String()
+
+	err = mem.RotateKeys(correlationID, "invalid", newSecret, encoded)
+	require.NotNil(t, err, "could not reject rotation with invalid secret")
+	err = mem.RotateKeys(correlationID, secret, newSecret, encoded)
+	require.Nil(t, err, "could not rotate keys")
+
+	_, _, err = mem.GetInteractions(correlationID, secret)
+	require.NotNil(t, err, "could not reject poll with old secret")
+
+	data, key, err := mem.GetInteractions(correlationID, newSecret)
+	require.Nil(t, err, "could not get interaction from storage")
+	require.Len(t, data, 1, "could not get interaction added before rotation")
+
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	require.Nil(t, err, "could not decode key")
+	keyPlaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, newPriv, decodedKey, nil)
+	require.Nil(t, err, "could not decrypt key with rotated private key")
+
+	decoded, err := AESDecrypt(keyPlaintext, data[0])
+	require.Nil(t, err, "could not decrypt interaction")
+	require.Equal(t, dataOriginal, decoded, "could not get correct decrypted interaction")
+}
+
+func TestStorageRotateKeysConcurrent(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour, DbPath: t.TempDir()})
+	require.Nil(t, err)
+	defer mem.Close()
+
+	correlationID, secret, _ := registerTestID(t, mem)
+
+	newPriv, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.Nil(t, err, "could not generate rsa key")
+	encoded := encodeTestPublicKey(t, newPriv)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 200; i++ {
+			_ = mem.AddInteraction(correlationID, []byte("interaction "+strconv.Itoa(i)))
+		}
+	}()
+	newSecret := uuid.New().String()
+	err = mem.RotateKeys(correlationID, secret, newSecret, encoded)
+	require.Nil(t, err, "could not rotate keys")
+	<-done
+
+	data, key, err := mem.GetInteractions(correlationID, newSecret)
+	require.Nil(t, err, "could not get interactions from storage")
+	require.Len(t, data, 200, "could not get all interactions")
+
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	require.Nil(t, err, "could not decode key")
+	keyPlaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, newPriv, decodedKey, nil)
+	require.Nil(t, err, "could not decrypt key with rotated private key")
+	for _, item := range data {
+		decoded, err := AESDecrypt(keyPlaintext, item)
+		require.Nil(t, err, "could not decrypt interaction")
+		require.True(t, strings.HasPrefix(string(decoded), "interaction "), "could not decrypt interaction added during rotation")
+	}
+}
+
+func TestStorageResumeSession(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	correlationID, secret, priv := registerTestID(t, mem)
+	encoded := encodeTestPublicKey(t, priv)
+	dataOriginal := []byte("interaction before resumption")
+	err = mem.AddInteraction(correlationID, dataOriginal)
+	require.Nil(t, err, "could not add interaction to storage")
+
+	err = mem.SetIDPublicKey(correlationID, uuid.New().String(), encoded)
+	require.NotNil(t, err, "could not reject registration of existing correlation-id with another secret")
+	err = mem.SetIDPublicKey(correlationID, secret, encoded)
+	require.Nil(t, err, "could not resume session")
+
+	data, key, err := mem.GetInteractions(correlationID, secret)
+	require.Nil(t, err, "could not get interaction from storage")
+	require.Len(t, data, 1, "could not keep interaction added before resumption")
+
+	decodedKey, err := base64.StdEncoding.DecodeString(key)
+	require.Nil(t, err, "could not decode key")
+	keyPlaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, decodedKey, nil)
+	require.Nil(t, err, "could not decrypt key to plaintext")
+	decoded, err := AESDecrypt(keyPlaintext, data[0])
+	require.Nil(t, err, "could not decrypt interaction")
+	require.Equal(t, dataOriginal, decoded, "could not get correct decrypted interaction")
+}
+
+func TestStorageQuotas(t *testing.T) {
+	for policy, expected := range map[string][]string{
+		OverflowDropOldest: {"interaction 2", "interaction 3"},
+		OverflowDropNew:    {"interaction 0", "interaction 1"},
+	} {
+		mem, err := New(&Options{EvictionTTL: 1 * time.Hour, MaxInteractions: 2, OverflowPolicy: policy})
+		require.Nil(t, err)
+
+		correlationID, secret, priv := registerTestID(t, mem)
+
+		for i := 0; i < 4; i++ {
+			err = mem.AddInteraction(correlationID, []byte("interaction "+strconv.Itoa(i)))
+			require.Nil(t, err, "could not add interaction to storage")
+		}
+
+		data, key, err := mem.GetInteractions(correlationID, secret)
+		require.Nil(t, err, "could not get interactions from storage")
+		require.Len(t, data, 3, "could not apply %s quota", policy)
+
+		decodedKey, err := base64.StdEncoding.DecodeString(key)
+		require.Nil(t, err, "could not decode key")
+		keyPlaintext, err := rsa.DecryptOAEP(sha256.New(), rand.Reader, priv, decodedKey, nil)
+		require.Nil(t, err, "could not decrypt key to plaintext")
+		for i, item := range expected {
+			decoded, err := AESDecrypt(keyPlaintext, data[i])
+			require.Nil(t, err, "could not decrypt interaction")
+			require.Equal(t, item, string(decoded), "could not keep interactions with %s", policy)
+		}
+
+		decoded, err := AESDecrypt(keyPlaintext, data[2])
+		require.Nil(t, err, "could not decrypt overflow interaction")
+		overflow := &overflowInteraction{}
+		require.Nil(t, jsoniter.Unmarshal(decoded, overflow), "could not decode overflow interaction")
+		require.Equal(t, "overflow", overflow.Protocol, "could not report overflow")
+		require.Equal(t, uint64(2), overflow.Dropped, "could not count dropped interactions")
+
+		data, _, err = mem.GetInteractions(correlationID, secret)
+		require.Nil(t, err, "could not get interactions from storage")
+		require.Empty(t, data, "could not reset overflow after poll")
+	}
+}
+
+func TestStorageMemoryBudget(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour, MaxMemory: 30, OverflowPolicy: OverflowDropOldest})
+	require.Nil(t, err)
+
+	require.Nil(t, mem.SetID("first"), "could not set id in storage")
+	require.Nil(t, mem.SetID("second"), "could not set id in storage")
+	for i := 0; i < 2; i++ {
+		require.Nil(t, mem.AddInteractionWithId("first", []byte("interaction "+strconv.Itoa(i))), "could not add interaction to storage")
+	}
+
+	// the budget is used by the other id, the new interaction is dropped
+	require.Nil(t, mem.AddInteractionWithId("second", []byte("interaction 0")), "could not add interaction to storage")
+	metrics := mem.GetOverflowMetrics()
+	require.Equal(t, uint64(1), metrics.BudgetDropped, "could not drop interaction over budget")
+	require.Equal(t, int64(26), metrics.PendingBytes, "could not account pending interactions")
+
+	// the oldest interaction of the same id makes room for the new one
+	require.Nil(t, mem.AddInteractionWithId("first", []byte("interaction 2")), "could not add interaction to storage")
+	metrics = mem.GetOverflowMetrics()
+	require.Equal(t, uint64(2), metrics.BudgetDropped, "could not drop oldest interaction over budget")
+	require.Equal(t, int64(26), metrics.PendingBytes, "could not account pending interactions")
+
+	stats, err := mem.GetStats()
+	require.Nil(t, err, "could not get storage stats")
+	require.Equal(t, 2, stats.QueuedInteractions, "could not keep interactions within budget")
+	require.Equal(t, uint64(2), stats.BudgetDropped, "could not count dropped interactions")
+
+	require.Nil(t, mem.EvictID("first"), "could not evict id")
+	require.Equal(t, int64(0), mem.GetOverflowMetrics().PendingBytes, "could not release evicted interactions")
+	require.Nil(t, mem.AddInteractionWithId("second", []byte("interaction 0")), "could not add interaction to storage")
+	require.Equal(t, int64(13), mem.GetOverflowMetrics().PendingBytes, "could not store interaction within budget")
+}
+
+func TestStorageStats(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour})
+	require.Nil(t, err)
+
+	var correlationIDs, secrets []string
+	for i := 0; i < 3; i++ {
+		correlationID, secret, _ := registerTestID(t, mem)
+		correlationIDs = append(correlationIDs, correlationID)
+		secrets = append(secrets, secret)
+	}
+	for i := 0; i < 3; i++ {
+		err = mem.AddInteraction(correlationIDs[0], []byte("interaction "+strconv.Itoa(i)))
+		require.Nil(t, err, "could not add interaction to storage")
+	}
+
+	stats, err := mem.GetStats()
+	require.Nil(t, err, "could not get storage stats")
+	require.Equal(t, 3, stats.Sessions, "could not count sessions")
+	require.Equal(t, 3, stats.QueuedInteractions, "could not count queued interactions")
+	require.Equal(t, int64(len("interaction 0")*3), stats.QueuedBytes, "could not count queued bytes")
+	require.Greater(t, stats.MemoryEstimate, stats.QueuedBytes, "could not estimate memory")
+
+	require.Nil(t, mem.RemoveID(correlationIDs[1], secrets[1]), "could not deregister correlation-id")
+	require.Nil(t, mem.EvictID(correlationIDs[2]), "could not evict correlation-id")
+	// the removals are notified asynchronously by the cache
+	require.Eventually(t, func() bool {
+		stats, err = mem.GetStats()
+		return err == nil && stats.Evictions[RemovalDeregistered] == 1 && stats.Evictions[RemovalEvicted] == 1
+	}, time.Second, 10*time.Millisecond, "could not count removals by reason")
+	require.Equal(t, 1, stats.Sessions, "could not remove sessions")
+}
+
+func TestStorageSetTenant(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour, MaxInteractions: 5})
+	require.Nil(t, err)
+
+	correlationID, secret, _ := registerTestID(t, mem)
+
+	require.Nil(t, mem.SetTenant(correlationID, secret, "red", &Quota{MaxInteractions: 2}), "could not set tenant")
+	require.Nil(t, mem.SetTenant(correlationID, secret, "red", &Quota{MaxInteractions: 2}), "could not set tenant again")
+	require.NotNil(t, mem.SetTenant(correlationID, secret, "blue", nil), "could change tenant")
+	require.Equal(t, 1, mem.GetTenantSessions("red"), "could not count tenant sessions")
+
+	for i := 0; i < 4; i++ {
+		err = mem.AddInteraction(correlationID, []byte("interaction "+strconv.Itoa(i)))
+		require.Nil(t, err, "could not add interaction to storage")
+	}
+	data, _, err := mem.GetInteractions(correlationID, secret)
+	require.Nil(t, err, "could not get interactions from storage")
+	require.Len(t, data, 3, "could not apply tenant quota")
+
+	stats, err := mem.GetStats()
+	require.Nil(t, err, "could not get storage stats")
+	require.Equal(t, map[string]int{"red": 1}, stats.Tenants, "could not count sessions by tenant")
+
+	require.Nil(t, mem.RemoveID(correlationID, secret), "could not deregister correlation-id")
+	require.Eventually(t, func() bool {
+		return mem.GetTenantSessions("red") == 0
+	}, time.Second, 10*time.Millisecond, "could not remove tenant session")
+}
+
+func TestStorageMetadata(t *testing.T) {
+	mem, err := New(&Options{EvictionTTL: 1 * time.Hour, MaxInteractions: 1, OverflowPolicy: OverflowDropNew})
+	require.Nil(t, err)
+
+	correlationID, secret, _ := registerTestID(t, mem)
+
+	first := time.Now().UTC()
+	last := first.Add(time.Second)
+	require.Nil(t, mem.RecordMetadata(correlationID, "DNS", first, false), "could not record metadata")
+	require.Nil(t, mem.RecordMetadata(correlationID, "dns", first, false), "could not record metadata")
+	require.Nil(t, mem.RecordMetadata(correlationID, "http", last, true), "could not record metadata")
+	require.NotNil(t, mem.RecordMetadata("unknown", "http", last, false), "could record metadata of unknown correlation-id")
+
+	for i := 0; i < 3; i++ {
+		err = mem.AddInteraction(correlationID, []byte("interaction "+strconv.Itoa(i)))
+		require.Nil(t, err, "could not add interaction to storage")
+	}
+
+	metadata := mem.GetMetadata(correlationID)
+	require.NotNil(t, metadata, "could not get metadata")
+	require.Equal(t, uint64(2), metadata.Protocols["dns"].Count, "could not count dns interactions")
+	require.Equal(t, uint64(1), metadata.Protocols["http"].Count, "could not count http interactions")
+	require.Equal(t, last, metadata.Protocols["http"].LastInteraction, "could not get last http interaction")
+	require.Equal(t, last, *metadata.LastInteraction, "could not get last interaction")
+	require.Equal(t, uint64(1), metadata.Truncated, "could not count truncated interactions")
+	require.Equal(t, uint64(2), metadata.Dropped, "could not count dropped interactions")
+
+	// the dropped interactions are still counted after the overflow is polled
+	_, _, err = mem.GetInteractions(correlationID, secret)
+	require.Nil(t, err, "could not get interactions from storage")
+	metadata.Protocols["dns"].Count = 0
+	require.Equal(t, uint64(2), mem.GetMetadata(correlationID).Dropped, "could not keep dropped counter")
+	require.Equal(t, uint64(2), mem.GetMetadata(correlationID).Protocols["dns"].Count, "could not copy metadata")
+	require.Nil(t, mem.GetMetadata("unknown"), "could get metadata of unknown correlation-id")
+}
+
 func BenchmarkCacheParallel(b *testing.B) {
 	config := ccache.Configure().MaxSize(int64(DefaultOptions.MaxSize)).Buckets(64).GetsPerPromote(10).PromoteBuffer(4096)
 	cache := ccache.New(config)
@@ -3,6 +3,8 @@ package storage
 import (
 	"sync"
 	"time"
+
+	"github.com/projectdiscovery/interactsh/pkg/types"
 )
 
 type GetInteractionsFunc func() []string
@@ -21,10 +23,51 @@ type CorrelationData struct {
 	sync.Mutex
 	// data contains data for a correlation-id in AES encrypted json format.
 	Data []string `json:"data"`
-	// secretkey is a secret key for original user verification
-	SecretKey string `json:"-"`
+	// SecretKeyHash is the HMAC of the secret key for original user verification
+	SecretKeyHash string `json:"-"`
 	// AESKey is the AES encryption key in encrypted format.
 	AESKeyEncrypted string `json:"aes-key"`
 	// decrypted AES key for signing
 	AESKey []byte `json:"-"`
+	// Sequence is the sequence number of the last stored interaction
+	Sequence uint64 `json:"-"`
+	// sequences contains the sequence number of each item of data
+	sequences []uint64
+	// created is the time the correlation-id was put into the cache
+	created time.Time
+	// removal is the reason of the removal of the correlation-id, if explicitly removed
+	removal string
+	// dropped is the number of interactions dropped by the quotas or the memory budget since the last poll
+	dropped uint64
+	// pending is the size of the interactions pending in memory, accounted in the memory budget
+	pending int
+	// metadata contains the counters of the interactions since the registration
+	metadata SessionMetadata
+	// replay contains the delivered interactions kept for replay
+	replay []replayItem
+	// DNSAnswers contains the custom dns answers for the correlation-id subdomains
+	DNSAnswers *DNSAnswers `json:"-"`
+	// HTTPResponse contains the custom http response for the correlation-id subdomains
+	HTTPResponse *HTTPResponseDefinition `json:"-"`
+	// ResponseDelay is the delay of the responses for the correlation-id subdomains
+	ResponseDelay time.Duration `json:"-"`
+	// Tenant is the name of the tenant which registered the correlation-id, if any
+	Tenant string `json:"-"`
+	// Quota overrides the quotas of the storage options for the correlation-id if not nil
+	Quota *Quota `json:"-"`
+}
+
+// HTTPResponseDefinition is the http response served for the subdomains of a
+// correlation-id instead of the default one.
+type HTTPResponseDefinition = types.HTTPResponseDefinition
+
+// DNSAnswers are the dns answers returned for the subdomains of a correlation-id
+// instead of the default ones. A CNAME takes precedence over A and AAAA records.
+type DNSAnswers = types.DNSAnswers
+
+// replayItem is a delivered interaction kept for replay
+type replayItem struct {
+	sequence  uint64
+	data      string
+	delivered time.Time
 }
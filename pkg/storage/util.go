@@ -6,11 +6,17 @@ import (
 	"crypto/cipher"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/sha256"
 	"crypto/x509"
 	"encoding/base64"
 	"encoding/pem"
-	"errors"
 	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/pkg/errors"
 )
 
 // ParseB64RSAPublicKeyFromPEM parses a base64 encoded rsa pem to a public key structure
@@ -58,6 +64,42 @@ func AESEncrypt(key []byte, message []byte) (string, error) {
 	return string(encMessage), nil
 }
 
+// AESDecrypt decrypts a base64 encoded message encrypted with AESEncrypt
+func AESDecrypt(key []byte, message string) ([]byte, error) {
+	cipherText, err := base64.StdEncoding.DecodeString(message)
+	if err != nil {
+		return nil, err
+	}
+	if len(cipherText) < aes.BlockSize {
+		return nil, errors.New("ciphertext block size is too small")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	iv := cipherText[:aes.BlockSize]
+	cipherText = cipherText[aes.BlockSize:]
+	stream := cipher.NewCFBDecrypter(block, iv)
+	stream.XORKeyStream(cipherText, cipherText)
+	return cipherText, nil
+}
+
+// newAESKey generates an AES key for the interactions, returning it
+// together with its base64 encoded encryption with the rsa public key.
+func newAESKey(publicKey string) ([]byte, string, error) {
+	publicKeyData, err := ParseB64RSAPublicKeyFromPEM(publicKey)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "could not read public Key")
+	}
+	aesKey := uuid.New().String()[:32]
+
+	ciphertext, err := rsa.EncryptOAEP(sha256.New(), rand.Reader, publicKeyData, []byte(aesKey), []byte(""))
+	if err != nil {
+		return nil, "", errors.New("could not encrypt event data")
+	}
+	return []byte(aesKey), base64.StdEncoding.EncodeToString(ciphertext), nil
+}
+
 func AppendMany(sep string, slices ...[]byte) []byte {
 	var final [][]byte
 	for _, slice := range slices {
@@ -68,3 +110,64 @@ func AppendMany(sep string, slices ...[]byte) []byte {
 	}
 	return bytes.Join(final, []byte(sep))
 }
+
+// formatDiskItem prefixes the ciphertext stored on disk with its sequence number
+func formatDiskItem(sequence uint64, ct string) string {
+	return strconv.FormatUint(sequence, 10) + ":" + ct
+}
+
+// withSequence sets the sequence number of an interaction encoded in json, the
+// data which isn't a json object being returned as is.
+func withSequence(data []byte, sequence uint64) []byte {
+	trimmed := bytes.TrimRight(data, " \t\r\n")
+	if len(trimmed) < 2 || trimmed[0] != '{' || trimmed[len(trimmed)-1] != '}' {
+		return data
+	}
+	stamped := make([]byte, 0, len(trimmed)+32)
+	stamped = append(stamped, trimmed[:len(trimmed)-1]...)
+	if len(bytes.TrimSpace(trimmed[1:len(trimmed)-1])) > 0 {
+		stamped = append(stamped, ',')
+	}
+	stamped = append(stamped, `"sequence":`...)
+	stamped = strconv.AppendUint(stamped, sequence, 10)
+	return append(stamped, '}')
+}
+
+// initialSequence returns the sequence number of a new correlation-id, based on the
+// time of the registration so that the numbers keep increasing across restarts of
+// the server for the resumed sessions.
+func initialSequence() uint64 {
+	return uint64(time.Now().UnixNano() / int64(time.Microsecond))
+}
+
+// parseDiskItem returns the sequence number and the ciphertext of an item stored on disk.
+// Items without sequence number have sequence 0.
+func parseDiskItem(item []byte) (uint64, string) {
+	value := string(item)
+	if index := strings.Index(value, ":"); index > 0 {
+		if sequence, err := strconv.ParseUint(value[:index], 10, 64); err == nil {
+			return sequence, value[index+1:]
+		}
+	}
+	return 0, value
+}
+
+// replayKey returns the disk key of the delivered interactions for an id
+func replayKey(id string) []byte {
+	return []byte(id + "/replay")
+}
+
+// formatReplayItem prefixes a delivered disk item with its delivery time
+func formatReplayItem(delivered time.Time, item []byte) []byte {
+	return append([]byte(strconv.FormatInt(delivered.UnixNano(), 10)+":"), item...)
+}
+
+// parseReplayItem returns the delivery time and the disk item of a delivered item
+func parseReplayItem(item []byte) (time.Time, []byte) {
+	if index := bytes.IndexByte(item, ':'); index > 0 {
+		if delivered, err := strconv.ParseInt(string(item[:index]), 10, 64); err == nil {
+			return time.Unix(0, delivered), item[index+1:]
+		}
+	}
+	return time.Time{}, item
+}
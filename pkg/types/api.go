@@ -0,0 +1,145 @@
+package types
+
+import "time"
+
+// DNSAnswersRequest is a request for setting the custom dns answers of a client.
+type DNSAnswersRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey for the interactsh client.
+	SecretKey string `json:"secret-key"`
+	// Answers are the custom dns answers, the default ones are restored if empty.
+	Answers *DNSAnswers `json:"answers,omitempty"`
+}
+
+// HTTPResponseRequest is a request for setting the custom http response of a client.
+type HTTPResponseRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey for the interactsh client.
+	SecretKey string `json:"secret-key"`
+	// Response is the custom http response, the default one is restored if nil.
+	Response *HTTPResponseDefinition `json:"response,omitempty"`
+}
+
+// RegisterRequest is a request for client registration to interactsh server.
+type RegisterRequest struct {
+	// PublicKey is the public RSA Key of the client.
+	PublicKey string `json:"public-key"`
+	// SecretKey is the secret-key for correlation ID registered for the client.
+	SecretKey string `json:"secret-key"`
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// CorrelationIdNonceLength is the nonce length the client will use in its URLs.
+	CorrelationIdNonceLength int `json:"correlation-id-nonce-length,omitempty"`
+	// DNSAnswers are the custom dns answers for the subdomains of the client.
+	DNSAnswers *DNSAnswers `json:"dns-answers,omitempty"`
+	// NonceEncoding is the encoding of the nonces of the client URLs, the
+	// default one if empty.
+	NonceEncoding string `json:"nonce-encoding,omitempty"`
+}
+
+// RegisterResponse is the response of the interactsh server to a registration.
+//
+// The correlation id and nonce lengths used by the server are always returned,
+// so that clients with different lengths can adapt and register again.
+type RegisterResponse struct {
+	Message                  string `json:"message,omitempty"`
+	Error                    string `json:"error,omitempty"`
+	CorrelationIdLength      int    `json:"correlation-id-length"`
+	CorrelationIdNonceLength int    `json:"correlation-id-nonce-length"`
+	// PublicPorts contains the public ports of the services, by service name.
+	PublicPorts map[string]int `json:"public-ports,omitempty"`
+	// DisabledServices contains the services disabled on the server, whose
+	// payloads are not captured.
+	DisabledServices []string `json:"disabled-services,omitempty"`
+	// NonceEncodings contains the nonce encodings accepted by the server
+	NonceEncodings []string `json:"nonce-encodings,omitempty"`
+	// Subdomain is the label to prepend to the domain in the URLs, for the
+	// tenants with a dedicated subdomain.
+	Subdomain string `json:"subdomain,omitempty"`
+}
+
+// DeregisterRequest is a request for client deregistration to interactsh server.
+type DeregisterRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey for the interactsh client.
+	SecretKey string `json:"secret-key"`
+}
+
+// RotateRequest is a request for rotating the keys of a registered client.
+type RotateRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the current secretKey for the interactsh client.
+	SecretKey string `json:"secret-key"`
+	// PublicKey is the new public RSA Key of the client.
+	PublicKey string `json:"public-key"`
+	// NewSecretKey is the new secretKey for the client, the current one is kept if empty.
+	NewSecretKey string `json:"new-secret-key,omitempty"`
+}
+
+// PollResponse is the response for a polling request
+type PollResponse struct {
+	Data    []string `json:"data"`
+	Extra   []string `json:"extra"`
+	AESKey  string   `json:"aes_key"`
+	TLDData []string `json:"tlddata,omitempty"`
+	// Sequence is the sequence number of the last interaction, to be used as
+	// since in the next poll. It is only returned for polls with since.
+	Sequence uint64 `json:"sequence,omitempty"`
+	// Metadata contains the counters of the interactions of the session, in
+	// clear, to detect an overflow or a silence without decrypting the data.
+	Metadata *SessionMetadata `json:"metadata,omitempty"`
+}
+
+// SessionMetadata contains the counters of the interactions received for a
+// correlation-id since its registration.
+type SessionMetadata struct {
+	// Protocols contains the counters of the interactions by protocol
+	Protocols map[string]*ProtocolMetadata `json:"protocols,omitempty"`
+	// Dropped is the number of interactions dropped by the quotas or the memory budget
+	Dropped uint64 `json:"dropped"`
+	// Truncated is the number of interactions whose content was truncated by the server
+	Truncated uint64 `json:"truncated"`
+	// LastInteraction is the time of the last interaction, if any
+	LastInteraction *time.Time `json:"last-interaction,omitempty"`
+}
+
+// ProtocolMetadata contains the counters of the interactions of a protocol
+type ProtocolMetadata struct {
+	// Count is the number of interactions received
+	Count uint64 `json:"count"`
+	// LastInteraction is the time of the last interaction
+	LastInteraction time.Time `json:"last-interaction"`
+}
+
+// ResponseDelayRequest is a request for setting the response delay of a client.
+type ResponseDelayRequest struct {
+	// CorrelationID is an ID for correlation with requests.
+	CorrelationID string `json:"correlation-id"`
+	// SecretKey is the secretKey for the interactsh client.
+	SecretKey string `json:"secret-key"`
+	// Delay is the delay of the responses in seconds, responses are not delayed if zero.
+	Delay int `json:"delay"`
+}
+
+// HTTPResponseDefinition is the http response served for the subdomains of a
+// correlation-id instead of the default one.
+type HTTPResponseDefinition struct {
+	StatusCode int                 `json:"status-code,omitempty"`
+	Headers    map[string][]string `json:"headers,omitempty"`
+	Body       []byte              `json:"body,omitempty"`
+}
+
+// DNSAnswers are the dns answers returned for the subdomains of a correlation-id
+// instead of the default ones. A CNAME takes precedence over A and AAAA records.
+type DNSAnswers struct {
+	A     []string `json:"a,omitempty"`
+	AAAA  []string `json:"aaaa,omitempty"`
+	TXT   []string `json:"txt,omitempty"`
+	CNAME string   `json:"cname,omitempty"`
+	// TTL is the ttl of the answers, they are not cached if zero
+	TTL uint32 `json:"ttl,omitempty"`
+}
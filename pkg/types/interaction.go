@@ -0,0 +1,252 @@
+// Package types contains the types exchanged between the interactsh server and
+// its clients. It only depends on the standard library, so that the client can be
+// built for any platform, including js/wasm.
+package types
+
+import (
+	"net/http"
+	"time"
+)
+
+// Interaction is an interaction received to the server.
+type Interaction struct {
+	// Protocol for interaction, can contains HTTP/DNS/SMTP,etc.
+	Protocol string `json:"protocol"`
+	// UniqueID is the uniqueID for the subdomain receiving the interaction.
+	UniqueID string `json:"unique-id"`
+	// FullId is the full path for the subdomain receiving the interaction.
+	FullId string `json:"full-id"`
+	// QType is the question type for the interaction
+	QType string `json:"q-type,omitempty"`
+	// RawRequest is the raw request received by the interactsh server.
+	RawRequest string `json:"raw-request,omitempty"`
+	// RawResponse is the raw response sent by the interactsh server.
+	RawResponse string `json:"raw-response,omitempty"`
+	// DNS contains the details of the dns query
+	DNS *DNSQuery `json:"dns,omitempty"`
+	// HTTPRequest contains the structured fields of the http request
+	HTTPRequest *HTTPRequest `json:"http-request,omitempty"`
+	// HTTPResponse contains the structured fields of the http response
+	HTTPResponse *HTTPResponse `json:"http-response,omitempty"`
+	// SMTPFrom is the mail form field
+	SMTPFrom string `json:"smtp-from,omitempty"`
+	// SMTPMessage contains the parsed headers, text parts and attachments of the mail
+	SMTPMessage *SMTPMessage `json:"smtp-message,omitempty"`
+	// SMTPSession contains the responses of the server during the smtp session
+	SMTPSession *SMTPSession `json:"smtp-session,omitempty"`
+	// RemoteAddress is the remote address for interaction
+	RemoteAddress string `json:"remote-address"`
+	// TLS contains the tls handshake parameters for interactions over tls
+	TLS *TLSInfo `json:"tls,omitempty"`
+	// Sequence is the sequence number assigned by the server to the interactions
+	// in the order they are received, increasing monotonically since its start.
+	Sequence uint64 `json:"sequence,omitempty"`
+	// Timestamp is the timestamp for the interaction in UTC, with nanosecond precision
+	Timestamp time.Time `json:"timestamp"`
+	// Count is the number of identical interactions collapsed by deduplication
+	Count int `json:"count,omitempty"`
+	// ExfilData is the data reassembled from dns queries for dns-exfil interactions
+	ExfilData []byte `json:"exfil-data,omitempty"`
+	// Dropped is the number of interactions dropped by the quotas or the memory budget for overflow interactions
+	Dropped uint64 `json:"dropped,omitempty"`
+	// Tags are the tags added to the interaction by the middlewares
+	Tags []string `json:"tags,omitempty"`
+	// Tenant is the tenant which registered the correlation-id, set by the
+	// server for the history and the exporters
+	Tenant string `json:"tenant,omitempty"`
+}
+
+// DNSQuery contains the details of a dns query
+type DNSQuery struct {
+	// Transport is the network the query arrived on (udp or tcp)
+	Transport string `json:"transport"`
+	// QType is the type of the question
+	QType string `json:"qtype"`
+	// QClass is the class of the question
+	QClass string `json:"qclass"`
+	// RecursionDesired is the value of the RD flag
+	RecursionDesired bool `json:"recursion-desired"`
+	// Wire is the base64 encoded wire format of the query
+	Wire string `json:"wire,omitempty"`
+	// EDNS contains the EDNS0 parameters of the query, if any
+	EDNS *DNSEDNS `json:"edns,omitempty"`
+	// FailureMode is the failure mode of the response requested by the payload
+	// (nxdomain, servfail, noerror or timeout), if any
+	FailureMode string `json:"failure-mode,omitempty"`
+}
+
+// DNSEDNS contains the EDNS0 parameters of a dns query
+type DNSEDNS struct {
+	// Version is the EDNS version
+	Version uint8 `json:"version"`
+	// UDPSize is the udp payload size advertised by the client
+	UDPSize uint16 `json:"udp-size"`
+	// DNSSECOk is the value of the DO flag
+	DNSSECOk bool `json:"dnssec-ok,omitempty"`
+	// ClientSubnet is the client subnet option (RFC7871) in CIDR notation
+	ClientSubnet string `json:"client-subnet,omitempty"`
+	// Cookie is the dns cookie option (RFC7873)
+	Cookie string `json:"cookie,omitempty"`
+	// Options contains all the EDNS0 options of the query
+	Options []DNSEDNSOption `json:"options,omitempty"`
+}
+
+// DNSEDNSOption is a single EDNS0 option
+type DNSEDNSOption struct {
+	// Code is the option code
+	Code uint16 `json:"code"`
+	// Value is the textual representation of the option
+	Value string `json:"value"`
+}
+
+// HTTPRequest contains the structured fields of a http request
+type HTTPRequest struct {
+	// Method is the http method of the request
+	Method string `json:"method"`
+	// Path is the unescaped path of the request
+	Path string `json:"path"`
+	// Query is the raw query string of the request
+	Query string `json:"query,omitempty"`
+	// Proto is the protocol version of the request
+	Proto string `json:"proto"`
+	// Host is the host requested by the client
+	Host string `json:"host"`
+	// Headers contains all the headers of the request
+	Headers http.Header `json:"headers,omitempty"`
+	HTTPBody
+}
+
+// HTTPResponse contains the structured fields of the http response served
+type HTTPResponse struct {
+	// StatusCode is the status code of the response
+	StatusCode int `json:"status-code"`
+	// Headers contains all the headers of the response
+	Headers http.Header `json:"headers,omitempty"`
+	HTTPBody
+	// Redirect describes the hop of a redirect chain answered by the response, if any
+	Redirect *HTTPRedirect `json:"redirect,omitempty"`
+}
+
+// HTTPRedirect is a hop of a redirect chain requested by a payload
+type HTTPRedirect struct {
+	// Hop is the position of the hop in the chain, starting at 0
+	Hop int `json:"hop"`
+	// Remaining is the number of hops left through the server before the target
+	Remaining int `json:"remaining"`
+	// Target is the url the chain finally redirects to
+	Target string `json:"target"`
+}
+
+// HTTPBody is a http body truncated to the size captured by the server
+type HTTPBody struct {
+	// Body is the captured body, base64 encoded if not valid utf8
+	Body string `json:"body,omitempty"`
+	// BodyBase64 is true if the body is base64 encoded
+	BodyBase64 bool `json:"body-base64,omitempty"`
+	// BodySize is the full size of the body
+	BodySize int `json:"body-size"`
+	// BodyTruncated is true if the body exceeded the size captured by the server
+	BodyTruncated bool `json:"body-truncated,omitempty"`
+}
+
+// SMTPMessage contains the structured content of a smtp message
+type SMTPMessage struct {
+	// Headers contains the headers of the message
+	Headers map[string][]string `json:"headers,omitempty"`
+	// Subject is the decoded subject of the message
+	Subject string `json:"subject,omitempty"`
+	// TextParts contains the text parts of the message
+	TextParts []SMTPTextPart `json:"text-parts,omitempty"`
+	// Attachments contains the attachments of the message
+	Attachments []SMTPAttachment `json:"attachments,omitempty"`
+}
+
+// SMTPSession contains the responses of the server during the smtp session of a message
+type SMTPSession struct {
+	// Greeting is the greeting sent by the server when the client connected
+	Greeting string `json:"greeting"`
+	// Relay is the response to the recipients of external domains (accept or reject)
+	Relay string `json:"relay"`
+	// MaxSize is the maximum size of the messages accepted by the server
+	MaxSize int `json:"max-size"`
+	// Recipients are the responses to the recipients of the message
+	Recipients []SMTPRecipient `json:"recipients,omitempty"`
+}
+
+// SMTPRecipient is the response of the server to a recipient of a message
+type SMTPRecipient struct {
+	// Address is the address of the recipient
+	Address string `json:"address"`
+	// Code is the reply code, 250 if accepted and 550 if rejected
+	Code int `json:"code"`
+}
+
+// SMTPTextPart is a text part of a smtp message
+type SMTPTextPart struct {
+	// ContentType is the media type of the part
+	ContentType string `json:"content-type"`
+	// Content is the decoded content of the part
+	Content string `json:"content"`
+	// Truncated is true if the content exceeded the size stored by the server
+	Truncated bool `json:"truncated,omitempty"`
+}
+
+// SMTPAttachment is an attachment of a smtp message
+type SMTPAttachment struct {
+	// Filename is the name of the attached file
+	Filename string `json:"filename,omitempty"`
+	// ContentType is the media type of the attachment
+	ContentType string `json:"content-type"`
+	// Size is the decoded size of the attachment
+	Size int `json:"size"`
+	// SHA256 is the hash of the decoded attachment
+	SHA256 string `json:"sha256"`
+	// Content is the base64 encoded attachment, empty if it exceeded the size stored by the server
+	Content string `json:"content,omitempty"`
+}
+
+// TLSInfo contains the parameters of the TLS handshake for an interaction
+type TLSInfo struct {
+	// JA3 is the JA3 string of the client hello
+	JA3 string `json:"ja3,omitempty"`
+	// JA3Hash is the md5 hash of the JA3 string
+	JA3Hash string `json:"ja3-hash,omitempty"`
+	// JA3S is the JA3S string of the server hello
+	JA3S string `json:"ja3s,omitempty"`
+	// JA3SHash is the md5 hash of the JA3S string
+	JA3SHash string `json:"ja3s-hash,omitempty"`
+	// SNI is the server name requested by the client
+	SNI string `json:"sni,omitempty"`
+	// ALPN contains the application protocols offered by the client
+	ALPN []string `json:"alpn,omitempty"`
+	// CipherSuites contains the cipher suites offered by the client
+	CipherSuites []string `json:"cipher-suites,omitempty"`
+	// ClientCertificates contains the certificate chain presented by the client, if any
+	ClientCertificates []*TLSCertificate `json:"client-certificates,omitempty"`
+}
+
+// TLSCertificate describes a certificate presented by a client during the TLS handshake
+type TLSCertificate struct {
+	// Subject is the distinguished name of the subject
+	Subject string `json:"subject"`
+	// Issuer is the distinguished name of the issuer
+	Issuer string `json:"issuer"`
+	// SerialNumber is the hexadecimal serial number
+	SerialNumber string `json:"serial-number,omitempty"`
+	// DNSNames contains the DNS names of the subject alternative names
+	DNSNames []string `json:"dns-names,omitempty"`
+	// EmailAddresses contains the email addresses of the subject alternative names
+	EmailAddresses []string `json:"email-addresses,omitempty"`
+	// URIs contains the URIs of the subject alternative names, like SPIFFE identities
+	URIs []string `json:"uris,omitempty"`
+	// NotBefore is the start of the validity period
+	NotBefore time.Time `json:"not-before,omitempty"`
+	// NotAfter is the end of the validity period
+	NotAfter time.Time `json:"not-after,omitempty"`
+	// SHA1 is the hexadecimal SHA-1 fingerprint of the certificate
+	SHA1 string `json:"sha1"`
+	// SHA256 is the hexadecimal SHA-256 fingerprint of the certificate
+	SHA256 string `json:"sha256"`
+	// Raw is the base64 encoded DER certificate
+	Raw string `json:"raw"`
+}
@@ -0,0 +1,18 @@
+package types
+
+// SessionInfo is the session of a client saved to resume it later
+type SessionInfo struct {
+	ServerURL                string         `yaml:"server-url"`
+	Token                    string         `yaml:"server-token"`
+	PrivateKey               string         `yaml:"private-key"`
+	CorrelationID            string         `yaml:"correlation-id"`
+	SecretKey                string         `yaml:"secret-key"`
+	CorrelationIdNonceLength int            `yaml:"correlation-id-nonce-length,omitempty"`
+	Sequence                 uint64         `yaml:"sequence,omitempty"`
+	PublicPorts              map[string]int `yaml:"public-ports,omitempty"`
+	DisabledServices         []string       `yaml:"disabled-services,omitempty"`
+	// Keychain is true if the secrets of the session are stored in the OS keychain
+	Keychain bool `yaml:"keychain,omitempty"`
+	// DerivationKey authenticates the payloads derived from keys, it is never sent to the server
+	DerivationKey string `yaml:"derivation-key,omitempty"`
+}